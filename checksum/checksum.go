@@ -0,0 +1,222 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"lukechampine.com/blake3"
+)
+
+// Supported checksum algorithms
+const (
+	SHA256 = "sha256"
+	MD5    = "md5"
+	BLAKE3 = "blake3"
+)
+
+// NewHash returns a new hash for the given algorithm
+func NewHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case SHA256:
+		return sha256.New(), nil
+	case MD5:
+		return md5.New(), nil
+	case BLAKE3:
+		return blake3.New(32, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+}
+
+// entry is a cached checksum of a file
+type entry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Sum     string `json:"sum"`
+}
+
+// Cache stores the checksums of files on disk, keyed on their path.
+// A cached checksum is only used while the size and modification time
+// of the file are unchanged.
+type Cache struct {
+	dir string
+}
+
+// NewCache returns a cache that stores its entries in dir
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// entryPath returns the file where the checksum of path is cached
+func (c *Cache) entryPath(path, algo string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(absPath))
+	return filepath.Join(c.dir, hex.EncodeToString(key[:])+"."+algo), nil
+}
+
+// Lookup returns the cached checksum of a file if it is still valid
+func (c *Cache) Lookup(path, algo string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	return c.LookupInfo(path, info, algo)
+}
+
+// LookupInfo returns the cached checksum of the file identified by key if
+// it is still valid for the current information of the file
+func (c *Cache) LookupInfo(key string, info fs.FileInfo, algo string) (string, bool) {
+	entryPath, err := c.entryPath(key, algo)
+	if err != nil {
+		return "", false
+	}
+
+	data, err := os.ReadFile(entryPath)
+	if err != nil {
+		return "", false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return "", false
+	}
+
+	if e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+
+	return e.Sum, true
+}
+
+// Store saves the checksum of a file in the cache
+func (c *Cache) Store(path, algo, sum string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return c.StoreInfo(path, info, algo, sum)
+}
+
+// StoreInfo saves the checksum of the file identified by key, valid while
+// the file keeps the given information
+func (c *Cache) StoreInfo(key string, info fs.FileInfo, algo, sum string) error {
+	entryPath, err := c.entryPath(key, algo)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Sum:     sum,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %v", err)
+	}
+
+	// Write to a temporary file so readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), entryPath)
+}
+
+// Sum returns the checksum of a file, calculating it if it isn't cached
+func (c *Cache) Sum(path, algo string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return c.SumInfo(path, info, algo, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// SumInfo returns the checksum of the file identified by key, calling open
+// to read its content if it isn't cached. It's used for files that aren't
+// on the local disk.
+func (c *Cache) SumInfo(key string, info fs.FileInfo, algo string, open func() (io.ReadCloser, error)) (string, error) {
+	if sum, ok := c.LookupInfo(key, info, algo); ok {
+		return sum, nil
+	}
+
+	file, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sum, err := Reader(file, algo)
+	if err != nil {
+		return "", err
+	}
+
+	// A cache that can't be written only makes the next request slower
+	_ = c.StoreInfo(key, info, algo, sum)
+
+	return sum, nil
+}
+
+// File calculates the checksum of a file without using any cache
+func File(path, algo string) (string, error) {
+	if _, err := NewHash(algo); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return Reader(file, algo)
+}
+
+// Reader calculates the checksum of everything read from r
+func Reader(r io.Reader, algo string) (string, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DefaultDir returns the directory used to cache checksums when none is configured
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "shareiscare", "checksums")
+}
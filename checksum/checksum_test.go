@@ -0,0 +1,122 @@
+package checksum
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		algo     string
+		content  string
+		expected string
+	}{
+		{SHA256, "hola", "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79"},
+		{MD5, "hola", "4d186321c1a7f0f354b297e8914ab240"},
+		// Vector de prueba oficial de BLAKE3 para la entrada vacía
+		{BLAKE3, "", "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.algo, func(t *testing.T) {
+			path := filepath.Join(dir, tc.algo+".txt")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("No se pudo crear archivo de prueba: %v", err)
+			}
+
+			sum, err := File(path, tc.algo)
+			if err != nil {
+				t.Fatalf("File() devolvió error: %v", err)
+			}
+			if sum != tc.expected {
+				t.Errorf("File(%s) = %s, quería %s", tc.algo, sum, tc.expected)
+			}
+		})
+	}
+
+	if _, err := File(filepath.Join(dir, "sha256.txt"), "crc32"); err == nil {
+		t.Error("File() debería fallar para un algoritmo no soportado")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(filepath.Join(dir, "cache"))
+
+	path := filepath.Join(dir, "archivo.txt")
+	if err := os.WriteFile(path, []byte("hola"), 0644); err != nil {
+		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
+	}
+
+	// Caso 1: Sin entrada en la caché
+	if _, ok := cache.Lookup(path, SHA256); ok {
+		t.Error("Lookup() no debería encontrar una entrada antes de calcular el checksum")
+	}
+
+	sum, err := cache.Sum(path, SHA256)
+	if err != nil {
+		t.Fatalf("Sum() devolvió error: %v", err)
+	}
+
+	// Caso 2: La entrada queda guardada
+	cached, ok := cache.Lookup(path, SHA256)
+	if !ok || cached != sum {
+		t.Errorf("Lookup() = %s, %v, quería %s, true", cached, ok, sum)
+	}
+
+	// Caso 3: Una entrada guardada se usa mientras el archivo no cambie
+	if err := cache.Store(path, SHA256, "valor-en-cache"); err != nil {
+		t.Fatalf("Store() devolvió error: %v", err)
+	}
+	if sum, _ := cache.Sum(path, SHA256); sum != "valor-en-cache" {
+		t.Errorf("Sum() = %s, debería usar el valor guardado en la caché", sum)
+	}
+
+	// Caso 4: Si el archivo cambia la entrada deja de ser válida
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("No se pudo cambiar la fecha del archivo: %v", err)
+	}
+	if _, ok := cache.Lookup(path, SHA256); ok {
+		t.Error("Lookup() no debería devolver una entrada de un archivo modificado")
+	}
+	if sum, _ := cache.Sum(path, SHA256); sum == "valor-en-cache" {
+		t.Error("Sum() debería recalcular el checksum de un archivo modificado")
+	}
+}
+
+func TestSumInfo(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(dir)
+
+	path := filepath.Join(dir, "archivo.txt")
+	os.WriteFile(path, []byte("hola"), 0644)
+	info, _ := os.Stat(path)
+
+	opened := 0
+	open := func() (io.ReadCloser, error) {
+		opened++
+		return io.NopCloser(strings.NewReader("hola")), nil
+	}
+
+	for range 2 {
+		sum, err := cache.SumInfo("memoria/archivo.txt", info, SHA256, open)
+		if err != nil {
+			t.Fatalf("SumInfo() devolvió error: %v", err)
+		}
+		if sum != "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79" {
+			t.Errorf("SumInfo() = %s", sum)
+		}
+	}
+
+	// La segunda llamada usa la caché
+	if opened != 1 {
+		t.Errorf("El contenido se leyó %d veces, quería 1", opened)
+	}
+}
@@ -0,0 +1,138 @@
+// Package cli implements the client commands of the shareiscare binary, which
+// work with the files of a remote ShareIsCare server through its JSON API.
+//
+// Remote files are given as URLs, e.g. http://localhost:8080/docs/nota.txt.
+// The credentials of each server are kept in a client configuration file and
+// saved with the login command.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/client"
+)
+
+// Commands lists the client commands, for the help of the binary
+var Commands = []struct{ Name, Usage, Description string }{
+	{"login", "login <url>", "Save the credentials of a server"},
+	{"ls", "ls <url>", "List a remote directory"},
+	{"get", "get [-r] <url> [local]", "Download files, resuming partial downloads, or a share link"},
+	{"put", "put [-e] <files> <url>", "Upload files (glob patterns are supported)"},
+	{"rm", "rm [-r] <url>...", "Delete remote files"},
+	{"sync", "sync [flags] <dir> <url>", "Sync a local directory with a remote one"},
+}
+
+// IsCommand reports whether name is a client command
+func IsCommand(name string) bool {
+	for _, command := range Commands {
+		if command.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CLI runs the client commands
+type CLI struct {
+	// ConfigPath is the client configuration file
+	ConfigPath string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer // Progress bars and messages
+}
+
+// New returns a CLI using the default configuration file and the standard
+// input and output
+func New() (*CLI, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return &CLI{ConfigPath: path, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}, nil
+}
+
+// Run runs a client command with its arguments
+func (c *CLI) Run(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "login":
+		return c.login(ctx, args)
+	case "ls":
+		return c.ls(ctx, args)
+	case "get":
+		return c.get(ctx, args)
+	case "put":
+		return c.put(ctx, args)
+	case "rm":
+		return c.rm(ctx, args)
+	case "sync":
+		return c.sync(ctx, args)
+	}
+	return fmt.Errorf("unknown command: %s", name)
+}
+
+// remote is a path on a server
+type remote struct {
+	client *client.Client
+	server string // URL of the server
+	path   string // Path relative to the shared directory
+}
+
+// url returns the URL of a path of the server, for messages
+func (r *remote) url(p string) string {
+	return r.server + "/" + p
+}
+
+// parseURL splits a remote URL into the server URL and the path. The server
+// is the configured one that contains the URL, or the host of the URL.
+func parseURL(config *Config, rawURL string) (string, string, Credentials, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", Credentials{}, fmt.Errorf("invalid URL: %s (e.g. http://localhost:8080/docs)", rawURL)
+	}
+
+	server, creds, ok := config.lookup(u)
+	if !ok {
+		server = u.Scheme + "://" + u.Host
+	}
+	serverURL, _ := url.Parse(server)
+	p := strings.TrimPrefix(u.Path, serverURL.Path)
+	return server, strings.Trim(p, "/"), creds, nil
+}
+
+// remote returns a client for the server of a remote URL, authenticated with
+// its saved credentials. The SHAREISCARE_TOKEN environment variable takes
+// precedence over them.
+func (c *CLI) remote(ctx context.Context, rawURL string) (*remote, error) {
+	config, err := LoadConfig(c.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	server, p, creds, err := parseURL(config, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("SHAREISCARE_TOKEN"); token != "" {
+		creds = Credentials{Token: token}
+	}
+
+	cl, err := client.New(server)
+	if err != nil {
+		return nil, err
+	}
+	if creds.Token != "" {
+		cl.Token = creds.Token
+	} else if creds.Username != "" {
+		if err := cl.Login(ctx, creds.Username, creds.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	return &remote{client: cl, server: server, path: p}, nil
+}
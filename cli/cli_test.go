@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/handlers"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+// newTestServer inicia un servidor con la API sobre un directorio temporal
+func newTestServer(t *testing.T) (*httptest.Server, *config.Config) {
+	t.Helper()
+
+	cfg := &config.Config{
+		RootDir:   t.TempDir(),
+		CacheDir:  t.TempDir(),
+		Title:     "ShareIsCare Test",
+		Username:  "testuser",
+		Password:  "testpass",
+		SecretKey: "test-secret-key",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", handlers.LoginPost(cfg))
+	mux.HandleFunc("/api/v1/", handlers.API(cfg))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, cfg
+}
+
+// newTestCLI devuelve un CLI con un archivo de configuración temporal
+func newTestCLI(t *testing.T, stdin string) (*CLI, *bytes.Buffer) {
+	t.Helper()
+
+	stdout := &bytes.Buffer{}
+	return &CLI{
+		ConfigPath: filepath.Join(t.TempDir(), "client.yaml"),
+		Stdin:      strings.NewReader(stdin),
+		Stdout:     stdout,
+		Stderr:     &bytes.Buffer{},
+	}, stdout
+}
+
+func TestParseURL(t *testing.T) {
+	cfg := &Config{Servers: map[string]Credentials{
+		"http://localhost:8080":          {Token: "raiz"},
+		"https://example.com/compartido": {Token: "subruta"},
+	}}
+
+	tests := []struct {
+		url        string
+		wantServer string
+		wantPath   string
+		wantToken  string
+		wantErr    bool
+	}{
+		{"http://localhost:8080", "http://localhost:8080", "", "raiz", false},
+		{"http://localhost:8080/docs/nota.txt", "http://localhost:8080", "docs/nota.txt", "raiz", false},
+		{"http://localhost:8080/mis%20fotos/", "http://localhost:8080", "mis fotos", "raiz", false},
+		{"https://example.com/compartido/docs", "https://example.com/compartido", "docs", "subruta", false},
+		{"https://example.com/otro/docs", "https://example.com", "otro/docs", "", false},
+		{"localhost:8080/docs", "", "", "", true},
+		{"ftp://localhost/docs", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			server, p, creds, err := parseURL(cfg, tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseURL() error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if server != tt.wantServer || p != tt.wantPath || creds.Token != tt.wantToken {
+				t.Errorf("parseURL() = %q, %q, %q; se esperaba %q, %q, %q", server, p, creds.Token, tt.wantServer, tt.wantPath, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, _ := newTestCLI(t, "testuser\nincorrecta\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err == nil {
+		t.Error("login debería fallar con credenciales incorrectas")
+	}
+
+	c, _ = newTestCLI(t, "testpass\n")
+	if err := c.Run(ctx, "login", []string{"-username", "testuser", server.URL + "/"}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+	info, err := os.Stat(c.ConfigPath)
+	if err != nil {
+		t.Fatalf("No se guardó la configuración: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Permisos incorrectos: %v", info.Mode().Perm())
+	}
+	saved, _ := LoadConfig(c.ConfigPath)
+	if creds := saved.Servers[server.URL]; creds.Username != "testuser" || creds.Password != "testpass" {
+		t.Errorf("Credenciales guardadas incorrectas: %+v", saved.Servers)
+	}
+
+	// Los tokens se comprueban antes de guardarlos
+	store, err := tokens.Open(filepath.Join(cfg.CacheDir, "tokens.json"))
+	if err != nil {
+		t.Fatalf("No se pudo abrir el almacén de tokens: %v", err)
+	}
+	cfg.Tokens = store
+	_, secret, _ := store.Create("cli", "testuser", tokens.Scopes, time.Time{})
+
+	if err := c.Run(ctx, "login", []string{"-token", "sic_invalido", server.URL}); err == nil {
+		t.Error("login debería fallar con un token inválido")
+	}
+	if err := c.Run(ctx, "login", []string{"-token", secret, server.URL}); err != nil {
+		t.Fatalf("login con token devolvió error: %v", err)
+	}
+	saved, _ = LoadConfig(c.ConfigPath)
+	if creds := saved.Servers[server.URL]; creds.Token != secret || creds.Username != "" {
+		t.Errorf("Credenciales guardadas incorrectas: %+v", saved.Servers)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, stdout := newTestCLI(t, "testuser\ntestpass\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+
+	// put con patrones
+	local := t.TempDir()
+	os.WriteFile(filepath.Join(local, "a.txt"), []byte("archivo a"), 0644)
+	os.WriteFile(filepath.Join(local, "b.txt"), []byte("archivo b"), 0644)
+	os.WriteFile(filepath.Join(local, "c.json"), []byte("{}"), 0644)
+	os.MkdirAll(filepath.Join(cfg.RootDir, "docs"), 0755)
+
+	if err := c.Run(ctx, "put", []string{filepath.Join(local, "*.txt"), server.URL + "/docs"}); err != nil {
+		t.Fatalf("put devolvió error: %v", err)
+	}
+	if err := c.Run(ctx, "put", []string{filepath.Join(local, "c.json"), server.URL + "/docs/datos.json"}); err != nil {
+		t.Fatalf("put con nombre devolvió error: %v", err)
+	}
+	for name, want := range map[string]string{"a.txt": "archivo a", "b.txt": "archivo b", "datos.json": "{}"} {
+		if data, _ := os.ReadFile(filepath.Join(cfg.RootDir, "docs", name)); string(data) != want {
+			t.Errorf("Contenido de %s incorrecto: %q", name, data)
+		}
+	}
+	if err := c.Run(ctx, "put", []string{filepath.Join(local, "*.png"), server.URL + "/docs"}); err == nil {
+		t.Error("put debería fallar si ningún archivo coincide")
+	}
+
+	// ls
+	if err := c.Run(ctx, "ls", []string{server.URL}); err != nil {
+		t.Fatalf("ls devolvió error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "docs/") {
+		t.Errorf("Listado incorrecto: %s", stdout.String())
+	}
+	stdout.Reset()
+	if err := c.Run(ctx, "ls", []string{server.URL + "/docs"}); err != nil {
+		t.Fatalf("ls devolvió error: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "datos.json"} {
+		if !strings.Contains(stdout.String(), name) {
+			t.Errorf("El listado no contiene %s: %s", name, stdout.String())
+		}
+	}
+
+	// get reanuda las descargas parciales
+	dest := t.TempDir()
+	os.WriteFile(filepath.Join(dest, "a.txt"), []byte("arch"), 0644)
+	if err := c.Run(ctx, "get", []string{server.URL + "/docs/a.txt", dest}); err != nil {
+		t.Fatalf("get devolvió error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "a.txt")); string(data) != "archivo a" {
+		t.Errorf("Contenido descargado incorrecto: %q", data)
+	}
+
+	// get de directorios
+	if err := c.Run(ctx, "get", []string{server.URL + "/docs", dest}); err == nil {
+		t.Error("get de un directorio debería requerir -r")
+	}
+	if err := c.Run(ctx, "get", []string{"-r", server.URL + "/docs", filepath.Join(dest, "copia")}); err != nil {
+		t.Fatalf("get -r devolvió error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "copia", "datos.json")); string(data) != "{}" {
+		t.Errorf("Contenido descargado incorrecto: %q", data)
+	}
+
+	// rm
+	if err := c.Run(ctx, "rm", []string{server.URL + "/docs/a.txt"}); err != nil {
+		t.Fatalf("rm devolvió error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.RootDir, "docs", "a.txt")); !os.IsNotExist(err) {
+		t.Error("rm no eliminó el archivo")
+	}
+	if err := c.Run(ctx, "rm", []string{server.URL + "/docs"}); err == nil {
+		t.Error("rm de un directorio con archivos debería requerir -r")
+	}
+	if err := c.Run(ctx, "rm", []string{"-r", server.URL + "/docs"}); err != nil {
+		t.Fatalf("rm -r devolvió error: %v", err)
+	}
+	if err := c.Run(ctx, "rm", []string{server.URL}); err == nil {
+		t.Error("rm no debería eliminar el directorio compartido")
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, stdout := newTestCLI(t, "testuser\ntestpass\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+
+	// put -e cifra el archivo y muestra el enlace para compartirlo
+	local := t.TempDir()
+	content := strings.Repeat("documento confidencial ", 5000)
+	os.WriteFile(filepath.Join(local, "informe.txt"), []byte(content), 0644)
+	if err := c.Run(ctx, "put", []string{"-e", filepath.Join(local, "informe.txt"), server.URL}); err != nil {
+		t.Fatalf("put -e devolvió error: %v", err)
+	}
+	link := strings.TrimSpace(stdout.String())
+	if !strings.HasPrefix(link, server.URL+"/e2e?filename=") || !strings.Contains(link, "#") {
+		t.Fatalf("Enlace incorrecto: %s", link)
+	}
+
+	// El servidor solo guarda el contenido cifrado con un nombre aleatorio
+	entries, _ := os.ReadDir(cfg.RootDir)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".e2e") {
+		t.Fatalf("Archivos guardados incorrectos: %v", entries)
+	}
+	stored, _ := os.ReadFile(filepath.Join(cfg.RootDir, entries[0].Name()))
+	if strings.Contains(string(stored), "confidencial") || strings.Contains(string(stored), "informe") {
+		t.Error("El servidor recibió el contenido o el nombre sin cifrar")
+	}
+
+	// get del enlace lo descifra con su nombre original
+	dest := t.TempDir()
+	if err := c.Run(ctx, "get", []string{link, dest}); err != nil {
+		t.Fatalf("get del enlace devolvió error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "informe.txt")); string(data) != content {
+		t.Errorf("Contenido descifrado incorrecto (%d bytes)", len(data))
+	}
+
+	// Con otra clave no se descifra ni queda un archivo a medias
+	wrong := link[:strings.Index(link, "#")+1] + strings.Repeat("A", 43)
+	if err := c.Run(ctx, "get", []string{wrong, filepath.Join(dest, "otro.txt")}); err == nil {
+		t.Error("get con otra clave debería fallar")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "otro.txt")); !os.IsNotExist(err) {
+		t.Error("No debería quedar un archivo descifrado a medias")
+	}
+}
@@ -0,0 +1,370 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rodrwan/shareiscare/client"
+	"github.com/rodrwan/shareiscare/e2e"
+)
+
+// flagSet returns the flag set of a command, which prints its usage on errors
+func (c *CLI) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.Stderr, "Usage: shareiscare %s\n", usage(name))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usage returns the usage of a command
+func usage(name string) string {
+	for _, command := range Commands {
+		if command.Name == name {
+			return command.Usage
+		}
+	}
+	return name
+}
+
+// usageError is the error of a command called with the wrong arguments
+func usageError(name string) error {
+	return fmt.Errorf("usage: shareiscare %s", usage(name))
+}
+
+// login checks the credentials of a server and saves them
+func (c *CLI) login(ctx context.Context, args []string) error {
+	fs := c.flagSet("login")
+	token := fs.String("token", "", "API token to use instead of a username and password")
+	username := fs.String("username", "", "Username (asked if not given)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("login")
+	}
+
+	server := strings.TrimSuffix(fs.Arg(0), "/")
+	cl, err := client.New(server)
+	if err != nil {
+		return err
+	}
+
+	var creds Credentials
+	if *token != "" {
+		creds.Token = *token
+		cl.Token = *token
+		if _, err := cl.Stat(ctx, ""); err != nil {
+			return err
+		}
+	} else {
+		input := bufio.NewReader(c.Stdin)
+		creds.Username = *username
+		if creds.Username == "" {
+			if creds.Username, err = prompt(c.Stderr, input, "Username: "); err != nil {
+				return err
+			}
+		}
+		if creds.Password, err = prompt(c.Stderr, input, "Password: "); err != nil {
+			return err
+		}
+		if err := cl.Login(ctx, creds.Username, creds.Password); err != nil {
+			return err
+		}
+	}
+
+	config, err := LoadConfig(c.ConfigPath)
+	if err != nil {
+		return err
+	}
+	config.Servers[server] = creds
+	if err := config.Save(c.ConfigPath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stderr, "Credentials for %s saved to %s\n", server, c.ConfigPath)
+	return nil
+}
+
+// prompt asks for a line of input
+func prompt(w io.Writer, r *bufio.Reader, label string) (string, error) {
+	fmt.Fprint(w, label)
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ls lists a remote directory, or shows a remote file
+func (c *CLI) ls(ctx context.Context, args []string) error {
+	fs := c.flagSet("ls")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("ls")
+	}
+
+	r, err := c.remote(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	file, err := r.client.Stat(ctx, r.path)
+	if err != nil {
+		return err
+	}
+
+	files := []client.File{*file}
+	if file.IsDir {
+		if files, err = r.client.List(ctx, r.path); err != nil {
+			return err
+		}
+	}
+
+	tw := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range files {
+		name, size := f.Name, formatSize(f.Size)
+		if f.IsDir {
+			name, size = name+"/", "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Modified.Local().Format("2006-01-02 15:04"), size, name)
+	}
+	return tw.Flush()
+}
+
+// get downloads a remote file or directory
+func (c *CLI) get(ctx context.Context, args []string) error {
+	fs := c.flagSet("get")
+	recursive := fs.Bool("r", false, "Download directories with all their content")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("get")
+	}
+
+	// Share links of files encrypted end to end are decrypted
+	if _, _, _, err := e2e.ParseLink(fs.Arg(0)); err == nil {
+		return c.getEncrypted(ctx, fs.Arg(0), fs.Arg(1))
+	}
+
+	r, err := c.remote(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	file, err := r.client.Stat(ctx, r.path)
+	if err != nil {
+		return err
+	}
+
+	// Like cp, an existing local directory receives the download
+	local := fs.Arg(1)
+	if local == "" {
+		local = file.Name
+	} else if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, file.Name)
+	}
+
+	if file.IsDir {
+		if !*recursive {
+			return fmt.Errorf("%s is a directory (use -r to download it)", r.url(r.path))
+		}
+		return c.getDir(ctx, r, file.Path, local)
+	}
+	return c.getFile(ctx, r, file, local)
+}
+
+// getDir downloads a remote directory with all its content
+func (c *CLI) getDir(ctx context.Context, r *remote, dir, local string) error {
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return err
+	}
+
+	files, err := r.client.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		target := filepath.Join(local, file.Name)
+		if file.IsDir {
+			err = c.getDir(ctx, r, file.Path, target)
+		} else {
+			err = c.getFile(ctx, r, &file, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFile downloads a remote file. A smaller local file is a previous
+// download that was interrupted, which is resumed. Completed downloads get
+// the modification time of the remote file, so they aren't downloaded again.
+func (c *CLI) getFile(ctx context.Context, r *remote, file *client.File, local string) error {
+	var offset int64
+	if info, err := os.Stat(local); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", local)
+		}
+		if info.Size() == file.Size && info.ModTime().Equal(file.Modified) {
+			fmt.Fprintf(c.Stderr, "%s is already downloaded\n", local)
+			return nil
+		}
+		if info.Size() < file.Size {
+			offset = info.Size()
+		}
+	}
+
+	body, err := r.client.DownloadFrom(ctx, file.Path, offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(local, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	bar := newProgress(c.Stderr, file.Name, file.Size, offset)
+	_, err = io.Copy(f, bar.reader(body))
+	bar.finish()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading %s (run the command again to resume): %v", file.Path, err)
+	}
+
+	return os.Chtimes(local, file.Modified, file.Modified)
+}
+
+// put uploads local files to a remote directory. With a single file, the
+// remote URL can also be the name of the uploaded file.
+func (c *CLI) put(ctx context.Context, args []string) error {
+	fs := c.flagSet("put")
+	encrypt := fs.Bool("e", false, "Encrypt the files end to end and print their share links")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageError("put")
+	}
+
+	// Patterns are expanded here too, for shells that don't do it
+	var files []string
+	for _, pattern := range fs.Args()[:fs.NArg()-1] {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no files match %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+
+	r, err := c.remote(ctx, fs.Arg(fs.NArg()-1))
+	if err != nil {
+		return err
+	}
+
+	dir, name := r.path, ""
+	target, err := r.client.Stat(ctx, r.path)
+	switch {
+	case err == nil && target.IsDir:
+	case (err == nil || client.IsNotFound(err)) && len(files) == 1 && r.path != "":
+		dir, name = path.Dir(r.path), path.Base(r.path)
+		if dir == "." {
+			dir = ""
+		}
+	case err != nil:
+		return err
+	default:
+		return fmt.Errorf("%s is not a directory", r.url(r.path))
+	}
+
+	for _, local := range files {
+		if *encrypt {
+			if err := c.putEncrypted(ctx, r, local, dir); err != nil {
+				return err
+			}
+			continue
+		}
+		uploadName := name
+		if uploadName == "" {
+			uploadName = filepath.Base(local)
+		}
+		if err := c.putFile(ctx, r, local, dir, uploadName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putFile uploads a local file
+func (c *CLI) putFile(ctx context.Context, r *remote, local, dir, name string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", local)
+	}
+
+	bar := newProgress(c.Stderr, name, info.Size(), 0)
+	_, err = r.client.Upload(ctx, dir, name, bar.reader(f))
+	bar.finish()
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %v", local, err)
+	}
+	return nil
+}
+
+// rm deletes remote files
+func (c *CLI) rm(ctx context.Context, args []string) error {
+	fs := c.flagSet("rm")
+	recursive := fs.Bool("r", false, "Delete directories with all their content")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageError("rm")
+	}
+
+	for _, rawURL := range fs.Args() {
+		r, err := c.remote(ctx, rawURL)
+		if err != nil {
+			return err
+		}
+		if r.path == "" {
+			return fmt.Errorf("refusing to delete the shared directory")
+		}
+		if err := r.client.Delete(ctx, r.path, *recursive); err != nil {
+			return fmt.Errorf("error deleting %s: %v", r.url(r.path), err)
+		}
+	}
+	return nil
+}
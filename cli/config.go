@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Credentials are used to authenticate with a server. A token is used
+// instead of the username and password if both are set.
+type Credentials struct {
+	Token    string `yaml:"token,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Config is the client configuration file, with the credentials of each
+// server by URL
+type Config struct {
+	Servers map[string]Credentials `yaml:"servers"`
+}
+
+// DefaultConfigPath returns the default location of the client
+// configuration, in the user configuration directory
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "shareiscare", "client.yaml"), nil
+}
+
+// LoadConfig reads the client configuration. A missing file is an empty
+// configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{Servers: make(map[string]Credentials)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if config.Servers == nil {
+		config.Servers = make(map[string]Credentials)
+	}
+	return config, nil
+}
+
+// Save writes the client configuration, readable only by the user since it
+// contains credentials
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// lookup returns the configured server of a URL, which is the one with the
+// longest URL that contains it
+func (c *Config) lookup(u *url.URL) (string, Credentials, bool) {
+	target := strings.TrimSuffix(u.Scheme+"://"+u.Host+u.Path, "/") + "/"
+
+	var server string
+	for s := range c.Servers {
+		prefix := strings.TrimSuffix(s, "/") + "/"
+		if strings.HasPrefix(target, prefix) && len(s) > len(server) {
+			server = s
+		}
+	}
+	if server == "" {
+		return "", Credentials{}, false
+	}
+	return strings.TrimSuffix(server, "/"), c.Servers[server], true
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rodrwan/shareiscare/e2e"
+)
+
+// putEncrypted encrypts a local file end to end, uploads it with a random
+// name and prints its share link
+func (c *CLI) putEncrypted(ctx context.Context, r *remote, local, dir string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", local)
+	}
+
+	key, err := e2e.NewKey()
+	if err != nil {
+		return err
+	}
+	name, err := e2e.NewName()
+	if err != nil {
+		return err
+	}
+	meta := e2e.Metadata{
+		Name: info.Name(),
+		Type: mime.TypeByExtension(filepath.Ext(local)),
+		Size: info.Size(),
+	}
+
+	// The file is encrypted while it's being uploaded
+	body, writer := io.Pipe()
+	bar := newProgress(c.Stderr, info.Name(), info.Size(), 0)
+	go func() {
+		w, err := e2e.NewWriter(writer, key, meta)
+		if err == nil {
+			if _, err = io.Copy(w, bar.reader(f)); err == nil {
+				err = w.Close()
+			}
+		}
+		writer.CloseWithError(err)
+	}()
+	_, err = r.client.Upload(ctx, dir, name, body)
+	body.Close()
+	bar.finish()
+	if err != nil {
+		return fmt.Errorf("error uploading %s: %v", local, err)
+	}
+
+	fmt.Fprintln(c.Stdout, e2e.Link(r.server, path.Join(dir, name), key))
+	return nil
+}
+
+// getEncrypted downloads the file of a share link and decrypts it. Without
+// a local name, it's saved with its original name.
+func (c *CLI) getEncrypted(ctx context.Context, link, local string) error {
+	server, p, key, err := e2e.ParseLink(link)
+	if err != nil {
+		return err
+	}
+	r, err := c.remote(ctx, server)
+	if err != nil {
+		return err
+	}
+	file, err := r.client.Stat(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	body, err := r.client.Download(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	bar := newProgress(c.Stderr, file.Name, file.Size, 0)
+	defer bar.finish()
+	reader, err := e2e.NewReader(bar.reader(body), key)
+	if err != nil {
+		return err
+	}
+
+	// The original name comes from the uploader, only its base is used
+	name := filepath.Base(filepath.Clean("/" + reader.Metadata.Name))
+	if name == "/" || name == "." || name == `\` {
+		name = file.Name
+	}
+	if local == "" {
+		local = name
+	} else if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, name)
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, reader)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A damaged file isn't kept
+		os.Remove(local)
+		return fmt.Errorf("error downloading %s: %v", link, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// progressWidth is the width of the progress bars in characters
+const progressWidth = 25
+
+// progress draws the progress bar of a transfer on a line of the terminal
+type progress struct {
+	w     io.Writer
+	name  string
+	total int64
+	done  int64
+	drawn time.Time
+}
+
+// newProgress starts the progress bar of a transfer of total bytes, of which
+// done are already transferred
+func newProgress(w io.Writer, name string, total, done int64) *progress {
+	p := &progress{w: w, name: name, total: total, done: done}
+	p.draw()
+	return p
+}
+
+// reader returns a reader that advances the progress bar as r is read
+func (p *progress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, progress: p}
+}
+
+// add advances the progress bar, redrawing it at most every 100ms
+func (p *progress) add(n int) {
+	p.done += int64(n)
+	if time.Since(p.drawn) >= 100*time.Millisecond {
+		p.draw()
+	}
+}
+
+// draw draws the progress bar over the current line
+func (p *progress) draw() {
+	p.drawn = time.Now()
+
+	percent := 100
+	if p.total > 0 {
+		percent = int(min(p.done*100/p.total, 100))
+	}
+	filled := percent * progressWidth / 100
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	fmt.Fprintf(p.w, "\r%-30s [%s] %3d%%  %s / %s ", truncate(p.name, 30), bar, percent, formatSize(p.done), formatSize(p.total))
+}
+
+// finish draws the final state of the progress bar and ends its line
+func (p *progress) finish() {
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+// progressReader is a reader that advances a progress bar
+type progressReader struct {
+	r        io.Reader
+	progress *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.progress.add(n)
+	return n, err
+}
+
+// truncate shortens a name to at most n characters
+func truncate(name string, n int) string {
+	runes := []rune(name)
+	if len(runes) <= n {
+		return name
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// formatSize formats a size in bytes for display
+func formatSize(bytes int64) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	} else if bytes < 1024*1024 {
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1024)
+	} else if bytes < 1024*1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
+	}
+	return fmt.Sprintf("%.1f GB", float64(bytes)/(1024*1024*1024))
+}
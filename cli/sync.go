@@ -0,0 +1,524 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodrwan/shareiscare/client"
+)
+
+// Sync modes
+const (
+	SyncPush = "push" // Only upload local changes
+	SyncPull = "pull" // Only download remote changes
+	SyncBoth = "both" // Upload and download changes
+)
+
+// syncStateFile keeps the files of the last sync in the local directory,
+// and syncTrashDir the local files deleted on the server. Files with the
+// prefix of the state file are never synced.
+const (
+	syncPrefix    = ".shareiscare-"
+	syncStateFile = syncPrefix + "sync.json"
+	syncTrashDir  = syncPrefix + "trash"
+)
+
+// syncFile is the size and modification time of a file on one side
+type syncFile struct {
+	Size     int64
+	Modified time.Time
+}
+
+// syncedFile is a file as it was on both sides after the last sync, used to
+// know which side changed since then
+type syncedFile struct {
+	Size   int64     `json:"size"`
+	Local  time.Time `json:"local"`
+	Remote time.Time `json:"remote"`
+}
+
+// syncState is the state of the last sync of a local directory
+type syncState struct {
+	Remote string                `json:"remote"`
+	Files  map[string]syncedFile `json:"files"`
+}
+
+// Sync actions
+const (
+	actionUpload       = "upload"
+	actionDownload     = "download"
+	actionDeleteLocal  = "delete-local"
+	actionDeleteRemote = "delete-remote"
+	actionConflict     = "conflict"
+)
+
+// syncAction is a change needed to sync a file
+type syncAction struct {
+	kind string
+	path string
+}
+
+// syncer syncs a local directory with a remote directory
+type syncer struct {
+	cli    *CLI
+	remote *remote
+	local  string
+	mode   string
+	dryRun bool
+
+	state       *syncState
+	localFiles  map[string]syncFile
+	remoteFiles map[string]syncFile
+	remoteDirs  map[string]bool
+	started     time.Time // When the changes of the current sync started
+}
+
+// sync syncs a local directory with a remote directory
+func (c *CLI) sync(ctx context.Context, args []string) error {
+	flags := c.flagSet("sync")
+	mode := flags.String("mode", SyncBoth, "Direction of the changes: push, pull or both")
+	dryRun := flags.Bool("dry-run", false, "Show the changes without making them")
+	watch := flags.Bool("watch", false, "Keep syncing until interrupted")
+	interval := flags.Duration("interval", 10*time.Second, "Time between syncs in watch mode")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return usageError("sync")
+	}
+	if *mode != SyncPush && *mode != SyncPull && *mode != SyncBoth {
+		return fmt.Errorf("invalid mode %s: must be push, pull or both", *mode)
+	}
+
+	local := flags.Arg(0)
+	if info, err := os.Stat(local); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", local)
+	}
+
+	r, err := c.remote(ctx, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if file, err := r.client.Stat(ctx, r.path); err != nil {
+		return err
+	} else if !file.IsDir {
+		return fmt.Errorf("%s is not a directory", r.url(r.path))
+	}
+
+	s := &syncer{cli: c, remote: r, local: local, mode: *mode, dryRun: *dryRun}
+	for {
+		if err := s.run(ctx); err != nil {
+			if !*watch || ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintf(c.Stderr, "Error syncing: %v\n", err)
+		}
+		if !*watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// run syncs the directories once
+func (s *syncer) run(ctx context.Context) error {
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	actions, err := s.plan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		fmt.Fprintf(s.cli.Stdout, "%-14s%s\n", action.kind, action.path)
+	}
+	if s.dryRun {
+		if len(actions) == 0 {
+			fmt.Fprintln(s.cli.Stdout, "Everything is in sync")
+		}
+		return nil
+	}
+
+	var failed int
+	s.started = time.Now()
+	for _, action := range actions {
+		if err := s.apply(ctx, action); err != nil {
+			fmt.Fprintf(s.cli.Stderr, "Error in %s of %s: %v\n", action.kind, action.path, err)
+			failed++
+		}
+	}
+
+	if err := s.saveState(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", failed, len(actions))
+	}
+	return nil
+}
+
+// load reads the state of the last sync and both directory trees
+func (s *syncer) load(ctx context.Context) error {
+	s.state = &syncState{Files: make(map[string]syncedFile)}
+	data, err := os.ReadFile(filepath.Join(s.local, syncStateFile))
+	if err == nil {
+		if err := json.Unmarshal(data, s.state); err != nil {
+			return fmt.Errorf("invalid sync state: %v", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// The state of another remote directory doesn't say anything about this one
+	if remote := s.remote.url(s.remote.path); s.state.Remote != remote {
+		s.state = &syncState{Remote: remote, Files: make(map[string]syncedFile)}
+	}
+
+	s.localFiles = make(map[string]syncFile)
+	err = filepath.WalkDir(s.local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), syncPrefix) && p != s.local {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(s.local, p)
+		s.localFiles[filepath.ToSlash(rel)] = syncFile{Size: info.Size(), Modified: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.remoteFiles = make(map[string]syncFile)
+	s.remoteDirs = map[string]bool{"": true}
+	return s.listRemote(ctx, "")
+}
+
+// listRemote adds the files of a remote directory and its subdirectories.
+// Paths are relative to the synced directory.
+func (s *syncer) listRemote(ctx context.Context, dir string) error {
+	files, err := s.remote.client.List(ctx, path.Join(s.remote.path, dir))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		rel := path.Join(dir, file.Name)
+		if file.IsDir {
+			s.remoteDirs[rel] = true
+			if err := s.listRemote(ctx, rel); err != nil {
+				return err
+			}
+		} else if !strings.HasPrefix(file.Name, syncPrefix) && !strings.HasPrefix(file.Name, ".upload-") {
+			// Uploads in progress on the server are skipped too
+			s.remoteFiles[rel] = syncFile{Size: file.Size, Modified: file.Modified}
+		}
+	}
+	return nil
+}
+
+// plan compares both trees with the last sync and returns the changes to
+// make. Files that are already in sync are recorded in the state.
+func (s *syncer) plan(ctx context.Context) ([]syncAction, error) {
+	paths := make(map[string]bool)
+	for p := range s.localFiles {
+		paths[p] = true
+	}
+	for p := range s.remoteFiles {
+		paths[p] = true
+	}
+	for p := range s.state.Files {
+		paths[p] = true
+	}
+
+	var actions []syncAction
+	for p := range paths {
+		kind, err := s.compare(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if kind != "" {
+			actions = append(actions, syncAction{kind: kind, path: p})
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].path < actions[j].path
+	})
+	return actions, nil
+}
+
+// compare returns the action needed to sync a file, or "" if there's nothing
+// to do in the current mode
+func (s *syncer) compare(ctx context.Context, p string) (string, error) {
+	local, hasLocal := s.localFiles[p]
+	remote, hasRemote := s.remoteFiles[p]
+	last, synced := s.state.Files[p]
+
+	localChanged := hasLocal && (!synced || local.Size != last.Size || !local.Modified.Equal(last.Local))
+	remoteChanged := hasRemote && (!synced || remote.Size != last.Size || !remote.Modified.Equal(last.Remote))
+	push := s.mode != SyncPull
+	pull := s.mode != SyncPush
+
+	switch {
+	case hasLocal && hasRemote:
+		if !localChanged && !remoteChanged {
+			return "", nil
+		}
+		// Files with the same content are in sync, e.g. on the first sync
+		same, err := s.sameContent(ctx, p, local, remote)
+		if err != nil {
+			return "", err
+		}
+		if same {
+			s.state.Files[p] = syncedFile{Size: local.Size, Local: local.Modified, Remote: remote.Modified}
+			return "", nil
+		}
+		switch {
+		case localChanged && remoteChanged:
+			if push && !pull {
+				return actionUpload, nil
+			}
+			return actionConflict, nil
+		case localChanged && push:
+			return actionUpload, nil
+		case remoteChanged && pull:
+			return actionDownload, nil
+		}
+
+	case hasLocal:
+		switch {
+		case synced && !localChanged && pull:
+			// Deleted on the server since the last sync
+			return actionDeleteLocal, nil
+		case push:
+			return actionUpload, nil
+		}
+
+	case hasRemote:
+		switch {
+		case synced && !remoteChanged && push:
+			// Deleted locally since the last sync
+			return actionDeleteRemote, nil
+		case pull:
+			return actionDownload, nil
+		}
+
+	default:
+		// Deleted on both sides
+		delete(s.state.Files, p)
+	}
+	return "", nil
+}
+
+// sameContent reports whether the local and remote files have the same
+// content, comparing their checksums if they have the same size
+func (s *syncer) sameContent(ctx context.Context, p string, local, remote syncFile) (bool, error) {
+	if local.Size != remote.Size {
+		return false, nil
+	}
+
+	f, err := os.Open(s.localPath(p))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false, err
+	}
+
+	sum, err := s.remote.client.Checksum(ctx, s.remotePath(p))
+	if err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == sum, nil
+}
+
+// localPath returns the local path of a synced file
+func (s *syncer) localPath(p string) string {
+	return filepath.Join(s.local, filepath.FromSlash(p))
+}
+
+// remotePath returns the remote path of a synced file
+func (s *syncer) remotePath(p string) string {
+	return path.Join(s.remote.path, p)
+}
+
+// apply makes a change and records the result in the state
+func (s *syncer) apply(ctx context.Context, action syncAction) error {
+	p := action.path
+
+	switch action.kind {
+	case actionUpload:
+		return s.upload(ctx, p)
+
+	case actionDownload:
+		return s.download(ctx, p)
+
+	case actionDeleteLocal:
+		if err := s.trash(p); err != nil {
+			return err
+		}
+		delete(s.state.Files, p)
+
+	case actionDeleteRemote:
+		if err := s.remote.client.Delete(ctx, s.remotePath(p), false); err != nil && !client.IsNotFound(err) {
+			return err
+		}
+		delete(s.state.Files, p)
+
+	case actionConflict:
+		// The local version is kept as a copy and the remote one wins
+		copyPath := conflictName(p, s.started)
+		if err := os.Rename(s.localPath(p), s.localPath(copyPath)); err != nil {
+			return err
+		}
+		fmt.Fprintf(s.cli.Stdout, "%-14s%s\n", "conflict-copy", copyPath)
+		if err := s.download(ctx, p); err != nil {
+			return err
+		}
+		if s.mode == SyncBoth {
+			return s.upload(ctx, copyPath)
+		}
+	}
+	return nil
+}
+
+// trash moves a local file to the trash of the sync instead of deleting
+// it. A file missing on the server may only be hidden or excluded for the
+// user, or be in a folder that can't be listed anymore.
+func (s *syncer) trash(p string) error {
+	dst := filepath.Join(s.local, syncTrashDir, s.started.Format("2006-01-02 150405"), filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(s.localPath(p), dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// upload uploads a local file, creating its remote directory if needed
+func (s *syncer) upload(ctx context.Context, p string) error {
+	dir := path.Dir(p)
+	if dir == "." {
+		dir = ""
+	}
+	if !s.remoteDirs[dir] {
+		if _, err := s.remote.client.Mkdir(ctx, s.remotePath(dir)); err != nil {
+			return err
+		}
+		for d := dir; d != "." && d != ""; d = path.Dir(d) {
+			s.remoteDirs[d] = true
+		}
+	}
+
+	f, err := os.Open(s.localPath(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	bar := newProgress(s.cli.Stderr, path.Base(p), info.Size(), 0)
+	file, err := s.remote.client.Upload(ctx, s.remotePath(dir), path.Base(p), bar.reader(f))
+	bar.finish()
+	if err != nil {
+		return err
+	}
+
+	s.state.Files[p] = syncedFile{Size: info.Size(), Local: info.ModTime(), Remote: file.Modified}
+	return nil
+}
+
+// download downloads a remote file to a temporary file, which replaces the
+// local file once complete
+func (s *syncer) download(ctx context.Context, p string) error {
+	remote := s.remoteFiles[p]
+	local := s.localPath(p)
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+
+	body, err := s.remote.client.Download(ctx, s.remotePath(p))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(local), syncPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	bar := newProgress(s.cli.Stderr, path.Base(p), remote.Size, 0)
+	_, err = io.Copy(tmp, bar.reader(body))
+	bar.finish()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), remote.Modified, remote.Modified); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return err
+	}
+
+	s.state.Files[p] = syncedFile{Size: remote.Size, Local: remote.Modified, Remote: remote.Modified}
+	return nil
+}
+
+// saveState writes the state of the sync to the local directory
+func (s *syncer) saveState() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.local, syncStateFile), data, 0644)
+}
+
+// conflictName returns the name of the conflict copy of a file, e.g.
+// "notes (conflict 2006-01-02 150405).txt"
+func conflictName(p string, t time.Time) string {
+	ext := path.Ext(p)
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(p, ext), t.Format("2006-01-02 150405"), ext)
+}
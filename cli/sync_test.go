@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// writeFiles crea archivos con su contenido en un directorio
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("No se pudo crear el directorio: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("No se pudo crear el archivo: %v", err)
+		}
+	}
+}
+
+// checkFiles comprueba el contenido de archivos de un directorio
+func checkFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, want := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s no debería existir", name)
+			}
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("Contenido de %s incorrecto: %q, esperado %q", name, data, want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, stdout := newTestCLI(t, "testuser\ntestpass\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+
+	local := t.TempDir()
+	remote := filepath.Join(cfg.RootDir, "sync")
+	writeFiles(t, local, map[string]string{"local.txt": "local", "igual.txt": "igual", "docs/nota.txt": "nota"})
+	writeFiles(t, remote, map[string]string{"remoto.txt": "remoto", "igual.txt": "igual"})
+	url := server.URL + "/sync"
+
+	// El informe no modifica nada
+	if err := c.Run(ctx, "sync", []string{"-dry-run", local, url}); err != nil {
+		t.Fatalf("sync -dry-run devolvió error: %v", err)
+	}
+	report := stdout.String()
+	for _, line := range []string{"upload        local.txt", "upload        docs/nota.txt", "download      remoto.txt"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("El informe no contiene %q: %s", line, report)
+		}
+	}
+	if strings.Contains(report, "igual.txt") {
+		t.Errorf("Los archivos iguales no deberían sincronizarse: %s", report)
+	}
+	checkFiles(t, remote, map[string]string{"local.txt": ""})
+
+	// Primera sincronización
+	if err := c.Run(ctx, "sync", []string{local, url}); err != nil {
+		t.Fatalf("sync devolvió error: %v", err)
+	}
+	all := map[string]string{"local.txt": "local", "igual.txt": "igual", "docs/nota.txt": "nota", "remoto.txt": "remoto"}
+	checkFiles(t, local, all)
+	checkFiles(t, remote, all)
+
+	stdout.Reset()
+	if err := c.Run(ctx, "sync", []string{"-dry-run", local, url}); err != nil {
+		t.Fatalf("sync -dry-run devolvió error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Everything is in sync") {
+		t.Errorf("Los directorios deberían estar sincronizados: %s", stdout.String())
+	}
+
+	// Los borrados se propagan
+	os.Remove(filepath.Join(local, "local.txt"))
+	os.Remove(filepath.Join(remote, "remoto.txt"))
+	if err := c.Run(ctx, "sync", []string{local, url}); err != nil {
+		t.Fatalf("sync devolvió error: %v", err)
+	}
+	checkFiles(t, remote, map[string]string{"local.txt": ""})
+	checkFiles(t, local, map[string]string{"remoto.txt": ""})
+
+	// Los archivos borrados en el servidor se guardan en la papelera, porque
+	// puede que solo estén ocultos para el usuario
+	trashed, _ := filepath.Glob(filepath.Join(local, syncTrashDir, "*", "remoto.txt"))
+	if len(trashed) != 1 {
+		t.Fatalf("Se esperaba el archivo borrado en la papelera: %v", trashed)
+	}
+	if data, _ := os.ReadFile(trashed[0]); string(data) != "remoto" {
+		t.Errorf("Contenido incorrecto en la papelera: %q", data)
+	}
+
+	// Los conflictos guardan una copia de la versión local
+	writeFiles(t, local, map[string]string{"igual.txt": "cambio local"})
+	writeFiles(t, remote, map[string]string{"igual.txt": "cambio remoto"})
+	if err := c.Run(ctx, "sync", []string{local, url}); err != nil {
+		t.Fatalf("sync devolvió error: %v", err)
+	}
+	checkFiles(t, local, map[string]string{"igual.txt": "cambio remoto"})
+	copies, _ := filepath.Glob(filepath.Join(remote, "igual (conflict *).txt"))
+	if len(copies) != 1 {
+		t.Fatalf("Se esperaba una copia del conflicto en el servidor: %v", copies)
+	}
+	checkFiles(t, local, map[string]string{filepath.Base(copies[0]): "cambio local"})
+
+	// La papelera no se sincroniza
+	if _, err := os.Stat(filepath.Join(remote, syncTrashDir)); !os.IsNotExist(err) {
+		t.Error("La papelera no debería subirse al servidor")
+	}
+}
+
+func TestSyncModes(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, _ := newTestCLI(t, "testuser\ntestpass\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{"local.txt": "local"})
+	writeFiles(t, cfg.RootDir, map[string]string{"remoto.txt": "remoto"})
+
+	// Solo subida
+	if err := c.Run(ctx, "sync", []string{"-mode", "push", local, server.URL}); err != nil {
+		t.Fatalf("sync -mode push devolvió error: %v", err)
+	}
+	checkFiles(t, cfg.RootDir, map[string]string{"local.txt": "local"})
+	checkFiles(t, local, map[string]string{"remoto.txt": ""})
+
+	// Solo descarga
+	writeFiles(t, local, map[string]string{"nuevo.txt": "nuevo"})
+	if err := c.Run(ctx, "sync", []string{"-mode", "pull", local, server.URL}); err != nil {
+		t.Fatalf("sync -mode pull devolvió error: %v", err)
+	}
+	checkFiles(t, local, map[string]string{"remoto.txt": "remoto"})
+	checkFiles(t, cfg.RootDir, map[string]string{"nuevo.txt": ""})
+
+	if err := c.Run(ctx, "sync", []string{"-mode", "otro", local, server.URL}); err == nil {
+		t.Error("sync debería rechazar modos inválidos")
+	}
+}
+
+func TestSyncHiddenFiles(t *testing.T) {
+	server, cfg := newTestServer(t)
+	cfg.ShowHidden = config.HiddenNever
+	ctx := context.Background()
+
+	c, _ := newTestCLI(t, "testuser\ntestpass\n")
+	if err := c.Run(ctx, "login", []string{server.URL}); err != nil {
+		t.Fatalf("login devolvió error: %v", err)
+	}
+
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{".env": "SECRETO=1", "nota.txt": "nota"})
+
+	// El servidor rechaza los archivos ocultos, que nunca se borran localmente
+	for i := 0; i < 2; i++ {
+		if err := c.Run(ctx, "sync", []string{local, server.URL}); err == nil {
+			t.Error("sync debería informar de la subida rechazada")
+		}
+		checkFiles(t, local, map[string]string{".env": "SECRETO=1", "nota.txt": "nota"})
+	}
+	checkFiles(t, cfg.RootDir, map[string]string{".env": "", "nota.txt": "nota"})
+}
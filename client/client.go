@@ -0,0 +1,346 @@
+// Package client is a Go client for the JSON API of a ShareIsCare server.
+//
+//	c, err := client.New("http://localhost:8080")
+//	if err != nil {
+//		return err
+//	}
+//	if err := c.Login(ctx, "admin", "shareiscare"); err != nil {
+//		return err
+//	}
+//	files, err := c.List(ctx, "docs")
+//
+// Scripts can set Token to an API token instead of calling Login.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// File is a file or directory of the shared directory
+type File struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"` // Path relative to the shared directory
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	IsDir    bool      `json:"is_dir"`
+	Type     string    `json:"type"` // directory, image, video, text or unknown
+}
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int    `json:"status"`
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("shareiscare: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is an API error for a missing file
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client makes requests to the API of a ShareIsCare server
+type Client struct {
+	// HTTPClient is used to make the requests (http.DefaultClient if nil)
+	HTTPClient *http.Client
+
+	// Token is an API token sent as a bearer token instead of logging in
+	Token string
+
+	baseURL *url.URL
+	session string
+}
+
+// New returns a client for the server at baseURL (e.g. http://localhost:8080)
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL: %s", baseURL)
+	}
+	return &Client{baseURL: u}, nil
+}
+
+// httpClient returns the HTTP client used for the requests
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// endpoint returns the URL of an API endpoint with the given query
+func (c *Client) endpoint(p string, query url.Values) string {
+	u := *c.baseURL
+	u.Path += p
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do sends a request and returns the response if its status is a success.
+// Otherwise the error of the response body is returned.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.session != "" {
+		req.AddCookie(&http.Cookie{Name: "session", Value: c.session})
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Message == "" {
+		apiErr.StatusCode = resp.StatusCode
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, apiErr
+}
+
+// doJSON sends a request and decodes the JSON response into v
+func (c *Client) doJSON(req *http.Request, v any) error {
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// postJSON sends body as JSON to an API endpoint and decodes the response into v
+func (c *Client) postJSON(ctx context.Context, p string, body any, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(p, nil), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.doJSON(req, v)
+}
+
+// Login logs in with a username and password. The session is used by all
+// the following requests of the client.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	form := url.Values{"username": {username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/login", nil), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// The session cookie is set on the redirect after a successful login
+	httpClient := *c.httpClient()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session" && cookie.Value != "" {
+			c.session = cookie.Value
+			return nil
+		}
+	}
+	return &Error{StatusCode: http.StatusUnauthorized, Message: "Incorrect username or password"}
+}
+
+// List returns the files of a directory ("" for the root)
+func (c *Client) List(ctx context.Context, dir string) ([]File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/api/v1/files", url.Values{"path": {dir}}), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var listing struct {
+		Files []File `json:"files"`
+	}
+	if err := c.doJSON(req, &listing); err != nil {
+		return nil, err
+	}
+	return listing.Files, nil
+}
+
+// Stat returns the information of a file or directory
+func (c *Client) Stat(ctx context.Context, p string) (*File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/api/v1/stat", url.Values{"path": {p}}), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := c.doJSON(req, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Checksum returns the hex SHA-256 checksum of a file
+func (c *Client) Checksum(ctx context.Context, p string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/api/v1/checksum", url.Values{"path": {p}}), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Checksum string `json:"checksum"`
+	}
+	if err := c.doJSON(req, &result); err != nil {
+		return "", err
+	}
+	return result.Checksum, nil
+}
+
+// Download returns the content of a file. The caller must close it.
+func (c *Client) Download(ctx context.Context, p string) (io.ReadCloser, error) {
+	return c.DownloadFrom(ctx, p, 0)
+}
+
+// DownloadFrom returns the content of a file starting at offset, which is
+// used to resume downloads. The caller must close it.
+func (c *Client) DownloadFrom(ctx context.Context, p string, offset int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/api/v1/download", url.Values{"path": {p}}), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("the server doesn't support resuming downloads")
+	}
+	return resp.Body, nil
+}
+
+// Upload streams the content of r to a file called name in dir. The
+// SHA-256 checksum is calculated while uploading and verified by the server.
+func (c *Client) Upload(ctx context.Context, dir, name string, r io.Reader) (*File, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	// Write the form while it's being sent
+	go func() {
+		part, err := form.CreateFormFile("files", name)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(part, hash), r); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		// The server reads the whole form before checking the checksums
+		checksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash.Sum(nil)), name)
+		if err := form.WriteField("checksums", checksums); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		writer.CloseWithError(form.Close())
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/api/v1/upload", url.Values{"path": {dir}}), body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var result struct {
+		Files  []File   `json:"files"`
+		Errors []string `json:"errors"`
+	}
+	err = c.doJSON(req, &result)
+	// Stop writing the form if the request ended early
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Files) == 0 {
+		return nil, fmt.Errorf("upload failed: %s", strings.Join(result.Errors, "; "))
+	}
+	return &result.Files[0], nil
+}
+
+// Delete deletes a file or an empty directory. With recursive, directories
+// are deleted with all their content.
+func (c *Client) Delete(ctx context.Context, p string, recursive bool) error {
+	query := url.Values{"path": {p}}
+	if recursive {
+		query.Set("recursive", "true")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.endpoint("/api/v1/files", query), nil)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, nil)
+}
+
+// Mkdir creates a directory, including any missing parents
+func (c *Client) Mkdir(ctx context.Context, p string) (*File, error) {
+	var file File
+	if err := c.postJSON(ctx, "/api/v1/mkdir", map[string]string{"path": p}, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Move moves or renames a file or directory
+func (c *Client) Move(ctx context.Context, from, to string) (*File, error) {
+	var file File
+	if err := c.postJSON(ctx, "/api/v1/move", map[string]string{"from": from, "to": to}, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/handlers"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+// newTestServer inicia un servidor con la API sobre un directorio temporal
+func newTestServer(t *testing.T) (*httptest.Server, *config.Config) {
+	t.Helper()
+
+	cfg := &config.Config{
+		RootDir:   t.TempDir(),
+		CacheDir:  t.TempDir(),
+		Title:     "ShareIsCare Test",
+		Username:  "testuser",
+		Password:  "testpass",
+		SecretKey: "test-secret-key",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", handlers.LoginPost(cfg))
+	mux.HandleFunc("/api/v1/", handlers.API(cfg))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, cfg
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("localhost:8080"); err == nil {
+		t.Error("New() debería rechazar URLs sin esquema")
+	}
+	if _, err := New("http://localhost:8080/"); err != nil {
+		t.Errorf("New() devolvió error: %v", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+	ctx := context.Background()
+
+	c, _ := New(server.URL)
+	if err := c.Login(ctx, "testuser", "incorrecta"); err == nil {
+		t.Error("Login() debería fallar con credenciales incorrectas")
+	}
+	if err := c.Login(ctx, "testuser", "testpass"); err != nil {
+		t.Errorf("Login() devolvió error: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	c, _ := New(server.URL)
+
+	// Las operaciones de escritura requieren sesión
+	_, err := c.Upload(ctx, "", "nota.txt", strings.NewReader("hola"))
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Upload() sin sesión debería devolver 401, obtenido %v", err)
+	}
+
+	if err := c.Login(ctx, "testuser", "testpass"); err != nil {
+		t.Fatalf("Login() devolvió error: %v", err)
+	}
+
+	if _, err := c.Mkdir(ctx, "docs"); err != nil {
+		t.Fatalf("Mkdir() devolvió error: %v", err)
+	}
+
+	// Subida en streaming
+	content := strings.Repeat("contenido ", 100000)
+	file, err := c.Upload(ctx, "docs", "nota.txt", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Upload() devolvió error: %v", err)
+	}
+	if file.Path != "docs/nota.txt" || file.Size != int64(len(content)) {
+		t.Errorf("Archivo subido incorrecto: %+v", file)
+	}
+
+	files, err := c.List(ctx, "docs")
+	if err != nil {
+		t.Fatalf("List() devolvió error: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "nota.txt" || files[0].Type != "text" {
+		t.Errorf("Listado incorrecto: %+v", files)
+	}
+
+	sum, err := c.Checksum(ctx, "docs/nota.txt")
+	if err != nil {
+		t.Fatalf("Checksum() devolvió error: %v", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(content))); sum != want {
+		t.Errorf("Checksum() = %s, esperado %s", sum, want)
+	}
+
+	// Descarga completa y parcial
+	body, err := c.Download(ctx, "docs/nota.txt")
+	if err != nil {
+		t.Fatalf("Download() devolvió error: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != content {
+		t.Error("El contenido descargado no coincide")
+	}
+
+	body, err = c.DownloadFrom(ctx, "docs/nota.txt", 10)
+	if err != nil {
+		t.Fatalf("DownloadFrom() devolvió error: %v", err)
+	}
+	data, _ = io.ReadAll(body)
+	body.Close()
+	if string(data) != content[10:] {
+		t.Error("El contenido descargado desde la posición no coincide")
+	}
+
+	if _, err := c.Move(ctx, "docs/nota.txt", "nota.txt"); err != nil {
+		t.Fatalf("Move() devolvió error: %v", err)
+	}
+	if _, err := c.Stat(ctx, "nota.txt"); err != nil {
+		t.Errorf("Stat() devolvió error: %v", err)
+	}
+
+	if err := c.Delete(ctx, "nota.txt", false); err != nil {
+		t.Fatalf("Delete() devolvió error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.RootDir, "nota.txt")); !os.IsNotExist(err) {
+		t.Error("El archivo debería haberse eliminado")
+	}
+
+	if _, err := c.Stat(ctx, "nota.txt"); !IsNotFound(err) {
+		t.Errorf("Stat() de un archivo eliminado debería devolver 404, obtenido %v", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	server, cfg := newTestServer(t)
+	ctx := context.Background()
+
+	store, err := tokens.Open(filepath.Join(cfg.CacheDir, "tokens.json"))
+	if err != nil {
+		t.Fatalf("tokens.Open() devolvió error: %v", err)
+	}
+	cfg.Tokens = store
+
+	_, secret, err := store.Create("ci", cfg.Username, []string{tokens.Read, tokens.Upload}, time.Time{})
+	if err != nil {
+		t.Fatalf("Create() devolvió error: %v", err)
+	}
+
+	c, _ := New(server.URL)
+	c.Token = secret
+
+	if _, err := c.Upload(ctx, "", "nota.txt", strings.NewReader("hola")); err != nil {
+		t.Fatalf("Upload() con token devolvió error: %v", err)
+	}
+	if _, err := c.Stat(ctx, "nota.txt"); err != nil {
+		t.Errorf("Stat() con token devolvió error: %v", err)
+	}
+
+	// El token no tiene el scope delete
+	err = c.Delete(ctx, "nota.txt", false)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Delete() sin el scope debería devolver 403, obtenido %v", err)
+	}
+}
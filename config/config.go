@@ -3,8 +3,18 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/rodrwan/shareiscare/diskusage"
+	"github.com/rodrwan/shareiscare/expiry"
+	"github.com/rodrwan/shareiscare/ignore"
+	"github.com/rodrwan/shareiscare/quota"
+	"github.com/rodrwan/shareiscare/storage"
+	"github.com/rodrwan/shareiscare/tokens"
+	"github.com/rodrwan/shareiscare/watcher"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +27,157 @@ type Config struct {
 	Password  string `yaml:"password"`   // Password for authentication
 	SecretKey string `yaml:"secret_key"` // Secret key for signing sessions
 	Hostname  string `yaml:"hostname"`   // Domain for the server
+	CacheDir  string `yaml:"cache_dir"`  // Directory for cached data such as checksums
+	ReadOnly  bool   `yaml:"read_only"`  // Reject every change to the shared files
+	Encrypt   bool   `yaml:"encrypt"`    // Store the files encrypted with a passphrase asked at startup
+	Dedup     bool   `yaml:"dedup"`      // Keep identical files only once (local backend)
+	Symlinks  string `yaml:"symlinks"`   // Symbolic links that are followed: deny, within_root or follow
+
+	ShowHidden Visibility `yaml:"show_hidden"` // Who sees dotfiles and OS metadata files: never, admins or always
+
+	ScanInterval time.Duration `yaml:"scan_interval"` // Interval between disk usage scans
+	TokensFile   string        `yaml:"tokens_file"`   // File where API tokens are stored
+	UploadsFile  string        `yaml:"uploads_file"`  // File where the owners of the uploaded files are stored
+	ExpiryFile   string        `yaml:"expiry_file"`   // File where the expiration of the uploaded files is stored
+
+	Backend BackendConfig `yaml:"backend"` // Where the shared files are kept
+	Shares  []ShareConfig `yaml:"shares"`  // Named shares shown as top-level folders (root_dir is shared if empty)
+	Quotas  QuotaConfig   `yaml:"quotas"`  // Limits of the uploaded files
+
+	Expiry map[string]time.Duration `yaml:"expiry"` // Default lifetime of the files uploaded to each folder and its subfolders ("/" for all)
+
+	Exclude []string `yaml:"exclude"` // Gitignore-style patterns of the files hidden from every client, added to the .shareignore files
+
+	S3   S3Config   `yaml:"s3"`   // S3-compatible endpoint
+	SFTP SFTPConfig `yaml:"sftp"` // SFTP server
+
+	// Runtime services, set up when the server starts (not saved to config.yaml)
+	Storage storage.Storage    `yaml:"-"` // Where the shared files are kept
+	Usage   *diskusage.Scanner `yaml:"-"` // Background disk usage scanner
+	Watcher *watcher.Watcher   `yaml:"-"` // Filesystem watcher for live listings
+	Tokens  *tokens.Store      `yaml:"-"` // API tokens
+	Quota   *quota.Store       `yaml:"-"` // Owners of the uploaded files and uploads in progress
+
+	Expirations *expiry.Store   `yaml:"-"` // Expiration of the uploaded files
+	Ignore      *ignore.Matcher `yaml:"-"` // Files excluded by the patterns
+
+	Passphrase string `yaml:"-"` // Passphrase of the encrypted files
+}
+
+// BackendConfig configures where the shared files are kept
+type BackendConfig struct {
+	Type      string `yaml:"type"`       // "local" for root_dir or "s3" for a bucket
+	Endpoint  string `yaml:"endpoint"`   // URL of the S3 service, e.g. "http://nas:9000"
+	Bucket    string `yaml:"bucket"`     // Bucket with the shared files
+	Prefix    string `yaml:"prefix"`     // Folder of the bucket with the shared files (optional)
+	Region    string `yaml:"region"`     // Region of the bucket
+	AccessKey string `yaml:"access_key"` // Credentials of the S3 service
+	SecretKey string `yaml:"secret_key"`
+	PathStyle bool   `yaml:"path_style"` // Use endpoint/bucket URLs, needed by MinIO
+}
+
+// ShareConfig configures a named share
+type ShareConfig struct {
+	Name     string `yaml:"name"`      // Name of its top-level folder
+	Path     string `yaml:"path"`      // Directory with the files (folder of the bucket with the s3 backend)
+	Title    string `yaml:"title"`     // Title shown on the index page and its listings (optional)
+	ReadOnly bool   `yaml:"read_only"` // Reject uploads, deletions and other changes
+	Private  bool   `yaml:"private"`   // Only shown to logged-in users
+}
+
+// QuotaConfig configures the limits of the uploaded files
+type QuotaConfig struct {
+	Users   map[string]Quota `yaml:"users"`   // Limits of the files uploaded by each user
+	Folders map[string]Quota `yaml:"folders"` // Limits of the files in each folder and its subfolders ("/" for all)
+}
+
+// Quota limits the total size and number of files. Zero means no limit.
+type Quota struct {
+	Bytes Size `yaml:"bytes"` // Total size, e.g. 500MB or 10GB
+	Files int  `yaml:"files"` // Number of files
+}
+
+// Size is a number of bytes, which can be written with a unit in the
+// configuration file, e.g. 10GB
+type Size int64
+
+// sizeUnits are the units accepted by ParseSize
+var sizeUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// ParseSize parses a size in bytes with an optional unit (B, KB, MB, GB or
+// TB, in powers of 1024), e.g. "1.5GB"
+func ParseSize(text string) (Size, error) {
+	text = strings.TrimSpace(text)
+	number := strings.TrimRightFunc(text, unicode.IsLetter)
+	unit, ok := sizeUnits[strings.ToUpper(text[len(number):])]
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if !ok || err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %q", text)
+	}
+	return Size(value * unit), nil
+}
+
+// UnmarshalYAML reads a size written as a number of bytes or with a unit
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	size, err := ParseSize(node.Value)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// Visibility decides who sees the hidden files: dotfiles and OS metadata
+// files such as .DS_Store
+type Visibility string
+
+// Visibilities of the hidden files
+const (
+	HiddenNever  Visibility = "never"  // Nobody sees them
+	HiddenAdmins Visibility = "admins" // Only the admin sees them
+	HiddenAlways Visibility = "always" // Everybody sees them
+)
+
+// UnmarshalYAML reads a visibility, rejecting unknown ones. Empty is
+// HiddenAdmins.
+func (v *Visibility) UnmarshalYAML(node *yaml.Node) error {
+	switch visibility := Visibility(node.Value); visibility {
+	case "":
+		*v = HiddenAdmins
+		return nil
+	case HiddenNever, HiddenAdmins, HiddenAlways:
+		*v = visibility
+		return nil
+	}
+	return fmt.Errorf("invalid show_hidden: %q, it must be never, admins or always", node.Value)
+}
+
+// S3Config configures the optional S3-compatible endpoint
+type S3Config struct {
+	Address    string            `yaml:"address"`     // Address to listen on, e.g. ":9000" (empty to disable)
+	Region     string            `yaml:"region"`      // Region expected in the request signatures
+	Buckets    map[string]string `yaml:"buckets"`     // Bucket names and their folder in the shared directory
+	AccessKeys []S3AccessKey     `yaml:"access_keys"` // Credentials accepted by the endpoint
+}
+
+// S3AccessKey is a pair of credentials for the S3-compatible endpoint
+type S3AccessKey struct {
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+// SFTPConfig configures the optional SFTP server
+type SFTPConfig struct {
+	Address        string `yaml:"address"`         // Address to listen on, e.g. ":2022" (empty to disable)
+	HostKey        string `yaml:"host_key"`        // Host key file, generated if missing (user config directory by default)
+	AuthorizedKeys string `yaml:"authorized_keys"` // authorized_keys file with the public keys of the user (optional)
 }
 
 // DefaultConfig returns a default configuration
@@ -29,6 +190,30 @@ func DefaultConfig() *Config {
 		Password:  "shareiscare",       // Default password
 		SecretKey: generateRandomKey(), // Secret key for sessions
 		Hostname:  "",                  // Default domain
+		CacheDir:  "",                  // User cache directory by default
+		ReadOnly:  false,               // Files can be uploaded and deleted
+		Encrypt:   false,               // Files are stored as they are
+		Dedup:     false,               // Every file keeps its own copy
+		Symlinks:  "within_root",       // Only links to files inside the shared directory
+
+		ShowHidden: HiddenAdmins, // Dotfiles are only shown to the admin
+
+		ScanInterval: 10 * time.Minute, // Rescan disk usage every 10 minutes
+		TokensFile:   "",               // User config directory by default
+		UploadsFile:  "",               // User config directory by default
+		ExpiryFile:   "",               // User config directory by default
+
+		Backend: BackendConfig{
+			Type:   "local",     // Files in root_dir by default
+			Region: "us-east-1", // Default region of most S3 services
+		},
+		S3: S3Config{
+			Address: "",          // Disabled by default
+			Region:  "us-east-1", // Default region of most S3 clients
+		},
+		SFTP: SFTPConfig{
+			Address: "", // Disabled by default
+		},
 	}
 }
 
@@ -130,3 +130,94 @@ func TestSaveConfig(t *testing.T) {
 		t.Error("El archivo no contiene el valor de título esperado")
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]Size{
+		"0":       0,
+		"1024":    1024,
+		"10B":     10,
+		"1KB":     1 << 10,
+		"500 MB":  500 << 20,
+		"1.5GB":   3 << 29,
+		"2tb":     2 << 40,
+		" 10GB  ": 10 << 30,
+	}
+	for text, expected := range tests {
+		size, err := ParseSize(text)
+		if err != nil {
+			t.Errorf("Error inesperado con %q: %v", text, err)
+			continue
+		}
+		if size != expected {
+			t.Errorf("Tamaño erróneo para %q, esperado: %d, obtenido: %d", text, expected, size)
+		}
+	}
+
+	for _, text := range []string{"", "GB", "10XB", "-1MB", "diez"} {
+		if _, err := ParseSize(text); err == nil {
+			t.Errorf("Se esperaba un error con %q", text)
+		}
+	}
+}
+
+func TestLoadQuotas(t *testing.T) {
+	yamlContent := `quotas:
+  users:
+    admin:
+      bytes: 10GB
+      files: 1000
+  folders:
+    fotos:
+      bytes: 1048576
+`
+	if err := os.WriteFile("config.yaml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("Error al escribir la configuración: %v", err)
+	}
+	defer os.Remove("config.yaml")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Error al cargar la configuración: %v", err)
+	}
+
+	if q := cfg.Quotas.Users["admin"]; q.Bytes != 10<<30 || q.Files != 1000 {
+		t.Errorf("Cuota de usuario errónea: %+v", q)
+	}
+	if q := cfg.Quotas.Folders["fotos"]; q.Bytes != 1<<20 || q.Files != 0 {
+		t.Errorf("Cuota de carpeta errónea: %+v", q)
+	}
+
+	// Un tamaño inválido es un error de la configuración
+	os.WriteFile("config.yaml", []byte("quotas:\n  users:\n    admin:\n      bytes: mucho\n"), 0644)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Se esperaba un error con un tamaño inválido")
+	}
+}
+
+func TestLoadShowHidden(t *testing.T) {
+	defer os.Remove("config.yaml")
+
+	tests := map[string]Visibility{
+		"":                    HiddenAdmins,
+		"show_hidden: never":  HiddenNever,
+		"show_hidden: admins": HiddenAdmins,
+		"show_hidden: always": HiddenAlways,
+	}
+	for content, expected := range tests {
+		if err := os.WriteFile("config.yaml", []byte(content+"\n"), 0644); err != nil {
+			t.Fatalf("Error al escribir la configuración: %v", err)
+		}
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("%q: error al cargar la configuración: %v", content, err)
+		}
+		if cfg.ShowHidden != expected {
+			t.Errorf("%q: show_hidden = %q, se esperaba %q", content, cfg.ShowHidden, expected)
+		}
+	}
+
+	os.WriteFile("config.yaml", []byte("show_hidden: a veces\n"), 0644)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Se esperaba un error con un valor desconocido")
+	}
+}
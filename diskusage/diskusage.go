@@ -0,0 +1,206 @@
+package diskusage
+
+import (
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxLargestFiles is the number of largest files kept after each scan
+const maxLargestFiles = 100
+
+// Entry contains the disk usage of a file or directory
+type Entry struct {
+	Path  string // Path relative to the scanned root, using forward slashes
+	Size  int64  // Size in bytes (recursive for directories)
+	Files int    // Number of files (recursive for directories)
+	IsDir bool
+}
+
+// Scanner calculates the recursive size of every directory under a root
+// in the background and caches the results between scans
+type Scanner struct {
+	root string
+
+	mu      sync.RWMutex
+	dirs    map[string]Entry
+	largest []Entry
+	scanned time.Time
+
+	stop chan struct{}
+}
+
+// NewScanner returns a scanner for the given root directory
+func NewScanner(root string) *Scanner {
+	return &Scanner{root: root}
+}
+
+// Start scans the root directory now and then every interval until Stop
+// is called. With an interval of zero the directory is only scanned once.
+func (s *Scanner) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+
+	go func() {
+		for {
+			if err := s.Scan(); err != nil {
+				log.Printf("Error scanning disk usage: %v", err)
+			}
+
+			if interval <= 0 {
+				return
+			}
+
+			select {
+			case <-time.After(interval):
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background scans
+func (s *Scanner) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+	}
+}
+
+// parent returns the parent of a relative path ("" for the root)
+func parent(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// Scan walks the root directory and replaces the cached results
+func (s *Scanner) Scan() error {
+	dirs := map[string]*Entry{}
+	var files []Entry
+
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip what can't be read instead of aborting the whole scan
+			if d != nil && d.IsDir() && p != s.root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		if d.IsDir() {
+			dirs[rel] = &Entry{Path: rel, IsDir: true}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		files = append(files, Entry{Path: rel, Size: info.Size(), Files: 1})
+
+		// Add the file to every directory that contains it
+		for dir := parent(rel); ; dir = parent(dir) {
+			if entry, ok := dirs[dir]; ok {
+				entry.Size += info.Size()
+				entry.Files++
+			}
+			if dir == "" {
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Size > files[j].Size
+	})
+	if len(files) > maxLargestFiles {
+		files = files[:maxLargestFiles]
+	}
+
+	results := make(map[string]Entry, len(dirs))
+	for rel, entry := range dirs {
+		results[rel] = *entry
+	}
+
+	s.mu.Lock()
+	s.dirs = results
+	s.largest = files
+	s.scanned = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Dir returns the usage of a directory from the last scan
+func (s *Scanner) Dir(rel string) (Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.dirs[filepath.ToSlash(filepath.Clean("/" + rel))[1:]]
+	return entry, ok
+}
+
+// LargestDirs returns the n largest directories of the last scan,
+// not counting the root
+func (s *Scanner) LargestDirs(n int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dirs []Entry
+	for rel, entry := range s.dirs {
+		if rel != "" {
+			dirs = append(dirs, entry)
+		}
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Size == dirs[j].Size {
+			return dirs[i].Path < dirs[j].Path
+		}
+		return dirs[i].Size > dirs[j].Size
+	})
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs
+}
+
+// LargestFiles returns the n largest files of the last scan
+func (s *Scanner) LargestFiles(n int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.largest) < n {
+		n = len(s.largest)
+	}
+	return append([]Entry(nil), s.largest[:n]...)
+}
+
+// LastScan returns when the last scan finished (zero if none has)
+func (s *Scanner) LastScan() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.scanned
+}
@@ -0,0 +1,113 @@
+package diskusage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createFile crea un archivo con el tamaño indicado
+func createFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatalf("No se pudo crear archivo: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	createFile(t, filepath.Join(root, "raiz.txt"), 10)
+	createFile(t, filepath.Join(root, "fotos", "a.jpg"), 100)
+	createFile(t, filepath.Join(root, "fotos", "2024", "b.jpg"), 1000)
+	createFile(t, filepath.Join(root, "docs", "c.txt"), 50)
+	if err := os.Mkdir(filepath.Join(root, "vacio"), 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio: %v", err)
+	}
+
+	scanner := NewScanner(root)
+
+	// Antes del primer escaneo no hay resultados
+	if _, ok := scanner.Dir("fotos"); ok {
+		t.Error("Dir() no debería devolver resultados antes de escanear")
+	}
+
+	if err := scanner.Scan(); err != nil {
+		t.Fatalf("Scan() devolvió error: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		size  int64
+		files int
+	}{
+		{"", 1160, 4},
+		{"fotos", 1100, 2},
+		{"fotos/2024", 1000, 1},
+		{"fotos/2024/", 1000, 1},
+		{"docs", 50, 1},
+		{"vacio", 0, 0},
+	}
+	for _, tc := range tests {
+		entry, ok := scanner.Dir(tc.path)
+		if !ok {
+			t.Errorf("Dir(%q) no encontró el directorio", tc.path)
+			continue
+		}
+		if entry.Size != tc.size || entry.Files != tc.files {
+			t.Errorf("Dir(%q) = %d bytes, %d archivos, quería %d bytes, %d archivos",
+				tc.path, entry.Size, entry.Files, tc.size, tc.files)
+		}
+	}
+
+	dirs := scanner.LargestDirs(2)
+	if len(dirs) != 2 || dirs[0].Path != "fotos" || dirs[1].Path != "fotos/2024" {
+		t.Errorf("LargestDirs(2) = %+v, quería fotos y fotos/2024", dirs)
+	}
+
+	files := scanner.LargestFiles(10)
+	if len(files) != 4 || files[0].Path != "fotos/2024/b.jpg" {
+		t.Errorf("LargestFiles(10) = %+v, quería 4 archivos empezando por fotos/2024/b.jpg", files)
+	}
+
+	if scanner.LastScan().IsZero() {
+		t.Error("LastScan() no debería ser cero después de escanear")
+	}
+}
+
+func TestStart(t *testing.T) {
+	root := t.TempDir()
+	createFile(t, filepath.Join(root, "a.txt"), 5)
+
+	scanner := NewScanner(root)
+	scanner.Start(time.Hour)
+	defer scanner.Stop()
+
+	// El primer escaneo se hace inmediatamente
+	deadline := time.Now().Add(2 * time.Second)
+	for scanner.LastScan().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if entry, ok := scanner.Dir(""); !ok || entry.Size != 5 {
+		t.Errorf("Dir(\"\") = %+v, %v, quería 5 bytes", entry, ok)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	total, free, err := FreeSpace(t.TempDir())
+	if err != nil {
+		t.Skipf("FreeSpace() no soportado: %v", err)
+	}
+
+	if total == 0 {
+		t.Error("el tamaño total del sistema de archivos no debería ser cero")
+	}
+	if free > total {
+		t.Errorf("espacio libre (%d) mayor que el total (%d)", free, total)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package diskusage
+
+import "errors"
+
+// FreeSpace is not supported on this platform
+func FreeSpace(path string) (total uint64, free uint64, err error) {
+	return 0, 0, errors.New("free space reporting is not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package diskusage
+
+import "syscall"
+
+// FreeSpace returns the total size and the space available to the current
+// user of the filesystem that contains path
+func FreeSpace(path string) (total uint64, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	blockSize := uint64(stat.Bsize)
+	return stat.Blocks * blockSize, stat.Bavail * blockSize, nil
+}
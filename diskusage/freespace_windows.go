@@ -0,0 +1,32 @@
+//go:build windows
+
+package diskusage
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// FreeSpace returns the total size and the space available to the current
+// user of the filesystem that contains path
+func FreeSpace(path string) (total uint64, free uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var available, totalBytes, totalFree uint64
+	r, _, callErr := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return 0, 0, callErr
+	}
+
+	return totalBytes, available, nil
+}
@@ -0,0 +1,288 @@
+// Package e2e implements the format of the files encrypted end to end. They
+// are encrypted before the upload with a random key that the server never
+// sees, because it's only kept in the fragment of the share link, and they
+// are decrypted after the download. The upload and download pages of the
+// web interface implement the same format in JavaScript.
+//
+// A file starts with the magic string and the length of its metadata (name,
+// type and size of the original file) as a big-endian uint32, followed by
+// the sealed metadata and the content in chunks of ChunkSize bytes. Every
+// part is sealed with AES-256-GCM and the magic string as additional data.
+// The nonce is the big-endian index of the chunk followed by a flag for the
+// last chunk or the metadata, so parts can't be reordered, dropped or
+// appended without being detected.
+package e2e
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Ext is the extension of the uploaded files
+	Ext = ".e2e"
+	// ChunkSize is the size of the chunks of the content
+	ChunkSize = 64 << 10
+	// KeySize is the size of the keys
+	KeySize = 32
+
+	magic     = "SICE2E01"
+	nonceSize = 12
+	tagSize   = 16
+
+	// Flags in the last byte of the nonce
+	flagChunk    = 0
+	flagLast     = 1
+	flagMetadata = 2
+
+	// maxMetadata limits the size of the metadata
+	maxMetadata = 64 << 10
+)
+
+// ErrDamaged is returned when a file can't be decrypted, because it was
+// changed or the key is wrong
+var ErrDamaged = errors.New("e2e: the file is damaged or the key is wrong")
+
+// Metadata describes the original file
+type Metadata struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // MIME type
+	Size int64  `json:"size"`
+}
+
+// NewKey returns a random key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewName returns a random name for an uploaded file, so that the server
+// doesn't see the original one
+func NewName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + Ext, nil
+}
+
+// EncodeKey encodes a key for the fragment of a link
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes a key encoded with EncodeKey
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, errors.New("e2e: invalid key")
+	}
+	return key, nil
+}
+
+// Link returns the share link of an uploaded file: the download page of the
+// server with the path of the file and the key in the fragment, which
+// browsers don't send to the server
+func Link(server, p string, key []byte) string {
+	return strings.TrimSuffix(server, "/") + "/e2e?filename=" + url.QueryEscape(p) + "#" + EncodeKey(key)
+}
+
+// ParseLink returns the server, the path of the file and the key of a share
+// link
+func ParseLink(link string) (server, p string, key []byte, err error) {
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasSuffix(u.Path, "/e2e") || u.Query().Get("filename") == "" {
+		return "", "", nil, fmt.Errorf("e2e: invalid share link: %s", link)
+	}
+	if key, err = DecodeKey(u.Fragment); err != nil {
+		return "", "", nil, err
+	}
+	server = u.Scheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/e2e")
+	return server, u.Query().Get("filename"), key, nil
+}
+
+// nonce returns the nonce of a part
+func nonce(index uint64, flag byte) []byte {
+	n := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(n, index)
+	n[nonceSize-1] = flag
+	return n
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("e2e: invalid key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Writer encrypts a file
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	plain []byte
+	index uint64
+}
+
+// NewWriter writes the header of a file encrypted with key to w and returns
+// a writer for its content. It must be closed to write the last chunk.
+func NewWriter(w io.Writer, key []byte, meta Metadata) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nil, nonce(0, flagMetadata), data, []byte(magic))
+	header := binary.BigEndian.AppendUint32([]byte(magic), uint32(len(sealed)))
+	if _, err := w.Write(append(header, sealed...)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, plain: make([]byte, 0, ChunkSize)}, nil
+}
+
+// seal writes the pending chunk
+func (w *Writer) seal(flag byte) error {
+	sealed := w.aead.Seal(nil, nonce(w.index, flag), w.plain, []byte(magic))
+	if _, err := w.w.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.plain = w.plain[:0]
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is kept until more content arrives, because the
+		// last chunk is sealed with another flag
+		if len(w.plain) == ChunkSize {
+			if err := w.seal(flagChunk); err != nil {
+				return written, err
+			}
+		}
+		n := min(ChunkSize-len(w.plain), len(p))
+		w.plain = append(w.plain, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	return w.seal(flagLast)
+}
+
+// Reader decrypts a file
+type Reader struct {
+	// Metadata describes the original file
+	Metadata Metadata
+
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	plain []byte
+	index uint64
+	done  bool
+}
+
+// NewReader reads the header of a file encrypted with key from r and
+// returns a reader for its content. Reads fail with ErrDamaged if the
+// content was changed.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(r, ChunkSize+tagSize)
+
+	header := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, ErrDamaged
+	}
+	size := binary.BigEndian.Uint32(header[len(magic):])
+	if size < tagSize || size > maxMetadata {
+		return nil, ErrDamaged
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(br, sealed); err != nil {
+		return nil, ErrDamaged
+	}
+	data, err := aead.Open(nil, nonce(0, flagMetadata), sealed, []byte(magic))
+	if err != nil {
+		return nil, ErrDamaged
+	}
+
+	reader := &Reader{r: br, aead: aead}
+	if err := json.Unmarshal(data, &reader.Metadata); err != nil {
+		return nil, ErrDamaged
+	}
+	return reader, nil
+}
+
+// next decrypts the next chunk. The last one is shorter than a full chunk
+// or followed by the end of the file.
+func (r *Reader) next() error {
+	sealed := make([]byte, ChunkSize+tagSize)
+	n, err := io.ReadFull(r.r, sealed)
+	switch {
+	case err == io.EOF:
+		// The last chunk is missing
+		return ErrDamaged
+	case err == io.ErrUnexpectedEOF:
+		r.done = true
+	case err != nil:
+		return err
+	default:
+		_, err := r.r.Peek(1)
+		r.done = err == io.EOF
+	}
+
+	flag := byte(flagChunk)
+	if r.done {
+		flag = flagLast
+	}
+	plain, err := r.aead.Open(r.plain[:0], nonce(r.index, flag), sealed[:n], []byte(magic))
+	if err != nil {
+		return ErrDamaged
+	}
+	r.plain = plain
+	r.index++
+	return nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
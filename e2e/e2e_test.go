@@ -0,0 +1,120 @@
+package e2e
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// encrypt cifra un contenido en memoria
+func encrypt(t *testing.T, key, content []byte, meta Metadata) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key, meta)
+	if err != nil {
+		t.Fatalf("NewWriter() devolvió error: %v", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatalf("Write() devolvió error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() devolvió error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// decrypt descifra un contenido en memoria
+func decrypt(key, blob []byte) (*Reader, []byte, error) {
+	r, err := NewReader(bytes.NewReader(blob), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(r)
+	return r, data, err
+}
+
+func TestRoundTrip(t *testing.T) {
+	key, _ := NewKey()
+	// Tamaños alrededor del tamaño de los fragmentos
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 7} {
+		content := bytes.Repeat([]byte("x"), size)
+		meta := Metadata{Name: "informe.pdf", Type: "application/pdf", Size: int64(size)}
+		blob := encrypt(t, key, content, meta)
+
+		if size > 0 && bytes.Contains(blob, content[:min(size, 64)]) {
+			t.Errorf("%d bytes: el contenido no se cifró", size)
+		}
+		if bytes.Contains(blob, []byte("informe")) {
+			t.Errorf("%d bytes: el nombre no se cifró", size)
+		}
+
+		r, data, err := decrypt(key, blob)
+		if err != nil {
+			t.Fatalf("%d bytes: error al descifrar: %v", size, err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%d bytes: contenido incorrecto (%d bytes)", size, len(data))
+		}
+		if r.Metadata != meta {
+			t.Errorf("%d bytes: metadatos incorrectos: %+v", size, r.Metadata)
+		}
+	}
+}
+
+func TestDamaged(t *testing.T) {
+	key, _ := NewKey()
+	content := bytes.Repeat([]byte("abc"), ChunkSize)
+	blob := encrypt(t, key, content, Metadata{Name: "a.txt", Size: int64(len(content))})
+
+	other, _ := NewKey()
+	if _, _, err := decrypt(other, blob); !errors.Is(err, ErrDamaged) {
+		t.Errorf("Descifrar con otra clave devolvió %v", err)
+	}
+
+	changed := bytes.Clone(blob)
+	changed[len(changed)-1] ^= 1
+	if _, _, err := decrypt(key, changed); !errors.Is(err, ErrDamaged) {
+		t.Errorf("Descifrar un archivo modificado devolvió %v", err)
+	}
+
+	// Quitar el último fragmento deja un fragmento completo que no está
+	// marcado como el último
+	header := len(magic) + 4 + int(len(`{"name":"a.txt","size":196608}`)) + tagSize
+	truncated := blob[:header+2*(ChunkSize+tagSize)]
+	if _, _, err := decrypt(key, truncated); !errors.Is(err, ErrDamaged) {
+		t.Errorf("Descifrar un archivo truncado devolvió %v", err)
+	}
+	if _, _, err := decrypt(key, blob[:header]); !errors.Is(err, ErrDamaged) {
+		t.Errorf("Descifrar un archivo sin contenido devolvió %v", err)
+	}
+	if _, _, err := decrypt(key, []byte("no cifrado")); !errors.Is(err, ErrDamaged) {
+		t.Errorf("Descifrar un archivo sin cifrar devolvió %v", err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	key, _ := NewKey()
+	link := Link("http://localhost:8080/", "docs/x y.e2e", key)
+	if want := "http://localhost:8080/e2e?filename=docs%2Fx+y.e2e#" + EncodeKey(key); link != want {
+		t.Errorf("Link() = %s, quería %s", link, want)
+	}
+
+	server, p, parsed, err := ParseLink(link)
+	if err != nil {
+		t.Fatalf("ParseLink() devolvió error: %v", err)
+	}
+	if server != "http://localhost:8080" || p != "docs/x y.e2e" || !bytes.Equal(parsed, key) {
+		t.Errorf("ParseLink() = %s, %s, %x", server, p, parsed)
+	}
+
+	for _, invalid := range []string{
+		"http://localhost:8080/download?filename=a.e2e#" + EncodeKey(key),
+		"http://localhost:8080/e2e?filename=a.e2e",
+		"http://localhost:8080/e2e?filename=a.e2e#corta",
+		"http://localhost:8080/e2e#" + EncodeKey(key),
+	} {
+		if _, _, _, err := ParseLink(invalid); err == nil {
+			t.Errorf("ParseLink(%s) debería fallar", invalid)
+		}
+	}
+}
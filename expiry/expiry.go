@@ -0,0 +1,177 @@
+// Package expiry keeps when the uploaded files expire, and deletes them
+// once they have.
+package expiry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// entry is the expiration of a file
+type entry struct {
+	Expires time.Time `json:"expires"`
+	ModTime time.Time `json:"mod_time"` // Modification time of the uploaded file
+}
+
+// Store keeps the expiration of the uploaded files in a JSON file. An
+// expiration is only valid while the file keeps its modification time, so
+// a file replaced by other means is never deleted.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]entry // By name in the storage
+}
+
+// Open loads the expirations saved in path. A missing file is an empty
+// store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, entries: map[string]entry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("error reading expiration file: %v", err)
+	}
+	return s, nil
+}
+
+// save writes the expirations to disk. The caller must hold the lock.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("error creating expiration directory: %v", err)
+	}
+
+	// Write to a temporary file so the expirations are never left half
+	// written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".expiry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Set records when a file expires. modTime is its modification time once
+// uploaded.
+func (s *Store) Set(name string, expires, modTime time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.entries[name]
+	s.entries[name] = entry{Expires: expires.UTC(), ModTime: modTime.UTC()}
+	if err := s.save(); err != nil {
+		if ok {
+			s.entries[name] = previous
+		} else {
+			delete(s.entries, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Expires returns when a file with the given modification time expires
+func (s *Store) Expires(name string, modTime time.Time) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[name]
+	if !ok || !e.ModTime.Equal(modTime) {
+		return time.Time{}, false
+	}
+	return e.Expires, true
+}
+
+// Forget removes the expiration of files that were deleted or replaced
+func (s *Store) Forget(names ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, name := range names {
+		if _, ok := s.entries[name]; ok {
+			delete(s.entries, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Rename keeps the expiration of a moved file, or of the files of a moved
+// directory
+func (s *Store) Rename(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for name, e := range s.entries {
+		rest, ok := strings.CutPrefix(name, oldName)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		delete(s.entries, name)
+		s.entries[newName+rest] = e
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Expired returns the names of the files that have expired at the given
+// time, sorted
+func (s *Store) Expired(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{}
+	for name, e := range s.entries {
+		if !now.Before(e.Expires) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultPath returns the file used to store the expirations when none is
+// configured
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "shareiscare", "expiry.json")
+}
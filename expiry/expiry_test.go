@@ -0,0 +1,108 @@
+package expiry
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expiry.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error abriendo un almacén nuevo: %v", err)
+	}
+
+	now := time.Now()
+	modTime := now.Add(-time.Minute)
+	s.Set("viejo.txt", now.Add(-time.Second), modTime)
+	s.Set("fotos/a.jpg", now.Add(time.Hour), modTime)
+	s.Set("fotos/b.jpg", now.Add(-time.Hour), modTime)
+
+	if expired := s.Expired(now); !reflect.DeepEqual(expired, []string{"fotos/b.jpg", "viejo.txt"}) {
+		t.Errorf("Archivos caducados incorrectos: %v", expired)
+	}
+
+	// La caducidad solo vale mientras el archivo no cambie
+	if expires, ok := s.Expires("fotos/a.jpg", modTime); !ok || !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("Caducidad incorrecta: %v %v", expires, ok)
+	}
+	if _, ok := s.Expires("fotos/a.jpg", now); ok {
+		t.Error("Un archivo reemplazado no debería caducar")
+	}
+
+	// Mover una carpeta mantiene la caducidad de sus archivos
+	s.Rename("fotos", "viaje")
+	s.Forget("viejo.txt")
+
+	// La caducidad se conserva al reabrir
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Error reabriendo: %v", err)
+	}
+	if expired := s.Expired(now.Add(2 * time.Hour)); !reflect.DeepEqual(expired, []string{"viaje/a.jpg", "viaje/b.jpg"}) {
+		t.Errorf("Archivos caducados incorrectos tras reabrir: %v", expired)
+	}
+	if _, ok := s.Expires("viaje/a.jpg", modTime); !ok {
+		t.Error("El archivo movido debería conservar su caducidad")
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "expiry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := storage.NewMemory()
+
+	set := func(name string, expires time.Time) {
+		t.Helper()
+		if err := storage.WriteFile(files, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := files.Stat(name)
+		if err := s.Set(name, expires, info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	set("caducado.txt", now.Add(-time.Minute))
+	set("vigente.txt", now.Add(time.Hour))
+	set("borrado.txt", now.Add(-time.Minute))
+	set("reemplazado.txt", now.Add(-time.Minute))
+	files.Remove("borrado.txt")
+	time.Sleep(time.Millisecond)
+	storage.WriteFile(files, "reemplazado.txt", []byte("nuevo contenido"))
+
+	j := NewJanitor(s, files)
+	if deleted := j.Clean(now); !reflect.DeepEqual(deleted, []string{"caducado.txt"}) {
+		t.Errorf("Archivos borrados incorrectos: %v", deleted)
+	}
+	if _, err := files.Stat("caducado.txt"); err == nil {
+		t.Error("El archivo caducado debería haberse borrado")
+	}
+	for _, name := range []string{"vigente.txt", "reemplazado.txt"} {
+		if _, err := files.Stat(name); err != nil {
+			t.Errorf("%s no debería haberse borrado", name)
+		}
+	}
+
+	// Los archivos que ya no existen o cambiaron se olvidan
+	if expired := s.Expired(now); len(expired) != 0 {
+		t.Errorf("Deberían olvidarse los archivos ya tratados: %v", expired)
+	}
+
+	// Un archivo que no se puede borrar también se olvida
+	set("protegido.txt", now.Add(-time.Minute))
+	j = NewJanitor(s, storage.ReadOnly(files))
+	if deleted := j.Clean(now); len(deleted) != 0 {
+		t.Errorf("No debería borrarse nada: %v", deleted)
+	}
+	if expired := s.Expired(now); len(expired) != 0 {
+		t.Errorf("Debería olvidarse el archivo protegido: %v", expired)
+	}
+}
@@ -0,0 +1,87 @@
+package expiry
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"time"
+
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// Janitor deletes the expired files of a storage in the background
+type Janitor struct {
+	store *Store
+	files storage.Storage
+	stop  chan struct{}
+}
+
+// NewJanitor returns a janitor for the files of a storage whose expiration
+// is kept in store
+func NewJanitor(store *Store, files storage.Storage) *Janitor {
+	return &Janitor{store: store, files: files}
+}
+
+// Start deletes the expired files now and then every interval
+func (j *Janitor) Start(interval time.Duration) {
+	j.stop = make(chan struct{})
+
+	go func() {
+		for {
+			j.Clean(time.Now())
+
+			select {
+			case <-time.After(interval):
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background cleanups
+func (j *Janitor) Stop() {
+	if j.stop != nil {
+		close(j.stop)
+	}
+}
+
+// Clean deletes the files that have expired at the given time and returns
+// their names. Files that were deleted or replaced since they were uploaded
+// are only forgotten.
+func (j *Janitor) Clean(now time.Time) []string {
+	deleted := []string{}
+	var forget []string
+
+	for _, name := range j.store.Expired(now) {
+		info, err := j.files.Stat(name)
+		if err != nil || info.IsDir() {
+			forget = append(forget, name)
+			continue
+		}
+		if _, ok := j.store.Expires(name, info.ModTime().UTC()); !ok {
+			forget = append(forget, name)
+			continue
+		}
+
+		err = j.files.Remove(name)
+		switch {
+		case err == nil:
+			log.Printf("Deleted expired file %s", name)
+			deleted = append(deleted, name)
+			forget = append(forget, name)
+		case errors.Is(err, fs.ErrPermission):
+			// E.g. in a read-only share, trying again won't help
+			log.Printf("Expired file %s can't be deleted: %v", name, err)
+			forget = append(forget, name)
+		default:
+			// Tried again in the next cleanup
+			log.Printf("Error deleting expired file %s: %v", name, err)
+		}
+	}
+
+	if err := j.store.Forget(forget...); err != nil {
+		log.Printf("Error saving the expiration of the uploaded files: %v", err)
+	}
+	return deleted
+}
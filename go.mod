@@ -4,4 +4,18 @@ go 1.24.0
 
 require gopkg.in/yaml.v3 v3.0.1
 
-require github.com/a-h/templ v0.3.857
+require (
+	github.com/a-h/templ v0.3.857
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/pkg/sftp v1.13.10
+	golang.org/x/crypto v0.41.0
+	golang.org/x/net v0.42.0
+	golang.org/x/term v0.34.0
+	lukechampine.com/blake3 v1.4.1
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+)
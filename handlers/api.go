@@ -0,0 +1,645 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rodrwan/shareiscare/checksum"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// apiFile is a file or directory in the responses of the JSON API
+type apiFile struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	IsDir    bool      `json:"is_dir"`
+	Type     string    `json:"type"`
+}
+
+// apiListing is the response of the list endpoint
+type apiListing struct {
+	Path  string    `json:"path"`
+	Files []apiFile `json:"files"`
+}
+
+// apiUploadResult is the response of the upload endpoint
+type apiUploadResult struct {
+	Files  []apiFile `json:"files"`
+	Errors []string  `json:"errors,omitempty"`
+}
+
+// apiChecksumResult is the response of the checksum endpoint
+type apiChecksumResult struct {
+	Path     string `json:"path"`
+	Algo     string `json:"algo"`
+	Checksum string `json:"checksum"`
+}
+
+// apiErrorBody is the body of every error response of the JSON API
+type apiErrorBody struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// apiRoute maps the methods of an API endpoint to their handlers
+type apiRoute map[string]http.HandlerFunc
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding API response: %v", err)
+	}
+}
+
+// apiError writes an error response of the JSON API
+func apiError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiErrorBody{Status: status, Error: message})
+}
+
+// apiPath normalizes a path given to the API, relative to the root directory.
+// Paths outside the root keep their ".." so that apiResolve rejects them.
+func apiPath(p string) string {
+	p = path.Clean(strings.Trim(filepath.ToSlash(p), "/"))
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+// apiResolve validates a path given to the API and returns its name in the
+// storage. If it isn't valid or can't be accessed, an error response is
+// written to w.
+func apiResolve(w http.ResponseWriter, r *http.Request, config *config.Config, p string) (string, bool) {
+	name, err := cleanPath(config, p)
+	if err != nil {
+		apiError(w, err.status, err.message)
+		return "", false
+	}
+	if !canAccess(r, config, name) {
+		apiError(w, http.StatusUnauthorized, "Authentication required")
+		return "", false
+	}
+	if !visible(r, config, name) {
+		apiError(w, http.StatusNotFound, "File not found")
+		return "", false
+	}
+	return name, true
+}
+
+// newAPIFile builds the API representation of a file
+func newAPIFile(relPath string, info fs.FileInfo) apiFile {
+	fileType := "directory"
+	if !info.IsDir() {
+		fileType = string(getFileType(info.Name()))
+	}
+
+	return apiFile{
+		Name:     info.Name(),
+		Path:     relPath,
+		Size:     info.Size(),
+		Modified: info.ModTime().UTC(),
+		IsDir:    info.IsDir(),
+		Type:     fileType,
+	}
+}
+
+// apiRequireAuth is the JSON API version of RequireAuth
+func apiRequireAuth(next http.HandlerFunc, config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r, config) == "" {
+			if bearerToken(r) != "" {
+				apiError(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+			apiError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiRequireScope is the JSON API version of RequireScope
+func apiRequireScope(scope string, next http.HandlerFunc, config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken(r) == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := requestToken(r, config)
+		if !ok {
+			apiError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		if !token.HasScope(scope) {
+			apiError(w, http.StatusForbidden, "The token doesn't have the "+scope+" scope")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiRequireAdmin is the JSON API version of RequireAdmin
+func apiRequireAdmin(next http.HandlerFunc, config *config.Config) http.HandlerFunc {
+	return apiRequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r, config) != config.Username {
+			apiError(w, http.StatusForbidden, "Admin access required")
+			return
+		}
+		next(w, r)
+	}, config)
+}
+
+// apiRoutes returns the endpoints of the JSON API
+func apiRoutes(config *config.Config) map[string]apiRoute {
+	return map[string]apiRoute{
+		"/api/v1/files": {
+			http.MethodGet:    apiRequireScope(tokens.Read, apiList(config), config),
+			http.MethodDelete: apiRequireAdmin(apiRequireScope(tokens.Delete, apiDelete(config), config), config),
+		},
+		"/api/v1/stat": {
+			http.MethodGet: apiRequireScope(tokens.Read, apiStat(config), config),
+		},
+		"/api/v1/checksum": {
+			http.MethodGet: apiRequireScope(tokens.Read, apiChecksum(config), config),
+		},
+		"/api/v1/download": {
+			http.MethodGet: apiRequireScope(tokens.Read, apiDownload(config), config),
+		},
+		"/api/v1/upload": {
+			http.MethodPost: apiRequireAuth(apiRequireScope(tokens.Upload, apiUpload(config), config), config),
+		},
+		"/api/v1/mkdir": {
+			http.MethodPost: apiRequireAuth(apiRequireScope(tokens.Upload, apiMkdir(config), config), config),
+		},
+		"/api/v1/move": {
+			http.MethodPost: apiRequireAdmin(apiRequireScope(tokens.Delete, apiMove(config), config), config),
+		},
+	}
+}
+
+// API serves the JSON API under /api/v1
+func API(config *config.Config) http.HandlerFunc {
+	routes := apiRoutes(config)
+
+	// Only the endpoints that read files are available when nothing can be changed
+	if ReadOnly(config) {
+		for _, route := range routes {
+			for method := range route {
+				if method != http.MethodGet {
+					delete(route, method)
+				}
+			}
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routes[strings.TrimSuffix(r.URL.Path, "/")]
+		if !ok {
+			apiError(w, http.StatusNotFound, "Endpoint not found")
+			return
+		}
+
+		handler, ok := route[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			handler, ok = route[http.MethodGet]
+		}
+		if !ok {
+			var methods []string
+			for method := range route {
+				methods = append(methods, method)
+			}
+			sort.Strings(methods)
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			apiError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// OpenAPI serves the OpenAPI 3 document that describes the JSON API
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// apiList lists the files of a directory
+func apiList(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dir := apiPath(r.URL.Query().Get("path"))
+
+		name, ok := apiResolve(w, r, config, dir)
+		if !ok {
+			return
+		}
+		store := fileStorage(config)
+
+		fileInfo, err := store.Stat(name)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "Path not found")
+			return
+		}
+		if !fileInfo.IsDir() {
+			apiError(w, http.StatusBadRequest, "Not a directory")
+			return
+		}
+
+		entries, err := store.ReadDir(name)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "Error reading directory")
+			return
+		}
+
+		listing := apiListing{Path: dir, Files: []apiFile{}}
+		for _, info := range entries {
+			// Filter excluded files, dotfiles and private shares
+			child := path.Join(name, info.Name())
+			if excluded(config, child, info.IsDir()) || !visible(r, config, info.Name()) || !canAccess(r, config, child) {
+				continue
+			}
+
+			listing.Files = append(listing.Files, newAPIFile(path.Join(dir, info.Name()), info))
+		}
+
+		writeJSON(w, http.StatusOK, listing)
+	}
+}
+
+// apiStat returns the information of a single file or directory
+func apiStat(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := apiPath(r.URL.Query().Get("path"))
+
+		if _, ok := apiResolve(w, r, config, name); !ok {
+			return
+		}
+
+		info, err := fileStorage(config).Stat(name)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "File not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newAPIFile(name, info))
+	}
+}
+
+// apiChecksum returns the checksum of a file
+func apiChecksum(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := apiPath(r.URL.Query().Get("path"))
+		if name == "" {
+			apiError(w, http.StatusBadRequest, "Path is required")
+			return
+		}
+
+		algo := r.URL.Query().Get("algo")
+		if algo == "" {
+			algo = checksum.SHA256
+		}
+		if _, err := checksum.NewHash(algo); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if _, ok := apiResolve(w, r, config, name); !ok {
+			return
+		}
+
+		info, err := fileStorage(config).Stat(name)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		if info.IsDir() {
+			apiError(w, http.StatusBadRequest, "Cannot calculate the checksum of a directory")
+			return
+		}
+
+		sum, err := fileChecksum(config, name, info, algo)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "Error calculating checksum")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, apiChecksumResult{Path: name, Algo: algo, Checksum: sum})
+	}
+}
+
+// apiDownload returns the content of a file, supporting range requests
+func apiDownload(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := apiPath(r.URL.Query().Get("path"))
+		if name == "" {
+			apiError(w, http.StatusBadRequest, "Path is required")
+			return
+		}
+
+		if _, ok := apiResolve(w, r, config, name); !ok {
+			return
+		}
+
+		file, err := fileStorage(config).Open(name)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "Error reading file")
+			return
+		}
+		if info.IsDir() {
+			apiError(w, http.StatusBadRequest, "Cannot download a directory")
+			return
+		}
+
+		if digest, ok := downloadDigest(config, name, info); ok {
+			w.Header().Set("Digest", digest)
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+		w.Header().Set("Content-Type", "application/octet-stream")
+
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	}
+}
+
+// apiUpload stores the files of a multipart form in a directory
+func apiUpload(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dir := apiPath(r.URL.Query().Get("path"))
+
+		if apiRejectHidden(w, r, config, dir) {
+			return
+		}
+		if _, ok := apiResolve(w, r, config, dir); !ok {
+			return
+		}
+		if apiRejectReadOnly(w, config, dir) {
+			return
+		}
+		store := fileStorage(config)
+
+		fileInfo, err := store.Stat(dir)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "Path not found")
+			return
+		}
+		if !fileInfo.IsDir() {
+			apiError(w, http.StatusBadRequest, "Not a directory")
+			return
+		}
+
+		username := currentUser(r, config)
+		form, err := receiveForm(r, config, username, func(url.Values) (string, error) {
+			return dir, nil
+		})
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if form.files == 0 {
+			apiError(w, http.StatusBadRequest, "No files have been sent")
+			return
+		}
+
+		// Lifetime of the files (optional)
+		lifetime, err := parseLifetime(form.fields.Get("expires"))
+		if err != nil {
+			form.discard()
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		result := apiUploadResult{Files: []apiFile{}}
+		for _, upload := range form.commit(config, lifetime) {
+			info, err := store.Stat(upload.name)
+			if err != nil {
+				continue
+			}
+			result.Files = append(result.Files, newAPIFile(upload.name, info))
+		}
+
+		status := http.StatusBadRequest
+		for _, err := range form.errors {
+			result.Errors = append(result.Errors, err.Error())
+			if errors.Is(err, fs.ErrPermission) {
+				status = http.StatusForbidden
+			} else if quotaExceeded(err) {
+				status = http.StatusRequestEntityTooLarge
+			}
+		}
+
+		if len(result.Files) == 0 {
+			apiError(w, status, strings.Join(result.Errors, "; "))
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, result)
+	}
+}
+
+// apiDelete deletes a file or directory. Directories must be empty unless
+// recursive=true is given.
+func apiDelete(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := apiPath(r.URL.Query().Get("path"))
+		if name == "" {
+			apiError(w, http.StatusBadRequest, "Path is required")
+			return
+		}
+
+		if _, ok := apiResolve(w, r, config, name); !ok {
+			return
+		}
+		if apiRejectReadOnly(w, config, name) {
+			return
+		}
+		store := fileStorage(config)
+
+		info, err := store.Stat(name)
+		if err != nil {
+			apiError(w, http.StatusNotFound, "File not found")
+			return
+		}
+
+		if info.IsDir() && r.URL.Query().Get("recursive") == "true" {
+			err = store.RemoveAll(name)
+		} else {
+			err = store.Remove(name)
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			apiError(w, http.StatusForbidden, "The file can't be deleted")
+			return
+		}
+		if err != nil {
+			if info.IsDir() {
+				apiError(w, http.StatusConflict, "Directory is not empty")
+				return
+			}
+			apiError(w, http.StatusInternalServerError, "Error deleting file")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// apiMkdir creates a directory, including any missing parents
+func apiMkdir(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Path string `json:"path"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apiError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		name := apiPath(request.Path)
+		if name == "" {
+			apiError(w, http.StatusBadRequest, "Path is required")
+			return
+		}
+
+		if apiRejectHidden(w, r, config, name) {
+			return
+		}
+		if _, ok := apiResolve(w, r, config, name); !ok {
+			return
+		}
+		if apiRejectReadOnly(w, config, name) {
+			return
+		}
+		store := fileStorage(config)
+
+		if _, err := store.Stat(name); err == nil {
+			apiError(w, http.StatusConflict, "File already exists")
+			return
+		}
+
+		if err := store.MkdirAll(name); err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				apiError(w, http.StatusForbidden, "The directory can't be created")
+				return
+			}
+			apiError(w, http.StatusInternalServerError, "Error creating directory")
+			return
+		}
+
+		info, err := store.Stat(name)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "Error creating directory")
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, newAPIFile(name, info))
+	}
+}
+
+// apiMove moves or renames a file or directory. Existing files are never
+// overwritten.
+func apiMove(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apiError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		from, to := apiPath(request.From), apiPath(request.To)
+		if from == "" || to == "" {
+			apiError(w, http.StatusBadRequest, "Source and destination are required")
+			return
+		}
+
+		if to == from || strings.HasPrefix(to, from+"/") {
+			apiError(w, http.StatusBadRequest, "Cannot move a directory into itself")
+			return
+		}
+
+		if _, ok := apiResolve(w, r, config, from); !ok {
+			return
+		}
+		if apiRejectHidden(w, r, config, to) {
+			return
+		}
+		if _, ok := apiResolve(w, r, config, to); !ok {
+			return
+		}
+		if apiRejectReadOnly(w, config, from) || apiRejectReadOnly(w, config, to) {
+			return
+		}
+		store := fileStorage(config)
+
+		if _, err := store.Stat(from); err != nil {
+			apiError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		if _, err := store.Stat(to); err == nil {
+			apiError(w, http.StatusConflict, "Destination already exists")
+			return
+		}
+
+		if err := store.Rename(from, to); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				apiError(w, http.StatusNotFound, "Destination directory not found")
+				return
+			}
+			if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EXDEV) {
+				apiError(w, http.StatusForbidden, "The file can't be moved there")
+				return
+			}
+			apiError(w, http.StatusInternalServerError, "Error moving file")
+			return
+		}
+
+		// The moved files keep counting against the quotas of their owners,
+		// and keep their expiration
+		if config.Quota != nil {
+			if err := config.Quota.Rename(from, to); err != nil {
+				log.Printf("Error saving the owners of the uploaded files: %v", err)
+			}
+		}
+		if config.Expirations != nil {
+			if err := config.Expirations.Rename(from, to); err != nil {
+				log.Printf("Error saving the expiration of the uploaded files: %v", err)
+			}
+		}
+
+		info, err := store.Stat(to)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, "Error moving file")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newAPIFile(to, info))
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// sessionCookie crea una cookie de sesión válida para el usuario indicado
+func sessionCookie(cfg *config.Config, username string) *http.Cookie {
+	timestamp := "1617123456"
+	signature := generateSignature(username, timestamp, cfg.SecretKey)
+	return &http.Cookie{
+		Name:  "session",
+		Value: fmt.Sprintf("%s:%s:%s", username, timestamp, signature),
+	}
+}
+
+// apiRequest ejecuta una petición contra la API, opcionalmente autenticada
+func apiRequest(cfg *config.Config, method, target string, body io.Reader, username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	if username != "" {
+		req.AddCookie(sessionCookie(cfg, username))
+	}
+	rr := httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	return rr
+}
+
+// checkAPIError comprueba que la respuesta es un error JSON con el código indicado
+func checkAPIError(t *testing.T, rr *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if rr.Code != status {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v (%s)", rr.Code, status, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type incorrecto: %q", ct)
+	}
+
+	var body apiErrorBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Cuerpo de error inválido: %v", err)
+	}
+	if body.Status != status || body.Error == "" {
+		t.Errorf("Cuerpo de error incorrecto: %+v", body)
+	}
+}
+
+// setupAPIFiles crea una estructura de archivos de prueba
+func setupAPIFiles(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	files := map[string]string{
+		"config.yaml":        "port: 8080",
+		"foto.jpg":           "jpg",
+		"docs/nota.txt":      "hola",
+		"docs/viejos/a.txt":  "a",
+		"docs/viejos/b.json": "{}",
+	}
+	for name, content := range files {
+		writeTestFile(t, cfg, name, content)
+	}
+}
+
+func TestAPIRouting(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/noexiste", nil, ""), http.StatusNotFound)
+
+	rr := apiRequest(cfg, http.MethodPut, "/api/v1/files", nil, "")
+	checkAPIError(t, rr, http.StatusMethodNotAllowed)
+	if allow := rr.Header().Get("Allow"); allow != "DELETE, GET" {
+		t.Errorf("Cabecera Allow incorrecta: %q", allow)
+	}
+}
+
+func TestAPIList(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	rr := apiRequest(cfg, http.MethodGet, "/api/v1/files", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+
+	var listing apiListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if len(listing.Files) != 2 {
+		t.Fatalf("Número de archivos incorrecto: %+v", listing.Files)
+	}
+	if f := listing.Files[0]; f.Name != "docs" || !f.IsDir || f.Type != "directory" {
+		t.Errorf("Directorio incorrecto: %+v", f)
+	}
+	if f := listing.Files[1]; f.Name != "foto.jpg" || f.Size != 3 || f.Type != "image" || f.Modified.IsZero() {
+		t.Errorf("Archivo incorrecto: %+v", f)
+	}
+
+	rr = apiRequest(cfg, http.MethodGet, "/api/v1/files?path=/docs/viejos/", nil, "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if listing.Path != "docs/viejos" || len(listing.Files) != 2 || listing.Files[0].Path != "docs/viejos/a.txt" {
+		t.Errorf("Listado incorrecto: %+v", listing)
+	}
+
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/files?path=../", nil, ""), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/files?path=noexiste", nil, ""), http.StatusNotFound)
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/files?path=foto.jpg", nil, ""), http.StatusBadRequest)
+}
+
+func TestAPIStatAndDownload(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	rr := apiRequest(cfg, http.MethodGet, "/api/v1/stat?path=docs/nota.txt", nil, "")
+	var file apiFile
+	if err := json.Unmarshal(rr.Body.Bytes(), &file); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if file.Path != "docs/nota.txt" || file.Size != 4 || file.Type != "text" {
+		t.Errorf("Archivo incorrecto: %+v", file)
+	}
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/stat?path=docs/../../x", nil, ""), http.StatusForbidden)
+
+	// Con la suma ya calculada la descarga incluye el Digest
+	apiRequest(cfg, http.MethodGet, "/api/v1/checksum?path=docs/nota.txt", nil, "")
+	rr = apiRequest(cfg, http.MethodGet, "/api/v1/download?path=docs/nota.txt", nil, "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "hola" {
+		t.Errorf("Descarga incorrecta: %v %q", rr.Code, rr.Body.String())
+	}
+	if digest := rr.Header().Get("Digest"); digest != digestHeader(holaSHA256) {
+		t.Errorf("Cabecera Digest incorrecta: %q", digest)
+	}
+
+	// Descarga parcial
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/download?path=docs/nota.txt", nil)
+	req.Header.Set("Range", "bytes=1-2")
+	rr = httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "ol" {
+		t.Errorf("Descarga parcial incorrecta: %v %q", rr.Code, rr.Body.String())
+	}
+
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/download?path=docs", nil, ""), http.StatusBadRequest)
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/download?path=noexiste", nil, ""), http.StatusNotFound)
+}
+
+func TestAPIChecksum(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	rr := apiRequest(cfg, http.MethodGet, "/api/v1/checksum?path=docs/nota.txt", nil, "")
+	var result apiChecksumResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if result.Path != "docs/nota.txt" || result.Algo != "sha256" || result.Checksum != holaSHA256 {
+		t.Errorf("Checksum incorrecto: %+v", result)
+	}
+
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/checksum?path=docs/nota.txt&algo=crc", nil, ""), http.StatusBadRequest)
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/checksum?path=docs", nil, ""), http.StatusBadRequest)
+	checkAPIError(t, apiRequest(cfg, http.MethodGet, "/api/v1/checksum?path=noexiste", nil, ""), http.StatusNotFound)
+}
+
+func TestAPIUpload(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	newRequest := func(target, content string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("files", "subido.txt")
+		part.Write([]byte(content))
+		writer.WriteField("checksums", holaSHA256+"  subido.txt")
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, target, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	// Sin autenticación
+	rr := httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, newRequest("/api/v1/upload?path=docs", "hola"))
+	checkAPIError(t, rr, http.StatusUnauthorized)
+
+	req := newRequest("/api/v1/upload?path=docs", "hola")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v (%s)", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var result apiUploadResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != "docs/subido.txt" {
+		t.Errorf("Resultado incorrecto: %+v", result)
+	}
+	if content, _ := storage.ReadFile(cfg.Storage, "docs/subido.txt"); string(content) != "hola" {
+		t.Errorf("Contenido incorrecto: %q", content)
+	}
+
+	// Checksum incorrecto
+	req = newRequest("/api/v1/upload?path=docs", "adiós")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	checkAPIError(t, rr, http.StatusBadRequest)
+	if !strings.Contains(rr.Body.String(), "Checksum mismatch") {
+		t.Errorf("Mensaje de error incorrecto: %s", rr.Body.String())
+	}
+
+	// Destino fuera del directorio compartido
+	req = newRequest("/api/v1/upload?path=../", "hola")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	checkAPIError(t, rr, http.StatusForbidden)
+}
+
+func TestAPIDelete(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	checkAPIError(t, apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", nil, ""), http.StatusUnauthorized)
+	checkAPIError(t, apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", nil, "otro"), http.StatusForbidden)
+
+	rr := apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", nil, cfg.Username)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNoContent)
+	}
+	if fileExists(cfg, "foto.jpg") {
+		t.Error("El archivo debería haberse eliminado")
+	}
+
+	// Los directorios con contenido solo se eliminan con recursive=true
+	checkAPIError(t, apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=docs", nil, cfg.Username), http.StatusConflict)
+	rr = apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=docs&recursive=true", nil, cfg.Username)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNoContent)
+	}
+
+	checkAPIError(t, apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=", nil, cfg.Username), http.StatusBadRequest)
+	checkAPIError(t, apiRequest(cfg, http.MethodDelete, "/api/v1/files?path=noexiste", nil, cfg.Username), http.StatusNotFound)
+}
+
+func TestAPIMkdirAndMove(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	// Crear directorio
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`{"path":"nuevo"}`), ""), http.StatusUnauthorized)
+
+	rr := apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`{"path":"nuevo/sub"}`), cfg.Username)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusCreated)
+	}
+	if info, err := cfg.Storage.Stat("nuevo/sub"); err != nil || !info.IsDir() {
+		t.Error("El directorio debería haberse creado")
+	}
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`{"path":"docs"}`), cfg.Username), http.StatusConflict)
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`{"path":"../fuera"}`), cfg.Username), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`no es json`), cfg.Username), http.StatusBadRequest)
+
+	// Mover
+	move := func(body, username string) *httptest.ResponseRecorder {
+		return apiRequest(cfg, http.MethodPost, "/api/v1/move", strings.NewReader(body), username)
+	}
+
+	checkAPIError(t, move(`{"from":"foto.jpg","to":"nuevo/foto.jpg"}`, "otro"), http.StatusForbidden)
+
+	rr = move(`{"from":"foto.jpg","to":"nuevo/foto.jpg"}`, cfg.Username)
+	var file apiFile
+	if err := json.Unmarshal(rr.Body.Bytes(), &file); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+	if rr.Code != http.StatusOK || file.Path != "nuevo/foto.jpg" {
+		t.Errorf("Movimiento incorrecto: %v %+v", rr.Code, file)
+	}
+
+	checkAPIError(t, move(`{"from":"docs/nota.txt","to":"docs/viejos/a.txt"}`, cfg.Username), http.StatusConflict)
+	checkAPIError(t, move(`{"from":"docs","to":"docs/viejos/docs"}`, cfg.Username), http.StatusBadRequest)
+	checkAPIError(t, move(`{"from":"docs/nota.txt","to":"../nota.txt"}`, cfg.Username), http.StatusForbidden)
+	checkAPIError(t, move(`{"from":"noexiste","to":"otro"}`, cfg.Username), http.StatusNotFound)
+	checkAPIError(t, move(`{"from":"docs/nota.txt","to":"noexiste/nota.txt"}`, cfg.Username), http.StatusNotFound)
+}
+
+func TestOpenAPI(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	rr := httptest.NewRecorder()
+	OpenAPI(rr, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Documento OpenAPI inválido: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Versión de OpenAPI incorrecta: %q", spec.OpenAPI)
+	}
+
+	// Todos los endpoints de la API deben estar documentados y viceversa
+	routes := apiRoutes(cfg)
+	for path, route := range routes {
+		for method := range route {
+			if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s no está documentado", method, path)
+			}
+		}
+	}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if _, ok := routes[path][strings.ToUpper(method)]; !ok {
+				t.Errorf("%s %s está documentado pero no existe", method, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rodrwan/shareiscare/checksum"
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// checksumCache returns the checksum cache of the configured cache directory
+func checksumCache(config *config.Config) *checksum.Cache {
+	if config.CacheDir == "" {
+		return checksum.NewCache(checksum.DefaultDir())
+	}
+	return checksum.NewCache(filepath.Join(config.CacheDir, "checksums"))
+}
+
+// checksumKey identifies a file of the storage in the checksum cache. It's
+// the path of the file in the configured directory, so that the entries are
+// shared with the servers that read the directory directly.
+func checksumKey(config *config.Config, name string) string {
+	return filepath.Join(config.RootDir, filepath.FromSlash(name))
+}
+
+// fileChecksum returns the checksum of a file of the storage, calculating
+// it if it isn't cached
+func fileChecksum(config *config.Config, name string, info fs.FileInfo, algo string) (string, error) {
+	return checksumCache(config).SumInfo(checksumKey(config, name), info, algo, func() (io.ReadCloser, error) {
+		return fileStorage(config).Open(name)
+	})
+}
+
+// computing holds the keys of the checksums being calculated in the
+// background, so that each one is only calculated once at a time
+var computing sync.Map
+
+// downloadDigest returns the Digest header of a download if the SHA-256
+// checksum of the file is already cached. Otherwise it's calculated in the
+// background for the next downloads, without delaying this one.
+func downloadDigest(config *config.Config, name string, info fs.FileInfo) (string, bool) {
+	key := checksumKey(config, name)
+	if sum, ok := checksumCache(config).LookupInfo(key, info, checksum.SHA256); ok {
+		return digestHeader(sum), true
+	}
+
+	if _, busy := computing.LoadOrStore(key, true); !busy {
+		go func() {
+			defer computing.Delete(key)
+			if _, err := fileChecksum(config, name, info, checksum.SHA256); err != nil {
+				log.Printf("Error calculating checksum: %v", err)
+			}
+		}()
+	}
+	return "", false
+}
+
+// digestHeader formats a hex SHA-256 checksum as a Digest header value (RFC 3230)
+func digestHeader(sum string) string {
+	raw, err := hex.DecodeString(sum)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(raw)
+}
+
+// parseChecksums parses checksums in the format used by sha256sum
+// ("<hash>  <filename>" per line) and returns them indexed by filename
+func parseChecksums(text string) map[string]string {
+	checksums := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		// sha256sum marks files read in binary mode with an asterisk
+		name := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+		checksums[filepath.Base(name)] = strings.ToLower(fields[0])
+	}
+
+	return checksums
+}
+
+// Checksum returns the checksum of a file in the format used by sha256sum
+func Checksum(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := r.URL.Query().Get("filename")
+		if filename == "" {
+			http.Error(w, "Filename is required", http.StatusBadRequest)
+			return
+		}
+
+		algo := r.URL.Query().Get("algo")
+		if algo == "" {
+			algo = checksum.SHA256
+		}
+		if _, err := checksum.NewHash(algo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
+			return
+		}
+
+		// Check if the file exists
+		fileInfo, err := fileStorage(config).Stat(name)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if fileInfo.IsDir() {
+			http.Error(w, "Cannot calculate the checksum of a directory", http.StatusBadRequest)
+			return
+		}
+
+		sum, err := fileChecksum(config, name, fileInfo, algo)
+		if err != nil {
+			http.Error(w, "Error calculating checksum", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%s  %s\n", sum, filepath.Base(filename))
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/checksum"
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// SHA-256 de "hola"
+const holaSHA256 = "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79"
+
+// newUploadRequest crea una petición de subida autenticada a target con los archivos y campos indicados
+func newUploadRequest(t *testing.T, cfg *config.Config, target string, files map[string]string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatalf("No se pudo crear parte del formulario: %v", err)
+		}
+		part.Write([]byte(content))
+	}
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+
+	return req
+}
+
+func TestChecksum(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	writeTestFile(t, cfg, "archivo.txt", "hola")
+
+	handler := Checksum(cfg)
+
+	// Caso 1: SHA-256 por defecto en formato sha256sum
+	req := httptest.NewRequest(http.MethodGet, "/checksum?filename=archivo.txt", nil)
+	res := httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("status code = %d, quería %d", res.Code, http.StatusOK)
+	}
+	if expected := holaSHA256 + "  archivo.txt\n"; res.Body.String() != expected {
+		t.Errorf("respuesta = %q, quería %q", res.Body.String(), expected)
+	}
+
+	// Caso 2: MD5
+	req = httptest.NewRequest(http.MethodGet, "/checksum?filename=archivo.txt&algo=md5", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if !strings.HasPrefix(res.Body.String(), "4d186321c1a7f0f354b297e8914ab240") {
+		t.Errorf("respuesta MD5 incorrecta: %q", res.Body.String())
+	}
+
+	// Caso 3: Algoritmo no soportado
+	req = httptest.NewRequest(http.MethodGet, "/checksum?filename=archivo.txt&algo=crc32", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("status code para algoritmo no soportado = %d, quería %d", res.Code, http.StatusBadRequest)
+	}
+
+	// Caso 4: Archivo inexistente
+	req = httptest.NewRequest(http.MethodGet, "/checksum?filename=noexiste.txt", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusNotFound {
+		t.Errorf("status code para archivo inexistente = %d, quería %d", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestDownloadDigest(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	writeTestFile(t, cfg, "archivo.txt", "hola")
+
+	download := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/download?filename=archivo.txt", nil)
+		res := httptest.NewRecorder()
+		Download(cfg)(res, req)
+		if res.Code != http.StatusOK {
+			t.Errorf("status code = %d, quería %d", res.Code, http.StatusOK)
+		}
+		return res
+	}
+
+	// La primera descarga no espera a que se calcule la suma
+	if got := download().Header().Get("Digest"); got != "" {
+		t.Errorf("Digest = %q sin la suma en caché", got)
+	}
+
+	// Se calcula en segundo plano para las siguientes
+	info, err := cfg.Storage.Stat("archivo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := checksumCache(cfg).LookupInfo(checksumKey(cfg, "archivo.txt"), info, checksum.SHA256); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("la suma no se calculó en segundo plano")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expected := digestHeader(holaSHA256)
+	if got := download().Header().Get("Digest"); got != expected {
+		t.Errorf("Digest = %q, quería %q", got, expected)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	text := holaSHA256 + "  archivo.txt\n" +
+		"ABCDEF *binario.bin\n" +
+		"linea-invalida\n" +
+		"123  carpeta/con espacios.txt\n"
+
+	checksums := parseChecksums(text)
+
+	expected := map[string]string{
+		"archivo.txt":      holaSHA256,
+		"binario.bin":      "abcdef",
+		"con espacios.txt": "123",
+	}
+	if len(checksums) != len(expected) {
+		t.Errorf("parseChecksums() devolvió %d entradas, quería %d", len(checksums), len(expected))
+	}
+	for name, sum := range expected {
+		if checksums[name] != sum {
+			t.Errorf("checksum de %s = %q, quería %q", name, checksums[name], sum)
+		}
+	}
+}
+
+func TestUploadPostChecksum(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	handler := UploadPost(cfg)
+
+	// Caso 1: El checksum coincide, el archivo se guarda
+	req := newUploadRequest(t, cfg, "/upload", map[string]string{"correcto.txt": "hola"},
+		map[string]string{"checksums": holaSHA256 + "  correcto.txt"})
+	res := httptest.NewRecorder()
+	handler(res, req)
+
+	if !fileExists(cfg, "correcto.txt") {
+		t.Error("el archivo con checksum correcto no fue guardado")
+	}
+
+	// Caso 2: El checksum no coincide, el archivo no se guarda
+	req = newUploadRequest(t, cfg, "/upload", map[string]string{"corrupto.txt": "adios"},
+		map[string]string{"checksums": holaSHA256 + "  corrupto.txt"})
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if fileExists(cfg, "corrupto.txt") {
+		t.Error("el archivo con checksum incorrecto no debería haber sido guardado")
+	}
+	if !strings.Contains(res.Body.String(), "Checksum mismatch") {
+		t.Error("la respuesta debería indicar que el checksum no coincide")
+	}
+
+	// No deben quedar archivos temporales en el directorio
+	entries, _ := cfg.Storage.ReadDir("")
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".upload-") {
+			t.Errorf("quedó un archivo temporal: %s", entry.Name())
+		}
+	}
+}
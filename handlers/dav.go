@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/safepath"
+	"github.com/rodrwan/shareiscare/tokens"
+	"golang.org/x/net/webdav"
+)
+
+// davScopes maps the WebDAV methods to the token scope they require.
+// Methods that require the delete scope are also admin only, and so is a
+// COPY that replaces an existing resource (see davOverwrites).
+var davScopes = map[string]string{
+	http.MethodOptions: tokens.Read,
+	http.MethodGet:     tokens.Read,
+	http.MethodHead:    tokens.Read,
+	"PROPFIND":         tokens.Read,
+	http.MethodPut:     tokens.Upload,
+	"MKCOL":            tokens.Upload,
+	"COPY":             tokens.Upload,
+	"PROPPATCH":        tokens.Upload,
+	"LOCK":             tokens.Upload,
+	"UNLOCK":           tokens.Upload,
+	http.MethodDelete:  tokens.Delete,
+	"MOVE":             tokens.Delete,
+}
+
+// WebDAVMethods returns the methods served by the WebDAV handler
+func WebDAVMethods() []string {
+	methods := make([]string, 0, len(davScopes))
+	for method := range davScopes {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// davUserKey is the context key of the user of a WebDAV request
+type davUserKey struct{}
+
+// davUser returns the user of the WebDAV request of ctx
+func davUser(ctx context.Context) string {
+	user, _ := ctx.Value(davUserKey{}).(string)
+	return user
+}
+
+// davFS is the shared directory as seen by WebDAV clients. Excluded files,
+// the dotfiles the user can't see and the symbolic links denied by the
+// policy don't exist for them, as in the web listings.
+type davFS struct {
+	webdav.Dir
+	resolver *safepath.Resolver
+	config   *config.Config
+}
+
+// check returns the error for a path that the user of ctx can't use
+func (d davFS) check(ctx context.Context, name string) error {
+	if excludedName(d.config, name) || !visibleTo(d.config, davUser(ctx), name) {
+		return os.ErrNotExist
+	}
+	_, err := d.resolver.Resolve(name)
+	return err
+}
+
+func (d davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if err := d.check(ctx, name); err != nil {
+		return err
+	}
+	return d.Dir.Mkdir(ctx, name, perm)
+}
+
+func (d davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if err := d.check(ctx, name); err != nil {
+		return nil, err
+	}
+	f, err := d.Dir.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return davFile{File: f, name: name, user: davUser(ctx), dav: d}, nil
+}
+
+func (d davFS) RemoveAll(ctx context.Context, name string) error {
+	if err := d.check(ctx, name); err != nil {
+		return err
+	}
+	return d.Dir.RemoveAll(ctx, name)
+}
+
+func (d davFS) Rename(ctx context.Context, oldName, newName string) error {
+	if err := d.check(ctx, oldName); err != nil {
+		return err
+	}
+	if err := d.check(ctx, newName); err != nil {
+		return err
+	}
+	return d.Dir.Rename(ctx, oldName, newName)
+}
+
+func (d davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	if err := d.check(ctx, name); err != nil {
+		return nil, err
+	}
+	return d.Dir.Stat(ctx, name)
+}
+
+// davFile is a file of davFS, whose directory listings skip excluded files,
+// hidden dotfiles and denied links
+type davFile struct {
+	webdav.File
+	name string
+	user string
+	dav  davFS
+}
+
+func (f davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	visible := infos[:0]
+	for _, info := range infos {
+		name := path.Join(f.name, info.Name())
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if _, err := f.dav.resolver.Resolve(name); err != nil {
+				continue
+			}
+		}
+		if excludedName(f.dav.config, name) || !visibleTo(f.dav.config, f.user, name) {
+			continue
+		}
+		visible = append(visible, info)
+	}
+	return visible, err
+}
+
+// davOverwrites reports whether a COPY or MOVE request replaces a resource
+// that exists, which deletes it. The Overwrite header is "T" by default.
+func davOverwrites(r *http.Request, files davFS) bool {
+	if r.Method != "COPY" && r.Method != "MOVE" || r.Header.Get("Overwrite") == "F" {
+		return false
+	}
+	destination, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil {
+		return false
+	}
+	name, ok := strings.CutPrefix(destination.Path, "/dav/")
+	if !ok {
+		return false
+	}
+	_, err = files.Dir.Stat(r.Context(), name)
+	return err == nil
+}
+
+// davAuthenticate checks the HTTP Basic credentials of a WebDAV request and
+// returns the user. The password can also be an API token, which is returned
+// so that its scopes can be checked.
+func davAuthenticate(r *http.Request, config *config.Config) (string, *tokens.Token, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", nil, false
+	}
+
+	if config.Tokens != nil {
+		if token, ok := config.Tokens.Authenticate(password); ok {
+			return token.Username, token, true
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(username), []byte(config.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(config.Password)) == 1 {
+		return username, nil, true
+	}
+	return "", nil, false
+}
+
+// WebDAV serves the shared directory over WebDAV under /dav/, so that it can
+// be mounted as a network drive
+func WebDAV(config *config.Config) http.HandlerFunc {
+	files := davFS{
+		Dir:      webdav.Dir(config.RootDir),
+		resolver: safepath.New(config.RootDir, symlinkPolicy(config)),
+		config:   config,
+	}
+	dav := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: files,
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("WebDAV error in %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, token, ok := davAuthenticate(r, config)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", config.Title))
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		scope, ok := davScopes[r.Method]
+		if ok && davOverwrites(r, files) {
+			scope = tokens.Delete
+		}
+		if !ok || (scope != tokens.Read && ReadOnly(config)) {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != nil && !token.HasScope(scope) {
+			http.Error(w, "The token doesn't have the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		// Uploads can't be limited by the quotas, the web and the API must be used
+		if scope == tokens.Upload && quotasEnabled(config) {
+			http.Error(w, "Uploads are limited by quotas, use the web interface or the API", http.StatusForbidden)
+			return
+		}
+		// Same permissions as RequireAdmin for deleting and moving files
+		if scope == tokens.Delete && username != config.Username {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+
+		dav.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), davUserKey{}, username)))
+	}
+}
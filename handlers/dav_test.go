@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+// davRequest ejecuta una petición WebDAV con autenticación básica
+func davRequest(handler http.HandlerFunc, method, target, body, username, password string, headers map[string]string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// davAdmin ejecuta una petición WebDAV con las credenciales configuradas
+func davAdmin(cfg *config.Config, handler http.HandlerFunc, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	return davRequest(handler, method, target, body, cfg.Username, cfg.Password, headers)
+}
+
+func TestWebDAVAuthentication(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	handler := WebDAV(cfg)
+
+	rr := davRequest(handler, "PROPFIND", "/dav/", "", "", "", nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusUnauthorized)
+	}
+	if !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Errorf("Cabecera WWW-Authenticate incorrecta: %q", rr.Header().Get("WWW-Authenticate"))
+	}
+
+	rr = davRequest(handler, "PROPFIND", "/dav/", "", cfg.Username, "incorrecta", nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	rr = davAdmin(cfg, handler, "PROPFIND", "/dav/", "", map[string]string{"Depth": "1"})
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusMultiStatus)
+	}
+
+	// Los archivos del sistema no se muestran
+	body := rr.Body.String()
+	if !strings.Contains(body, "/dav/foto.jpg") || !strings.Contains(body, "/dav/docs/") {
+		t.Error("El listado debería incluir los archivos compartidos")
+	}
+	if strings.Contains(body, "config.yaml") {
+		t.Error("El listado no debería incluir config.yaml")
+	}
+
+	rr = davAdmin(cfg, handler, http.MethodGet, "/dav/config.yaml", "", nil)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestWebDAVFiles(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	handler := WebDAV(cfg)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		headers map[string]string
+		code    int
+	}{
+		{"leer", http.MethodGet, "/dav/docs/nota.txt", "", nil, http.StatusOK},
+		{"crear directorio", "MKCOL", "/dav/nuevo", "", nil, http.StatusCreated},
+		{"subir", http.MethodPut, "/dav/nuevo/a.txt", "contenido", nil, http.StatusCreated},
+		{"copiar", "COPY", "/dav/nuevo/a.txt", "", map[string]string{"Destination": "/dav/nuevo/b.txt"}, http.StatusCreated},
+		{"mover", "MOVE", "/dav/nuevo/b.txt", "", map[string]string{"Destination": "/dav/docs/b.txt"}, http.StatusCreated},
+		{"bloquear", "LOCK", "/dav/nuevo/a.txt", `<?xml version="1.0"?><lockinfo xmlns="DAV:"><lockscope><exclusive/></lockscope><locktype><write/></locktype></lockinfo>`, nil, http.StatusOK},
+		{"eliminar", http.MethodDelete, "/dav/docs/viejos", "", nil, http.StatusNoContent},
+		{"sobrescribir config.yaml", http.MethodPut, "/dav/config.yaml", "port: 1", nil, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := davAdmin(cfg, handler, tt.method, tt.target, tt.body, tt.headers)
+			if rr.Code != tt.code {
+				t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, tt.code)
+			}
+		})
+	}
+
+	checks := map[string]string{
+		"nuevo/a.txt": "contenido",
+		"docs/b.txt":  "contenido",
+		"config.yaml": "port: 8080",
+	}
+	for name, expected := range checks {
+		data, err := os.ReadFile(filepath.Join(cfg.RootDir, name))
+		if err != nil || string(data) != expected {
+			t.Errorf("Contenido incorrecto de %s: %q (%v)", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.RootDir, "docs", "viejos")); !os.IsNotExist(err) {
+		t.Error("El directorio debería haberse eliminado")
+	}
+}
+
+func TestWebDAVTokens(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+	setupAPIFiles(t, cfg)
+	handler := WebDAV(cfg)
+
+	reader := createToken(t, cfg, cfg.Username, []string{tokens.Read}, time.Time{})
+	uploader := createToken(t, cfg, "otro", []string{tokens.Read, tokens.Upload, tokens.Delete}, time.Time{})
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		target string
+		code   int
+	}{
+		{"leer con scope read", reader, http.MethodGet, "/dav/foto.jpg", http.StatusOK},
+		{"subir sin scope upload", reader, http.MethodPut, "/dav/x.txt", http.StatusForbidden},
+		{"subir con scope upload", uploader, http.MethodPut, "/dav/x.txt", http.StatusCreated},
+		{"eliminar sin ser admin", uploader, http.MethodDelete, "/dav/foto.jpg", http.StatusForbidden},
+		{"token inválido", "sic_invalido", http.MethodGet, "/dav/foto.jpg", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// El nombre de usuario se ignora con los tokens
+			rr := davRequest(handler, tt.method, tt.target, "x", "token", tt.token, nil)
+			if rr.Code != tt.code {
+				t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestWebDAVOverwrite(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+	setupAPIFiles(t, cfg)
+	handler := WebDAV(cfg)
+
+	uploader := createToken(t, cfg, cfg.Username, []string{tokens.Read, tokens.Upload}, time.Time{})
+	other := createToken(t, cfg, "otro", []string{tokens.Read, tokens.Upload, tokens.Delete}, time.Time{})
+
+	tests := []struct {
+		name        string
+		token       string
+		destination string
+		overwrite   string
+		code        int
+	}{
+		{"copiar a un archivo nuevo", uploader, "/dav/docs/copia.txt", "", http.StatusCreated},
+		{"reemplazar sin scope delete", uploader, "/dav/foto.jpg", "", http.StatusForbidden},
+		{"reemplazar con Overwrite: T", uploader, "/dav/foto.jpg", "T", http.StatusForbidden},
+		{"reemplazar sin ser admin", other, "/dav/foto.jpg", "T", http.StatusForbidden},
+		{"sin reemplazar", uploader, "/dav/foto.jpg", "F", http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Destination": tt.destination}
+			if tt.overwrite != "" {
+				headers["Overwrite"] = tt.overwrite
+			}
+			rr := davRequest(handler, "COPY", "/dav/docs/nota.txt", "", "token", tt.token, headers)
+			if rr.Code != tt.code {
+				t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, tt.code)
+			}
+		})
+	}
+	if content, _ := os.ReadFile(filepath.Join(cfg.RootDir, "foto.jpg")); string(content) != "jpg" {
+		t.Errorf("El archivo no debería haberse reemplazado: %q", content)
+	}
+
+	// El administrador con todos los permisos puede reemplazarlo
+	rr := davAdmin(cfg, handler, "COPY", "/dav/docs/nota.txt", "", map[string]string{"Destination": "/dav/foto.jpg"})
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNoContent)
+	}
+	if content, _ := os.ReadFile(filepath.Join(cfg.RootDir, "foto.jpg")); string(content) != "hola" {
+		t.Errorf("El archivo debería haberse reemplazado: %q", content)
+	}
+}
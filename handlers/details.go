@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/checksum"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/metadata"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+// isJPEG checks if a file is a JPEG image based on its extension
+func isJPEG(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// imageDetails reads the metadata of an image for the details panel
+func imageDetails(config *config.Config, name string) *templates.ImageDetails {
+	file, err := fileStorage(config).Open(name)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, err := metadata.ReadImage(file)
+	if err != nil {
+		// Formats not supported by the standard library (e.g. SVG, WebP)
+		return nil
+	}
+
+	details := &templates.ImageDetails{
+		Dimensions: fmt.Sprintf("%d × %d px", info.Width, info.Height),
+		Camera:     info.Camera(),
+		Lens:       info.LensModel,
+		HasGPS:     info.HasGPS,
+	}
+	if !info.Taken.IsZero() {
+		details.Taken = info.Taken.Format(dateLayout)
+	}
+	if info.HasGPS {
+		details.Latitude = fmt.Sprintf("%.6f", info.Latitude)
+		details.Longitude = fmt.Sprintf("%.6f", info.Longitude)
+	}
+
+	return details
+}
+
+// Details renders the details panel of a file
+func Details(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := r.URL.Query().Get("filename")
+		if filename == "" {
+			http.Error(w, "Filename is required", http.StatusBadRequest)
+			return
+		}
+
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
+			return
+		}
+
+		// Check if the file exists
+		fileInfo, err := fileStorage(config).Stat(name)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		data := templates.FileDetails{
+			Name:        fileInfo.Name(),
+			Path:        filename,
+			Size:        folderSize(config, filename),
+			Modified:    fileInfo.ModTime().Format(dateLayout),
+			Permissions: fileInfo.Mode().String(),
+			IsDir:       fileInfo.IsDir(),
+		}
+
+		if !fileInfo.IsDir() {
+			data.Size = formatSize(fileInfo.Size())
+
+			sum, err := fileChecksum(config, name, fileInfo, checksum.SHA256)
+			if err != nil {
+				log.Printf("Error calculating checksum: %v", err)
+			}
+			data.SHA256 = sum
+
+			if getFileType(filename) == templates.FileTypeImage {
+				data.Image = imageDetails(config, name)
+				data.CanStripGPS = data.Image != nil && data.Image.HasGPS && isJPEG(filename)
+			}
+		}
+
+		templ.Handler(templates.Details(data)).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDetails(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	writeTestFile(t, cfg, "documento.txt", "hola")
+
+	handler := Details(cfg)
+
+	// Caso 1: Detalles de un archivo existente
+	req := httptest.NewRequest(http.MethodGet, "/details?filename=documento.txt", nil)
+	res := httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("status code = %d, quería %d", res.Code, http.StatusOK)
+	}
+
+	body := res.Body.String()
+	// SHA-256 de "hola"
+	if !strings.Contains(body, "b221d9dbb083a7f33428d7c2a3c3198ae925614d70210e28716ccaa7cd4ddb79") {
+		t.Error("la respuesta no contiene el checksum SHA-256 del archivo")
+	}
+	if !strings.Contains(body, "-rw-r--r--") {
+		t.Error("la respuesta no contiene los permisos del archivo")
+	}
+
+	// Caso 2: Archivo inexistente
+	req = httptest.NewRequest(http.MethodGet, "/details?filename=noexiste.txt", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusNotFound {
+		t.Errorf("status code para archivo inexistente = %d, quería %d", res.Code, http.StatusNotFound)
+	}
+
+	// Caso 3: Intento de acceso fuera del directorio raíz
+	req = httptest.NewRequest(http.MethodGet, "/details?filename=../config.yaml", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusForbidden {
+		t.Errorf("status code para path-traversal = %d, quería %d", res.Code, http.StatusForbidden)
+	}
+}
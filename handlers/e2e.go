@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+// E2E renders the page that decrypts a file encrypted end to end. The page
+// downloads the file and decrypts it in the browser with the key in the
+// fragment of the link, which the server never receives.
+func E2E(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := r.URL.Query().Get("filename")
+		if filename == "" {
+			http.Error(w, "Filename is required", http.StatusBadRequest)
+			return
+		}
+
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
+			return
+		}
+
+		fileInfo, err := fileStorage(config).Stat(name)
+		if err != nil || fileInfo.IsDir() {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		data := templates.E2EData{
+			Title: config.Title,
+			Path:  name,
+		}
+		renderE2E(w, r, templates.E2E(data))
+	}
+}
+
+// E2EUpload renders the page that encrypts files end to end and uploads
+// them through the API
+func E2EUpload(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := templates.UploadData{
+			Title:        config.Title,
+			Shares:       uploadShares(config),
+			FolderExpiry: len(config.Expiry) > 0,
+		}
+		renderE2E(w, r, templates.E2EUpload(data))
+	}
+}
+
+// renderE2E renders a page that handles the keys of files encrypted end to
+// end. The page only runs its own inline code, and neither it nor its
+// requests send its address, which may have a key, anywhere.
+func renderE2E(w http.ResponseWriter, r *http.Request, component templ.Component) {
+	nonce, err := cspNonce()
+	if err != nil {
+		http.Error(w, "Error rendering the page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'nonce-"+nonce+"'; style-src 'nonce-"+nonce+"'; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	templ.Handler(component).ServeHTTP(w, r.WithContext(templ.WithNonce(r.Context(), nonce)))
+}
+
+// cspNonce returns a random nonce for the inline scripts and styles allowed
+// by a Content-Security-Policy
+func cspNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// checkE2EPage comprueba que una página que maneja las claves solo ejecuta
+// su propio código y no envía su dirección a nadie
+func checkE2EPage(t *testing.T, res *httptest.ResponseRecorder) {
+	t.Helper()
+	body := res.Body.String()
+	for _, host := range []string{"cdn.tailwindcss.com", "unpkg.com", "cdnjs.cloudflare.com"} {
+		if strings.Contains(body, host) {
+			t.Errorf("La página no debería cargar recursos de %s", host)
+		}
+	}
+	if policy := res.Header().Get("Referrer-Policy"); policy != "no-referrer" {
+		t.Errorf("Referrer-Policy = %q", policy)
+	}
+	csp := res.Header().Get("Content-Security-Policy")
+	nonce, _, _ := strings.Cut(strings.TrimPrefix(csp[strings.Index(csp, "script-src"):], "script-src 'nonce-"), "'")
+	if !strings.Contains(csp, "default-src 'none'") || nonce == "" {
+		t.Fatalf("Content-Security-Policy incorrecta: %q", csp)
+	}
+	if strings.Count(body, "<script") != strings.Count(body, `<script nonce="`+nonce+`"`) {
+		t.Error("Todos los scripts deberían llevar el nonce de la política")
+	}
+}
+
+func TestE2E(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	storage.WriteFile(cfg.Storage, "docs/0a1b.e2e", []byte("SICE2E01 cifrado"))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"archivo cifrado", "/e2e?filename=docs/0a1b.e2e", http.StatusOK},
+		{"sin nombre", "/e2e", http.StatusBadRequest},
+		{"inexistente", "/e2e?filename=docs/noexiste.e2e", http.StatusNotFound},
+		{"directorio", "/e2e?filename=docs", http.StatusNotFound},
+		{"fuera de la raíz", "/e2e?filename=../secreto.e2e", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			E2E(cfg)(res, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if res.Code != tt.status {
+				t.Errorf("status code = %d, quería %d", res.Code, tt.status)
+			}
+		})
+	}
+
+	// La página descarga el archivo y lo descifra en el navegador
+	res := httptest.NewRecorder()
+	E2E(cfg)(res, httptest.NewRequest(http.MethodGet, "/e2e?filename=docs/0a1b.e2e", nil))
+	body := res.Body.String()
+	for _, want := range []string{`data-path="docs/0a1b.e2e"`, "crypto.subtle.decrypt", "window.location.hash"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("La página no contiene %s", want)
+		}
+	}
+	if strings.Contains(body, "cifrado") {
+		t.Error("La página no debería incluir el contenido del archivo")
+	}
+
+	checkE2EPage(t, res)
+}
+
+func TestE2EUpload(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/upload/e2e", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	res := httptest.NewRecorder()
+	E2EUpload(cfg)(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("status code = %d, quería %d", res.Code, http.StatusOK)
+	}
+	for _, want := range []string{"crypto.subtle.encrypt", "/api/v1/upload", `id="expires"`} {
+		if !strings.Contains(res.Body.String(), want) {
+			t.Errorf("La página no contiene %s", want)
+		}
+	}
+	checkE2EPage(t, res)
+
+	// La página de subida, con los scripts de otros sitios, no cifra nada
+	req = httptest.NewRequest(http.MethodGet, "/upload", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	res = httptest.NewRecorder()
+	Upload(cfg)(res, req)
+	if page := res.Body.String(); strings.Contains(page, "crypto.subtle") || !strings.Contains(page, `href="/upload/e2e"`) {
+		t.Error("La página de subida debería enlazar a la subida cifrada en vez de cifrar")
+	}
+}
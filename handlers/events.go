@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/templates"
+	"github.com/rodrwan/shareiscare/watcher"
+)
+
+// eventsHeartbeat is the interval between keep-alive comments on idle streams
+const eventsHeartbeat = 30 * time.Second
+
+// fileEvent is the data of an event sent to the browser
+type fileEvent struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Card string `json:"card,omitempty"` // Rendered grid view card
+	Row  string `json:"row,omitempty"`  // Rendered list view row
+}
+
+// newFileInfo builds the listing entry of a file
+func newFileInfo(config *config.Config, relPath string, info os.FileInfo, isAdmin bool) templates.FileInfo {
+	size := folderSize(config, relPath)
+	fileType := templates.FileTypeUnknown
+	if !info.IsDir() {
+		size = formatSize(info.Size())
+		fileType = getFileType(info.Name())
+	}
+
+	return templates.FileInfo{
+		Name:     info.Name(),
+		Path:     relPath,
+		Size:     size,
+		Modified: info.ModTime().Format(dateLayout),
+		Expires:  fileExpiry(config, relPath, info),
+		IsDir:    info.IsDir(),
+		IsAdmin:  isAdmin,
+		ReadOnly: readOnlyPath(config, relPath),
+		FileType: fileType,
+	}
+}
+
+// newFileEvent renders the listing entries of a changed file
+func newFileEvent(r *http.Request, config *config.Config, event watcher.Event, isAdmin bool) (fileEvent, error) {
+	data := fileEvent{Name: event.Name, Path: event.Path()}
+	if event.Op == watcher.Delete {
+		return data, nil
+	}
+
+	info, err := fileStorage(config).Stat(event.Path())
+	if err != nil {
+		return data, err
+	}
+	file := newFileInfo(config, event.Path(), info, isAdmin)
+
+	var card, row bytes.Buffer
+	if err := templates.FileCard(file).Render(r.Context(), &card); err != nil {
+		return data, err
+	}
+	if err := templates.FileRow(file).Render(r.Context(), &row); err != nil {
+		return data, err
+	}
+	data.Card = card.String()
+	data.Row = row.String()
+
+	return data, nil
+}
+
+// Events streams the changes of a directory as server-sent events
+func Events(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if config.Watcher == nil {
+			http.Error(w, "File events are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		name, ok := resolvePath(w, r, config, strings.Trim(r.URL.Query().Get("dir"), "/"))
+		if !ok {
+			return
+		}
+
+		// Check if the path exists and is a directory
+		fileInfo, err := fileStorage(config).Stat(name)
+		if err != nil {
+			http.Error(w, "Path not found", http.StatusNotFound)
+			return
+		}
+		if !fileInfo.IsDir() {
+			http.Error(w, "Not a directory", http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Check if the user is authenticated and is admin
+		isAdmin := false
+		if isAuthenticated(r, config) {
+			isAdmin = currentUser(r, config) == config.Username
+		}
+
+		events, cancel := config.Watcher.Subscribe(name)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// Disable response buffering in reverse proxies such as nginx
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		fmt.Fprint(w, "retry: 3000\n\n")
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+
+			case event := <-events:
+				// Skip excluded files, uploads still in progress and dotfiles
+				if excluded(config, event.Path(), event.IsDir) || !visible(r, config, event.Path()) {
+					continue
+				}
+
+				data, err := newFileEvent(r, config, event, isAdmin)
+				if err != nil {
+					// The file may have been removed in the meantime
+					continue
+				}
+
+				payload, err := json.Marshal(data)
+				if err != nil {
+					log.Printf("Error encoding file event: %v", err)
+					continue
+				}
+
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Op, payload)
+				flusher.Flush()
+			}
+		}
+	}
+}
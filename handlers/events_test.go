@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/ignore"
+	"github.com/rodrwan/shareiscare/watcher"
+)
+
+// readEvent lee el siguiente evento de un flujo SSE, ignorando los comentarios
+func readEvent(t *testing.T, reader *bufio.Reader) (string, fileEvent) {
+	t.Helper()
+
+	var name string
+	var data fileEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error leyendo evento: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("Datos de evento inválidos: %v", err)
+			}
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+
+	if err := os.Mkdir(filepath.Join(cfg.RootDir, "docs"), 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio de prueba: %v", err)
+	}
+
+	// Sin watcher el endpoint no está disponible
+	rr := httptest.NewRecorder()
+	Events(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/events?dir=docs", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	w, err := watcher.New(cfg.RootDir)
+	if err != nil {
+		t.Fatalf("No se pudo crear el watcher: %v", err)
+	}
+	defer w.Close()
+	cfg.Watcher = w
+	if cfg.Ignore, err = ignore.New(cfg.Storage, []string{"build/"}); err != nil {
+		t.Fatalf("ignore.New() devolvió error: %v", err)
+	}
+
+	// Rutas inválidas
+	tests := []struct {
+		dir  string
+		code int
+	}{
+		{"../", http.StatusForbidden},
+		{"noexiste", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		Events(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/events?dir="+tt.dir, nil))
+		if rr.Code != tt.code {
+			t.Errorf("dir=%q: código de estado incorrecto: obtenido %v, esperado %v", tt.dir, rr.Code, tt.code)
+		}
+	}
+
+	server := httptest.NewServer(Events(cfg))
+	defer server.Close()
+
+	// Se suscribe a la ruta ya limpia
+	resp, err := http.Get(server.URL + "/events?dir=otro/../docs/")
+	if err != nil {
+		t.Fatalf("Error conectando: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type incorrecto: %q", ct)
+	}
+
+	// Esperar a que la suscripción esté activa
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("Inicio de flujo inesperado: %q", line)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		name, data := readEvent(t, reader)
+		if name != "create" || data.Path != "docs/nota.txt" {
+			t.Errorf("Evento incorrecto: %s %+v", name, data)
+		}
+		if !strings.Contains(data.Card, `data-file="nota.txt"`) || !strings.Contains(data.Row, "<tr") {
+			t.Error("El evento debería incluir la tarjeta y la fila del archivo")
+		}
+
+		name, data = readEvent(t, reader)
+		if name != "delete" || data.Name != "nota.txt" || data.Card != "" {
+			t.Errorf("Evento incorrecto: %s %+v", name, data)
+		}
+	}()
+
+	// Los directorios excluidos no generan eventos, tampoco al eliminarse
+	build := filepath.Join(cfg.RootDir, "docs", "build")
+	if err := os.Mkdir(build, 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if err := os.Remove(build); err != nil {
+		t.Fatalf("No se pudo eliminar directorio: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	path := filepath.Join(cfg.RootDir, "docs", "nota.txt")
+	if err := os.WriteFile(path, []byte("hola"), 0644); err != nil {
+		t.Fatalf("No se pudo crear archivo: %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("No se pudo eliminar archivo: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No se recibieron los eventos")
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// defaultLifetime is the lifetime of an upload that uses the default of its
+// folder
+const defaultLifetime time.Duration = -1
+
+// parseLifetime parses the lifetime chosen for an upload: empty for the
+// default of the folder, "never" or a duration such as "24h"
+func parseLifetime(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return defaultLifetime, nil
+	case "never", "0":
+		return 0, nil
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil || lifetime <= 0 {
+		return 0, fmt.Errorf("Invalid expiration: %s", value)
+	}
+	return lifetime, nil
+}
+
+// folderLifetime returns the default lifetime of the files uploaded to dir,
+// which is the one of the closest folder that has one, or 0 if they don't
+// expire
+func folderLifetime(config *config.Config, dir string) time.Duration {
+	lifetime := time.Duration(0)
+	closest := -1
+	for folder, l := range config.Expiry {
+		name := configFolder(folder)
+		if name != "" && dir != name && !strings.HasPrefix(dir, name+"/") {
+			continue
+		}
+		if len(name) > closest {
+			lifetime, closest = l, len(name)
+		}
+	}
+	return lifetime
+}
+
+// setExpiry records when an uploaded file expires. A file uploaded without
+// a lifetime never expires, even if the one it replaced did.
+func setExpiry(config *config.Config, name string, info fs.FileInfo, lifetime time.Duration) {
+	if config.Expirations == nil {
+		return
+	}
+
+	var err error
+	if lifetime > 0 {
+		err = config.Expirations.Set(name, time.Now().Add(lifetime), info.ModTime())
+	} else {
+		err = config.Expirations.Forget(name)
+	}
+	if err != nil {
+		log.Printf("Error saving the expiration of %s: %v", name, err)
+	}
+}
+
+// fileExpiry returns the remaining lifetime of a file for the listings,
+// empty if it doesn't expire
+func fileExpiry(config *config.Config, name string, info fs.FileInfo) string {
+	if config.Expirations == nil || info.IsDir() {
+		return ""
+	}
+	expires, ok := config.Expirations.Expires(name, info.ModTime())
+	if !ok {
+		return ""
+	}
+	return formatLifetime(time.Until(expires))
+}
+
+// formatLifetime formats a remaining lifetime for display
+func formatLifetime(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("in 1 %s", unit)
+		}
+		return fmt.Sprintf("in %d %ss", n, unit)
+	}
+
+	switch {
+	case d < time.Minute:
+		return "in less than a minute"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	}
+	return plural(int(d/(24*time.Hour)), "day")
+}
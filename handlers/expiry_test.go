@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/expiry"
+)
+
+func TestFormatLifetime(t *testing.T) {
+	tests := map[time.Duration]string{
+		-time.Minute:        "in less than a minute",
+		30 * time.Second:    "in less than a minute",
+		time.Minute:         "in 1 minute",
+		59 * time.Minute:    "in 59 minutes",
+		time.Hour:           "in 1 hour",
+		47 * time.Hour:      "in 47 hours",
+		48 * time.Hour:      "in 2 days",
+		30*24*time.Hour - 1: "in 29 days",
+	}
+	for d, expected := range tests {
+		if got := formatLifetime(d); got != expected {
+			t.Errorf("formatLifetime(%v) = %q, esperado %q", d, got, expected)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	store, err := expiry.Open(filepath.Join(t.TempDir(), "expiry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Expirations = store
+	cfg.Expiry = map[string]time.Duration{"/": 48 * time.Hour, "docs/": time.Hour}
+
+	upload := func(dir, name, expires string) *httptest.ResponseRecorder {
+		fields := map[string]string{}
+		if expires != "" {
+			fields["expires"] = expires
+		}
+		req := newUploadRequest(t, cfg, "/api/v1/upload?path="+dir, map[string]string{name: "hola"}, fields)
+		rr := httptest.NewRecorder()
+		API(cfg).ServeHTTP(rr, req)
+		return rr
+	}
+	expires := func(name string) time.Duration {
+		info, err := cfg.Storage.Stat(name)
+		if err != nil {
+			t.Fatalf("No se encontró %s: %v", name, err)
+		}
+		at, ok := store.Expires(name, info.ModTime())
+		if !ok {
+			return 0
+		}
+		return time.Until(at).Round(time.Minute)
+	}
+
+	// La carpeta más cercana define la duración por defecto
+	for _, test := range []struct {
+		dir, name, expires string
+		lifetime           time.Duration
+	}{
+		{"", "raiz.txt", "", 48 * time.Hour},
+		{"docs", "doc.txt", "", time.Hour},
+		{"docs", "semana.txt", "168h", 168 * time.Hour},
+		{"docs", "siempre.txt", "never", 0},
+	} {
+		if rr := upload(test.dir, test.name, test.expires); rr.Code != http.StatusCreated {
+			t.Fatalf("Error subiendo %s: %d %s", test.name, rr.Code, rr.Body.String())
+		}
+		if got := expires(strings.TrimPrefix(test.dir+"/"+test.name, "/")); got != test.lifetime {
+			t.Errorf("Duración de %s incorrecta: %v, esperada %v", test.name, got, test.lifetime)
+		}
+	}
+
+	// Reemplazar un archivo sin duración hace que no caduque
+	cfg.Expiry = nil
+	upload("docs", "doc.txt", "")
+	if got := expires("docs/doc.txt"); got != 0 {
+		t.Errorf("El archivo reemplazado no debería caducar: %v", got)
+	}
+
+	checkAPIError(t, upload("", "mal.txt", "mañana"), http.StatusBadRequest)
+
+	// page devuelve una página vista por el administrador
+	page := func(handler http.HandlerFunc, target string) string {
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	// Las tarjetas muestran el tiempo que le queda a cada archivo
+	if body := page(Browse(cfg), "/browse/docs"); !strings.Contains(body, "Expires in 6 days") {
+		t.Errorf("El listado debería mostrar cuándo caduca el archivo: %s", body)
+	}
+	if body := page(Index(cfg), "/"); !strings.Contains(body, "Expires in 47 hours") {
+		t.Errorf("El índice debería mostrar cuándo caduca el archivo: %s", body)
+	}
+
+	// El formulario de subida ofrece la duración por defecto de la carpeta
+	if body := page(Upload(cfg), "/upload"); !strings.Contains(body, `name="expires"`) || strings.Contains(body, "Default of the folder") {
+		t.Error("Sin duraciones por defecto, el formulario no debería ofrecerlas")
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/metadata"
+	"github.com/rodrwan/shareiscare/storage"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+// hasImages checks if a file listing contains any image
+func hasImages(files []templates.FileInfo) bool {
+	for _, file := range files {
+		if file.FileType == templates.FileTypeImage {
+			return true
+		}
+	}
+	return false
+}
+
+// imageSize returns the dimensions of an image as it's shown, or 0x0 if they
+// can't be read
+func imageSize(store storage.Storage, name string) (int, int) {
+	file, err := store.Open(name)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+
+	info, err := metadata.ReadImage(file)
+	if err != nil {
+		return 0, 0
+	}
+	if info.Orientation >= 5 {
+		return info.Height, info.Width
+	}
+	return info.Width, info.Height
+}
+
+// Gallery displays the images of a directory as a gallery with a slideshow
+func Gallery(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract the path from the URL (empty for the root directory)
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/gallery"), "/")
+
+		name, ok := resolvePath(w, r, config, path)
+		if !ok {
+			return
+		}
+		store := fileStorage(config)
+
+		// Check if the path exists and is a directory
+		fileInfo, err := store.Stat(name)
+		if err != nil {
+			http.Error(w, "Path not found", http.StatusNotFound)
+			return
+		}
+		if !fileInfo.IsDir() {
+			http.Error(w, "Not a directory", http.StatusBadRequest)
+			return
+		}
+
+		files, err := store.ReadDir(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The image to open directly, used for deep links
+		selected := r.URL.Query().Get("image")
+
+		data := templates.GalleryData{
+			Title:     config.Title,
+			Directory: path,
+			Start:     -1,
+		}
+
+		for _, file := range files {
+			if file.IsDir() || getFileType(file.Name()) != templates.FileTypeImage {
+				continue
+			}
+			if excluded(config, name+"/"+file.Name(), false) || !visible(r, config, file.Name()) {
+				continue
+			}
+
+			width, height := imageSize(store, filepath.ToSlash(filepath.Join(name, file.Name())))
+			if width == 0 || height == 0 {
+				// Unknown dimensions (e.g. SVG), use a standard aspect ratio
+				width, height = 4, 3
+			}
+
+			if file.Name() == selected {
+				data.Start = len(data.Images)
+			}
+
+			data.Images = append(data.Images, templates.GalleryImage{
+				Name:   file.Name(),
+				Path:   filepath.ToSlash(filepath.Join(path, file.Name())),
+				Width:  width,
+				Height: height,
+			})
+		}
+
+		// Get the username if authenticated
+		isLoggedIn := isAuthenticated(r, config)
+		username := ""
+		if isLoggedIn {
+			username = currentUser(r, config)
+		}
+
+		layoutData := templates.LayoutData{
+			Title:      config.Title + " - Gallery",
+			IsLoggedIn: isLoggedIn,
+			Username:   username,
+			ReadOnly:   ReadOnly(config),
+		}
+
+		// Render the template with the layout
+		component := templates.Gallery(data)
+		ctx := r.Context()
+		handler := templates.LayoutWithData(layoutData)
+
+		templ.Handler(handler).ServeHTTP(w, r.WithContext(templ.WithChildren(ctx, component)))
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGallery(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	// Crear un directorio con una imagen y un archivo de texto
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil); err != nil {
+		t.Fatalf("No se pudo codificar imagen de prueba: %v", err)
+	}
+	writeTestFile(t, cfg, "fotos/paisaje.jpg", img.String())
+	writeTestFile(t, cfg, "fotos/notas.txt", "texto")
+
+	handler := Gallery(cfg)
+
+	// Caso 1: Galería de un directorio con imágenes
+	req := httptest.NewRequest(http.MethodGet, "/gallery/fotos", nil)
+	res := httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("status code = %d, quería %d", res.Code, http.StatusOK)
+	}
+
+	body := res.Body.String()
+	if !strings.Contains(body, "fotos/paisaje.jpg") {
+		t.Error("la galería no muestra la imagen del directorio")
+	}
+	if strings.Contains(body, "notas.txt") {
+		t.Error("la galería no debería mostrar archivos que no son imágenes")
+	}
+	if !strings.Contains(body, `data-start="-1"`) {
+		t.Error("la galería no debería abrir ninguna imagen sin el parámetro image")
+	}
+
+	// Caso 2: Enlace directo a una imagen
+	req = httptest.NewRequest(http.MethodGet, "/gallery/fotos?image=paisaje.jpg", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if !strings.Contains(res.Body.String(), `data-start="0"`) {
+		t.Error("la galería debería abrir la imagen indicada en el parámetro image")
+	}
+
+	// Las direcciones de las imágenes se escapan
+	writeTestFile(t, cfg, "fotos/a&b #1.jpg", img.String())
+	req = httptest.NewRequest(http.MethodGet, "/gallery/fotos", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+	body = res.Body.String()
+	for _, want := range []string{`href="?image=a%26b+%231.jpg"`, `src="/thumbnail?filename=fotos%2Fa%26b+%231.jpg"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("la galería no contiene %s", want)
+		}
+	}
+
+	// Caso 3: Directorio inexistente
+	req = httptest.NewRequest(http.MethodGet, "/gallery/noexiste", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusNotFound {
+		t.Errorf("status code para directorio inexistente = %d, quería %d", res.Code, http.StatusNotFound)
+	}
+
+	// Caso 4: Ruta que apunta a un archivo
+	req = httptest.NewRequest(http.MethodGet, "/gallery/fotos/notas.txt", nil)
+	res = httptest.NewRecorder()
+	handler(res, req)
+
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("status code para archivo = %d, quería %d", res.Code, http.StatusBadRequest)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	encode := func(width, height int) string {
+		var img bytes.Buffer
+		if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+			t.Fatalf("No se pudo codificar imagen de prueba: %v", err)
+		}
+		return img.String()
+	}
+	large := encode(1200, 800)
+	writeTestFile(t, cfg, "fotos/grande.jpg", large)
+	writeTestFile(t, cfg, "fotos/panorama.jpg", encode(6000, 500))
+	writeTestFile(t, cfg, "fotos/pequena.jpg", encode(30, 20))
+	writeTestFile(t, cfg, "fotos/notas.txt", "texto")
+
+	thumbnail := func(name string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		Thumbnail(cfg)(res, httptest.NewRequest(http.MethodGet, "/thumbnail?filename="+name, nil))
+		return res
+	}
+	size := func(res *httptest.ResponseRecorder) (int, int) {
+		cfg, _, err := image.DecodeConfig(res.Body)
+		if err != nil {
+			t.Fatalf("La miniatura no es una imagen: %v", err)
+		}
+		return cfg.Width, cfg.Height
+	}
+
+	// Las imágenes grandes se reducen manteniendo la proporción
+	res := thumbnail("fotos/grande.jpg")
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("status code = %d, Content-Type = %q", res.Code, res.Header().Get("Content-Type"))
+	}
+	if res.Body.Len() >= len(large) {
+		t.Error("la miniatura debería ser más pequeña que la imagen")
+	}
+	if width, height := size(res); width != 600 || height != 400 {
+		t.Errorf("miniatura de %dx%d, quería 600x400", width, height)
+	}
+	if width, height := size(thumbnail("fotos/panorama.jpg")); width != 1600 || height != 133 {
+		t.Errorf("miniatura del panorama de %dx%d, quería 1600x133", width, height)
+	}
+
+	// Las imágenes pequeñas se sirven tal cual
+	if width, height := size(thumbnail("fotos/pequena.jpg")); width != 30 || height != 20 {
+		t.Errorf("la imagen pequeña no debería cambiar: %dx%d", width, height)
+	}
+
+	if res := thumbnail("fotos/notas.txt"); res.Code != http.StatusBadRequest {
+		t.Errorf("status code para un archivo de texto = %d, quería %d", res.Code, http.StatusBadRequest)
+	}
+	if res := thumbnail("fotos/noexiste.jpg"); res.Code != http.StatusNotFound {
+		t.Errorf("status code para una imagen inexistente = %d, quería %d", res.Code, http.StatusNotFound)
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// Una imagen de 2x1 con un píxel rojo a la izquierda
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red := color.RGBA{255, 0, 0, 255}
+	src.SetRGBA(0, 0, red)
+
+	tests := map[int]image.Point{
+		1: {0, 0},
+		2: {1, 0},
+		3: {1, 0},
+		6: {0, 0},
+		8: {0, 1},
+	}
+	for orientation, want := range tests {
+		img := orientImage(src, orientation)
+		if orientation >= 5 && img.Rect.Dx() != 1 {
+			t.Errorf("orientación %d: la imagen debería girar: %v", orientation, img.Rect)
+		}
+		if img.RGBAAt(want.X, want.Y) != red {
+			t.Errorf("orientación %d: el píxel rojo debería estar en %v", orientation, want)
+		}
+	}
+}
+
+func TestThumbnailCache(t *testing.T) {
+	cache := &thumbnailCache{order: list.New(), entries: map[string]*list.Element{}}
+	for i := 0; i < maxThumbnails; i++ {
+		cache.put(thumbnail{key: fmt.Sprint(i)})
+	}
+
+	// Usar una miniatura la mantiene en la caché, y al llenarse se olvida
+	// la que lleva más tiempo sin usarse
+	cache.get("0")
+	cache.put(thumbnail{key: "nueva"})
+	if _, ok := cache.get("0"); !ok {
+		t.Error("La miniatura usada hace poco no debería olvidarse")
+	}
+	if _, ok := cache.get("1"); ok {
+		t.Error("La miniatura usada hace más tiempo debería olvidarse")
+	}
+	if len(cache.entries) != maxThumbnails || cache.order.Len() != maxThumbnails {
+		t.Errorf("La caché tiene %d miniaturas, se esperaban %d", len(cache.entries), maxThumbnails)
+	}
+}
@@ -2,18 +2,30 @@ package handlers
 
 import (
 	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/checksum"
 	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/ignore"
+	"github.com/rodrwan/shareiscare/metadata"
+	"github.com/rodrwan/shareiscare/quota"
+	"github.com/rodrwan/shareiscare/safepath"
+	"github.com/rodrwan/shareiscare/storage"
 	"github.com/rodrwan/shareiscare/templates"
+	"github.com/rodrwan/shareiscare/tokens"
 )
 
 // Session stores the user's session information
@@ -22,8 +34,36 @@ type Session struct {
 	Timestamp time.Time
 }
 
-// isAuthenticated checks if a user is authenticated via a session cookie
+// bearerToken returns the token of the Authorization header, or "" if there is none
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// requestToken returns the API token of a request if it has a valid one
+func requestToken(r *http.Request, config *config.Config) (*tokens.Token, bool) {
+	secret := bearerToken(r)
+	if secret == "" || config.Tokens == nil {
+		return nil, false
+	}
+	return config.Tokens.Authenticate(secret)
+}
+
+// isAuthenticated checks if a user is authenticated via a session cookie or
+// an API token
 func isAuthenticated(r *http.Request, config *config.Config) bool {
+	if hasValidSession(r, config) {
+		return true
+	}
+	_, ok := requestToken(r, config)
+	return ok
+}
+
+// hasValidSession checks if a request has a valid session cookie
+func hasValidSession(r *http.Request, config *config.Config) bool {
 	sessionCookie, err := r.Cookie("session")
 	if err != nil {
 		return false
@@ -48,6 +88,18 @@ func isAuthenticated(r *http.Request, config *config.Config) bool {
 	return true
 }
 
+// currentUser returns the name of the authenticated user, or "" if there is none
+func currentUser(r *http.Request, config *config.Config) string {
+	if hasValidSession(r, config) {
+		sessionCookie, _ := r.Cookie("session")
+		return strings.Split(sessionCookie.Value, ":")[0]
+	}
+	if token, ok := requestToken(r, config); ok {
+		return token.Username
+	}
+	return ""
+}
+
 // generateSignature generates a simple signature for the session
 func generateSignature(username, timestamp, secretKey string) string {
 	// This is a basic implementation. In a real application,
@@ -76,6 +128,12 @@ func createSessionCookie(username string, config *config.Config) *http.Cookie {
 func RequireAuth(next http.HandlerFunc, config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !isAuthenticated(r, config) {
+			// Scripts get an error instead of the login page
+			if bearerToken(r) != "" {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
 			// Redirect to the login page
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
@@ -84,6 +142,130 @@ func RequireAuth(next http.HandlerFunc, config *config.Config) http.HandlerFunc
 	}
 }
 
+// RequireScope is a middleware that checks that requests authenticated with
+// an API token have been granted a scope. Other requests are not affected.
+func RequireScope(scope string, next http.HandlerFunc, config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bearerToken(r) == "" {
+			next(w, r)
+			return
+		}
+
+		token, ok := requestToken(r, config)
+		if !ok {
+			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		if !token.HasScope(scope) {
+			http.Error(w, "The token doesn't have the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// dateLayout is the format used to display dates in the interface
+const dateLayout = "2006-01-02 15:04"
+
+// formatSize formats a size in bytes for display
+func formatSize(bytes int64) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	} else if bytes < 1024*1024 {
+		return fmt.Sprintf("%.1f KB", float64(bytes)/1024)
+	} else if bytes < 1024*1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
+	}
+	return fmt.Sprintf("%.1f GB", float64(bytes)/(1024*1024*1024))
+}
+
+// pathError is an error validating a path, with the status code to respond with
+type pathError struct {
+	status  int
+	message string
+}
+
+func (e *pathError) Error() string {
+	return e.message
+}
+
+// fileStorage returns the storage of the shared files. Without one, the
+// files are read from the configured directory.
+func fileStorage(config *config.Config) storage.Storage {
+	if config.Storage == nil {
+		local := storage.NewLocal(config.RootDir)
+		local.SetSymlinks(symlinkPolicy(config))
+		return local
+	}
+	return config.Storage
+}
+
+// cleanPath validates that filename is within the configured directory and
+// returns its name in the storage. Symbolic links are checked by the
+// storage with the configured policy.
+func cleanPath(config *config.Config, filename string) (string, *pathError) {
+	name, err := safepath.Clean(filename)
+	if errors.Is(err, safepath.ErrOutside) {
+		return "", &pathError{http.StatusForbidden, "Access denied"}
+	}
+	if err != nil {
+		return "", &pathError{http.StatusBadRequest, "Invalid path"}
+	}
+
+	// A link that the policy doesn't allow is denied even if it exists
+	info, err := fileStorage(config).Stat(name)
+	if errors.Is(err, fs.ErrPermission) {
+		return "", &pathError{http.StatusForbidden, "Access denied"}
+	}
+	// Excluded files don't exist for clients, even with a direct URL
+	if excluded(config, name, err == nil && info.IsDir()) {
+		return "", &pathError{http.StatusNotFound, "File not found"}
+	}
+	return name, nil
+}
+
+// resolvePath validates that filename is within the configured directory and
+// returns its name in the storage. If it isn't or it can't be accessed, an
+// error response is written to w.
+func resolvePath(w http.ResponseWriter, r *http.Request, config *config.Config, filename string) (string, bool) {
+	name, err := cleanPath(config, filename)
+	if err != nil {
+		http.Error(w, err.message, err.status)
+		return "", false
+	}
+
+	if !checkAccess(w, r, config, name) {
+		return "", false
+	}
+	// Dotfiles don't exist for who can't see them
+	if !visible(r, config, name) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return "", false
+	}
+
+	return name, true
+}
+
+// excludedFiles are the ShareIsCare system files hidden from listings
+var excludedFiles = map[string]bool{
+	"config.yaml":     true,
+	"shareiscare":     true,
+	"shareiscare.exe": true,
+	storage.BlobDir:   true,
+	ignore.FileName:   true,
+}
+
+// hiddenPath checks if a slash-separated path is or is inside a ShareIsCare
+// system file or a partial upload, which are never exposed
+func hiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if excludedFiles[part] || strings.HasPrefix(part, ".upload-") {
+			return true
+		}
+	}
+	return false
+}
+
 // getFileType determina el tipo de archivo basado en su extensión
 func getFileType(filename string) templates.FileType {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -101,71 +283,47 @@ func getFileType(filename string) templates.FileType {
 
 func Index(config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		files, err := os.ReadDir(config.RootDir)
+		files, err := fileStorage(config).ReadDir("")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// List of files to exclude
-		excludeFiles := map[string]bool{
-			"config.yaml":     true,
-			"shareiscare":     true,
-			"shareiscare.exe": true,
-		}
-
 		// Check if the user is authenticated and is admin
 		isLoggedIn := isAuthenticated(r, config)
 		isAdmin := false
 		if isLoggedIn {
-			sessionCookie, _ := r.Cookie("session")
-			parts := strings.Split(sessionCookie.Value, ":")
-			if len(parts) >= 1 {
-				isAdmin = parts[0] == config.Username
-			}
+			isAdmin = currentUser(r, config) == config.Username
 		}
 
 		var fileInfos []templates.FileInfo
-		for _, file := range files {
-			// Filter ShareIsCare system files
-			if excludeFiles[file.Name()] {
-				continue
-			}
-
-			filePath := filepath.Join(config.RootDir, file.Name())
-
-			// Get file information
-			info, err := os.Stat(filePath)
-			if err != nil {
+		for _, info := range files {
+			// Filter excluded files, dotfiles and private shares
+			if excluded(config, info.Name(), info.IsDir()) || !visible(r, config, info.Name()) || !canAccess(r, config, info.Name()) {
 				continue
 			}
 
 			// Format size
-			size := ""
+			size := folderSize(config, info.Name())
 			if !info.IsDir() {
-				bytes := info.Size()
-				if bytes < 1024 {
-					size = fmt.Sprintf("%d B", bytes)
-				} else if bytes < 1024*1024 {
-					size = fmt.Sprintf("%.1f KB", float64(bytes)/1024)
-				} else {
-					size = fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
-				}
-			} else {
-				size = "directory"
+				size = formatSize(info.Size())
 			}
 
 			fileType := templates.FileTypeUnknown
 			if !info.IsDir() {
-				fileType = getFileType(file.Name())
+				fileType = getFileType(info.Name())
 			}
 
 			fileInfos = append(fileInfos, templates.FileInfo{
-				Name:     file.Name(),
-				Path:     file.Name(),
+				Name:     info.Name(),
+				Title:    shareTitle(config, info.Name()),
+				Path:     info.Name(),
 				Size:     size,
+				Modified: info.ModTime().Format(dateLayout),
+				Expires:  fileExpiry(config, info.Name(), info),
 				IsDir:    info.IsDir(),
 				IsAdmin:  isAdmin,
+				ReadOnly: readOnlyPath(config, info.Name()),
 				FileType: fileType,
 			})
 		}
@@ -176,11 +334,7 @@ func Index(config *config.Config) http.HandlerFunc {
 		// Get the username if authenticated
 		username := ""
 		if isLoggedIn {
-			sessionCookie, _ := r.Cookie("session")
-			parts := strings.Split(sessionCookie.Value, ":")
-			if len(parts) >= 1 {
-				username = parts[0]
-			}
+			username = currentUser(r, config)
 		}
 
 		// Create breadcrumbs for navigation
@@ -195,12 +349,15 @@ func Index(config *config.Config) http.HandlerFunc {
 			Directory:   "",
 			Files:       fileInfos,
 			Breadcrumbs: breadcrumbs,
+			HasImages:   hasImages(fileInfos),
+			ReadOnly:    ReadOnly(config),
 		}
 
 		layoutData := templates.LayoutData{
 			Title:      config.Title,
 			IsLoggedIn: isLoggedIn,
 			Username:   username,
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -222,26 +379,14 @@ func Download(config *config.Config) http.HandlerFunc {
 		}
 
 		// Validate that the file is within the configured directory
-		fullPath := filepath.Join(config.RootDir, filename)
-		absRoot, err := filepath.Abs(config.RootDir)
-		if err != nil {
-			http.Error(w, "Configuration error", http.StatusInternalServerError)
-			return
-		}
-		absPath, err := filepath.Abs(fullPath)
-		if err != nil {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
-			return
-		}
-
-		rel, err := filepath.Rel(absRoot, absPath)
-		if err != nil || strings.HasPrefix(rel, "..") || strings.Contains(rel, "/../") {
-			http.Error(w, "Access denied", http.StatusForbidden)
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
 			return
 		}
+		store := fileStorage(config)
 
 		// Check if the file exists
-		fileInfo, err := os.Stat(fullPath)
+		fileInfo, err := store.Stat(name)
 		if err != nil {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
@@ -258,21 +403,20 @@ func Download(config *config.Config) http.HandlerFunc {
 			defer zipWriter.Close()
 
 			// Walk through the directory and add files to the zip
-			err = filepath.Walk(fullPath, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
+			err = storage.Walk(store, name, func(path string, info fs.FileInfo) error {
+				// Skip excluded files, dotfiles, and directories
+				if path != name && (excluded(config, path, info.IsDir()) || !visible(r, config, path)) {
+					if info.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
 				}
-
-				// Skip directories
 				if info.IsDir() {
 					return nil
 				}
 
 				// Create a relative path for the file in the zip
-				relPath, err := filepath.Rel(fullPath, path)
-				if err != nil {
-					return err
-				}
+				relPath := strings.TrimPrefix(path, name+"/")
 
 				// Create a new file in the zip
 				zipFile, err := zipWriter.Create(relPath)
@@ -281,7 +425,7 @@ func Download(config *config.Config) http.HandlerFunc {
 				}
 
 				// Open the file
-				file, err := os.Open(path)
+				file, err := store.Open(path)
 				if err != nil {
 					return err
 				}
@@ -302,7 +446,7 @@ func Download(config *config.Config) http.HandlerFunc {
 		}
 
 		// For regular files, serve them directly
-		file, err := os.Open(fullPath)
+		file, err := store.Open(name)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -312,13 +456,22 @@ func Download(config *config.Config) http.HandlerFunc {
 		// Configure headers to force download
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(filename)))
 		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
 
-		// Send the file
-		_, err = io.Copy(w, file)
-		if err != nil {
-			log.Printf("Error sending file: %v", err)
+		// Remove the GPS coordinates from JPEG images if requested
+		if r.URL.Query().Get("strip_gps") == "1" && isJPEG(filename) {
+			if err := metadata.StripGPS(w, file); err != nil {
+				log.Printf("Error removing GPS data: %v", err)
+			}
+			return
+		}
+
+		// Announce the checksum so the download can be verified
+		if digest, ok := downloadDigest(config, name, fileInfo); ok {
+			w.Header().Set("Digest", digest)
 		}
+
+		// Send the file, or the requested ranges of it
+		http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), file)
 	}
 }
 
@@ -327,7 +480,7 @@ func Login(config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// If already authenticated, redirect to the upload page
 		if isAuthenticated(r, config) {
-			http.Redirect(w, r, "/upload", http.StatusSeeOther)
+			http.Redirect(w, r, homePage(config), http.StatusSeeOther)
 			return
 		}
 
@@ -341,6 +494,7 @@ func Login(config *config.Config) http.HandlerFunc {
 			Title:      config.Title + " - Log in",
 			IsLoggedIn: false,
 			Username:   "",
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -371,7 +525,7 @@ func LoginPost(config *config.Config) http.HandlerFunc {
 			http.SetCookie(w, sessionCookie)
 
 			// Redirect to the upload page
-			http.Redirect(w, r, "/upload", http.StatusSeeOther)
+			http.Redirect(w, r, homePage(config), http.StatusSeeOther)
 			return
 		}
 
@@ -386,6 +540,7 @@ func LoginPost(config *config.Config) http.HandlerFunc {
 			Title:      config.Title + " - Log in",
 			IsLoggedIn: false,
 			Username:   "",
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -418,24 +573,23 @@ func Logout(config *config.Config) http.HandlerFunc {
 func Upload(config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the username
-		username := ""
-		sessionCookie, _ := r.Cookie("session")
-		parts := strings.Split(sessionCookie.Value, ":")
-		if len(parts) >= 1 {
-			username = parts[0]
-		}
+		username := currentUser(r, config)
 
 		data := templates.UploadData{
-			Title:     config.Title,
-			Directory: config.RootDir,
-			Success:   false,
-			Message:   "",
+			Title:        config.Title,
+			Directory:    config.RootDir,
+			Shares:       uploadShares(config),
+			Quotas:       uploadQuotas(config, username),
+			Success:      false,
+			Message:      "",
+			FolderExpiry: len(config.Expiry) > 0,
 		}
 
 		layoutData := templates.LayoutData{
 			Title:      config.Title + " - Upload files",
 			IsLoggedIn: true,
 			Username:   username,
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -447,88 +601,300 @@ func Upload(config *config.Config) http.HandlerFunc {
 	}
 }
 
+// maxFormFields is the maximum size of the fields of an upload form other
+// than the files
+const maxFormFields = 10 << 20
+
+// pendingUpload is a file of an upload form that has been written to the
+// storage but not committed yet
+type pendingUpload struct {
+	filename    string
+	name        string // Name in the storage
+	user        string
+	writer      storage.Writer
+	reservation *quota.Reservation // Nil if there are no quotas to enforce
+	sum         string             // SHA-256 checksum of the content
+	size        int64
+}
+
+// receiveUpload writes a file uploaded by user to the directory dir of the
+// storage as it's received, without committing it. The file must fit in
+// the quotas of the user and the folder, so the upload stops as soon as it
+// goes over them.
+func receiveUpload(config *config.Config, file io.Reader, filename, dir, user string) (*pendingUpload, error) {
+	// Excluded files can't be uploaded, they would disappear
+	dst := path.Join(dir, filename)
+	if excluded(config, dst, false) {
+		return nil, &excludedError{filename: filename}
+	}
+	// Neither can the files that the user wouldn't see
+	if !visibleTo(config, user, dst) {
+		return nil, &hiddenError{filename: filename}
+	}
+
+	// Reserve its space before storing anything
+	reservation, err := reserveQuota(config, user, dst)
+	if err != nil {
+		return nil, err
+	}
+	upload := &pendingUpload{filename: filename, name: dst, user: user, reservation: reservation}
+
+	// Create the destination
+	upload.writer, err = fileStorage(config).Create(dst)
+	if err != nil {
+		upload.discard()
+		return nil, fmt.Errorf("Error creating destination file: %w", err)
+	}
+
+	// Copy content while calculating its checksum, reserving the space of
+	// each chunk before writing it
+	hash := sha256.New()
+	limited := &quotaWriter{w: io.MultiWriter(upload.writer, hash), reservation: reservation, filename: filename}
+	if _, err := io.Copy(limited, file); err != nil {
+		upload.discard()
+		if quotaExceeded(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error saving file: %v", err)
+	}
+
+	upload.sum = hex.EncodeToString(hash.Sum(nil))
+	upload.size = limited.written
+	return upload, nil
+}
+
+// discard frees the reserved space of an upload, deleting the file if it
+// hasn't been committed
+func (u *pendingUpload) discard() {
+	if u.writer != nil {
+		u.writer.Close()
+	}
+	if u.reservation != nil {
+		u.reservation.Release()
+	}
+}
+
+// commit stores the file once, if an expected SHA-256 checksum is given,
+// its content has been verified. It's deleted after its lifetime, if it has
+// one.
+func (u *pendingUpload) commit(config *config.Config, expected string, lifetime time.Duration) error {
+	// Nothing to discard once the file has been committed
+	defer u.discard()
+
+	if expected != "" && u.sum != expected {
+		return fmt.Errorf("Checksum mismatch for %s: expected %s, got %s", u.filename, expected, u.sum)
+	}
+
+	store := fileStorage(config)
+	replaced := int64(-1)
+	if info, err := store.Stat(u.name); err == nil && !info.IsDir() {
+		replaced = info.Size()
+	}
+
+	if err := u.writer.Commit(); err != nil {
+		return fmt.Errorf("Error saving file: %v", err)
+	}
+	recordOwner(config, u.name, u.user)
+	storedUpload(config, u.name, u.size, replaced)
+
+	info, err := store.Stat(u.name)
+	if err != nil {
+		log.Printf("Error reading uploaded file: %v", err)
+		return nil
+	}
+
+	// The checksum is already known, save it for later downloads
+	if err := checksumCache(config).StoreInfo(checksumKey(config, u.name), info, checksum.SHA256, u.sum); err != nil {
+		log.Printf("Error caching checksum: %v", err)
+	}
+
+	if lifetime == defaultLifetime {
+		dir := path.Dir(u.name)
+		if dir == "." {
+			dir = ""
+		}
+		lifetime = folderLifetime(config, dir)
+	}
+	setExpiry(config, u.name, info, lifetime)
+
+	return nil
+}
+
+// receivedForm is an upload form that has been read, with its files
+// written to the storage but not committed yet
+type receivedForm struct {
+	fields  url.Values
+	files   int // Files sent, including the rejected ones
+	uploads []*pendingUpload
+	errors  []error // Why the rejected files couldn't be received
+}
+
+// receiveForm reads a multipart upload form by user as it's received,
+// instead of spooling it first, so that the quotas stop the uploads that
+// go over them. The destination directory is chosen by destination with
+// the fields sent before the first file. The checksums and the lifetime of
+// the files can come after them, so the files must be committed or
+// discarded once the whole form has been read.
+func receiveForm(r *http.Request, config *config.Config, user string, destination func(fields url.Values) (string, error)) (*receivedForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("Error processing form: %v", err)
+	}
+
+	form := &receivedForm{fields: url.Values{}}
+	dir := ""
+	left := int64(maxFormFields)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			form.discard()
+			return nil, fmt.Errorf("Error processing form: %v", err)
+		}
+
+		if part.FormName() != "files" || part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, left+1))
+			left -= int64(len(value))
+			if err != nil || left < 0 {
+				form.discard()
+				return nil, errors.New("Error processing form: the fields are too large")
+			}
+			form.fields.Add(part.FormName(), string(value))
+			continue
+		}
+
+		if form.files == 0 {
+			if dir, err = destination(form.fields); err != nil {
+				form.discard()
+				return nil, err
+			}
+		}
+		form.files++
+
+		upload, err := receiveUpload(config, part, part.FileName(), dir, user)
+		if err != nil {
+			log.Printf("%s", err)
+			form.errors = append(form.errors, err)
+			continue
+		}
+		form.uploads = append(form.uploads, upload)
+	}
+}
+
+// commit stores the received files with the checksums given in the form
+// and lifetime, returning the ones that have been stored. The errors of
+// the others are added to the form.
+func (f *receivedForm) commit(config *config.Config, lifetime time.Duration) []*pendingUpload {
+	expected := parseChecksums(f.fields.Get("checksums"))
+
+	var stored []*pendingUpload
+	for _, upload := range f.uploads {
+		if err := upload.commit(config, expected[upload.filename], lifetime); err != nil {
+			log.Printf("%s", err)
+			f.errors = append(f.errors, err)
+			continue
+		}
+		stored = append(stored, upload)
+	}
+	f.uploads = nil
+	return stored
+}
+
+// discard deletes the received files that haven't been committed
+func (f *receivedForm) discard() {
+	for _, upload := range f.uploads {
+		upload.discard()
+	}
+	f.uploads = nil
+}
+
 // Route to process file uploads (POST) - protected
 func UploadPost(config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Limit the maximum form size to 32MB
-		r.ParseMultipartForm(32 << 20)
-
-		// Get the uploaded files
-		files := r.MultipartForm.File["files"]
-		if len(files) == 0 {
+		// Page for the forms rejected before storing any file
+		rejected := func(message string) {
 			data := templates.UploadData{
 				Title:     config.Title,
 				Directory: config.RootDir,
+				Shares:    uploadShares(config),
 				Success:   false,
-				Message:   "No files have been selected",
+				Message:   message,
 			}
 			templ.Handler(templates.Upload(data)).ServeHTTP(w, r)
+		}
+
+		if rejectReadOnly(w, config, "") {
 			return
 		}
+		username := currentUser(r, config)
+
+		// The files are read and stored as they arrive
+		readOnly := false
+		form, err := receiveForm(r, config, username, func(fields url.Values) (string, error) {
+			// With shares, the files are uploaded to the chosen one
+			dir := ""
+			if len(config.Shares) > 0 {
+				dir = fields.Get("share")
+				if readOnlyPath(config, dir) {
+					readOnly = true
+					return "", errors.New("The files are read-only")
+				}
+				if share := shareOf(config, dir); share == nil || dir == "" {
+					return "", errors.New("Invalid share: " + dir)
+				}
+			}
 
-		// Validation: ensure that the destination directory exists and has permissions
-		absRoot, err := filepath.Abs(config.RootDir)
-		if err != nil {
-			data := templates.UploadData{
-				Title:     config.Title,
-				Directory: config.RootDir,
-				Success:   false,
-				Message:   "Configuration error: " + err.Error(),
+			// Validation: ensure that the destination directory exists
+			if _, err := fileStorage(config).Stat(dir); err != nil {
+				return "", errors.New("Error accessing destination directory: " + err.Error())
 			}
-			templ.Handler(templates.Upload(data)).ServeHTTP(w, r)
+			return dir, nil
+		})
+		if readOnly {
+			http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			rejected(err.Error())
+			return
+		}
+		if form.files == 0 {
+			rejected("No files have been selected")
 			return
 		}
 
-		// Check write permissions
-		if _, err := os.Stat(absRoot); err != nil {
-			data := templates.UploadData{
-				Title:     config.Title,
-				Directory: config.RootDir,
-				Success:   false,
-				Message:   "Error accessing destination directory: " + err.Error(),
-			}
-			templ.Handler(templates.Upload(data)).ServeHTTP(w, r)
+		// Lifetime of the files (optional)
+		lifetime, err := parseLifetime(form.fields.Get("expires"))
+		if err != nil {
+			form.discard()
+			rejected(err.Error())
 			return
 		}
 
 		uploadedFiles := []string{}
-		var errorMessage string
-
-		// Process each file
-		for _, fileHeader := range files {
-			// Get the file
-			file, err := fileHeader.Open()
-			if err != nil {
-				log.Printf("Error opening file: %v", err)
-				continue
-			}
-			defer file.Close()
-
-			// Create the destination
-			dst, err := os.Create(filepath.Join(absRoot, fileHeader.Filename))
-			if err != nil {
-				errorMessage = "Error creating destination file: " + err.Error()
-				log.Printf("%s", errorMessage)
-				continue
-			}
-			defer dst.Close()
-
-			// Copy content
-			if _, err = io.Copy(dst, file); err != nil {
-				errorMessage = "Error saving file: " + err.Error()
-				log.Printf("%s", errorMessage)
-				continue
-			}
+		for _, upload := range form.commit(config, lifetime) {
+			uploadedFiles = append(uploadedFiles, upload.filename)
+		}
 
-			uploadedFiles = append(uploadedFiles, fileHeader.Filename)
+		var errorMessage string
+		exceeded := false
+		for _, err := range form.errors {
+			errorMessage = err.Error()
+			exceeded = exceeded || quotaExceeded(err)
 		}
 
 		// Prepare response
 		data := templates.UploadData{
-			Title:     config.Title,
-			Directory: config.RootDir,
-			Success:   len(uploadedFiles) > 0,
-			Message:   "",
+			Title:         config.Title,
+			Directory:     config.RootDir,
+			Shares:        uploadShares(config),
+			Quotas:        uploadQuotas(config, username),
+			Success:       len(uploadedFiles) > 0,
+			Message:       "",
+			QuotaExceeded: exceeded,
+			FolderExpiry:  len(config.Expiry) > 0,
 		}
 
 		if len(uploadedFiles) > 0 {
@@ -537,24 +903,21 @@ func UploadPost(config *config.Config) http.HandlerFunc {
 			} else {
 				data.Message = fmt.Sprintf("%d files uploaded successfully", len(uploadedFiles))
 			}
+			// Some files may not have fit in the quotas
+			if exceeded {
+				data.Message += ". " + errorMessage
+			}
 		} else if errorMessage != "" {
 			data.Message = errorMessage
 		} else {
 			data.Message = "No files could be processed"
 		}
 
-		// Get the username
-		username := ""
-		sessionCookie, _ := r.Cookie("session")
-		parts := strings.Split(sessionCookie.Value, ":")
-		if len(parts) >= 1 {
-			username = parts[0]
-		}
-
 		layoutData := templates.LayoutData{
 			Title:      config.Title + " - Upload files",
 			IsLoggedIn: true,
 			Username:   username,
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -578,26 +941,14 @@ func Browse(config *config.Config) http.HandlerFunc {
 		}
 
 		// Validate that the path is within the configured directory
-		fullPath := filepath.Join(config.RootDir, path)
-		absRoot, err := filepath.Abs(config.RootDir)
-		if err != nil {
-			http.Error(w, "Configuration error", http.StatusInternalServerError)
-			return
-		}
-		absPath, err := filepath.Abs(fullPath)
-		if err != nil {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
-			return
-		}
-
-		rel, err := filepath.Rel(absRoot, absPath)
-		if err != nil || strings.HasPrefix(rel, "..") || strings.Contains(rel, "/../") {
-			http.Error(w, "Access denied", http.StatusForbidden)
+		name, ok := resolvePath(w, r, config, path)
+		if !ok {
 			return
 		}
+		store := fileStorage(config)
 
 		// Check if the path exists and is a directory
-		fileInfo, err := os.Stat(fullPath)
+		fileInfo, err := store.Stat(name)
 		if err != nil {
 			http.Error(w, "Path not found", http.StatusNotFound)
 			return
@@ -609,74 +960,49 @@ func Browse(config *config.Config) http.HandlerFunc {
 		}
 
 		// List files in the directory
-		files, err := os.ReadDir(fullPath)
+		files, err := store.ReadDir(name)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// List of files to exclude
-		excludeFiles := map[string]bool{
-			"config.yaml":     true,
-			"shareiscare":     true,
-			"shareiscare.exe": true,
-		}
-
 		// Check if the user is authenticated and is admin
 		isLoggedIn := isAuthenticated(r, config)
 		isAdmin := false
 		if isLoggedIn {
-			sessionCookie, _ := r.Cookie("session")
-			parts := strings.Split(sessionCookie.Value, ":")
-			if len(parts) >= 1 {
-				isAdmin = parts[0] == config.Username
-			}
+			isAdmin = currentUser(r, config) == config.Username
 		}
 
 		var fileInfos []templates.FileInfo
-		for _, file := range files {
-			// Filter ShareIsCare system files
-			if excludeFiles[file.Name()] {
+		for _, info := range files {
+			// Filter excluded files and dotfiles
+			if excluded(config, name+"/"+info.Name(), info.IsDir()) || !visible(r, config, info.Name()) {
 				continue
 			}
 
-			filePath := filepath.Join(fullPath, file.Name())
-
-			// Get file information
-			info, err := os.Stat(filePath)
-			if err != nil {
-				continue
-			}
+			// Create relative path for links
+			relPath := filepath.Join(path, info.Name())
 
 			// Format size
-			size := ""
+			size := folderSize(config, relPath)
 			if !info.IsDir() {
-				bytes := info.Size()
-				if bytes < 1024 {
-					size = fmt.Sprintf("%d B", bytes)
-				} else if bytes < 1024*1024 {
-					size = fmt.Sprintf("%.1f KB", float64(bytes)/1024)
-				} else {
-					size = fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
-				}
-			} else {
-				size = "directory"
+				size = formatSize(info.Size())
 			}
 
-			// Create relative path for links
-			relPath := filepath.Join(path, file.Name())
-
 			fileType := templates.FileTypeUnknown
 			if !info.IsDir() {
-				fileType = getFileType(file.Name())
+				fileType = getFileType(info.Name())
 			}
 
 			fileInfos = append(fileInfos, templates.FileInfo{
-				Name:     file.Name(),
+				Name:     info.Name(),
 				Path:     relPath,
 				Size:     size,
+				Modified: info.ModTime().Format(dateLayout),
+				Expires:  fileExpiry(config, relPath, info),
 				IsDir:    info.IsDir(),
 				IsAdmin:  isAdmin,
+				ReadOnly: readOnlyPath(config, name),
 				FileType: fileType,
 			})
 		}
@@ -684,11 +1010,7 @@ func Browse(config *config.Config) http.HandlerFunc {
 		// Get the username if authenticated
 		username := ""
 		if isLoggedIn {
-			sessionCookie, _ := r.Cookie("session")
-			parts := strings.Split(sessionCookie.Value, ":")
-			if len(parts) >= 1 {
-				username = parts[0]
-			}
+			username = currentUser(r, config)
 		}
 
 		// Create breadcrumbs for navigation
@@ -710,17 +1032,26 @@ func Browse(config *config.Config) http.HandlerFunc {
 			}
 		}
 
+		// Listings of a share show its title
+		title := config.Title
+		if t := shareTitle(config, name); t != "" {
+			title = t
+		}
+
 		data := templates.IndexData{
-			Title:       config.Title,
+			Title:       title,
 			Directory:   path,
 			Files:       fileInfos,
 			Breadcrumbs: breadcrumbs,
+			HasImages:   hasImages(fileInfos),
+			ReadOnly:    readOnlyPath(config, name),
 		}
 
 		layoutData := templates.LayoutData{
 			Title:      config.Title + " - Browse",
 			IsLoggedIn: isLoggedIn,
 			Username:   username,
+			ReadOnly:   ReadOnly(config),
 		}
 
 		// Render the template with the layout
@@ -735,6 +1066,16 @@ func Browse(config *config.Config) http.HandlerFunc {
 // requireAdmin is a middleware that checks if the user is an admin
 func RequireAdmin(next http.HandlerFunc, config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Tokens act as the user that created them
+		if token, ok := requestToken(r, config); ok {
+			if token.Username != config.Username {
+				http.Error(w, "Admin access required", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+			return
+		}
+
 		sessionCookie, err := r.Cookie("session")
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -773,32 +1114,28 @@ func Delete(config *config.Config) http.HandlerFunc {
 		}
 
 		// Validate that the file is within the configured directory
-		fullPath := filepath.Join(config.RootDir, filename)
-		absRoot, err := filepath.Abs(config.RootDir)
-		if err != nil {
-			http.Error(w, "Configuration error", http.StatusInternalServerError)
+		name, pathErr := cleanPath(config, filename)
+		if pathErr != nil {
+			http.Error(w, pathErr.message, pathErr.status)
 			return
 		}
-		absPath, err := filepath.Abs(fullPath)
-		if err != nil {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
-			return
-		}
-
-		rel, err := filepath.Rel(absRoot, absPath)
-		if err != nil || strings.HasPrefix(rel, "..") || strings.Contains(rel, "/../") {
-			http.Error(w, "Access denied", http.StatusForbidden)
+		if rejectReadOnly(w, config, name) {
 			return
 		}
+		store := fileStorage(config)
 
 		// Check if the file exists
-		if _, err := os.Stat(fullPath); err != nil {
+		if _, err := store.Stat(name); err != nil {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
 
 		// Delete the file
-		err = os.Remove(fullPath)
+		err = store.Remove(name)
+		if errors.Is(err, fs.ErrPermission) {
+			http.Error(w, "The file can't be deleted", http.StatusForbidden)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Error deleting file", http.StatusInternalServerError)
 			return
@@ -824,26 +1161,20 @@ func Preview(config *config.Config) http.HandlerFunc {
 		}
 
 		// Validate that the file is within the configured directory
-		fullPath := filepath.Join(config.RootDir, filename)
-		absRoot, err := filepath.Abs(config.RootDir)
-		if err != nil {
-			http.Error(w, "Configuration error", http.StatusInternalServerError)
-			return
-		}
-		absPath, err := filepath.Abs(fullPath)
-		if err != nil {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
 			return
 		}
 
-		rel, err := filepath.Rel(absRoot, absPath)
-		if err != nil || strings.HasPrefix(rel, "..") || strings.Contains(rel, "/../") {
-			http.Error(w, "Access denied", http.StatusForbidden)
+		// Check if the file exists
+		file, err := fileStorage(config).Open(name)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		defer file.Close()
 
-		// Check if the file exists
-		fileInfo, err := os.Stat(fullPath)
+		fileInfo, err := file.Stat()
 		if err != nil {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
@@ -897,6 +1228,6 @@ func Preview(config *config.Config) http.HandlerFunc {
 		}
 
 		// Serve the file
-		http.ServeFile(w, r, fullPath)
+		http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), file)
 	}
 }
@@ -10,13 +10,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/storage"
 	"github.com/rodrwan/shareiscare/templates"
 )
 
@@ -31,6 +31,9 @@ func setupTestConfig() *config.Config {
 	// Crear directorio temporal para pruebas
 	tempDir, _ := os.MkdirTemp("", "shareiscare-test")
 
+	// La caché se guarda fuera del directorio compartido
+	cacheDir, _ := os.MkdirTemp("", "shareiscare-cache")
+
 	return &config.Config{
 		Port:      8080,
 		RootDir:   tempDir,
@@ -38,12 +41,37 @@ func setupTestConfig() *config.Config {
 		Username:  "testuser",
 		Password:  "testpass",
 		SecretKey: "test-secret-key",
+		CacheDir:  cacheDir,
+		Storage:   storage.NewMemory(),
+	}
+}
+
+// writeTestFile crea un archivo en el almacenamiento de la configuración
+func writeTestFile(t *testing.T, cfg *config.Config, name, content string) {
+	t.Helper()
+	if err := storage.WriteFile(cfg.Storage, name, []byte(content)); err != nil {
+		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
 	}
 }
 
+// fileExists comprueba si un archivo existe en el almacenamiento de la configuración
+func fileExists(cfg *config.Config, name string) bool {
+	_, err := cfg.Storage.Stat(name)
+	return err == nil
+}
+
+// setupDiskConfig es como setupTestConfig pero guarda los archivos en el
+// disco, para los servicios que leen el directorio compartido directamente
+func setupDiskConfig() *config.Config {
+	cfg := setupTestConfig()
+	cfg.Storage = storage.NewLocal(cfg.RootDir)
+	return cfg
+}
+
 // cleanup después de las pruebas
 func cleanupTestConfig(cfg *config.Config) {
 	os.RemoveAll(cfg.RootDir)
+	os.RemoveAll(cfg.CacheDir)
 }
 
 // Mock para templ.Component
@@ -315,13 +343,9 @@ func TestIndex(t *testing.T) {
 	defer cleanupTestConfig(cfg)
 
 	// Crear algunos archivos de prueba
-	testFilePath := filepath.Join(cfg.RootDir, "archivo-test.txt")
-	if err := os.WriteFile(testFilePath, []byte("Contenido de prueba"), 0644); err != nil {
-		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
-	}
+	writeTestFile(t, cfg, "archivo-test.txt", "Contenido de prueba")
 
-	testDirPath := filepath.Join(cfg.RootDir, "directorio-test")
-	if err := os.Mkdir(testDirPath, 0755); err != nil {
+	if err := cfg.Storage.MkdirAll("directorio-test"); err != nil {
 		t.Fatalf("No se pudo crear directorio de prueba: %v", err)
 	}
 
@@ -528,37 +552,19 @@ func TestUploadPost(t *testing.T) {
 	cfg := setupTestConfig()
 	defer cleanupTestConfig(cfg)
 
-	// Crear un archivo temporal para simular la carga
-	tempFile, err := os.CreateTemp("", "test-upload-*.txt")
-	if err != nil {
-		t.Fatalf("No se pudo crear archivo temporal: %v", err)
-	}
-	defer os.Remove(tempFile.Name())
-
-	// Escribir contenido en el archivo
+	// Contenido del archivo a subir
 	content := "Contenido de prueba para upload"
-	if _, err := tempFile.Write([]byte(content)); err != nil {
-		t.Fatalf("No se pudo escribir en archivo temporal: %v", err)
-	}
-	tempFile.Close()
 
 	// Preparar el formulario multipart
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
-	// Abrir el archivo para leerlo
-	file, err := os.Open(tempFile.Name())
-	if err != nil {
-		t.Fatalf("No se pudo abrir archivo temporal: %v", err)
-	}
-	defer file.Close()
-
 	// Añadir el archivo al formulario
 	part, err := writer.CreateFormFile("files", "test-upload.txt")
 	if err != nil {
 		t.Fatalf("No se pudo crear parte del formulario: %v", err)
 	}
-	if _, err = io.Copy(part, file); err != nil {
+	if _, err = io.Copy(part, strings.NewReader(content)); err != nil {
 		t.Fatalf("No se pudo copiar contenido al formulario: %v", err)
 	}
 	writer.Close()
@@ -595,13 +601,12 @@ func TestUploadPost(t *testing.T) {
 		t.Error("respuesta no indica éxito en la subida del archivo")
 	}
 
-	// Verificar que el archivo fue guardado en el directorio configurado
-	uploadedFilePath := filepath.Join(cfg.RootDir, "test-upload.txt")
-	if _, err := os.Stat(uploadedFilePath); os.IsNotExist(err) {
-		t.Errorf("el archivo no fue guardado en %s", uploadedFilePath)
+	// Verificar que el archivo fue guardado en el almacenamiento configurado
+	if !fileExists(cfg, "test-upload.txt") {
+		t.Error("el archivo test-upload.txt no fue guardado")
 	} else {
 		// Verificar el contenido del archivo
-		uploadedContent, err := os.ReadFile(uploadedFilePath)
+		uploadedContent, err := storage.ReadFile(cfg.Storage, "test-upload.txt")
 		if err != nil {
 			t.Errorf("no se pudo leer el archivo subido: %v", err)
 		} else if string(uploadedContent) != content {
@@ -615,17 +620,8 @@ func TestBrowse(t *testing.T) {
 	cfg := setupTestConfig()
 	defer cleanupTestConfig(cfg)
 
-	// Crear una estructura de directorios para probar
-	testDir := filepath.Join(cfg.RootDir, "testdir")
-	if err := os.Mkdir(testDir, 0755); err != nil {
-		t.Fatalf("No se pudo crear directorio de prueba: %v", err)
-	}
-
-	// Crear un archivo dentro del directorio
-	testFilePath := filepath.Join(testDir, "testfile.txt")
-	if err := os.WriteFile(testFilePath, []byte("Archivo de prueba"), 0644); err != nil {
-		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
-	}
+	// Crear un directorio con un archivo dentro para probar
+	writeTestFile(t, cfg, "testdir/testfile.txt", "Archivo de prueba")
 
 	// Caso 1: Probar navegación a un directorio válido
 	req := httptest.NewRequest(http.MethodGet, "/browse/testdir", nil)
@@ -793,15 +789,7 @@ func TestDelete(t *testing.T) {
 	defer cleanupTestConfig(cfg)
 
 	// Crear un archivo para eliminar
-	testFilePath := filepath.Join(cfg.RootDir, "archivo-a-eliminar.txt")
-	if err := os.WriteFile(testFilePath, []byte("Contenido de prueba"), 0644); err != nil {
-		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
-	}
-
-	// Verificar que el archivo existe inicialmente
-	if _, err := os.Stat(testFilePath); os.IsNotExist(err) {
-		t.Fatal("El archivo de prueba no se creó correctamente")
-	}
+	writeTestFile(t, cfg, "archivo-a-eliminar.txt", "Contenido de prueba")
 
 	// Caso 1: Intento de eliminar sin autenticación
 	req := httptest.NewRequest(http.MethodPost, "/delete?filename=archivo-a-eliminar.txt", nil)
@@ -819,17 +807,9 @@ func TestDelete(t *testing.T) {
 			res.Code, http.StatusSeeOther, http.StatusUnauthorized, http.StatusForbidden)
 	}
 
-	// Verificar si el archivo sigue existiendo después del intento
-	fileExists := true
-	if _, err := os.Stat(testFilePath); os.IsNotExist(err) {
-		fileExists = false
-	}
-
 	// Creamos de nuevo el archivo si fue eliminado
-	if !fileExists {
-		if err := os.WriteFile(testFilePath, []byte("Contenido de prueba"), 0644); err != nil {
-			t.Fatalf("No se pudo recrear archivo de prueba: %v", err)
-		}
+	if !fileExists(cfg, "archivo-a-eliminar.txt") {
+		writeTestFile(t, cfg, "archivo-a-eliminar.txt", "Contenido de prueba")
 	}
 
 	// Caso 2: Eliminar como admin
@@ -855,20 +835,8 @@ func TestDelete(t *testing.T) {
 			res.Code, http.StatusSeeOther, http.StatusOK)
 	}
 
-	// Vemos si el archivo fue eliminado (puede tomar un momento)
-	// Intentar varias veces
-	maxRetries := 5
-	deleted := false
-	for i := 0; i < maxRetries; i++ {
-		if _, err := os.Stat(testFilePath); os.IsNotExist(err) {
-			deleted = true
-			break
-		}
-		time.Sleep(100 * time.Millisecond) // pequeña pausa
-	}
-
-	if !deleted {
-		t.Error("El archivo no fue eliminado después de varios intentos")
+	if fileExists(cfg, "archivo-a-eliminar.txt") {
+		t.Error("El archivo no fue eliminado")
 	}
 }
 
@@ -878,15 +846,8 @@ func TestPreview(t *testing.T) {
 	defer cleanupTestConfig(cfg)
 
 	// Crear diferentes tipos de archivos para probar
-	imgFilePath := filepath.Join(cfg.RootDir, "imagen.jpg")
-	if err := os.WriteFile(imgFilePath, []byte("datos de imagen simulados"), 0644); err != nil {
-		t.Fatalf("No se pudo crear archivo de imagen: %v", err)
-	}
-
-	textFilePath := filepath.Join(cfg.RootDir, "documento.txt")
-	if err := os.WriteFile(textFilePath, []byte("Contenido de texto de prueba"), 0644); err != nil {
-		t.Fatalf("No se pudo crear archivo de texto: %v", err)
-	}
+	writeTestFile(t, cfg, "imagen.jpg", "datos de imagen simulados")
+	writeTestFile(t, cfg, "documento.txt", "Contenido de texto de prueba")
 
 	// Caso 1: Vista previa de imagen
 	req := httptest.NewRequest(http.MethodGet, "/preview?filename=imagen.jpg", nil)
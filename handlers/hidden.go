@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// osMetadataFiles are the files that operating systems leave in folders
+var osMetadataFiles = map[string]bool{
+	".DS_Store":   true,
+	"Thumbs.db":   true,
+	"desktop.ini": true,
+}
+
+// dotfile checks if a slash-separated path is or is inside a dotfile or an
+// OS metadata file, which are only shown as allowed by show_hidden
+func dotfile(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || osMetadataFiles[part] {
+			return true
+		}
+	}
+	return false
+}
+
+// showDotfiles checks if a user can see dotfiles and OS metadata files. The
+// user is "" for anonymous clients.
+func showDotfiles(cfg *config.Config, user string) bool {
+	switch cfg.ShowHidden {
+	case config.HiddenAlways:
+		return true
+	case config.HiddenNever:
+		return false
+	}
+	// Only the admin by default
+	return user != "" && user == cfg.Username
+}
+
+// visibleTo checks if a user can see a storage name, which isn't a dotfile
+// or the user may see dotfiles
+func visibleTo(config *config.Config, user, name string) bool {
+	return !dotfile(name) || showDotfiles(config, user)
+}
+
+// visible is visibleTo for the user of a request
+func visible(r *http.Request, config *config.Config, name string) bool {
+	return visibleTo(config, currentUser(r, config), name)
+}
+
+// hiddenError is a change rejected because its name is hidden from the user
+// that makes it, who would never see the result
+type hiddenError struct {
+	filename string
+}
+
+func (e *hiddenError) Error() string {
+	return e.filename + " is a hidden file, it can't be created"
+}
+
+func (e *hiddenError) Unwrap() error {
+	return fs.ErrPermission
+}
+
+// apiRejectHidden responds with a 403 error if the user of the request can't
+// see name, so it can't create it either
+func apiRejectHidden(w http.ResponseWriter, r *http.Request, config *config.Config, name string) bool {
+	if visible(r, config, name) {
+		return false
+	}
+	apiError(w, http.StatusForbidden, (&hiddenError{filename: name}).Error())
+	return true
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/diskusage"
+	"github.com/rodrwan/shareiscare/s3"
+)
+
+func TestDotfile(t *testing.T) {
+	tests := map[string]bool{
+		"foto.jpg":          false,
+		"docs/nota.txt":     false,
+		".env":              true,
+		".git/config":       true,
+		"proyecto/.git":     true,
+		"fotos/.DS_Store":   true,
+		"fotos/Thumbs.db":   true,
+		"fotos/thumbs.jpg":  false,
+		"docs/nota.txt.bak": false,
+	}
+	for name, expected := range tests {
+		if got := dotfile(name); got != expected {
+			t.Errorf("dotfile(%q) = %v, se esperaba %v", name, got, expected)
+		}
+	}
+}
+
+func TestShowHidden(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	cfg.ShowHidden = config.HiddenAdmins
+
+	for name, content := range map[string]string{
+		"foto.jpg":          "jpg",
+		".env":              "SECRETO=1",
+		"docs/nota.txt":     "hola",
+		"docs/.DS_Store":    "meta",
+		"docs/.git/config":  "[core]",
+		"fotos/Thumbs.db":   "meta",
+		"fotos/playa.jpg":   "jpg",
+		"fotos/.oculta.jpg": "jpg",
+	} {
+		writeTestFile(t, cfg, name, content)
+	}
+
+	anonymous := func(target string) *http.Request { return httptest.NewRequest("GET", target, nil) }
+	admin := func(target string) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		return req
+	}
+
+	// listed hace un listado y devuelve su cuerpo
+	listed := func(handler http.HandlerFunc, req *http.Request) string {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: código %d", req.URL, rr.Code)
+		}
+		return rr.Body.String()
+	}
+	// status devuelve el código de una descarga o vista previa
+	status := func(handler http.HandlerFunc, req *http.Request) int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Por defecto solo el administrador los ve
+	if body := listed(Index(cfg), anonymous("/")); strings.Contains(body, ".env") || !strings.Contains(body, "foto.jpg") {
+		t.Error("Un visitante no debería ver .env en la raíz")
+	}
+	if body := listed(Index(cfg), admin("/")); !strings.Contains(body, ".env") {
+		t.Error("El administrador debería ver .env en la raíz")
+	}
+	if body := listed(Browse(cfg), anonymous("/browse/docs")); strings.Contains(body, ".DS_Store") || strings.Contains(body, ".git") {
+		t.Error("Un visitante no debería ver los archivos ocultos de docs")
+	}
+	if body := listed(Browse(cfg), admin("/browse/docs")); !strings.Contains(body, ".DS_Store") {
+		t.Error("El administrador debería ver .DS_Store en docs")
+	}
+	if code := status(Download(cfg), anonymous("/download?filename=.env")); code != http.StatusNotFound {
+		t.Errorf("Un visitante no debería descargar .env: código %d", code)
+	}
+	if code := status(Download(cfg), anonymous("/download?filename=docs/.git/config")); code != http.StatusNotFound {
+		t.Errorf("Un visitante no debería descargar archivos de .git: código %d", code)
+	}
+	if code := status(Preview(cfg), anonymous("/preview?filename=fotos/.oculta.jpg")); code != http.StatusNotFound {
+		t.Errorf("Un visitante no debería ver la vista previa de un archivo oculto: código %d", code)
+	}
+	if code := status(Download(cfg), admin("/download?filename=.env")); code != http.StatusOK {
+		t.Errorf("El administrador debería descargar .env: código %d", code)
+	}
+	if code := status(Download(cfg), anonymous("/download?filename=foto.jpg")); code != http.StatusOK {
+		t.Errorf("Los archivos visibles se deberían poder descargar: código %d", code)
+	}
+
+	// Con never nadie los ve
+	cfg.ShowHidden = config.HiddenNever
+	if body := listed(Index(cfg), admin("/")); strings.Contains(body, ".env") {
+		t.Error("Con never ni el administrador debería ver .env")
+	}
+	if code := status(Download(cfg), admin("/download?filename=.env")); code != http.StatusNotFound {
+		t.Errorf("Con never ni el administrador debería descargar .env: código %d", code)
+	}
+	rr := httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, admin("/api/v1/files?path=/fotos"))
+	if strings.Contains(rr.Body.String(), "Thumbs.db") || !strings.Contains(rr.Body.String(), "playa.jpg") {
+		t.Errorf("La API no debería listar Thumbs.db: %s", rr.Body.String())
+	}
+
+	// Con always todos los ven
+	cfg.ShowHidden = config.HiddenAlways
+	if body := listed(Browse(cfg), anonymous("/browse/fotos")); !strings.Contains(body, "Thumbs.db") {
+		t.Error("Con always un visitante debería ver Thumbs.db")
+	}
+	if code := status(Download(cfg), anonymous("/download?filename=.env")); code != http.StatusOK {
+		t.Errorf("Con always un visitante debería descargar .env: código %d", code)
+	}
+}
+
+func TestHiddenChanges(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	cfg.ShowHidden = config.HiddenNever
+	writeTestFile(t, cfg, "foto.jpg", "jpg")
+
+	// upload sube un archivo a la raíz con la API
+	upload := func(name string) *httptest.ResponseRecorder {
+		req := newUploadRequest(t, cfg, "/api/v1/upload", map[string]string{name: "SECRETO=1"}, nil)
+		rr := httptest.NewRecorder()
+		API(cfg).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Nadie puede crear archivos que no vería
+	checkAPIError(t, upload(".env"), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/upload?path=.cache", nil, cfg.Username), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/mkdir", strings.NewReader(`{"path":"docs/.git"}`), cfg.Username), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, http.MethodPost, "/api/v1/move", strings.NewReader(`{"from":"foto.jpg","to":".foto.jpg"}`), cfg.Username), http.StatusForbidden)
+
+	rr := httptest.NewRecorder()
+	UploadPost(cfg).ServeHTTP(rr, newUploadRequest(t, cfg, "/upload", map[string]string{"Thumbs.db": "meta"}, nil))
+	if !strings.Contains(rr.Body.String(), "hidden file") {
+		t.Errorf("La página debería explicar por qué no se subió: %s", rr.Body.String())
+	}
+	for _, name := range []string{".env", "docs/.git", ".foto.jpg", "Thumbs.db"} {
+		if fileExists(cfg, name) {
+			t.Errorf("%s no debería haberse creado", name)
+		}
+	}
+	if !fileExists(cfg, "foto.jpg") {
+		t.Error("foto.jpg no debería haberse movido")
+	}
+
+	// Con always se pueden crear
+	cfg.ShowHidden = config.HiddenAlways
+	if rr := upload(".env"); rr.Code != http.StatusCreated || !fileExists(cfg, ".env") {
+		t.Errorf("Con always se debería poder subir .env: código %d", rr.Code)
+	}
+}
+
+func TestHiddenUsage(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	cfg.ShowHidden = config.HiddenAdmins
+
+	for name, size := range map[string]int{".git/objects/pack": 4096, "fotos/a.jpg": 2048} {
+		p := filepath.Join(cfg.RootDir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatalf("No se pudo crear archivo de prueba: %v", err)
+		}
+	}
+	cfg.Usage = diskusage.NewScanner(cfg.RootDir)
+	if err := cfg.Usage.Scan(); err != nil {
+		t.Fatalf("Error al escanear: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	DiskUsage(cfg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	if body := rr.Body.String(); strings.Contains(body, ".git") || !strings.Contains(body, "fotos/a.jpg") {
+		t.Error("Un visitante no debería ver .git en el uso del disco")
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	DiskUsage(cfg).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), ".git/objects/pack") {
+		t.Error("El administrador debería ver .git en el uso del disco")
+	}
+}
+
+func TestHiddenProtocols(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	setupTokens(t, cfg)
+	cfg.ShowHidden = config.HiddenAdmins
+	writeTestFile(t, cfg, "docs/.env", "SECRETO=1")
+
+	// WebDAV: solo el administrador ve los archivos ocultos
+	dav := WebDAV(cfg)
+	rr := davAdmin(cfg, dav, "PROPFIND", "/dav/docs/", "", map[string]string{"Depth": "1"})
+	if !strings.Contains(rr.Body.String(), "/dav/docs/.env") {
+		t.Error("El administrador debería ver .env por WebDAV")
+	}
+	other := createToken(t, cfg, "otro", []string{"read", "upload"}, time.Time{})
+	rr = davRequest(dav, "PROPFIND", "/dav/docs/", "", "otro", other, map[string]string{"Depth": "1"})
+	if rr.Code != http.StatusMultiStatus || strings.Contains(rr.Body.String(), ".env") {
+		t.Errorf("Otro usuario no debería ver .env por WebDAV: %s", rr.Body.String())
+	}
+	if rr := davRequest(dav, http.MethodGet, "/dav/docs/.env", "", "otro", other, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Otro usuario no debería descargar .env por WebDAV: código %d", rr.Code)
+	}
+	davRequest(dav, http.MethodPut, "/dav/.oculto", "x", "otro", other, nil)
+	if _, err := os.Stat(filepath.Join(cfg.RootDir, ".oculto")); !os.IsNotExist(err) {
+		t.Error("Otro usuario no debería crear archivos ocultos por WebDAV")
+	}
+
+	// S3: las claves de acceso no son de ningún usuario
+	cfg.S3 = config.S3Config{AccessKeys: []config.S3AccessKey{{AccessKey: "clave", SecretKey: "secreto"}}}
+	handler, err := S3(cfg)
+	if err != nil {
+		t.Fatalf("S3() devolvió error: %v", err)
+	}
+	request := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		s3.Sign(req, "clave", "secreto", "us-east-1", s3.UnsignedPayload, time.Now())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := request("/shareiscare?list-type=2"); strings.Contains(rr.Body.String(), ".env") {
+		t.Errorf("El listado de S3 no debería incluir .env: %s", rr.Body.String())
+	}
+	if rr := request("/shareiscare/docs/.env"); rr.Code != http.StatusNotFound {
+		t.Errorf("S3 no debería servir .env: código %d", rr.Code)
+	}
+	cfg.ShowHidden = config.HiddenAlways
+	if rr := request("/shareiscare/docs/.env"); rr.Code != http.StatusOK {
+		t.Errorf("Con always S3 debería servir .env: código %d", rr.Code)
+	}
+
+	// SFTP: el permiso depende del usuario
+	cfg.ShowHidden = config.HiddenAdmins
+	if !sftpPermissions(cfg, cfg.Username, nil).Private || sftpPermissions(cfg, "otro", nil).Private {
+		t.Error("Solo el administrador debería ver los archivos ocultos por SFTP")
+	}
+}
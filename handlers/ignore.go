@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// excluded checks if a storage name is hidden from every client: a
+// ShareIsCare system file, or a file matched by the exclude patterns of the
+// configuration or of a .shareignore file. isDir tells if the name is a
+// directory, for the patterns that only match directories.
+func excluded(config *config.Config, name string, isDir bool) bool {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return false
+	}
+	if hiddenPath(name) {
+		return true
+	}
+	return config.Ignore != nil && config.Ignore.Excluded(name, isDir)
+}
+
+// excludedName is excluded for a name whose type isn't known, which is
+// looked up in the storage. Names that don't exist are files.
+func excludedName(config *config.Config, name string) bool {
+	isDir := false
+	if info, err := fileStorage(config).Stat(name); err == nil {
+		isDir = info.IsDir()
+	}
+	return excluded(config, name, isDir)
+}
+
+// excludedError is an upload rejected because its name is excluded
+type excludedError struct {
+	filename string
+}
+
+func (e *excludedError) Error() string {
+	return e.filename + " can't be uploaded, it's excluded from the shared files"
+}
+
+func (e *excludedError) Unwrap() error {
+	return fs.ErrPermission
+}
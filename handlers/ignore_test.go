@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/ignore"
+)
+
+func TestExclude(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	for name, content := range map[string]string{
+		"foto.jpg":                 "jpg",
+		"servidor.key":             "clave",
+		"docs/nota.txt":            "hola",
+		"docs/.shareignore":        "borradores/\n*.bak\n",
+		"docs/copia.bak":           "copia",
+		"docs/borradores/idea.txt": "idea",
+		"docs/sub/.env":            "SECRETO=1",
+	} {
+		writeTestFile(t, cfg, name, content)
+	}
+	matcher, err := ignore.New(cfg.Storage, []string{"*.key", ".env"})
+	if err != nil {
+		t.Fatalf("ignore.New() devolvió error: %v", err)
+	}
+	cfg.Ignore = matcher
+
+	// admin crea una petición con la sesión del administrador
+	admin := func(target string) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		return req
+	}
+
+	// Los listados no muestran los archivos excluidos
+	rr := httptest.NewRecorder()
+	Index(cfg).ServeHTTP(rr, admin("/"))
+	if body := rr.Body.String(); !strings.Contains(body, "foto.jpg") || strings.Contains(body, "servidor.key") {
+		t.Error("El listado de la raíz debería ocultar servidor.key")
+	}
+	rr = httptest.NewRecorder()
+	Browse(cfg).ServeHTTP(rr, admin("/browse/docs"))
+	body := rr.Body.String()
+	if !strings.Contains(body, "nota.txt") {
+		t.Error("El listado de docs debería mostrar nota.txt")
+	}
+	for _, name := range []string{"copia.bak", "borradores", ".shareignore"} {
+		if strings.Contains(body, name) {
+			t.Errorf("El listado de docs no debería mostrar %s", name)
+		}
+	}
+
+	rr = apiRequest(cfg, "GET", "/api/v1/files?path=/docs", nil, cfg.Username)
+	if strings.Contains(rr.Body.String(), "copia.bak") || !strings.Contains(rr.Body.String(), "nota.txt") {
+		t.Errorf("La API no debería listar los archivos excluidos: %s", rr.Body.String())
+	}
+
+	// Tampoco se pueden obtener con un enlace directo
+	for _, target := range []string{
+		"/download?filename=servidor.key",
+		"/download?filename=docs/copia.bak",
+		"/download?filename=docs/borradores/idea.txt",
+		"/download?filename=docs/.shareignore",
+		"/download?filename=docs/sub/.env",
+		"/preview?filename=docs/borradores/idea.txt",
+		"/browse/docs/borradores",
+	} {
+		rr := httptest.NewRecorder()
+		req := admin(target)
+		switch {
+		case strings.HasPrefix(target, "/download"):
+			Download(cfg).ServeHTTP(rr, req)
+		case strings.HasPrefix(target, "/preview"):
+			Preview(cfg).ServeHTTP(rr, req)
+		default:
+			Browse(cfg).ServeHTTP(rr, req)
+		}
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: código %d, se esperaba %d", target, rr.Code, http.StatusNotFound)
+		}
+	}
+	checkAPIError(t, apiRequest(cfg, "GET", "/api/v1/download?path=/docs/copia.bak", nil, cfg.Username), http.StatusNotFound)
+
+	// Ni dentro de un zip
+	rr = httptest.NewRecorder()
+	Download(cfg).ServeHTTP(rr, admin("/download?filename=docs"))
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("Zip inválido: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "nota.txt" {
+		t.Errorf("El zip debería contener solo nota.txt: %s", got)
+	}
+
+	// Los archivos excluidos no se pueden subir
+	rr = httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, newUploadRequest(t, cfg, "/api/v1/upload?path=/docs", map[string]string{"nueva.bak": "copia"}, nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Subir un archivo excluido debería devolver 403: %d", rr.Code)
+	}
+	if fileExists(cfg, "docs/nueva.bak") {
+		t.Error("El archivo excluido no debería haberse guardado")
+	}
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/quota"
+	"github.com/rodrwan/shareiscare/storage"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+// quotaError is an upload rejected by a quota, with a message for the user
+type quotaError struct {
+	message string
+	err     *quota.ExceededError
+}
+
+func (e *quotaError) Error() string {
+	return e.message
+}
+
+func (e *quotaError) Unwrap() error {
+	return e.err
+}
+
+// quotaExceeded checks if an upload failed because of a quota
+func quotaExceeded(err error) bool {
+	var exceeded *quota.ExceededError
+	return errors.As(err, &exceeded)
+}
+
+// configFolder returns the name in the storage of a folder of the
+// configuration file
+func configFolder(folder string) string {
+	return path.Clean("/" + folder)[1:]
+}
+
+// accountName describes an account in the messages
+func accountName(account quota.Account) string {
+	if account.Kind == quota.User {
+		return "your uploads"
+	}
+	if account.Name == "" {
+		return "the shared folder"
+	}
+	return "folder " + account.Name
+}
+
+// userUsage returns the space used by the files uploaded by a user. Files
+// removed or replaced since then are forgotten.
+func userUsage(config *config.Config, user string) quota.Usage {
+	store := fileStorage(config)
+
+	var usage quota.Usage
+	var missing []string
+	for _, name := range config.Quota.Owned(user) {
+		info, err := store.Stat(name)
+		if err != nil || info.IsDir() {
+			missing = append(missing, name)
+			continue
+		}
+		usage.Bytes += info.Size()
+		usage.Files++
+	}
+
+	if err := config.Quota.Forget(missing...); err != nil {
+		log.Printf("Error saving the owners of the uploaded files: %v", err)
+	}
+	return usage
+}
+
+// folderUsage returns the space used by the files of a folder and its
+// subfolders. It's only calculated again once in a while, uploads update it
+// as they're stored.
+func folderUsage(config *config.Config, folder string) (quota.Usage, error) {
+	return config.Quota.FolderUsage(folder, func() (quota.Usage, error) {
+		return walkUsage(config, folder)
+	})
+}
+
+// walkUsage goes through a folder to calculate the space used by its files
+func walkUsage(config *config.Config, folder string) (quota.Usage, error) {
+	var usage quota.Usage
+	err := storage.Walk(fileStorage(config), folder, func(name string, info fs.FileInfo) error {
+		if name != folder && hiddenPath(name) {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			usage.Bytes += info.Size()
+			usage.Files++
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return quota.Usage{}, nil
+	}
+	return usage, err
+}
+
+// quotaAccounts returns the accounts with a quota that the uploads of a
+// user to dir count against, with their current usage
+func quotaAccounts(config *config.Config, user, dir string) ([]quota.Account, error) {
+	var accounts []quota.Account
+
+	if limit, ok := config.Quotas.Users[user]; ok && user != "" {
+		accounts = append(accounts, quota.Account{
+			Kind:  quota.User,
+			Name:  user,
+			Limit: quota.Usage{Bytes: int64(limit.Bytes), Files: limit.Files},
+			Used:  userUsage(config, user),
+		})
+	}
+
+	folders := make([]string, 0, len(config.Quotas.Folders))
+	for folder := range config.Quotas.Folders {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	for _, folder := range folders {
+		name := configFolder(folder)
+		if name != "" && dir != name && !strings.HasPrefix(dir, name+"/") {
+			continue
+		}
+		used, err := folderUsage(config, name)
+		if err != nil {
+			return nil, err
+		}
+		limit := config.Quotas.Folders[folder]
+		accounts = append(accounts, quota.Account{
+			Kind:  quota.Folder,
+			Name:  name,
+			Limit: quota.Usage{Bytes: int64(limit.Bytes), Files: limit.Files},
+			Used:  used,
+		})
+	}
+
+	return accounts, nil
+}
+
+// quotasEnabled checks if there are quotas to enforce
+func quotasEnabled(config *config.Config) bool {
+	return config.Quota != nil && (len(config.Quotas.Users) > 0 || len(config.Quotas.Folders) > 0)
+}
+
+// quotaMessage explains to the user why an upload doesn't fit in a quota,
+// once written bytes of it have been received
+func quotaMessage(filename string, written int64, exceeded *quota.ExceededError) string {
+	left := exceeded.Left()
+	return fmt.Sprintf("Quota exceeded: %s doesn't fit in the %s left for %s", filename, formatSize(left.Bytes+written), accountName(exceeded.Account))
+}
+
+// reserveQuota starts reserving the space of an upload by user to the file
+// name of the storage. It fails with a *quotaError if it doesn't fit in one
+// of the quotas. The returned reservation, nil if there are no quotas to
+// enforce, grows as the file is written with a quotaWriter and must be
+// released once the upload is over.
+func reserveQuota(config *config.Config, user, name string) (*quota.Reservation, error) {
+	if !quotasEnabled(config) {
+		return nil, nil
+	}
+
+	dir := path.Dir(name)
+	if dir == "." {
+		dir = ""
+	}
+	accounts, err := quotaAccounts(config, user, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking the quotas: %v", err)
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	// A replaced file frees its space
+	if info, err := fileStorage(config).Stat(name); err == nil && !info.IsDir() {
+		replaced := quota.Usage{Bytes: -info.Size(), Files: -1}
+		owner := config.Quota.Owner(name)
+		for i, account := range accounts {
+			if account.Kind == quota.Folder || account.Name == owner {
+				accounts[i].Used = account.Used.Add(replaced)
+			}
+		}
+	}
+
+	reservation, err := config.Quota.Reserve(accounts, quota.Usage{Files: 1})
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		filename := path.Base(name)
+		message := quotaMessage(filename, 0, exceeded)
+		if exceeded.Account.Limit.Files > 0 && exceeded.Left().Files == 0 {
+			message = fmt.Sprintf("Quota exceeded: %s can't be uploaded, %s can't have more than %d files", filename, accountName(exceeded.Account), exceeded.Account.Limit.Files)
+		}
+		return nil, &quotaError{message: message, err: exceeded}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// quotaWriter reserves the space of what's written before writing it, so
+// that an upload fails as soon as it doesn't fit in the quotas, without
+// storing the rest of it
+type quotaWriter struct {
+	w           io.Writer
+	reservation *quota.Reservation // Nil if there are no quotas to enforce
+	filename    string
+	written     int64
+}
+
+func (q *quotaWriter) Write(p []byte) (int, error) {
+	if q.reservation != nil {
+		err := q.reservation.Grow(quota.Usage{Bytes: int64(len(p))})
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return 0, &quotaError{message: quotaMessage(q.filename, q.written, exceeded), err: exceeded}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := q.w.Write(p)
+	q.written += int64(n)
+	return n, err
+}
+
+// storedUpload updates the usage of the folders with a quota once an upload
+// of size bytes to name has been stored. replaced is the size of the file it
+// replaced, -1 if it's a new file.
+func storedUpload(config *config.Config, name string, size, replaced int64) {
+	if config.Quota == nil {
+		return
+	}
+	if replaced < 0 {
+		config.Quota.Stored(name, quota.Usage{Bytes: size, Files: 1})
+		return
+	}
+	config.Quota.Stored(name, quota.Usage{Bytes: size - replaced})
+}
+
+// recordOwner records the user that uploaded a file, for the user quotas
+func recordOwner(config *config.Config, name, user string) {
+	if config.Quota == nil || user == "" {
+		return
+	}
+	if err := config.Quota.SetOwner(name, user); err != nil {
+		log.Printf("Error saving the owner of %s: %v", name, err)
+	}
+}
+
+// uploadQuotas returns the usage of the quotas that apply to the uploads of
+// a user from the upload page
+func uploadQuotas(config *config.Config, user string) []templates.QuotaUsage {
+	if config.Quota == nil {
+		return nil
+	}
+
+	dirs := []string{""}
+	if len(config.Shares) > 0 {
+		dirs = uploadShares(config)
+	}
+
+	quotas := []templates.QuotaUsage{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		accounts, err := quotaAccounts(config, user, dir)
+		if err != nil {
+			log.Printf("Error checking the quotas: %v", err)
+			continue
+		}
+		for _, account := range accounts {
+			key := account.Kind + ":" + account.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			quotas = append(quotas, newQuotaUsage(account))
+		}
+	}
+	return quotas
+}
+
+// newQuotaUsage returns the usage of an account for the upload page
+func newQuotaUsage(account quota.Account) templates.QuotaUsage {
+	name := strings.ToUpper(accountName(account)[:1]) + accountName(account)[1:]
+	usage := templates.QuotaUsage{
+		Name:     name,
+		Used:     formatSize(account.Used.Bytes),
+		Files:    account.Used.Files,
+		MaxFiles: account.Limit.Files,
+	}
+	if account.Limit.Bytes > 0 {
+		usage.Limit = formatSize(account.Limit.Bytes)
+		usage.Percent = int(account.Used.Bytes * 100 / account.Limit.Bytes)
+	}
+	if account.Limit.Files > 0 {
+		usage.Percent = max(usage.Percent, account.Used.Files*100/account.Limit.Files)
+	}
+	usage.Percent = min(usage.Percent, 100)
+	return usage
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/quota"
+	"github.com/rodrwan/shareiscare/s3"
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// setupQuotas configura las cuotas con un almacén de dueños temporal
+func setupQuotas(t *testing.T, cfg *config.Config, quotas config.QuotaConfig) {
+	t.Helper()
+	store, err := quota.Open(filepath.Join(t.TempDir(), "uploads.json"))
+	if err != nil {
+		t.Fatalf("Error abriendo el almacén de dueños: %v", err)
+	}
+	cfg.Quota = store
+	cfg.Quotas = quotas
+}
+
+func TestQuotaWeb(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupQuotas(t, cfg, config.QuotaConfig{
+		Users: map[string]config.Quota{cfg.Username: {Bytes: 10}},
+	})
+
+	upload := func(name, content string) string {
+		rr := httptest.NewRecorder()
+		UploadPost(cfg).ServeHTTP(rr, newUploadRequest(t, cfg, "/upload", map[string]string{name: content}, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Código de estado incorrecto: %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	if page := upload("uno.txt", "123456"); !strings.Contains(page, "uploaded successfully") {
+		t.Fatalf("La primera subida debería caber en la cuota: %s", page)
+	}
+	if cfg.Quota.Owner("uno.txt") != cfg.Username {
+		t.Error("Debería guardarse quién subió el archivo")
+	}
+
+	page := upload("dos.txt", "123456")
+	if !strings.Contains(page, "Quota exceeded") || !strings.Contains(page, "4 B left for your uploads") {
+		t.Errorf("Debería mostrarse que se superó la cuota: %s", page)
+	}
+	if fileExists(cfg, "dos.txt") {
+		t.Error("El archivo que no cabe no debería guardarse")
+	}
+
+	// Reemplazar un archivo propio libera su espacio
+	if page := upload("uno.txt", "1234567890"); !strings.Contains(page, "uploaded successfully") {
+		t.Errorf("Reemplazar el archivo debería caber en la cuota: %s", page)
+	}
+
+	// Los archivos borrados dejan de contar
+	if err := cfg.Storage.Remove("uno.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if page := upload("dos.txt", "123456"); !strings.Contains(page, "uploaded successfully") {
+		t.Errorf("El espacio de los archivos borrados debería liberarse: %s", page)
+	}
+
+	// La página de subida muestra el uso de la cuota
+	req := httptest.NewRequest("GET", "/upload", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr := httptest.NewRecorder()
+	Upload(cfg).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "Your uploads") || !strings.Contains(rr.Body.String(), "6 B of 10 B") {
+		t.Errorf("La página de subida debería mostrar el uso de la cuota: %s", rr.Body.String())
+	}
+}
+
+func TestQuotaAPI(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	setupQuotas(t, cfg, config.QuotaConfig{
+		Folders: map[string]config.Quota{"/docs/": {Files: 2}},
+	})
+	used, err := folderUsage(cfg, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(dir, name string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		API(cfg).ServeHTTP(rr, newUploadRequest(t, cfg, "/api/v1/upload?path="+dir, map[string]string{name: "hola"}, nil))
+		return rr
+	}
+
+	// La carpeta se llena con los archivos que ya tenía
+	for i := used.Files; i < 2; i++ {
+		if rr := upload("docs", "nuevo"+string(rune('a'+i))+".txt"); rr.Code != http.StatusCreated {
+			t.Fatalf("La subida debería caber en la cuota: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	rr := upload("docs", "otro.txt")
+	checkAPIError(t, rr, http.StatusRequestEntityTooLarge)
+	if !strings.Contains(rr.Body.String(), "folder docs can't have more than 2 files") {
+		t.Errorf("Mensaje de error incorrecto: %s", rr.Body.String())
+	}
+
+	// La cuota no afecta a otras carpetas
+	if rr := upload("", "raiz.txt"); rr.Code != http.StatusCreated {
+		t.Errorf("La subida fuera de la carpeta debería funcionar: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Mover un archivo mantiene su dueño
+	rr = apiRequest(cfg, "POST", "/api/v1/move", strings.NewReader(`{"from": "raiz.txt", "to": "movido.txt"}`), cfg.Username)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Error moviendo el archivo: %d %s", rr.Code, rr.Body.String())
+	}
+	if cfg.Quota.Owner("movido.txt") != cfg.Username {
+		t.Error("El archivo movido debería conservar su dueño")
+	}
+	if content, _ := storage.ReadFile(cfg.Storage, "movido.txt"); string(content) != "hola" {
+		t.Errorf("Contenido incorrecto: %q", content)
+	}
+}
+
+func TestQuotaStreaming(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupQuotas(t, cfg, config.QuotaConfig{
+		Users: map[string]config.Quota{cfg.Username: {Bytes: 1000}},
+	})
+
+	// El formulario se envía poco a poco, como una subida lenta
+	reader, pipe := io.Pipe()
+	writer := multipart.NewWriter(pipe)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("POST", "/api/v1/upload", reader)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		rr := httptest.NewRecorder()
+		API(cfg).ServeHTTP(rr, req)
+		done <- rr
+	}()
+
+	part, err := writer.CreateFormFile("files", "grande.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(bytes.Repeat([]byte("a"), 900)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lo recibido se reserva antes de terminar la subida
+	accounts, err := quotaAccounts(cfg, cfg.Username, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		reservation, err := cfg.Quota.Reserve(accounts, quota.Usage{Bytes: 200})
+		if err != nil {
+			break
+		}
+		reservation.Release()
+		if time.Now().After(deadline) {
+			t.Fatal("El espacio de la subida en curso debería estar reservado")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// La subida se corta al superar la cuota aunque no declare su tamaño
+	if _, err := part.Write(bytes.Repeat([]byte("a"), 2000)); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	pipe.Close()
+
+	rr := <-done
+	checkAPIError(t, rr, http.StatusRequestEntityTooLarge)
+	if fileExists(cfg, "grande.bin") {
+		t.Error("El archivo que no cabe no debería guardarse")
+	}
+
+	// Al terminar se libera lo reservado
+	reservation, err := cfg.Quota.Reserve(accounts, quota.Usage{Bytes: 1000})
+	if err != nil {
+		t.Errorf("El espacio reservado debería liberarse: %v", err)
+	} else {
+		reservation.Release()
+	}
+}
+
+func TestQuotaProtocols(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupQuotas(t, cfg, config.QuotaConfig{
+		Folders: map[string]config.Quota{"/": {Files: 10}},
+	})
+
+	// Los protocolos que no pueden aplicar las cuotas no aceptan subidas
+	rr := davAdmin(cfg, WebDAV(cfg), "PUT", "/dav/nuevo.txt", "contenido", nil)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Código de estado incorrecto para WebDAV: obtenido %v, esperado %v", rr.Code, http.StatusForbidden)
+	}
+	if fileExists(cfg, "nuevo.txt") {
+		t.Error("WebDAV no debería crear archivos con cuotas")
+	}
+
+	if perms := sftpPermissions(cfg, cfg.Username, nil); perms.Upload || !perms.Read {
+		t.Errorf("Permisos SFTP incorrectos: %+v", perms)
+	}
+
+	cfg.S3 = config.S3Config{AccessKeys: []config.S3AccessKey{{AccessKey: "clave", SecretKey: "secreto"}}}
+	handler, err := S3(cfg)
+	if err != nil {
+		t.Fatalf("S3() devolvió error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/shareiscare/nuevo.txt", strings.NewReader("contenido"))
+	s3.Sign(req, "clave", "secreto", "us-east-1", s3.UnsignedPayload, time.Now())
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "<Code>AccessDenied</Code>") || !strings.Contains(rr.Body.String(), "limited by quotas") {
+		t.Errorf("Respuesta incorrecta para S3: %d %s", rr.Code, rr.Body.String())
+	}
+	if fileExists(cfg, "nuevo.txt") {
+		t.Error("S3 no debería crear archivos con cuotas")
+	}
+}
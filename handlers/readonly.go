@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// ReadOnly reports whether none of the shared files can be changed, either
+// because read_only is set or because every share is read-only
+func ReadOnly(config *config.Config) bool {
+	return config.ReadOnly || (len(config.Shares) > 0 && len(uploadShares(config)) == 0)
+}
+
+// readOnlyPath reports whether a storage name is in a read-only share, or
+// anywhere if nothing can be changed
+func readOnlyPath(config *config.Config, name string) bool {
+	if ReadOnly(config) {
+		return true
+	}
+	share := shareOf(config, name)
+	return share != nil && share.ReadOnly
+}
+
+// homePage returns the page users are sent to after logging in: the upload
+// page, or the file listing if nothing can be uploaded
+func homePage(config *config.Config) string {
+	if ReadOnly(config) {
+		return "/"
+	}
+	return "/upload"
+}
+
+// rejectReadOnly responds with a 405 error if name can't be changed
+func rejectReadOnly(w http.ResponseWriter, config *config.Config, name string) bool {
+	if !readOnlyPath(config, name) {
+		return false
+	}
+	http.Error(w, "The files are read-only", http.StatusMethodNotAllowed)
+	return true
+}
+
+// apiRejectReadOnly is the JSON API version of rejectReadOnly
+func apiRejectReadOnly(w http.ResponseWriter, config *config.Config, name string) bool {
+	if !readOnlyPath(config, name) {
+		return false
+	}
+	apiError(w, http.StatusMethodNotAllowed, "The files are read-only")
+	return true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/s3"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+func TestReadOnlyConfig(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	if ReadOnly(cfg) {
+		t.Error("La configuración por defecto no debería ser de solo lectura")
+	}
+	cfg.Shares = []config.ShareConfig{{Name: "fotos", ReadOnly: true}, {Name: "docs"}}
+	if ReadOnly(cfg) || !readOnlyPath(cfg, "fotos/playa.jpg") || readOnlyPath(cfg, "docs/nota.txt") {
+		t.Error("Solo el recurso de solo lectura debería estar protegido")
+	}
+	cfg.Shares[1].ReadOnly = true
+	if !ReadOnly(cfg) {
+		t.Error("Si todos los recursos son de solo lectura, nada se puede modificar")
+	}
+	cfg.Shares = nil
+	cfg.ReadOnly = true
+	if !ReadOnly(cfg) || !readOnlyPath(cfg, "docs/nota.txt") {
+		t.Error("read_only debería proteger todos los archivos")
+	}
+	if homePage(cfg) != "/" {
+		t.Errorf("Página de inicio incorrecta: %s", homePage(cfg))
+	}
+}
+
+func TestReadOnlyWeb(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	cfg.ReadOnly = true
+
+	// Subidas
+	rr := httptest.NewRecorder()
+	UploadPost(cfg).ServeHTTP(rr, newUploadRequest(t, cfg, "/upload", map[string]string{"nuevo.txt": "nuevo"}, nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Subir archivos devolvió %d", rr.Code)
+	}
+	if fileExists(cfg, "nuevo.txt") {
+		t.Error("El archivo no debería haberse subido")
+	}
+
+	// Eliminaciones
+	req := httptest.NewRequest("POST", "/delete", strings.NewReader("filename=foto.jpg"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	Delete(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Eliminar un archivo devolvió %d", rr.Code)
+	}
+	if !fileExists(cfg, "foto.jpg") {
+		t.Error("El archivo no debería haberse eliminado")
+	}
+
+	// Ni botones de subida ni de eliminación
+	for _, target := range []string{"/", "/browse/docs"} {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		if target == "/" {
+			Index(cfg).ServeHTTP(rr, req)
+		} else {
+			Browse(cfg).ServeHTTP(rr, req)
+		}
+		page := rr.Body.String()
+		if strings.Contains(page, `href="/upload"`) || strings.Contains(page, `action="/delete"`) {
+			t.Errorf("%s no debería mostrar botones para modificar archivos", target)
+		}
+		if !strings.Contains(page, "nota.txt") && !strings.Contains(page, "foto.jpg") {
+			t.Errorf("%s debería mostrar los archivos", target)
+		}
+	}
+
+	// Después de iniciar sesión se vuelve al listado
+	req = httptest.NewRequest("POST", "/login", strings.NewReader("username=testuser&password=testpass"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	LoginPost(cfg).ServeHTTP(rr, req)
+	if location := rr.Header().Get("Location"); location != "/" {
+		t.Errorf("Redirección incorrecta después del login: %q", location)
+	}
+}
+
+func TestReadOnlyAPI(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	cfg.ReadOnly = true
+
+	req := newUploadRequest(t, cfg, "/api/v1/upload?path=docs", map[string]string{"nuevo.txt": "nuevo"}, nil)
+	rr := httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	checkAPIError(t, rr, http.StatusMethodNotAllowed)
+
+	checkAPIError(t, apiRequest(cfg, "POST", "/api/v1/mkdir", strings.NewReader(`{"path": "nueva"}`), cfg.Username), http.StatusMethodNotAllowed)
+	checkAPIError(t, apiRequest(cfg, "POST", "/api/v1/move", strings.NewReader(`{"from": "foto.jpg", "to": "docs/foto.jpg"}`), cfg.Username), http.StatusMethodNotAllowed)
+	rr = apiRequest(cfg, "DELETE", "/api/v1/files?path=foto.jpg", nil, cfg.Username)
+	checkAPIError(t, rr, http.StatusMethodNotAllowed)
+	if allow := rr.Header().Get("Allow"); allow != "GET" {
+		t.Errorf("Cabecera Allow incorrecta: %q", allow)
+	}
+
+	if fileExists(cfg, "docs/nuevo.txt") || fileExists(cfg, "nueva") || !fileExists(cfg, "foto.jpg") {
+		t.Error("Los archivos no deberían haber cambiado")
+	}
+
+	// La lectura sigue disponible
+	if rr := apiRequest(cfg, "GET", "/api/v1/files?path=docs", nil, cfg.Username); rr.Code != http.StatusOK {
+		t.Errorf("Listar archivos devolvió %d", rr.Code)
+	}
+}
+
+func TestReadOnlyServers(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+	cfg.ReadOnly = true
+
+	// WebDAV
+	dav := WebDAV(cfg)
+	for _, method := range []string{"PUT", "DELETE", "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK", "UNLOCK"} {
+		rr := davAdmin(cfg, dav, method, "/dav/docs/nota.txt", "", map[string]string{"Destination": "/dav/docs/copia.txt"})
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("WebDAV %s devolvió %d", method, rr.Code)
+		}
+	}
+	if rr := davAdmin(cfg, dav, "GET", "/dav/docs/nota.txt", "", nil); rr.Code != http.StatusOK {
+		t.Errorf("WebDAV GET devolvió %d", rr.Code)
+	}
+
+	// SFTP
+	perms := sftpPermissions(cfg, cfg.Username, nil)
+	if !perms.Read || perms.Upload || perms.Delete {
+		t.Errorf("Permisos SFTP incorrectos: %+v", perms)
+	}
+	token := &tokens.Token{Username: cfg.Username, Scopes: []string{tokens.Read, tokens.Upload, tokens.Delete}}
+	if perms := sftpPermissions(cfg, cfg.Username, token); perms.Upload || perms.Delete {
+		t.Errorf("Permisos SFTP de un token incorrectos: %+v", perms)
+	}
+
+	// S3
+	cfg.S3 = config.S3Config{AccessKeys: []config.S3AccessKey{{AccessKey: "clave", SecretKey: "secreto"}}}
+	handler, err := S3(cfg)
+	if err != nil {
+		t.Fatalf("S3() devolvió error: %v", err)
+	}
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/shareiscare/docs/nota.txt", nil)
+		s3.Sign(req, "clave", "secreto", "us-east-1", s3.UnsignedPayload, time.Now())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("S3 %s devolvió %d", method, rr.Code)
+		}
+	}
+}
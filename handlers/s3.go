@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/s3"
+)
+
+// defaultBucket is the bucket of the whole shared directory, used when no
+// buckets are configured
+const defaultBucket = "shareiscare"
+
+// s3UploadsDir returns the directory where the parts of S3 multipart uploads
+// are kept until they are completed
+func s3UploadsDir(config *config.Config) string {
+	if config.CacheDir != "" {
+		return filepath.Join(config.CacheDir, "s3-uploads")
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "shareiscare", "s3-uploads")
+}
+
+// S3 returns the handler of the S3-compatible endpoint, which serves the
+// configured folders of the shared directory as buckets
+func S3(config *config.Config) (http.Handler, error) {
+	if len(config.S3.AccessKeys) == 0 {
+		return nil, errors.New("at least one access key is required")
+	}
+	keys := make(map[string]string)
+	for _, key := range config.S3.AccessKeys {
+		if key.AccessKey == "" || key.SecretKey == "" {
+			return nil, errors.New("access keys need an access_key and a secret_key")
+		}
+		keys[key.AccessKey] = key.SecretKey
+	}
+
+	folders := config.S3.Buckets
+	if len(folders) == 0 {
+		folders = map[string]string{defaultBucket: ""}
+	}
+	buckets := make(map[string]string)
+	names := make(map[string]string) // Folder of each bucket in the storage
+	for name, folder := range folders {
+		if !s3.ValidBucketName(name) {
+			return nil, fmt.Errorf("invalid bucket name: %s", name)
+		}
+		dir, err := cleanPath(config, folder)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folder for bucket %s: %s", name, folder)
+		}
+		buckets[name] = filepath.Join(config.RootDir, filepath.FromSlash(dir))
+		names[name] = dir
+	}
+
+	region := config.S3.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// Keys are relative to the folder of their bucket. Access keys don't
+	// belong to any user, so they only see dotfiles if everyone does.
+	hidden := func(bucket, key string) bool {
+		name := path.Join(names[bucket], key)
+		return excludedName(config, name) || !visibleTo(config, "", name)
+	}
+
+	server := &s3.Server{
+		Region:     region,
+		Buckets:    buckets,
+		Keys:       keys,
+		UploadsDir: s3UploadsDir(config),
+		Hidden:     hidden,
+		Checksums:  checksumCache(config),
+		ReadOnly:   ReadOnly(config),
+		Symlinks:   symlinkPolicy(config),
+	}
+	// Uploads can't be limited by the quotas, the web and the API must be used
+	if quotasEnabled(config) {
+		server.DenyUploads = "Uploads are limited by quotas, use the web interface or the API"
+	}
+	return server, nil
+}
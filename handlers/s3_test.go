@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/s3"
+)
+
+func TestS3Config(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+
+	key := config.S3AccessKey{AccessKey: "clave", SecretKey: "secreto"}
+
+	tests := []struct {
+		name    string
+		s3      config.S3Config
+		wantErr bool
+	}{
+		{"sin claves", config.S3Config{}, true},
+		{"clave incompleta", config.S3Config{AccessKeys: []config.S3AccessKey{{AccessKey: "clave"}}}, true},
+		{"nombre inválido", config.S3Config{AccessKeys: []config.S3AccessKey{key}, Buckets: map[string]string{"Fotos": "fotos"}}, true},
+		{"carpeta fuera del directorio", config.S3Config{AccessKeys: []config.S3AccessKey{key}, Buckets: map[string]string{"fotos": "../fotos"}}, true},
+		{"carpeta del sistema", config.S3Config{AccessKeys: []config.S3AccessKey{key}, Buckets: map[string]string{"config": "config.yaml"}}, true},
+		{"bucket por defecto", config.S3Config{AccessKeys: []config.S3AccessKey{key}}, false},
+		{"buckets configurados", config.S3Config{AccessKeys: []config.S3AccessKey{key}, Buckets: map[string]string{"documentos": "docs"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.S3 = tt.s3
+			_, err := S3(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("S3() error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3Exclusions(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+	setupAPIFiles(t, cfg)
+
+	cfg.S3 = config.S3Config{AccessKeys: []config.S3AccessKey{{AccessKey: "clave", SecretKey: "secreto"}}}
+	handler, err := S3(cfg)
+	if err != nil {
+		t.Fatalf("S3() devolvió error: %v", err)
+	}
+
+	request := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		s3.Sign(req, "clave", "secreto", "us-east-1", s3.UnsignedPayload, time.Now())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Los archivos del sistema no se listan ni se pueden descargar
+	rr := request("/shareiscare?list-type=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "<Key>docs/nota.txt</Key>") || strings.Contains(rr.Body.String(), "config.yaml") {
+		t.Errorf("Listado incorrecto: %s", rr.Body.String())
+	}
+
+	if rr := request("/shareiscare/config.yaml"); rr.Code != http.StatusNotFound {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/sftpd"
+	"github.com/rodrwan/shareiscare/tokens"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpPermissions returns the SFTP permissions of a user, with the same rules
+// as the web handlers: anyone logged in can upload, only the admin deletes,
+// and dotfiles are shown as allowed by show_hidden. Uploads aren't allowed
+// with quotas, they can't be enforced. Tokens are also limited by their
+// scopes.
+func sftpPermissions(config *config.Config, username string, token *tokens.Token) sftpd.Permissions {
+	perms := sftpd.Permissions{
+		Read:    true,
+		Upload:  !ReadOnly(config) && !quotasEnabled(config),
+		Delete:  username == config.Username && !ReadOnly(config),
+		Private: showDotfiles(config, username),
+	}
+	if token != nil {
+		perms.Read = token.HasScope(tokens.Read)
+		perms.Upload = perms.Upload && token.HasScope(tokens.Upload)
+		perms.Delete = perms.Delete && token.HasScope(tokens.Delete)
+	}
+	return perms
+}
+
+// SFTP returns the SFTP server of the shared directory. Users log in with
+// their password or an API token, or with a key of the authorized_keys file.
+func SFTP(config *config.Config) (*sftpd.Server, error) {
+	hostKeyPath := config.SFTP.HostKey
+	if hostKeyPath == "" {
+		path, err := sftpd.DefaultHostKeyPath()
+		if err != nil {
+			return nil, err
+		}
+		hostKeyPath = path
+	}
+	hostKey, err := sftpd.LoadHostKey(hostKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the host key: %w", err)
+	}
+
+	server := &sftpd.Server{
+		Root:     config.RootDir,
+		HostKey:  hostKey,
+		Hidden:   func(name string) bool { return excludedName(config, name) },
+		Private:  dotfile,
+		Symlinks: symlinkPolicy(config),
+		Password: func(username, password string) (sftpd.Permissions, bool) {
+			if config.Tokens != nil {
+				if token, ok := config.Tokens.Authenticate(password); ok {
+					return sftpPermissions(config, token.Username, token), true
+				}
+			}
+			if subtle.ConstantTimeCompare([]byte(username), []byte(config.Username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(password), []byte(config.Password)) == 1 {
+				return sftpPermissions(config, username, nil), true
+			}
+			return sftpd.Permissions{}, false
+		},
+	}
+
+	if config.SFTP.AuthorizedKeys != "" {
+		keys, err := sftpd.LoadAuthorizedKeys(config.SFTP.AuthorizedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the authorized keys: %w", err)
+		}
+		// The keys belong to the configured user
+		server.PublicKey = func(username string, key ssh.PublicKey) (sftpd.Permissions, bool) {
+			if username != config.Username {
+				return sftpd.Permissions{}, false
+			}
+			for _, authorized := range keys {
+				if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+					return sftpPermissions(config, username, nil), true
+				}
+			}
+			return sftpd.Permissions{}, false
+		}
+	}
+
+	return server, nil
+}
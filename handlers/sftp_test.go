@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/sftpd"
+	"github.com/rodrwan/shareiscare/tokens"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPPasswords(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+
+	cfg.SFTP.HostKey = filepath.Join(cfg.CacheDir, "host_key")
+	server, err := SFTP(cfg)
+	if err != nil {
+		t.Fatalf("SFTP() devolvió error: %v", err)
+	}
+
+	// El administrador ve los archivos ocultos con show_hidden por defecto
+	admin := sftpd.Permissions{Read: true, Upload: true, Delete: true, Private: true}
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     sftpd.Permissions
+		wantOK   bool
+	}{
+		{"contraseña correcta", "testuser", "testpass", admin, true},
+		{"contraseña incorrecta", "testuser", "otra", sftpd.Permissions{}, false},
+		{"usuario incorrecto", "otro", "testpass", sftpd.Permissions{}, false},
+		{"token de lectura", "testuser", createToken(t, cfg, "testuser", []string{tokens.Read}, time.Time{}), sftpd.Permissions{Read: true, Private: true}, true},
+		{"token completo", "cualquiera", createToken(t, cfg, "testuser", tokens.Scopes, time.Time{}), admin, true},
+		{"token de otro usuario", "otro", createToken(t, cfg, "otro", tokens.Scopes, time.Time{}), sftpd.Permissions{Read: true, Upload: true}, true},
+		{"token caducado", "testuser", createToken(t, cfg, "testuser", tokens.Scopes, time.Now().Add(-time.Hour)), sftpd.Permissions{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms, ok := server.Password(tt.username, tt.password)
+			if ok != tt.wantOK || perms != tt.want {
+				t.Errorf("Password() = %+v, %v; se esperaba %+v, %v", perms, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// La clave del servidor se guarda para los siguientes inicios
+	if _, err := os.Stat(cfg.SFTP.HostKey); err != nil {
+		t.Errorf("La clave del servidor no se guardó: %v", err)
+	}
+}
+
+func TestSFTPAuthorizedKeys(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	signer, err := sftpd.LoadHostKey(filepath.Join(cfg.CacheDir, "id_ed25519"))
+	if err != nil {
+		t.Fatalf("Error al generar la clave: %v", err)
+	}
+	other, err := sftpd.LoadHostKey(filepath.Join(cfg.CacheDir, "otra"))
+	if err != nil {
+		t.Fatalf("Error al generar la clave: %v", err)
+	}
+
+	cfg.SFTP.HostKey = filepath.Join(cfg.CacheDir, "host_key")
+	cfg.SFTP.AuthorizedKeys = filepath.Join(cfg.CacheDir, "authorized_keys")
+	content := "# Claves de testuser\n" + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+	if err := os.WriteFile(cfg.SFTP.AuthorizedKeys, []byte(content), 0600); err != nil {
+		t.Fatalf("Error al escribir authorized_keys: %v", err)
+	}
+
+	server, err := SFTP(cfg)
+	if err != nil {
+		t.Fatalf("SFTP() devolvió error: %v", err)
+	}
+
+	if perms, ok := server.PublicKey("testuser", signer.PublicKey()); !ok || !perms.Delete {
+		t.Errorf("La clave autorizada no tiene permisos de administrador: %+v, %v", perms, ok)
+	}
+	if _, ok := server.PublicKey("otro", signer.PublicKey()); ok {
+		t.Error("Se aceptó la clave para otro usuario")
+	}
+	if _, ok := server.PublicKey("testuser", other.PublicKey()); ok {
+		t.Error("Se aceptó una clave no autorizada")
+	}
+
+	// Un archivo de claves que no existe es un error de configuración
+	cfg.SFTP.AuthorizedKeys = filepath.Join(cfg.CacheDir, "no-existe")
+	if _, err := SFTP(cfg); err == nil {
+		t.Error("SFTP() no devolvió error con un archivo de claves inexistente")
+	}
+}
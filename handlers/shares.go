@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+// shareOf returns the share of a storage name, or nil if there are no
+// shares or name is the root
+func shareOf(config *config.Config, name string) *config.ShareConfig {
+	top, _, _ := strings.Cut(name, "/")
+	for i := range config.Shares {
+		if config.Shares[i].Name == top {
+			return &config.Shares[i]
+		}
+	}
+	return nil
+}
+
+// canAccess checks if a request can see a storage name. Private shares are
+// only shown to logged-in users.
+func canAccess(r *http.Request, config *config.Config, name string) bool {
+	share := shareOf(config, name)
+	return share == nil || !share.Private || isAuthenticated(r, config)
+}
+
+// checkAccess checks if a request can see a storage name. If it can't, the
+// user is asked to log in like RequireAuth does.
+func checkAccess(w http.ResponseWriter, r *http.Request, config *config.Config, name string) bool {
+	if canAccess(r, config, name) {
+		return true
+	}
+	if bearerToken(r) != "" {
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+	} else {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+	return false
+}
+
+// shareTitle returns the title of the share of a storage name, or "" if
+// it doesn't have one
+func shareTitle(config *config.Config, name string) string {
+	if share := shareOf(config, name); share != nil {
+		return share.Title
+	}
+	return ""
+}
+
+// uploadShares returns the names of the shares that accept uploads
+func uploadShares(config *config.Config) []string {
+	var names []string
+	for _, share := range config.Shares {
+		if !share.ReadOnly {
+			names = append(names, share.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// setupShares configura tres recursos compartidos en memoria
+func setupShares(t *testing.T) *config.Config {
+	cfg := setupTestConfig()
+	t.Cleanup(func() { cleanupTestConfig(cfg) })
+
+	cfg.Shares = []config.ShareConfig{
+		{Name: "fotos", Title: "Fotos familiares"},
+		{Name: "builds", ReadOnly: true},
+		{Name: "docs", Private: true},
+	}
+	fotos, builds, docs := storage.NewMemory(), storage.NewMemory(), storage.NewMemory()
+	storage.WriteFile(fotos, "playa.jpg", []byte("jpg"))
+	storage.WriteFile(builds, "app.zip", []byte("zip"))
+	storage.WriteFile(docs, "secreto.txt", []byte("secreto"))
+	cfg.Storage = storage.NewMount(map[string]storage.Storage{
+		"fotos":  fotos,
+		"builds": storage.ReadOnly(builds),
+		"docs":   docs,
+	})
+	return cfg
+}
+
+func TestSharesIndex(t *testing.T) {
+	cfg := setupShares(t)
+
+	rr := httptest.NewRecorder()
+	Index(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "fotos") || !strings.Contains(body, "Fotos familiares") || !strings.Contains(body, "builds") {
+		t.Error("El índice debería mostrar los recursos compartidos")
+	}
+	if strings.Contains(body, "/browse/docs") {
+		t.Error("El índice no debería mostrar recursos privados sin sesión")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	Index(cfg).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "/browse/docs") {
+		t.Error("El índice debería mostrar los recursos privados con sesión")
+	}
+
+	// La API tampoco muestra los recursos privados sin sesión
+	rr = apiRequest(cfg, "GET", "/api/v1/files", nil, "")
+	if body := rr.Body.String(); rr.Code != http.StatusOK || !strings.Contains(body, `"fotos"`) || strings.Contains(body, `"docs"`) {
+		t.Errorf("Listado de la API sin sesión incorrecto: %d %s", rr.Code, body)
+	}
+	if body := apiRequest(cfg, "GET", "/api/v1/files", nil, cfg.Username).Body.String(); !strings.Contains(body, `"docs"`) {
+		t.Errorf("La API debería mostrar los recursos privados con sesión: %s", body)
+	}
+}
+
+func TestSharesAccess(t *testing.T) {
+	cfg := setupShares(t)
+
+	// Los recursos se recorren y descargan por su nombre
+	rr := httptest.NewRecorder()
+	Browse(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/browse/fotos", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "playa.jpg") || !strings.Contains(rr.Body.String(), "Fotos familiares") {
+		t.Errorf("Listado del recurso incorrecto: %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	Download(cfg).ServeHTTP(rr, httptest.NewRequest("GET", "/download?filename=builds/app.zip", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "zip" {
+		t.Errorf("Descarga incorrecta: %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Los recursos privados piden iniciar sesión
+	for _, target := range []string{"/browse/docs", "/download?filename=docs/secreto.txt", "/preview?filename=docs/secreto.txt"} {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		switch {
+		case strings.HasPrefix(target, "/browse/"):
+			Browse(cfg).ServeHTTP(rr, req)
+		case strings.HasPrefix(target, "/download"):
+			Download(cfg).ServeHTTP(rr, req)
+		default:
+			Preview(cfg).ServeHTTP(rr, req)
+		}
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login" {
+			t.Errorf("%s: se esperaba una redirección al login, obtenido %d", target, rr.Code)
+		}
+	}
+	checkAPIError(t, apiRequest(cfg, "GET", "/api/v1/files?path=docs", nil, ""), http.StatusUnauthorized)
+	if rr := apiRequest(cfg, "GET", "/api/v1/files?path=docs", nil, cfg.Username); rr.Code != http.StatusOK {
+		t.Errorf("La API debería listar recursos privados con sesión: %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/download?filename=docs/secreto.txt", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	Download(cfg).ServeHTTP(rr, req)
+	if rr.Body.String() != "secreto" {
+		t.Errorf("Descarga con sesión incorrecta: %d", rr.Code)
+	}
+}
+
+func TestSharesReadOnly(t *testing.T) {
+	cfg := setupShares(t)
+
+	req := httptest.NewRequest("POST", "/delete", strings.NewReader("filename=builds/app.zip"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	Delete(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Eliminar en un recurso de solo lectura devolvió %d", rr.Code)
+	}
+	if !fileExists(cfg, "builds/app.zip") {
+		t.Error("El archivo no debería haberse eliminado")
+	}
+
+	checkAPIError(t, apiRequest(cfg, "POST", "/api/v1/mkdir", strings.NewReader(`{"path": "builds/nueva"}`), cfg.Username), http.StatusMethodNotAllowed)
+	checkAPIError(t, apiRequest(cfg, "POST", "/api/v1/move", strings.NewReader(`{"from": "fotos/playa.jpg", "to": "builds/playa.jpg"}`), cfg.Username), http.StatusMethodNotAllowed)
+
+	// Los recursos en sí no se pueden eliminar ni mover entre ellos
+	checkAPIError(t, apiRequest(cfg, "DELETE", "/api/v1/files?path=fotos", nil, cfg.Username), http.StatusForbidden)
+	checkAPIError(t, apiRequest(cfg, "POST", "/api/v1/move", strings.NewReader(`{"from": "fotos/playa.jpg", "to": "docs/playa.jpg"}`), cfg.Username), http.StatusForbidden)
+
+	// Las subidas van al recurso elegido, que debe admitirlas
+	upload := func(share string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("share", share)
+		part, _ := writer.CreateFormFile("files", "nieve.jpg")
+		part.Write([]byte("jpg"))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		UploadPost(cfg).ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := upload("builds"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Subir a un recurso de solo lectura devolvió %d", rr.Code)
+	}
+	if rr := upload("otro"); !strings.Contains(rr.Body.String(), "Invalid share") {
+		t.Error("Subir a un recurso inexistente debería fallar")
+	}
+	upload("fotos")
+	if !fileExists(cfg, "fotos/nieve.jpg") {
+		t.Error("El archivo no se subió al recurso elegido")
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/safepath"
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// OpenStorage returns the storage of the shared files selected by the
+// backend configuration. With shares, each one is a top-level folder.
+func OpenStorage(config *config.Config) (storage.Storage, error) {
+	if len(config.Shares) == 0 {
+		return openFiles(config, nil)
+	}
+
+	storages := make(map[string]storage.Storage)
+	for _, share := range config.Shares {
+		name := share.Name
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || hiddenPath(name) {
+			return nil, fmt.Errorf("invalid share name: %q", name)
+		}
+		if _, ok := storages[name]; ok {
+			return nil, fmt.Errorf("duplicate share: %s", name)
+		}
+		if share.Path == "" {
+			return nil, fmt.Errorf("share %s needs a path", name)
+		}
+
+		s, err := openFiles(config, &share)
+		if err != nil {
+			return nil, err
+		}
+		if share.ReadOnly {
+			s = storage.ReadOnly(s)
+		}
+		storages[name] = s
+	}
+	return storage.NewMount(storages), nil
+}
+
+// symlinkPolicy returns the configured policy for symbolic links. An invalid
+// one, which OpenStorage rejects, denies every link.
+func symlinkPolicy(config *config.Config) safepath.Policy {
+	policy, err := safepath.ParsePolicy(config.Symlinks)
+	if err != nil {
+		return safepath.Deny
+	}
+	return policy
+}
+
+// openFiles returns the storage of the files of a share, or of all the
+// files without shares, encrypted if configured. Each share has its own key
+// file.
+func openFiles(config *config.Config, share *config.ShareConfig) (storage.Storage, error) {
+	if config.Encrypt && config.Dedup {
+		return nil, errors.New("deduplication can't be combined with encryption, which stores identical files differently")
+	}
+	s, err := openBackend(config, share)
+	if err != nil || !config.Encrypt {
+		return s, err
+	}
+	if config.Passphrase == "" {
+		return nil, errors.New("encryption needs a passphrase")
+	}
+	return storage.Encrypt(s, config.Passphrase)
+}
+
+// openBackend returns the storage of the files of a share, or of all the
+// files without shares. The path of a share is a directory of the local
+// disk, or a folder of the bucket with the s3 backend.
+func openBackend(config *config.Config, share *config.ShareConfig) (storage.Storage, error) {
+	backend := config.Backend
+	switch backend.Type {
+	case "", "local":
+		root := config.RootDir
+		if share != nil {
+			root = share.Path
+		}
+		symlinks, err := safepath.ParsePolicy(config.Symlinks)
+		if err != nil {
+			return nil, err
+		}
+		if config.Dedup {
+			d, err := storage.NewDedup(root)
+			if err != nil {
+				return nil, err
+			}
+			d.SetSymlinks(symlinks)
+			return d, nil
+		}
+		local := storage.NewLocal(root)
+		local.SetSymlinks(symlinks)
+		return local, nil
+	case "s3":
+		if config.Dedup {
+			return nil, errors.New("deduplication needs the local backend")
+		}
+		if backend.AccessKey == "" || backend.SecretKey == "" {
+			return nil, errors.New("the s3 backend needs an access_key and a secret_key")
+		}
+		prefix := backend.Prefix
+		if share != nil {
+			prefix = path.Join(prefix, share.Path)
+		}
+		return storage.NewS3(storage.S3Options{
+			Endpoint:  backend.Endpoint,
+			Bucket:    backend.Bucket,
+			Prefix:    prefix,
+			Region:    backend.Region,
+			AccessKey: backend.AccessKey,
+			SecretKey: backend.SecretKey,
+			PathStyle: backend.PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend.Type)
+	}
+}
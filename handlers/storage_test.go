@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+func TestOpenStorage(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if s, err := OpenStorage(cfg); err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	} else if _, ok := s.(*storage.Local); !ok {
+		t.Errorf("El almacenamiento por defecto debería ser local: %T", s)
+	}
+
+	cfg.Backend = config.BackendConfig{
+		Type:      "s3",
+		Endpoint:  "http://localhost:9000",
+		Bucket:    "compartido",
+		Region:    "us-east-1",
+		AccessKey: "clave",
+		SecretKey: "secreto",
+		PathStyle: true,
+	}
+	if s, err := OpenStorage(cfg); err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	} else if _, ok := s.(*storage.S3); !ok {
+		t.Errorf("Se esperaba un almacenamiento S3: %T", s)
+	}
+
+	// Configuraciones inválidas
+	invalid := map[string]func(*config.BackendConfig){
+		"sin credenciales": func(b *config.BackendConfig) { b.SecretKey = "" },
+		"sin bucket":       func(b *config.BackendConfig) { b.Bucket = "" },
+		"sin endpoint":     func(b *config.BackendConfig) { b.Endpoint = "" },
+		"tipo desconocido": func(b *config.BackendConfig) { b.Type = "ftp" },
+	}
+	for name, change := range invalid {
+		c := *cfg
+		change(&c.Backend)
+		if _, err := OpenStorage(&c); err == nil {
+			t.Errorf("%s: OpenStorage() debería fallar", name)
+		}
+	}
+
+	// Con recursos compartidos, cada uno es una carpeta de la raíz
+	cfg = config.DefaultConfig()
+	cfg.Shares = []config.ShareConfig{
+		{Name: "fotos", Path: t.TempDir()},
+		{Name: "builds", Path: t.TempDir(), ReadOnly: true},
+	}
+	s, err := OpenStorage(cfg)
+	if err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	}
+	if err := storage.WriteFile(s, "fotos/playa.jpg", []byte("jpg")); err != nil {
+		t.Errorf("No se pudo escribir en un recurso: %v", err)
+	}
+	if err := storage.WriteFile(s, "builds/app.zip", []byte("zip")); err == nil {
+		t.Error("Un recurso de solo lectura no debería admitir cambios")
+	}
+
+	for _, shares := range [][]config.ShareConfig{
+		{{Name: "", Path: "."}},
+		{{Name: "a/b", Path: "."}},
+		{{Name: "config.yaml", Path: "."}},
+		{{Name: "fotos"}},
+		{{Name: "fotos", Path: "."}, {Name: "fotos", Path: "."}},
+	} {
+		cfg.Shares = shares
+		if _, err := OpenStorage(cfg); err == nil {
+			t.Errorf("OpenStorage() debería fallar con %+v", shares)
+		}
+	}
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	cfg.Encrypt = true
+
+	if _, err := OpenStorage(cfg); err == nil {
+		t.Error("OpenStorage() debería fallar sin contraseña")
+	}
+	cfg.Passphrase = "contraseña"
+	s, err := OpenStorage(cfg)
+	if err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	}
+	cfg.Storage = s
+
+	// Los archivos subidos se guardan cifrados en el disco
+	content := strings.Repeat("contenido secreto ", 10000)
+	res := httptest.NewRecorder()
+	UploadPost(cfg)(res, newUploadRequest(t, cfg, "/upload", map[string]string{"secreto.txt": content}, nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("La subida devolvió %d", res.Code)
+	}
+	raw, err := os.ReadFile(filepath.Join(cfg.RootDir, "secreto.txt"))
+	if err != nil {
+		t.Fatalf("El archivo no se guardó en el disco: %v", err)
+	}
+	if strings.Contains(string(raw), "secreto") {
+		t.Error("El archivo se guardó sin cifrar")
+	}
+
+	// Las descargas, los rangos y la vista previa se descifran
+	req := httptest.NewRequest(http.MethodGet, "/download?filename=secreto.txt", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	res = httptest.NewRecorder()
+	Download(cfg)(res, req)
+	if res.Body.String() != content {
+		t.Errorf("La descarga no coincide con el contenido (%d bytes)", res.Body.Len())
+	}
+	req.Header.Set("Range", "bytes=65530-65547")
+	res = httptest.NewRecorder()
+	Download(cfg)(res, req)
+	if res.Code != http.StatusPartialContent || res.Body.String() != content[65530:65548] {
+		t.Errorf("Rango incorrecto: %d %q", res.Code, res.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/preview?filename=secreto.txt", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	res = httptest.NewRecorder()
+	Preview(cfg)(res, req)
+	if !strings.Contains(res.Body.String(), "contenido secreto") {
+		t.Error("La vista previa no muestra el contenido descifrado")
+	}
+
+	// Con otra contraseña no se abre
+	cfg.Passphrase = "otra"
+	if _, err := OpenStorage(cfg); !errors.Is(err, storage.ErrPassphrase) {
+		t.Errorf("OpenStorage() con otra contraseña devolvió %v", err)
+	}
+}
+
+func TestDedupStorage(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	cfg.Dedup = true
+
+	s, err := OpenStorage(cfg)
+	if err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	}
+	if _, ok := s.(*storage.Dedup); !ok {
+		t.Fatalf("Se esperaba un almacenamiento con deduplicación: %T", s)
+	}
+	cfg.Storage = s
+
+	// blobs cuenta los blobs guardados en el disco
+	blobs := func() int {
+		count := 0
+		filepath.WalkDir(filepath.Join(cfg.RootDir, storage.BlobDir), func(p string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+
+	// El mismo instalador subido dos veces se guarda una sola vez
+	for _, name := range []string{"instalador.zip", "copia.zip"} {
+		res := httptest.NewRecorder()
+		UploadPost(cfg)(res, newUploadRequest(t, cfg, "/upload", map[string]string{name: "instalador de prueba"}, nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("La subida de %s devolvió %d", name, res.Code)
+		}
+	}
+	if n := blobs(); n != 1 {
+		t.Errorf("Se esperaba 1 blob, hay %d", n)
+	}
+
+	// admin crea una petición con la sesión del administrador
+	admin := func(method, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		return req
+	}
+
+	// Eliminar un archivo solo libera el blob con la última referencia
+	res := httptest.NewRecorder()
+	Delete(cfg)(res, admin(http.MethodPost, "/delete?filename=instalador.zip"))
+	if n := blobs(); n != 1 {
+		t.Errorf("El blob todavía tiene una referencia, hay %d blobs", n)
+	}
+	if data, _ := storage.ReadFile(s, "copia.zip"); string(data) != "instalador de prueba" {
+		t.Errorf("La copia perdió su contenido: %q", data)
+	}
+	res = httptest.NewRecorder()
+	Delete(cfg)(res, admin(http.MethodPost, "/delete?filename=copia.zip"))
+	if n := blobs(); n != 0 {
+		t.Errorf("El blob sin referencias no se liberó, hay %d blobs", n)
+	}
+
+	// El directorio de blobs no aparece en los listados
+	res = httptest.NewRecorder()
+	Index(cfg)(res, admin(http.MethodGet, "/"))
+	if strings.Contains(res.Body.String(), storage.BlobDir) {
+		t.Error("El directorio de blobs no debería aparecer en el listado")
+	}
+
+	// Configuraciones incompatibles
+	cfg.Encrypt, cfg.Passphrase = true, "contraseña"
+	if _, err := OpenStorage(cfg); err == nil {
+		t.Error("La deduplicación no debería combinarse con el cifrado")
+	}
+	cfg.Encrypt = false
+	cfg.Backend = config.BackendConfig{Type: "s3", Endpoint: "http://localhost:9000", Bucket: "b", AccessKey: "a", SecretKey: "s"}
+	if _, err := OpenStorage(cfg); err == nil {
+		t.Error("La deduplicación necesita el almacenamiento local")
+	}
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secreto.txt"), []byte("secreto"), 0644)
+	os.MkdirAll(filepath.Join(cfg.RootDir, "docs"), 0755)
+	os.WriteFile(filepath.Join(cfg.RootDir, "docs", "informe.txt"), []byte("informe"), 0644)
+	if err := os.Symlink(outside, filepath.Join(cfg.RootDir, "fuera")); err != nil {
+		t.Skipf("No se pueden crear enlaces simbólicos: %v", err)
+	}
+	os.Symlink("docs", filepath.Join(cfg.RootDir, "dentro"))
+
+	cfg.Symlinks = "within_root"
+	s, err := OpenStorage(cfg)
+	if err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	}
+	cfg.Storage = s
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/download?filename=dentro/informe.txt", http.StatusOK},
+		{"/download?filename=fuera/secreto.txt", http.StatusForbidden},
+		{"/download?filename=../secreto.txt", http.StatusForbidden},
+		{"/download?filename=docs/a%00b", http.StatusBadRequest},
+		{"/preview?filename=fuera/secreto.txt", http.StatusForbidden},
+		{"/browse/fuera", http.StatusForbidden},
+		{"/browse/dentro", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tt.target, nil)
+		req.AddCookie(sessionCookie(cfg, cfg.Username))
+		switch {
+		case strings.HasPrefix(tt.target, "/download"):
+			Download(cfg).ServeHTTP(rr, req)
+		case strings.HasPrefix(tt.target, "/preview"):
+			Preview(cfg).ServeHTTP(rr, req)
+		default:
+			Browse(cfg).ServeHTTP(rr, req)
+		}
+		if rr.Code != tt.status {
+			t.Errorf("%s: código %d, se esperaba %d", tt.target, rr.Code, tt.status)
+		}
+	}
+
+	// Los enlaces externos no aparecen en el listado
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr := httptest.NewRecorder()
+	Index(cfg).ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "fuera") {
+		t.Error("El listado no debería mostrar enlaces fuera del directorio compartido")
+	}
+
+	// Con deny ningún enlace se sigue
+	cfg.Symlinks = "deny"
+	if cfg.Storage, err = OpenStorage(cfg); err != nil {
+		t.Fatalf("OpenStorage() devolvió error: %v", err)
+	}
+	req = httptest.NewRequest("GET", "/download?filename=dentro/informe.txt", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	Download(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Con deny un enlace interno debería denegarse: código %d", rr.Code)
+	}
+
+	cfg.Symlinks = "siempre"
+	if _, err := OpenStorage(cfg); err == nil {
+		t.Error("OpenStorage() debería rechazar una política desconocida")
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/metadata"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+const (
+	// thumbnailHeight is the height of the thumbnails, twice the height of
+	// the rows of the gallery for high-density screens
+	thumbnailHeight = 400
+	// thumbnailMaxWidth limits the width of the thumbnails of panoramas
+	thumbnailMaxWidth = 4 * thumbnailHeight
+	// maxThumbnailPixels is the size of the largest image that is decoded
+	// to make its thumbnail, since decoding needs its pixels in memory
+	// (about 160 MB for 40 megapixels)
+	maxThumbnailPixels = 40_000_000
+	// maxDecodes is how many images are decoded at the same time
+	maxDecodes = 2
+	// maxThumbnails is how many thumbnails are kept in memory
+	maxThumbnails = 512
+)
+
+// thumbnail is a scaled-down image encoded as JPEG, valid while the
+// original isn't modified
+type thumbnail struct {
+	key     string
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// thumbnailCache keeps the most recently used thumbnails
+type thumbnailCache struct {
+	mu      sync.Mutex
+	order   *list.List               // Most recently used first
+	entries map[string]*list.Element // By checksumKey
+}
+
+var (
+	thumbnails = &thumbnailCache{order: list.New(), entries: map[string]*list.Element{}}
+	// decodes limits the images decoded at the same time, which need all
+	// their pixels in memory
+	decodes = make(chan struct{}, maxDecodes)
+)
+
+// get returns the thumbnail of a file if it's cached
+func (c *thumbnailCache) get(key string) (thumbnail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return thumbnail{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(thumbnail), true
+}
+
+// put caches a thumbnail, forgetting the least recently used one if full
+func (c *thumbnailCache) put(t thumbnail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[t.key]; ok {
+		e.Value = t
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[t.key] = c.order.PushFront(t)
+	if c.order.Len() > maxThumbnails {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(thumbnail).key)
+	}
+}
+
+// scaleImage shrinks an image to fit in width x height, averaging the pixels
+// of the original that fall in each pixel of the result
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
+
+// orientImage turns an image as its EXIF orientation says, since the
+// thumbnails don't keep the metadata that browsers use to do it
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirror
+				sx, sy = w-1-x, y
+			case 3: // Rotate 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // Flip vertically
+				sx, sy = x, h-1-y
+			case 5: // Transpose
+				sx, sy = y, x
+			case 6: // Rotate 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotate 90° counterclockwise
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// makeThumbnail decodes an image and returns its thumbnail as JPEG, or nil
+// if the image is already small enough, or too large, to be used as is
+func makeThumbnail(ctx context.Context, file io.ReadSeeker) ([]byte, error) {
+	info, err := metadata.ReadImage(file)
+	if err != nil {
+		return nil, err
+	}
+	if int64(info.Width)*int64(info.Height) > maxThumbnailPixels {
+		return nil, nil
+	}
+	width, height := info.Width, info.Height
+	if info.Orientation >= 5 {
+		width, height = height, width
+	}
+	scale := min(float64(thumbnailHeight)/float64(height), float64(thumbnailMaxWidth)/float64(width))
+	if scale >= 1 && info.Orientation == 1 {
+		return nil, nil
+	}
+	scale = min(scale, 1)
+
+	select {
+	case decodes <- struct{}{}:
+		defer func() { <-decodes }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	tw, th := max(int(float64(width)*scale), 1), max(int(float64(height)*scale), 1)
+	if info.Orientation >= 5 {
+		tw, th = th, tw
+	}
+	img := orientImage(scaleImage(src, tw, th), info.Orientation)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Thumbnail serves a scaled-down version of an image for the gallery. The
+// images that can't be decoded, like SVG or WebP, and the ones that are
+// already small or too large to decode are served as they are, like Preview
+// does.
+func Thumbnail(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := r.URL.Query().Get("filename")
+		if filename == "" {
+			http.Error(w, "Filename is required", http.StatusBadRequest)
+			return
+		}
+
+		name, ok := resolvePath(w, r, config, filename)
+		if !ok {
+			return
+		}
+		if getFileType(filename) != templates.FileTypeImage {
+			http.Error(w, "Unsupported file type for thumbnails", http.StatusBadRequest)
+			return
+		}
+
+		file, err := fileStorage(config).Open(name)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		fileInfo, err := file.Stat()
+		if err != nil || fileInfo.IsDir() {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+
+		key := checksumKey(config, name)
+		cached, ok := thumbnails.get(key)
+		if !ok || !cached.modTime.Equal(fileInfo.ModTime()) || cached.size != fileInfo.Size() {
+			data, err := makeThumbnail(r.Context(), file)
+			if r.Context().Err() != nil {
+				return
+			}
+			if err != nil || data == nil {
+				Preview(config).ServeHTTP(w, r)
+				return
+			}
+			cached = thumbnail{key: key, modTime: fileInfo.ModTime(), size: fileInfo.Size(), data: data}
+			thumbnails.put(cached)
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		http.ServeContent(w, r, "", cached.modTime, bytes.NewReader(cached.data))
+	}
+}
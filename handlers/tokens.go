@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/templates"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+// requireSession rejects requests authenticated with an API token, so that
+// a token can't be used to create tokens with more scopes than its own
+func requireSession(w http.ResponseWriter, r *http.Request, config *config.Config) bool {
+	if bearerToken(r) != "" || !hasValidSession(r, config) {
+		http.Error(w, "Tokens can only be managed from a browser session", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// renderTokens renders the API tokens page
+func renderTokens(w http.ResponseWriter, r *http.Request, config *config.Config, data templates.TokensData) {
+	data.Title = config.Title
+	data.Scopes = tokens.Scopes
+
+	now := time.Now()
+	for _, token := range config.Tokens.List() {
+		info := templates.TokenInfo{
+			ID:       token.ID,
+			Name:     token.Name,
+			Scopes:   strings.Join(token.Scopes, ", "),
+			Created:  token.CreatedAt.Local().Format(dateLayout),
+			Expires:  "Never",
+			LastUsed: "Never",
+			Expired:  token.Expired(now),
+		}
+		if !token.ExpiresAt.IsZero() {
+			info.Expires = token.ExpiresAt.Local().Format(dateLayout)
+		}
+		if !token.LastUsed.IsZero() {
+			info.LastUsed = token.LastUsed.Local().Format(dateLayout)
+		}
+		data.Tokens = append(data.Tokens, info)
+	}
+
+	layoutData := templates.LayoutData{
+		Title:      config.Title + " - API tokens",
+		IsLoggedIn: true,
+		Username:   currentUser(r, config),
+		ReadOnly:   ReadOnly(config),
+	}
+
+	// Render the template with the layout
+	component := templates.Tokens(data)
+	ctx := r.Context()
+	handler := templates.LayoutWithData(layoutData)
+
+	templ.Handler(handler).ServeHTTP(w, r.WithContext(templ.WithChildren(ctx, component)))
+}
+
+// Tokens displays the API tokens page - protected and admin only
+func Tokens(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireSession(w, r, config) {
+			return
+		}
+		if config.Tokens == nil {
+			http.Error(w, "API tokens are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		renderTokens(w, r, config, templates.TokensData{})
+	}
+}
+
+// TokensPost creates an API token - protected and admin only
+func TokensPost(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireSession(w, r, config) {
+			return
+		}
+		if config.Tokens == nil {
+			http.Error(w, "API tokens are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Error processing form", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			renderTokens(w, r, config, templates.TokensData{Error: "A name is required"})
+			return
+		}
+
+		// Number of days until the token expires, 0 for never
+		var expiresAt time.Time
+		days, err := strconv.Atoi(r.FormValue("expires"))
+		if err != nil || days < 0 {
+			renderTokens(w, r, config, templates.TokensData{Error: "Invalid expiration"})
+			return
+		}
+		if days > 0 {
+			expiresAt = time.Now().UTC().AddDate(0, 0, days)
+		}
+
+		token, secret, err := config.Tokens.Create(name, currentUser(r, config), r.Form["scopes"], expiresAt)
+		if errors.Is(err, tokens.ErrInvalidScope) {
+			renderTokens(w, r, config, templates.TokensData{Error: "Select at least one valid scope"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error creating token: %v", err)
+			renderTokens(w, r, config, templates.TokensData{Error: "Error creating token"})
+			return
+		}
+
+		renderTokens(w, r, config, templates.TokensData{NewToken: secret, NewName: token.Name})
+	}
+}
+
+// TokensRevoke revokes an API token - protected and admin only
+func TokensRevoke(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireSession(w, r, config) {
+			return
+		}
+		if config.Tokens == nil {
+			http.Error(w, "API tokens are not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Error processing form", http.StatusBadRequest)
+			return
+		}
+
+		err := config.Tokens.Revoke(r.FormValue("id"))
+		if errors.Is(err, tokens.ErrNotFound) {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error revoking token: %v", err)
+			http.Error(w, "Error revoking token", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/settings/tokens", http.StatusSeeOther)
+	}
+}
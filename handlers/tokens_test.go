@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/tokens"
+)
+
+// setupTokens crea un almacén de tokens vacío para la configuración de pruebas
+func setupTokens(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	store, err := tokens.Open(filepath.Join(cfg.CacheDir, "tokens.json"))
+	if err != nil {
+		t.Fatalf("No se pudo abrir el almacén de tokens: %v", err)
+	}
+	cfg.Tokens = store
+}
+
+// createToken crea un token con los scopes indicados y devuelve su secreto
+func createToken(t *testing.T, cfg *config.Config, username string, scopes []string, expiresAt time.Time) string {
+	t.Helper()
+
+	_, secret, err := cfg.Tokens.Create("test", username, scopes, expiresAt)
+	if err != nil {
+		t.Fatalf("No se pudo crear el token: %v", err)
+	}
+	return secret
+}
+
+// bearerRequest ejecuta una petición contra la API con un token
+func bearerRequest(cfg *config.Config, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	API(cfg).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+	setupAPIFiles(t, cfg)
+
+	reader := createToken(t, cfg, cfg.Username, []string{tokens.Read}, time.Time{})
+	deleter := createToken(t, cfg, cfg.Username, []string{tokens.Read, tokens.Delete}, time.Time{})
+	other := createToken(t, cfg, "otro", []string{tokens.Delete}, time.Time{})
+	expired := createToken(t, cfg, cfg.Username, []string{tokens.Read}, time.Now().Add(-time.Hour))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+reader)
+	if !isAuthenticated(req, cfg) || currentUser(req, cfg) != cfg.Username {
+		t.Error("Un token válido debería autenticar la petición")
+	}
+
+	// Lectura
+	if rr := bearerRequest(cfg, http.MethodGet, "/api/v1/files", reader); rr.Code != http.StatusOK {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+	checkAPIError(t, bearerRequest(cfg, http.MethodGet, "/api/v1/files", other), http.StatusForbidden)
+	checkAPIError(t, bearerRequest(cfg, http.MethodGet, "/api/v1/files", expired), http.StatusUnauthorized)
+	checkAPIError(t, bearerRequest(cfg, http.MethodGet, "/api/v1/files", "sic_invalido"), http.StatusUnauthorized)
+
+	// Eliminación: requiere el scope y que el token sea de un admin
+	checkAPIError(t, bearerRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", reader), http.StatusForbidden)
+	checkAPIError(t, bearerRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", other), http.StatusForbidden)
+	if rr := bearerRequest(cfg, http.MethodDelete, "/api/v1/files?path=foto.jpg", deleter); rr.Code != http.StatusNoContent {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusNoContent)
+	}
+
+	// Se registra el último uso
+	for _, token := range cfg.Tokens.List() {
+		if token.Scopes[0] == tokens.Read && !token.Expired(time.Now()) && token.LastUsed.IsZero() {
+			t.Errorf("El último uso del token %s no se ha registrado", token.ID)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+
+	uploader := createToken(t, cfg, cfg.Username, []string{tokens.Upload}, time.Time{})
+	reader := createToken(t, cfg, cfg.Username, []string{tokens.Read}, time.Time{})
+
+	handler := RequireAuth(RequireScope(tokens.Upload, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, cfg), cfg)
+
+	tests := []struct {
+		name   string
+		header string
+		cookie *http.Cookie
+		code   int
+	}{
+		{"sesión", "", sessionCookie(cfg, cfg.Username), http.StatusOK},
+		{"token con scope", "Bearer " + uploader, nil, http.StatusOK},
+		{"token sin scope", "Bearer " + reader, nil, http.StatusForbidden},
+		{"token inválido", "Bearer sic_invalido", nil, http.StatusUnauthorized},
+		{"sin autenticación", "", nil, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != tt.code {
+				t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestTokensSettings(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+
+	// Crear un token desde la página de ajustes
+	form := url.Values{"name": {"backup nocturno"}, "expires": {"30"}, "scopes": {"read", "upload"}}
+	req := httptest.NewRequest(http.MethodPost, "/settings/tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr := httptest.NewRecorder()
+	TokensPost(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+	secret := regexp.MustCompile(`sic_[0-9a-f]+`).FindString(rr.Body.String())
+	if secret == "" {
+		t.Fatal("La página debería mostrar el token creado")
+	}
+
+	token, ok := cfg.Tokens.Authenticate(secret)
+	if !ok {
+		t.Fatal("El token creado debería ser válido")
+	}
+	if token.Name != "backup nocturno" || !token.HasScope(tokens.Upload) || token.HasScope(tokens.Delete) {
+		t.Errorf("Token incorrecto: %+v", token)
+	}
+	if days := time.Until(token.ExpiresAt).Hours() / 24; days < 29 || days > 30 {
+		t.Errorf("Expiración incorrecta: %v", token.ExpiresAt)
+	}
+
+	// El secreto no se guarda en disco
+	data, _ := os.ReadFile(filepath.Join(cfg.CacheDir, "tokens.json"))
+	if strings.Contains(string(data), secret) {
+		t.Error("El archivo de tokens no debería contener el secreto")
+	}
+
+	// Sin scopes
+	form = url.Values{"name": {"vacío"}, "expires": {"0"}}
+	req = httptest.NewRequest(http.MethodPost, "/settings/tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	TokensPost(cfg).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "Select at least one valid scope") {
+		t.Error("La página debería indicar que faltan scopes")
+	}
+
+	// Listado
+	req = httptest.NewRequest(http.MethodGet, "/settings/tokens", nil)
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	Tokens(cfg).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "backup nocturno") || strings.Contains(rr.Body.String(), secret) {
+		t.Error("El listado debería mostrar el nombre del token pero no su secreto")
+	}
+
+	// Los tokens no pueden gestionar tokens
+	req = httptest.NewRequest(http.MethodGet, "/settings/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rr = httptest.NewRecorder()
+	Tokens(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusForbidden)
+	}
+
+	// Revocar
+	form = url.Values{"id": {token.ID}}
+	req = httptest.NewRequest(http.MethodPost, "/settings/tokens/revoke", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sessionCookie(cfg, cfg.Username))
+	rr = httptest.NewRecorder()
+	TokensRevoke(cfg).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusSeeOther)
+	}
+	if _, ok := cfg.Tokens.Authenticate(secret); ok {
+		t.Error("El token revocado no debería ser válido")
+	}
+}
+
+func TestBearerPages(t *testing.T) {
+	cfg := setupTestConfig()
+	defer cleanupTestConfig(cfg)
+	setupTokens(t, cfg)
+	setupAPIFiles(t, cfg)
+
+	secret := createToken(t, cfg, cfg.Username, []string{tokens.Read}, time.Time{})
+
+	// Las páginas no deben depender de la cookie de sesión
+	pages := map[string]http.HandlerFunc{
+		"/":            Index(cfg),
+		"/browse/docs": Browse(cfg),
+		"/upload":      Upload(cfg),
+		"/usage":       DiskUsage(cfg),
+		"/gallery/":    Gallery(cfg),
+	}
+	for target, handler := range pages {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: código de estado incorrecto: obtenido %v, esperado %v", target, rr.Code, http.StatusOK)
+		}
+	}
+}
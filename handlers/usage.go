@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/a-h/templ"
+	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/diskusage"
+	"github.com/rodrwan/shareiscare/templates"
+)
+
+// usageEntries is the number of folders and files listed in the disk usage page
+const usageEntries = 20
+
+// folderSize returns the size displayed for a directory in the listings
+func folderSize(config *config.Config, rel string) string {
+	if config.Usage == nil {
+		return "directory"
+	}
+
+	entry, ok := config.Usage.Dir(rel)
+	if !ok {
+		return "directory"
+	}
+
+	if entry.Files == 1 {
+		return formatSize(entry.Size) + " · 1 file"
+	}
+	return fmt.Sprintf("%s · %d files", formatSize(entry.Size), entry.Files)
+}
+
+// visibleEntries returns the disk usage entries that aren't excluded nor
+// hidden from the user of the request
+func visibleEntries(r *http.Request, config *config.Config, entries []diskusage.Entry) []diskusage.Entry {
+	var shown []diskusage.Entry
+	for _, entry := range entries {
+		if !excluded(config, entry.Path, entry.IsDir) && visible(r, config, entry.Path) {
+			shown = append(shown, entry)
+		}
+	}
+	return shown
+}
+
+// usageBars converts disk usage entries into bars relative to total
+func usageBars(entries []diskusage.Entry, total int64) []templates.UsageEntry {
+	var bars []templates.UsageEntry
+	for _, entry := range entries {
+		percent := 0
+		if total > 0 {
+			percent = int(entry.Size * 100 / total)
+		}
+
+		link := "/browse/" + entry.Path
+		if !entry.IsDir {
+			link = "/browse/" + path.Dir(entry.Path)
+			if path.Dir(entry.Path) == "." {
+				link = "/"
+			}
+		}
+
+		bars = append(bars, templates.UsageEntry{
+			Path:    entry.Path,
+			Link:    link,
+			Size:    formatSize(entry.Size),
+			Files:   entry.Files,
+			Percent: percent,
+		})
+	}
+	return bars
+}
+
+// DiskUsage displays the largest folders and files and the free space
+func DiskUsage(config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := templates.UsageData{
+			Title: config.Title,
+		}
+
+		// Free space of the filesystem backing the shared directory
+		total, free, err := diskusage.FreeSpace(config.RootDir)
+		if err != nil {
+			log.Printf("Error reading free space: %v", err)
+		} else if total > 0 {
+			data.DiskTotal = formatSize(int64(total))
+			data.DiskFree = formatSize(int64(free))
+			data.DiskUsedPercent = int((total - free) * 100 / total)
+		}
+
+		if config.Usage != nil && !config.Usage.LastScan().IsZero() {
+			root, _ := config.Usage.Dir("")
+			data.Scanned = true
+			data.ScannedAt = config.Usage.LastScan().Format(dateLayout)
+			data.SharedSize = formatSize(root.Size)
+			data.SharedFiles = root.Files
+			data.Folders = usageBars(visibleEntries(r, config, config.Usage.LargestDirs(usageEntries)), root.Size)
+			data.Files = usageBars(visibleEntries(r, config, config.Usage.LargestFiles(usageEntries)), root.Size)
+		}
+
+		// Get the username if authenticated
+		isLoggedIn := isAuthenticated(r, config)
+		username := ""
+		if isLoggedIn {
+			username = currentUser(r, config)
+		}
+
+		layoutData := templates.LayoutData{
+			Title:      config.Title + " - Disk usage",
+			IsLoggedIn: isLoggedIn,
+			Username:   username,
+			ReadOnly:   ReadOnly(config),
+		}
+
+		// Render the template with the layout
+		component := templates.Usage(data)
+		ctx := r.Context()
+		handler := templates.LayoutWithData(layoutData)
+
+		templ.Handler(handler).ServeHTTP(w, r.WithContext(templ.WithChildren(ctx, component)))
+	}
+}
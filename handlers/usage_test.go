@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/diskusage"
+)
+
+func TestDiskUsage(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+
+	// Crear una carpeta con archivos de prueba
+	dir := filepath.Join(cfg.RootDir, "fotos")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio de prueba: %v", err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 2048), 0644); err != nil {
+			t.Fatalf("No se pudo crear archivo de prueba: %v", err)
+		}
+	}
+
+	handler := DiskUsage(cfg)
+
+	// Sin escaneo todavía
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Código de estado incorrecto: obtenido %v, esperado %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "first scan is still running") {
+		t.Error("La página debería indicar que el escaneo no ha terminado")
+	}
+
+	// Con un escaneo terminado
+	cfg.Usage = diskusage.NewScanner(cfg.RootDir)
+	if err := cfg.Usage.Scan(); err != nil {
+		t.Fatalf("Error al escanear: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "Largest folders") {
+		t.Error("La página debería mostrar las carpetas más grandes")
+	}
+	if !strings.Contains(body, "fotos/a.jpg") {
+		t.Error("La página debería mostrar los archivos más grandes")
+	}
+	if !strings.Contains(body, "4.0 KB") {
+		t.Error("La página debería mostrar el tamaño de la carpeta")
+	}
+}
+
+func TestFolderSize(t *testing.T) {
+	cfg := setupDiskConfig()
+	defer cleanupTestConfig(cfg)
+
+	dir := filepath.Join(cfg.RootDir, "docs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio de prueba: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("No se pudo crear archivo de prueba: %v", err)
+	}
+
+	// Sin escáner se muestra "directory"
+	if size := folderSize(cfg, "docs"); size != "directory" {
+		t.Errorf("Tamaño incorrecto sin escáner: %q", size)
+	}
+
+	cfg.Usage = diskusage.NewScanner(cfg.RootDir)
+	if err := cfg.Usage.Scan(); err != nil {
+		t.Fatalf("Error al escanear: %v", err)
+	}
+
+	if size := folderSize(cfg, "docs"); size != "1.0 KB · 1 file" {
+		t.Errorf("Tamaño incorrecto: %q", size)
+	}
+
+	// El listado principal usa el total de la carpeta
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	Index(cfg).ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "1.0 KB · 1 file") {
+		t.Error("El listado debería mostrar el tamaño de la carpeta")
+	}
+}
@@ -0,0 +1,261 @@
+// Package ignore decides which shared files are excluded, with the
+// gitignore-style patterns of the configuration and of the .shareignore
+// files of each directory.
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+// FileName is the name of the files with the patterns of a directory
+const FileName = ".shareignore"
+
+// recheckInterval is how long the rules of a directory are used before
+// checking if its .shareignore file has changed
+const recheckInterval = 2 * time.Second
+
+// rule is a pattern of a .shareignore file or of the configuration
+type rule struct {
+	negate  bool           // Re-includes what the previous rules excluded
+	dirOnly bool           // Only matches directories
+	re      *regexp.Regexp // Matches the path relative to the directory of the rule
+}
+
+// Rules are the patterns of a directory, in order
+type Rules []rule
+
+// compile converts a gitignore pattern, without its "!" and trailing
+// slash, to a regular expression
+func compile(pattern string) (*regexp.Regexp, error) {
+	// A pattern with a slash other than at the end only matches paths
+	// relative to its directory, otherwise it matches names at any level
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			// Any number of directories, even none
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && i > 0 && pattern[i-1] == '/':
+			// Everything inside the directory
+			b.WriteString(".+")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %q", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// Parse parses patterns with the syntax of .gitignore files: "#" starts a
+// comment, "!" re-includes what was excluded, a trailing "/" only matches
+// directories, a slash elsewhere anchors the pattern to its directory and
+// "**" matches any number of directories.
+func Parse(lines []string) (Rules, error) {
+	var rules Rules
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		// Trailing spaces are ignored unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r rule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		re, err := compile(line)
+		if err != nil {
+			return nil, err
+		}
+		r.re = re
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// match returns whether one of the rules matches a path relative to their
+// directory, and if so whether the last one that does excludes it
+func (rs Rules) match(name string, isDir bool) (matched, excluded bool) {
+	for _, r := range rs {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(name) {
+			matched, excluded = true, !r.negate
+		}
+	}
+	return matched, excluded
+}
+
+// cached are the rules of a directory, valid while its .shareignore file
+// isn't modified. Directories without one are cached too.
+type cached struct {
+	checked time.Time // When the file was last looked up
+	exists  bool
+	modTime time.Time
+	size    int64
+	rules   Rules
+}
+
+// Matcher decides which files of a storage are excluded. The patterns of
+// the configuration apply to the whole storage, and the ones of each
+// .shareignore file to its directory and subdirectories, overriding the
+// ones of the directories above. As in git, a file inside an excluded
+// directory can't be re-included.
+type Matcher struct {
+	files  storage.Storage
+	global Rules
+
+	mu    sync.Mutex
+	cache map[string]cached // By directory
+	now   func() time.Time  // Clock for the rechecks, time.Now if nil
+}
+
+// New returns a matcher for the files of a storage with the patterns of
+// the configuration
+func New(files storage.Storage, patterns []string) (*Matcher, error) {
+	global, err := Parse(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %v", err)
+	}
+	return &Matcher{files: files, global: global, cache: map[string]cached{}}, nil
+}
+
+// currentTime returns the time of the matcher's clock
+func (m *Matcher) currentTime() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// store caches the rules of a directory
+func (m *Matcher) store(dir string, c cached) {
+	m.mu.Lock()
+	m.cache[dir] = c
+	m.mu.Unlock()
+}
+
+// rules returns the rules of the .shareignore file of a directory. They're
+// cached, and only read again when the file has changed, which is checked
+// once in a while.
+func (m *Matcher) rules(dir string) Rules {
+	now := m.currentTime()
+	m.mu.Lock()
+	c, ok := m.cache[dir]
+	m.mu.Unlock()
+	if ok && now.Sub(c.checked) < recheckInterval {
+		return c.rules
+	}
+
+	name := path.Join(dir, FileName)
+	info, err := m.files.Stat(name)
+	if err != nil || info.IsDir() {
+		m.store(dir, cached{checked: now})
+		return nil
+	}
+	if ok && c.exists && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		c.checked = now
+		m.store(dir, c)
+		return c.rules
+	}
+
+	data, err := storage.ReadFile(m.files, name)
+	if err != nil {
+		return nil
+	}
+	// Invalid patterns are skipped, like git does, instead of exposing the
+	// whole directory
+	var rules Rules
+	for _, line := range strings.Split(string(data), "\n") {
+		if r, err := Parse([]string{line}); err == nil {
+			rules = append(rules, r...)
+		}
+	}
+
+	m.store(dir, cached{checked: now, exists: true, modTime: info.ModTime(), size: info.Size(), rules: rules})
+	return rules
+}
+
+// Excluded checks if a slash-separated name of the storage is excluded,
+// because it or one of its parents matches a pattern. isDir tells if the
+// name is a directory.
+func (m *Matcher) Excluded(name string, isDir bool) bool {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return false
+	}
+	parts := strings.Split(name, "/")
+
+	// The rules of every directory above the name, from the root down
+	dirs := make([]Rules, len(parts))
+	for i := range parts {
+		dirs[i] = m.rules(strings.Join(parts[:i], "/"))
+	}
+
+	for i := range parts {
+		p := strings.Join(parts[:i+1], "/")
+		dir := i < len(parts)-1 || isDir
+
+		_, excluded := m.global.match(p, dir)
+		for j := 0; j <= i; j++ {
+			rel := strings.Join(parts[j:i+1], "/")
+			if matched, ex := dirs[j].match(rel, dir); matched {
+				excluded = ex
+			}
+		}
+		if excluded {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package ignore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/storage"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		isDir   bool
+		match   bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/debug.log", false, true},
+		{"*.log", "debug.log.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/todo.txt", "todo.txt", false, true},
+		{"/todo.txt", "docs/todo.txt", false, false},
+		{"docs/*.pdf", "docs/a.pdf", false, true},
+		{"docs/*.pdf", "docs/sub/a.pdf", false, false},
+		{"docs/*.pdf", "otros/docs/a.pdf", false, false},
+		{"**/secretos", "secretos", true, true},
+		{"**/secretos", "a/b/secretos", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"privado/**", "privado/a/b.txt", false, true},
+		{"privado/**", "privado", true, false},
+		{"foto?.jpg", "foto1.jpg", false, true},
+		{"foto?.jpg", "foto10.jpg", false, false},
+		{"[abc].txt", "b.txt", false, true},
+		{"[!abc].txt", "b.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{`\#nota`, "#nota", false, true},
+		{`\!importante`, "!importante", false, true},
+		{"nota.txt   ", "nota.txt", false, true},
+		{`espacio\ `, "espacio ", false, true},
+		{"*.[ch]", "main.c", false, true},
+	}
+	for _, tt := range tests {
+		rules, err := Parse([]string{tt.pattern})
+		if err != nil {
+			t.Errorf("Parse(%q) devolvió error: %v", tt.pattern, err)
+			continue
+		}
+		if matched, _ := rules.match(tt.name, tt.isDir); matched != tt.match {
+			t.Errorf("%q con %q: coincide = %v, se esperaba %v", tt.pattern, tt.name, matched, tt.match)
+		}
+	}
+
+	rules, err := Parse([]string{"# comentario", "", "   ", "*.tmp", "!guardar.tmp"})
+	if err != nil {
+		t.Fatalf("Parse() devolvió error: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Errorf("Se esperaban 2 reglas, hay %d", len(rules))
+	}
+	if _, excluded := rules.match("borrar.tmp", false); !excluded {
+		t.Error("borrar.tmp debería estar excluido")
+	}
+	if matched, excluded := rules.match("guardar.tmp", false); !matched || excluded {
+		t.Error("La negación debería volver a incluir guardar.tmp")
+	}
+
+	if _, err := Parse([]string{"[abc"}); err == nil {
+		t.Error("Parse() debería rechazar una clase sin cerrar")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	files := storage.NewMemory()
+	for name, content := range map[string]string{
+		"docs/informe.pdf":       "pdf",
+		"docs/borrador.tmp":      "tmp",
+		"docs/.shareignore":      "*.pdf\n!publico.pdf\nprivado/\n[roto\n",
+		"docs/publico.pdf":       "pdf",
+		"docs/privado/nota.txt":  "nota",
+		"docs/sub/.shareignore":  "!informe.pdf\n",
+		"docs/sub/informe.pdf":   "pdf",
+		"otros/informe.pdf":      "pdf",
+		"node_modules/a/b.js":    "js",
+		"backups/.shareignore":   "!*.tmp\n",
+		"backups/copia.tmp":      "tmp",
+		"backups/privado/db.tmp": "tmp",
+	} {
+		if err := storage.WriteFile(files, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := New(files, []string{"*.tmp", "node_modules/"})
+	if err != nil {
+		t.Fatalf("New() devolvió error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		isDir    bool
+		excluded bool
+	}{
+		{"", true, false},
+		{"docs", true, false},
+		{"docs/borrador.tmp", false, true},
+		{"docs/informe.pdf", false, true},
+		{"docs/publico.pdf", false, false},
+		{"docs/privado", true, true},
+		{"docs/privado/nota.txt", false, true},
+		{"docs/sub/informe.pdf", false, false},
+		{"otros/informe.pdf", false, false},
+		{"node_modules", true, true},
+		{"node_modules/a/b.js", false, true},
+		{"backups/copia.tmp", false, false},
+		{"backups/privado/db.tmp", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Excluded(tt.name, tt.isDir); got != tt.excluded {
+			t.Errorf("Excluded(%q) = %v, se esperaba %v", tt.name, got, tt.excluded)
+		}
+	}
+
+	// Los cambios de un .shareignore se aplican sin reiniciar, tras
+	// comprobarse otra vez
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	m.Excluded("otros/informe.pdf", false)
+	storage.WriteFile(files, "otros/.shareignore", []byte("informe.pdf\n"))
+	if m.Excluded("otros/informe.pdf", false) {
+		t.Error("Las reglas deberían seguir en caché hasta la siguiente comprobación")
+	}
+	now = now.Add(recheckInterval)
+	if !m.Excluded("otros/informe.pdf", false) {
+		t.Error("El nuevo .shareignore debería aplicarse")
+	}
+	files.Remove("otros/.shareignore")
+	now = now.Add(recheckInterval)
+	if m.Excluded("otros/informe.pdf", false) {
+		t.Error("El .shareignore eliminado no debería aplicarse")
+	}
+
+	if _, err := New(files, []string{"[roto"}); err == nil {
+		t.Error("New() debería rechazar patrones inválidos en la configuración")
+	}
+}
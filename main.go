@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
+	"github.com/rodrwan/shareiscare/cli"
 	"github.com/rodrwan/shareiscare/config"
+	"github.com/rodrwan/shareiscare/diskusage"
+	"github.com/rodrwan/shareiscare/expiry"
 	"github.com/rodrwan/shareiscare/handlers"
+	"github.com/rodrwan/shareiscare/ignore"
+	"github.com/rodrwan/shareiscare/quota"
+	"github.com/rodrwan/shareiscare/storage"
+	"github.com/rodrwan/shareiscare/tokens"
+	"github.com/rodrwan/shareiscare/watcher"
+	"golang.org/x/term"
 )
 
 const (
@@ -37,40 +51,247 @@ func PrintHelp() {
 	fmt.Println("  shareiscare init [path]           Generate base configuration file")
 	fmt.Println("  shareiscare help                  Show this help")
 	fmt.Println("  shareiscare version               Show program version")
+	fmt.Println("\nClient commands:")
+	for _, command := range cli.Commands {
+		fmt.Printf("  shareiscare %-24s%s\n", command.Usage, command.Description)
+	}
 	fmt.Println("\nExamples:")
 	fmt.Println("  shareiscare                       Start server with config.yaml")
 	fmt.Println("  shareiscare init                  Generate config.yaml in current directory")
 	fmt.Println("  shareiscare init my-config.yaml   Generate configuration in my-config.yaml")
+	fmt.Println("  shareiscare login http://localhost:8080")
+	fmt.Println("  shareiscare put *.jpg http://localhost:8080/fotos")
+	fmt.Println("  shareiscare get -r http://localhost:8080/docs")
+	fmt.Println("  shareiscare sync ~/Documents http://localhost:8080/docs")
 }
 
 // RunServer starts the HTTP server
 func RunServer(config *config.Config) {
+	// Ask for the passphrase of the encrypted files
+	if config.Encrypt && config.Passphrase == "" {
+		passphrase, err := readPassphrase()
+		if err != nil {
+			log.Fatalf("Error reading the passphrase: %v", err)
+		}
+		config.Passphrase = passphrase
+	}
+
+	// Open the storage of the shared files
+	fileStorage, err := handlers.OpenStorage(config)
+	if err != nil {
+		log.Fatalf("Error opening the storage backend: %v", err)
+	}
+	config.Storage = fileStorage
+
+	// Files hidden from every client, by the configuration and the
+	// .shareignore files
+	config.Ignore, err = ignore.New(fileStorage, config.Exclude)
+	if err != nil {
+		log.Fatalf("Error reading the exclude patterns: %v", err)
+	}
+
+	// WebDAV, SFTP, the S3 endpoint and live updates work on root_dir
+	// directly, so they need the local backend without shares, encryption
+	// or deduplication
+	_, local := fileStorage.(*storage.Local)
+	if !local {
+		log.Printf("WebDAV is disabled, it's only available with the local storage backend, without shares, encryption or deduplication")
+	}
+
+	// Load the API tokens
+	tokensFile := config.TokensFile
+	if tokensFile == "" {
+		tokensFile = tokens.DefaultPath()
+	}
+	tokenStore, err := tokens.Open(tokensFile)
+	if err != nil {
+		log.Fatalf("Error loading API tokens: %v", err)
+	}
+	config.Tokens = tokenStore
+
+	// Load the owners of the uploaded files, for the quotas
+	uploadsFile := config.UploadsFile
+	if uploadsFile == "" {
+		uploadsFile = quota.DefaultPath()
+	}
+	quotaStore, err := quota.Open(uploadsFile)
+	if err != nil {
+		log.Fatalf("Error loading the uploaded files: %v", err)
+	}
+	config.Quota = quotaStore
+
+	// Load the expiration of the uploaded files and delete them once expired
+	expiryFile := config.ExpiryFile
+	if expiryFile == "" {
+		expiryFile = expiry.DefaultPath()
+	}
+	expiryStore, err := expiry.Open(expiryFile)
+	if err != nil {
+		log.Fatalf("Error loading the expiration of the uploaded files: %v", err)
+	}
+	config.Expirations = expiryStore
+	if handlers.ReadOnly(config) {
+		log.Printf("Expired files aren't deleted in read-only mode")
+	} else {
+		expiry.NewJanitor(expiryStore, fileStorage).Start(time.Minute)
+	}
+
+	if local {
+		// Start the disk usage scanner
+		config.Usage = diskusage.NewScanner(config.RootDir)
+		config.Usage.Start(config.ScanInterval)
+
+		// Start watching the shared directory for changes
+		fileWatcher, err := watcher.New(config.RootDir)
+		if err != nil {
+			log.Printf("Error watching %s, live updates are disabled: %v", config.RootDir, err)
+		} else {
+			config.Watcher = fileWatcher
+		}
+	}
+
+	// Start the S3-compatible endpoint
+	if config.S3.Address != "" {
+		if !local {
+			log.Fatalf("The S3 endpoint is only available with the local storage backend, without shares, encryption or deduplication")
+		}
+		s3Handler, err := handlers.S3(config)
+		if err != nil {
+			log.Fatalf("Error configuring the S3 endpoint: %v", err)
+		}
+		go func() {
+			log.Printf("S3 endpoint listening on %s", config.S3.Address)
+			log.Fatal(http.ListenAndServe(config.S3.Address, s3Handler))
+		}()
+	}
+
+	// Start the SFTP server
+	if config.SFTP.Address != "" {
+		if !local {
+			log.Fatalf("The SFTP server is only available with the local storage backend, without shares, encryption or deduplication")
+		}
+		sftpServer, err := handlers.SFTP(config)
+		if err != nil {
+			log.Fatalf("Error configuring the SFTP server: %v", err)
+		}
+		go func() {
+			log.Printf("SFTP server listening on %s", config.SFTP.Address)
+			log.Fatal(sftpServer.ListenAndServe(config.SFTP.Address))
+		}()
+	}
+
+	// Start the server
+	addr := fmt.Sprintf(":%d", config.Port)
+	log.Printf("ShareIsCare v%s started at http://localhost%s", Version, addr)
+	log.Printf("Default user: %s / Password: %s", config.Username, config.Password)
+	log.Fatal(http.ListenAndServe(addr, routes(config, local)))
+}
+
+// routes returns the handler of the web interface, the API and WebDAV.
+// local tells if the storage is the local backend, which WebDAV needs.
+func routes(config *config.Config, local bool) *http.ServeMux {
+	mux := http.NewServeMux()
+
 	// Main handler route (file listing)
-	http.HandleFunc("GET /", handlers.Index(config))
+	mux.HandleFunc("GET /", handlers.Index(config))
 	// Route for browsing directories
-	http.HandleFunc("GET /browse/", handlers.Browse(config))
+	mux.HandleFunc("GET /browse/", handlers.Browse(config))
 	// Route for downloading files
-	http.HandleFunc("GET /download", handlers.Download(config))
+	mux.HandleFunc("GET /download", handlers.RequireScope(tokens.Read, handlers.Download(config), config))
 	// Route for previewing files
-	http.HandleFunc("GET /preview", handlers.Preview(config))
+	mux.HandleFunc("GET /preview", handlers.RequireScope(tokens.Read, handlers.Preview(config), config))
+	// Route for the thumbnails of the gallery
+	mux.HandleFunc("GET /thumbnail", handlers.RequireScope(tokens.Read, handlers.Thumbnail(config), config))
+	// Route for the image gallery of a directory
+	mux.HandleFunc("GET /gallery/", handlers.Gallery(config))
+	// Route for calculating file checksums
+	mux.HandleFunc("GET /checksum", handlers.Checksum(config))
+	// Route for the disk usage page
+	mux.HandleFunc("GET /usage", handlers.DiskUsage(config))
+	// Route for live updates of a directory listing (server-sent events)
+	mux.HandleFunc("GET /events", handlers.Events(config))
+	// Route for the page that decrypts files encrypted end to end
+	mux.HandleFunc("GET /e2e", handlers.RequireScope(tokens.Read, handlers.E2E(config), config))
+	// Route for displaying file details
+	mux.HandleFunc("GET /details", handlers.Details(config))
 	// Login route (GET)
-	http.HandleFunc("GET /login", handlers.Login(config))
+	mux.HandleFunc("GET /login", handlers.Login(config))
 	// Login route (POST)
-	http.HandleFunc("POST /login", handlers.LoginPost(config))
+	mux.HandleFunc("POST /login", handlers.LoginPost(config))
 	// Logout route
-	http.HandleFunc("GET /logout", handlers.Logout(config))
-	// Route to display the file upload form (GET) - protected
-	http.HandleFunc("GET /upload", handlers.RequireAuth(handlers.Upload(config), config))
-	// Route to process file uploads (POST) - protected
-	http.HandleFunc("POST /upload", handlers.RequireAuth(handlers.UploadPost(config), config))
-	// Route to delete files (POST) - protected and admin only
-	http.HandleFunc("POST /delete", handlers.RequireAuth(handlers.RequireAdmin(handlers.Delete(config), config), config))
+	mux.HandleFunc("GET /logout", handlers.Logout(config))
+	// Nothing can be uploaded or deleted in read-only mode
+	if !handlers.ReadOnly(config) {
+		// Route to display the file upload form (GET) - protected
+		mux.HandleFunc("GET /upload", handlers.RequireAuth(handlers.Upload(config), config))
+		// Route to process file uploads (POST) - protected
+		mux.HandleFunc("POST /upload", handlers.RequireAuth(handlers.RequireScope(tokens.Upload, handlers.UploadPost(config), config), config))
+		// Route to the page that encrypts files end to end before uploading them
+		mux.HandleFunc("GET /upload/e2e", handlers.RequireAuth(handlers.E2EUpload(config), config))
+		// Route to delete files (POST) - protected and admin only
+		mux.HandleFunc("POST /delete", handlers.RequireAuth(handlers.RequireAdmin(handlers.RequireScope(tokens.Delete, handlers.Delete(config), config), config), config))
+	}
+	// API token settings - protected and admin only
+	mux.HandleFunc("GET /settings/tokens", handlers.RequireAuth(handlers.RequireAdmin(handlers.Tokens(config), config), config))
+	mux.HandleFunc("POST /settings/tokens", handlers.RequireAuth(handlers.RequireAdmin(handlers.TokensPost(config), config), config))
+	mux.HandleFunc("POST /settings/tokens/revoke", handlers.RequireAuth(handlers.RequireAdmin(handlers.TokensRevoke(config), config), config))
+	// JSON API, authentication is checked per endpoint. The methods are
+	// listed because a pattern without one would conflict with "GET /".
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		mux.HandleFunc(method+" /api/v1/", handlers.API(config))
+	}
+	// OpenAPI document of the JSON API
+	mux.HandleFunc("GET /api/openapi.json", handlers.OpenAPI)
+	// WebDAV access to the shared directory, with HTTP Basic authentication
+	if local {
+		davHandler := handlers.WebDAV(config)
+		for _, method := range handlers.WebDAVMethods() {
+			mux.HandleFunc(method+" /dav/", davHandler)
+		}
+	}
 
-	// Start the server
-	addr := fmt.Sprintf(":%d", config.Port)
-	log.Printf("ShareIsCare v%s started at http://localhost%s", Version, addr)
-	log.Printf("Default user: %s / Password: %s", config.Username, config.Password)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	return mux
+}
+
+// readPassphrase returns the passphrase of the encrypted files, from the
+// SHAREISCARE_PASSPHRASE environment variable or asked in the terminal
+func readPassphrase() (string, error) {
+	if passphrase := os.Getenv("SHAREISCARE_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("set SHAREISCARE_PASSPHRASE or start the server in a terminal")
+	}
+
+	fmt.Print("Passphrase of the encrypted files: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("the passphrase is empty")
+	}
+	return string(passphrase), nil
+}
+
+// RunClient runs a client command against a remote server
+func RunClient(cmd string, args []string) {
+	c, err := cli.New()
+	if err != nil {
+		log.Fatalf("Error locating the client configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := c.Run(ctx, cmd, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func main() {
@@ -119,6 +340,10 @@ func main() {
 			return
 
 		default:
+			if cli.IsCommand(cmd) {
+				RunClient(cmd, os.Args[2:])
+				return
+			}
 			log.Printf("Unknown command: %s\n\n", cmd)
 			PrintHelp()
 			return
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rodrwan/shareiscare/config"
+)
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		method  string
+		target  string
+		pattern string
+	}{
+		{"GET", "/", "GET /"},
+		{"GET", "/browse/docs", "GET /browse/"},
+		{"GET", "/api/v1/files", "GET /api/v1/"},
+		{"DELETE", "/api/v1/files", "DELETE /api/v1/"},
+		{"GET", "/api/openapi.json", "GET /api/openapi.json"},
+		{"PROPFIND", "/dav/docs", "PROPFIND /dav/"},
+		{"GET", "/dav/docs/nota.txt", "GET /dav/"},
+		{"OPTIONS", "/dav/", "OPTIONS /dav/"},
+	}
+
+	for _, readOnly := range []bool{false, true} {
+		cfg := config.DefaultConfig()
+		cfg.RootDir = t.TempDir()
+		cfg.ReadOnly = readOnly
+
+		// Registrar dos patrones en conflicto hace que ServeMux entre en pánico
+		mux := routes(cfg, true)
+		for _, tt := range tests {
+			if _, pattern := mux.Handler(httptest.NewRequest(tt.method, tt.target, nil)); pattern != tt.pattern {
+				t.Errorf("%s %s usa el patrón %q, se esperaba %q", tt.method, tt.target, pattern, tt.pattern)
+			}
+		}
+
+		// En modo de solo lectura no se puede subir nada
+		upload := "GET /upload/e2e"
+		if readOnly {
+			upload = "GET /"
+		}
+		if _, pattern := mux.Handler(httptest.NewRequest("GET", "/upload/e2e", nil)); pattern != upload {
+			t.Errorf("GET /upload/e2e usa el patrón %q, se esperaba %q", pattern, upload)
+		}
+
+		// Sin el almacenamiento local no hay WebDAV
+		mux = routes(cfg, false)
+		if _, pattern := mux.Handler(httptest.NewRequest("PROPFIND", "/dav/docs", nil)); pattern != "" {
+			t.Errorf("WebDAV no debería registrarse sin el almacenamiento local: %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF decoder for DecodeConfig
+	_ "image/jpeg" // Register JPEG decoder for DecodeConfig
+	_ "image/png"  // Register PNG decoder for DecodeConfig
+	"io"
+	"strings"
+	"time"
+)
+
+// ImageInfo contains the metadata extracted from an image file
+type ImageInfo struct {
+	Width       int
+	Height      int
+	Orientation int       // EXIF orientation to show the image, 1 to 8 (1 if unknown)
+	Taken       time.Time // EXIF capture date (zero if unknown)
+	Make        string    // Camera manufacturer
+	Model       string    // Camera model
+	LensModel   string    // Lens used for the shot
+	HasGPS      bool      // Whether the image contains GPS coordinates
+	Latitude    float64
+	Longitude   float64
+}
+
+// Camera returns the camera make and model as a single string
+func (i *ImageInfo) Camera() string {
+	if i.Model == "" {
+		return i.Make
+	}
+	if i.Make == "" || strings.HasPrefix(i.Model, i.Make) {
+		return i.Model
+	}
+	return i.Make + " " + i.Model
+}
+
+// EXIF tags used by ShareIsCare
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagLensModel        = 0xA434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// exifDateLayout is the date format used by EXIF
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// exifHeader prefixes the TIFF data in a JPEG APP1 segment
+var exifHeader = []byte("Exif\x00\x00")
+
+// ErrNotJPEG is returned when a JPEG operation receives another format
+var ErrNotJPEG = errors.New("not a JPEG file")
+
+// ReadImage reads the dimensions and EXIF metadata of an image.
+// Dimensions are read for every format supported by the standard library;
+// EXIF data is only read from JPEG files.
+func ReadImage(r io.ReadSeeker) (*ImageInfo, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %v", err)
+	}
+
+	info := &ImageInfo{Width: cfg.Width, Height: cfg.Height, Orientation: 1}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	tiff, err := findExif(bufio.NewReader(r))
+	if err != nil || tiff == nil {
+		// Images without EXIF data are still valid
+		return info, nil
+	}
+
+	parseExif(tiff, info)
+	return info, nil
+}
+
+// findExif returns the TIFF block of the first EXIF APP1 segment of a JPEG
+func findExif(r *bufio.Reader) ([]byte, error) {
+	var tiff []byte
+	err := walkJPEG(r, nil, func(marker byte, payload []byte) {
+		if tiff == nil && marker == 0xE1 && bytes.HasPrefix(payload, exifHeader) {
+			tiff = payload[len(exifHeader):]
+		}
+	})
+	return tiff, err
+}
+
+// StripGPS copies a JPEG image from r to w removing the GPS coordinates
+// from its EXIF data. The GPS entries are zeroed in place, so the output
+// has the same size as the input.
+func StripGPS(w io.Writer, r io.Reader) error {
+	return walkJPEG(bufio.NewReader(r), w, func(marker byte, payload []byte) {
+		if marker == 0xE1 && bytes.HasPrefix(payload, exifHeader) {
+			stripGPS(payload[len(exifHeader):])
+		}
+	})
+}
+
+// walkJPEG walks the metadata segments of a JPEG calling fn for each of them.
+// If w is not nil every byte read is written to it (after fn has had the
+// chance to modify the segment payload) and the image data following the
+// segments is copied as is.
+func walkJPEG(r *bufio.Reader, w io.Writer, fn func(marker byte, payload []byte)) error {
+	write := func(b []byte) error {
+		if w == nil {
+			return nil
+		}
+		_, err := w.Write(b)
+		return err
+	}
+
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil {
+		return ErrNotJPEG
+	}
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		return ErrNotJPEG
+	}
+	if err := write(soi); err != nil {
+		return err
+	}
+
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header[:2]); err != nil {
+			return err
+		}
+		marker := header[1]
+
+		// Anything that is not a segment with a length (start of scan,
+		// restart markers, fill bytes, end of image) means the metadata is over
+		if header[0] != 0xFF || marker == 0xDA || marker == 0xD9 || marker == 0xFF || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			if w == nil {
+				return nil
+			}
+			if err := write(header[:2]); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, r)
+			return err
+		}
+
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return fmt.Errorf("invalid JPEG segment length: %d", length)
+		}
+
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		fn(marker, payload)
+
+		if err := write(header); err != nil {
+			return err
+		}
+		if err := write(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// tiffReader gives access to the entries of a TIFF block
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is a single entry of an image file directory
+type ifdEntry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	offset int // Position of the entry inside the TIFF block
+}
+
+// newTIFFReader validates the TIFF header and returns a reader for it
+func newTIFFReader(data []byte) (*tiffReader, uint32, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+
+	if order.Uint16(data[2:4]) != 42 {
+		return nil, 0, false
+	}
+
+	return &tiffReader{data: data, order: order}, order.Uint32(data[4:8]), true
+}
+
+// entries returns the entries of the IFD located at offset
+func (t *tiffReader) entries(offset uint32) []ifdEntry {
+	if int(offset)+2 > len(t.data) || offset == 0 {
+		return nil
+	}
+
+	count := int(t.order.Uint16(t.data[offset:]))
+	var entries []ifdEntry
+	for i := 0; i < count; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(t.data) {
+			break
+		}
+		entries = append(entries, ifdEntry{
+			tag:    t.order.Uint16(t.data[pos:]),
+			typ:    t.order.Uint16(t.data[pos+2:]),
+			count:  t.order.Uint32(t.data[pos+4:]),
+			offset: pos,
+		})
+	}
+	return entries
+}
+
+// typeSize returns the size in bytes of a single value of a TIFF type
+func typeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11: // LONG, SLONG, FLOAT
+		return 4
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	default:
+		return 0
+	}
+}
+
+// value returns the raw bytes of an entry and, for values stored outside
+// the entry, their position inside the TIFF block (-1 otherwise)
+func (t *tiffReader) value(e ifdEntry) ([]byte, int) {
+	size := typeSize(e.typ) * int(e.count)
+	if size == 0 {
+		return nil, -1
+	}
+	if size <= 4 {
+		return t.data[e.offset+8 : e.offset+8+size], -1
+	}
+
+	pos := int(t.order.Uint32(t.data[e.offset+8:]))
+	if pos < 0 || pos+size > len(t.data) {
+		return nil, -1
+	}
+	return t.data[pos : pos+size], pos
+}
+
+// str returns the value of an ASCII entry
+func (t *tiffReader) str(e ifdEntry) string {
+	b, _ := t.value(e)
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+// long returns the value of a SHORT or LONG entry
+func (t *tiffReader) long(e ifdEntry) uint32 {
+	b, _ := t.value(e)
+	switch {
+	case e.typ == 3 && len(b) >= 2:
+		return uint32(t.order.Uint16(b))
+	case e.typ == 4 && len(b) >= 4:
+		return t.order.Uint32(b)
+	}
+	return 0
+}
+
+// rationals returns the values of a RATIONAL entry as floats
+func (t *tiffReader) rationals(e ifdEntry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	b, _ := t.value(e)
+	var values []float64
+	for i := 0; i+8 <= len(b); i += 8 {
+		num := t.order.Uint32(b[i:])
+		den := t.order.Uint32(b[i+4:])
+		if den == 0 {
+			values = append(values, 0)
+			continue
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+// parseExif fills info with the EXIF values found in a TIFF block
+func parseExif(data []byte, info *ImageInfo) {
+	t, ifd0, ok := newTIFFReader(data)
+	if !ok {
+		return
+	}
+
+	var exifIFD, gpsIFD uint32
+	for _, e := range t.entries(ifd0) {
+		switch e.tag {
+		case tagMake:
+			info.Make = t.str(e)
+		case tagModel:
+			info.Model = t.str(e)
+		case tagOrientation:
+			if o := t.long(e); o >= 1 && o <= 8 {
+				info.Orientation = int(o)
+			}
+		case tagDateTime:
+			if info.Taken.IsZero() {
+				info.Taken, _ = time.Parse(exifDateLayout, t.str(e))
+			}
+		case tagExifIFD:
+			exifIFD = t.long(e)
+		case tagGPSIFD:
+			gpsIFD = t.long(e)
+		}
+	}
+
+	for _, e := range t.entries(exifIFD) {
+		switch e.tag {
+		case tagDateTimeOriginal:
+			if taken, err := time.Parse(exifDateLayout, t.str(e)); err == nil {
+				info.Taken = taken
+			}
+		case tagLensModel:
+			info.LensModel = t.str(e)
+		}
+	}
+
+	var latRef, lonRef string
+	var lat, lon []float64
+	for _, e := range t.entries(gpsIFD) {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = t.str(e)
+		case tagGPSLatitude:
+			lat = t.rationals(e)
+		case tagGPSLongitudeRef:
+			lonRef = t.str(e)
+		case tagGPSLongitude:
+			lon = t.rationals(e)
+		}
+	}
+
+	if len(lat) == 3 && len(lon) == 3 {
+		info.HasGPS = true
+		info.Latitude = lat[0] + lat[1]/60 + lat[2]/3600
+		info.Longitude = lon[0] + lon[1]/60 + lon[2]/3600
+		if latRef == "S" {
+			info.Latitude = -info.Latitude
+		}
+		if lonRef == "W" {
+			info.Longitude = -info.Longitude
+		}
+	}
+}
+
+// stripGPS empties the GPS IFD of a TIFF block in place
+func stripGPS(data []byte) {
+	t, ifd0, ok := newTIFFReader(data)
+	if !ok {
+		return
+	}
+
+	var gpsIFD uint32
+	for _, e := range t.entries(ifd0) {
+		if e.tag == tagGPSIFD {
+			gpsIFD = t.long(e)
+		}
+	}
+
+	entries := t.entries(gpsIFD)
+	if len(entries) == 0 {
+		return
+	}
+
+	for _, e := range entries {
+		// Values that do not fit in the entry live elsewhere in the block
+		if b, pos := t.value(e); pos >= 0 {
+			clear(b)
+		}
+		clear(data[e.offset : e.offset+12])
+	}
+
+	// An IFD with zero entries is still valid
+	t.order.PutUint16(data[gpsIFD:], 0)
+}
@@ -0,0 +1,224 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+)
+
+// tiffEntry describe una entrada de IFD para construir EXIF de prueba
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// buildIFD serializa un IFD ubicado en offset y devuelve sus bytes
+func buildIFD(order binary.ByteOrder, offset uint32, entries []tiffEntry) []byte {
+	size := uint32(2 + len(entries)*12 + 4)
+	var ifd, extra bytes.Buffer
+
+	binary.Write(&ifd, order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&ifd, order, e.tag)
+		binary.Write(&ifd, order, e.typ)
+		binary.Write(&ifd, order, e.count)
+		if len(e.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, e.data)
+			ifd.Write(value)
+		} else {
+			binary.Write(&ifd, order, offset+size+uint32(extra.Len()))
+			extra.Write(e.data)
+		}
+	}
+	binary.Write(&ifd, order, uint32(0))
+
+	return append(ifd.Bytes(), extra.Bytes()...)
+}
+
+func ascii(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func rationals(order binary.ByteOrder, values ...uint32) []byte {
+	b := make([]byte, len(values)*8)
+	for i, v := range values {
+		order.PutUint32(b[i*8:], v)
+		order.PutUint32(b[i*8+4:], 1)
+	}
+	return b
+}
+
+func long(order binary.ByteOrder, v uint32) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+	return b
+}
+
+func short(order binary.ByteOrder, v uint16) []byte {
+	b := make([]byte, 2)
+	order.PutUint16(b, v)
+	return b
+}
+
+// buildExif genera un bloque TIFF con cámara, fecha y coordenadas GPS
+func buildExif(order binary.ByteOrder) []byte {
+	header := make([]byte, 8)
+	if order == binary.LittleEndian {
+		copy(header, "II")
+	} else {
+		copy(header, "MM")
+	}
+	order.PutUint16(header[2:], 42)
+	order.PutUint32(header[4:], 8)
+
+	// Los IFD se colocan uno detrás de otro: IFD0, Exif y GPS
+	ifd0Entries := func(exifOffset, gpsOffset uint32) []tiffEntry {
+		return []tiffEntry{
+			{tagMake, 2, 6, ascii("Canon")},
+			{tagModel, 2, 13, ascii("Canon EOS R6")},
+			{tagOrientation, 3, 1, short(order, 6)},
+			{tagExifIFD, 4, 1, long(order, exifOffset)},
+			{tagGPSIFD, 4, 1, long(order, gpsOffset)},
+		}
+	}
+	ifd0 := buildIFD(order, 8, ifd0Entries(0, 0))
+	exifOffset := 8 + uint32(len(ifd0))
+	exifIFD := buildIFD(order, exifOffset, []tiffEntry{
+		{tagDateTimeOriginal, 2, 20, ascii("2024:05:17 10:30:00")},
+		{tagLensModel, 2, 14, ascii("RF24-105mm F4")},
+	})
+	gpsOffset := exifOffset + uint32(len(exifIFD))
+	gpsIFD := buildIFD(order, gpsOffset, []tiffEntry{
+		{tagGPSLatitudeRef, 2, 2, ascii("S")},
+		{tagGPSLatitude, 5, 3, rationals(order, 33, 27, 0)},
+		{tagGPSLongitudeRef, 2, 2, ascii("W")},
+		{tagGPSLongitude, 5, 3, rationals(order, 70, 39, 36)},
+	})
+	ifd0 = buildIFD(order, 8, ifd0Entries(exifOffset, gpsOffset))
+
+	tiff := append(header, ifd0...)
+	tiff = append(tiff, exifIFD...)
+	return append(tiff, gpsIFD...)
+}
+
+// buildJPEG genera un JPEG de 4x3 con el bloque EXIF indicado
+func buildJPEG(t *testing.T, tiff []byte) []byte {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 3)), nil); err != nil {
+		t.Fatalf("No se pudo codificar la imagen: %v", err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := append([]byte{}, img.Bytes()[:2]...)
+	data = append(data, segment...)
+	return append(data, img.Bytes()[2:]...)
+}
+
+func TestReadImage(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			data := buildJPEG(t, buildExif(order))
+
+			info, err := ReadImage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadImage() devolvió error: %v", err)
+			}
+
+			if info.Width != 4 || info.Height != 3 {
+				t.Errorf("dimensiones = %dx%d, quería 4x3", info.Width, info.Height)
+			}
+
+			if info.Orientation != 6 {
+				t.Errorf("Orientation = %d, quería 6", info.Orientation)
+			}
+
+			if info.Camera() != "Canon EOS R6" {
+				t.Errorf("Camera() = %q, quería %q", info.Camera(), "Canon EOS R6")
+			}
+
+			if info.LensModel != "RF24-105mm F4" {
+				t.Errorf("LensModel = %q, quería %q", info.LensModel, "RF24-105mm F4")
+			}
+
+			expected := time.Date(2024, 5, 17, 10, 30, 0, 0, time.UTC)
+			if !info.Taken.Equal(expected) {
+				t.Errorf("Taken = %v, quería %v", info.Taken, expected)
+			}
+
+			if !info.HasGPS {
+				t.Fatal("HasGPS debería ser true")
+			}
+			if math.Abs(info.Latitude-(-33.45)) > 1e-6 {
+				t.Errorf("Latitude = %f, quería -33.45", info.Latitude)
+			}
+			if math.Abs(info.Longitude-(-70.66)) > 1e-6 {
+				t.Errorf("Longitude = %f, quería -70.66", info.Longitude)
+			}
+		})
+	}
+}
+
+func TestReadImageWithoutExif(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 2)), nil); err != nil {
+		t.Fatalf("No se pudo codificar la imagen: %v", err)
+	}
+
+	info, err := ReadImage(bytes.NewReader(img.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadImage() devolvió error: %v", err)
+	}
+
+	if info.Width != 8 || info.Height != 2 {
+		t.Errorf("dimensiones = %dx%d, quería 8x2", info.Width, info.Height)
+	}
+	if info.HasGPS || !info.Taken.IsZero() || info.Camera() != "" {
+		t.Error("una imagen sin EXIF no debería tener metadatos")
+	}
+
+	if _, err := ReadImage(bytes.NewReader([]byte("no es una imagen"))); err == nil {
+		t.Error("ReadImage() debería fallar para datos que no son una imagen")
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	data := buildJPEG(t, buildExif(binary.BigEndian))
+
+	var out bytes.Buffer
+	if err := StripGPS(&out, bytes.NewReader(data)); err != nil {
+		t.Fatalf("StripGPS() devolvió error: %v", err)
+	}
+
+	if out.Len() != len(data) {
+		t.Errorf("tamaño de salida = %d, quería %d", out.Len(), len(data))
+	}
+
+	info, err := ReadImage(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("la imagen resultante no es válida: %v", err)
+	}
+
+	if info.HasGPS {
+		t.Error("la imagen resultante no debería tener coordenadas GPS")
+	}
+
+	// El resto de los metadatos se conserva
+	if info.Camera() != "Canon EOS R6" {
+		t.Errorf("Camera() = %q, quería %q", info.Camera(), "Canon EOS R6")
+	}
+
+	if err := StripGPS(&out, bytes.NewReader([]byte("no es un jpeg"))); err != ErrNotJPEG {
+		t.Errorf("StripGPS() = %v, quería ErrNotJPEG", err)
+	}
+}
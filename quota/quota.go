@@ -0,0 +1,355 @@
+// Package quota keeps what's needed to limit the space used by the uploaded
+// files: who uploaded each file, the space used by the folders with a
+// limit, and the space reserved by the uploads in progress so that
+// concurrent uploads can't exceed a limit together.
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Usage is an amount of files and their total size
+type Usage struct {
+	Bytes int64
+	Files int
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{Bytes: u.Bytes + other.Bytes, Files: u.Files + other.Files}
+}
+
+// Exceeds checks if the usage is over a limit. A zero field of the limit
+// means that there is no limit.
+func (u Usage) Exceeds(limit Usage) bool {
+	return (limit.Bytes > 0 && u.Bytes > limit.Bytes) || (limit.Files > 0 && u.Files > limit.Files)
+}
+
+// Kinds of accounts
+const (
+	User   = "user"
+	Folder = "folder"
+)
+
+// Account is a user or folder with a limit
+type Account struct {
+	Kind  string // User or Folder
+	Name  string // Username or path of the folder
+	Limit Usage  // Zero fields mean no limit
+	Used  Usage  // Space used by the stored files
+}
+
+// key identifies the account in the reservations
+func (a Account) key() string {
+	return a.Kind + ":" + a.Name
+}
+
+// ExceededError is returned when an upload doesn't fit in the limit of an
+// account
+type ExceededError struct {
+	Account  Account
+	Reserved Usage // Space reserved by other uploads in progress
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota of %s %s exceeded", e.Account.Kind, e.Account.Name)
+}
+
+// Left returns the space left in the account, without the upload
+func (e *ExceededError) Left() Usage {
+	used := e.Account.Used.Add(e.Reserved)
+	return Usage{Bytes: max(e.Account.Limit.Bytes-used.Bytes, 0), Files: max(e.Account.Limit.Files-used.Files, 0)}
+}
+
+// folderTTL is how long the usage of a folder is kept. Uploads update it
+// right away, the other changes are seen once it expires.
+const folderTTL = time.Minute
+
+// folderUsage is the usage of a folder and when it was calculated
+type folderUsage struct {
+	usage Usage
+	at    time.Time
+}
+
+// Store keeps the owners of the uploaded files in a JSON file, and the
+// usage of the folders and the space reserved by the uploads in progress
+// in memory
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	owners   map[string]string      // Owner of each file, by name in the storage
+	folders  map[string]folderUsage // Usage of each folder, by name in the storage
+	reserved map[string]Usage       // Space reserved in each account
+
+	now func() time.Time
+}
+
+// Open loads the owners saved in path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, owners: map[string]string{}, folders: map[string]folderUsage{}, reserved: map[string]Usage{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.owners); err != nil {
+		return nil, fmt.Errorf("error reading uploads file: %v", err)
+	}
+	return s, nil
+}
+
+// save writes the owners to disk. The caller must hold the lock.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.owners, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("error creating uploads directory: %v", err)
+	}
+
+	// Write to a temporary file so the owners are never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".uploads-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Owner returns the user that uploaded a file, empty if unknown
+func (s *Store) Owner(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owners[name]
+}
+
+// SetOwner records the user that uploaded a file
+func (s *Store) SetOwner(name, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.owners[name]
+	s.owners[name] = owner
+	if err := s.save(); err != nil {
+		if ok {
+			s.owners[name] = previous
+		} else {
+			delete(s.owners, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Owned returns the names of the files uploaded by a user, sorted
+func (s *Store) Owned(owner string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{}
+	for name, o := range s.owners {
+		if o == owner {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Forget removes the owners of files that don't exist anymore
+func (s *Store) Forget(names ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, name := range names {
+		if _, ok := s.owners[name]; ok {
+			delete(s.owners, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// Rename keeps the owners of a moved file, or of the files of a moved
+// directory
+func (s *Store) Rename(oldName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for name, owner := range s.owners {
+		rest, ok := strings.CutPrefix(name, oldName)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		delete(s.owners, name)
+		s.owners[newName+rest] = owner
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// currentTime returns the current time
+func (s *Store) currentTime() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// FolderUsage returns the usage of a folder, calculated with compute when
+// it isn't known or it's too old. This way uploads don't need to go through
+// the whole folder every time.
+func (s *Store) FolderUsage(folder string, compute func() (Usage, error)) (Usage, error) {
+	s.mu.Lock()
+	cached, ok := s.folders[folder]
+	s.mu.Unlock()
+	if ok && s.currentTime().Sub(cached.at) < folderTTL {
+		return cached.usage, nil
+	}
+
+	usage, err := compute()
+	if err != nil {
+		return Usage{}, err
+	}
+	s.mu.Lock()
+	s.folders[folder] = folderUsage{usage: usage, at: s.currentTime()}
+	s.mu.Unlock()
+	return usage, nil
+}
+
+// Stored adds the change of usage of a stored file to the known usage of
+// the folders that contain it
+func (s *Store) Stored(name string, change Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for folder, cached := range s.folders {
+		if folder == "" || strings.HasPrefix(name, folder+"/") {
+			cached.usage = cached.usage.Add(change)
+			s.folders[folder] = cached
+		}
+	}
+}
+
+// Reservation is the space reserved by an upload in progress
+type Reservation struct {
+	s        *Store
+	accounts []Account
+	usage    Usage
+	released bool
+}
+
+// reserve adds usage to the space reserved in every account, if it fits in
+// all of them. The caller must hold the lock.
+func (s *Store) reserve(accounts []Account, usage Usage) error {
+	for _, account := range accounts {
+		reserved := s.reserved[account.key()]
+		if account.Used.Add(reserved).Add(usage).Exceeds(account.Limit) {
+			return &ExceededError{Account: account, Reserved: reserved}
+		}
+	}
+	for _, account := range accounts {
+		s.reserved[account.key()] = s.reserved[account.key()].Add(usage)
+	}
+	return nil
+}
+
+// Reserve reserves the space of an upload in every account it counts
+// against. It fails with an *ExceededError if the upload doesn't fit in
+// one of them, counting the other uploads in progress.
+func (s *Store) Reserve(accounts []Account, usage Usage) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reserve(accounts, usage); err != nil {
+		return nil, err
+	}
+	return &Reservation{s: s, accounts: accounts, usage: usage}, nil
+}
+
+// Grow reserves more space for the upload, as it's received. It fails with
+// an *ExceededError, without reserving anything, once the upload doesn't
+// fit in one of the accounts.
+func (r *Reservation) Grow(usage Usage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.released {
+		return errors.New("the reservation has been released")
+	}
+	if err := r.s.reserve(r.accounts, usage); err != nil {
+		return err
+	}
+	r.usage = r.usage.Add(usage)
+	return nil
+}
+
+// Reserved returns the space reserved by the upload
+func (r *Reservation) Reserved() Usage {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.usage
+}
+
+// Release frees the reserved space, once the upload has been stored or
+// has failed
+func (r *Reservation) Release() {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.released {
+		return
+	}
+	r.released = true
+	negative := Usage{Bytes: -r.usage.Bytes, Files: -r.usage.Files}
+	for _, account := range r.accounts {
+		left := r.s.reserved[account.key()].Add(negative)
+		if left == (Usage{}) {
+			delete(r.s.reserved, account.key())
+		} else {
+			r.s.reserved[account.key()] = left
+		}
+	}
+}
+
+// DefaultPath returns the file used to store the owners of the uploaded
+// files when none is configured
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "shareiscare", "uploads.json")
+}
@@ -0,0 +1,182 @@
+package quota
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExceeds(t *testing.T) {
+	limit := Usage{Bytes: 100, Files: 2}
+	if (Usage{Bytes: 100, Files: 2}).Exceeds(limit) {
+		t.Error("Llegar al límite no debería superarlo")
+	}
+	if !(Usage{Bytes: 101}).Exceeds(limit) || !(Usage{Files: 3}).Exceeds(limit) {
+		t.Error("Pasar el tamaño o el número de archivos debería superar el límite")
+	}
+	if (Usage{Bytes: 1 << 40, Files: 1 << 20}).Exceeds(Usage{}) {
+		t.Error("Un límite vacío no debería limitar nada")
+	}
+}
+
+func TestOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uploads.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Error abriendo un almacén nuevo: %v", err)
+	}
+
+	for name, owner := range map[string]string{"a.txt": "ana", "fotos/b.jpg": "ana", "fotos/c.jpg": "luis"} {
+		if err := s.SetOwner(name, owner); err != nil {
+			t.Fatalf("Error guardando el dueño: %v", err)
+		}
+	}
+	if owned := s.Owned("ana"); !reflect.DeepEqual(owned, []string{"a.txt", "fotos/b.jpg"}) {
+		t.Errorf("Archivos de ana incorrectos: %v", owned)
+	}
+
+	// Mover una carpeta mantiene los dueños de sus archivos
+	if err := s.Rename("fotos", "viaje"); err != nil {
+		t.Fatalf("Error moviendo: %v", err)
+	}
+	if err := s.Rename("a", "z"); err != nil {
+		t.Fatalf("Error moviendo: %v", err)
+	}
+	if err := s.Forget("a.txt", "no-existe.txt"); err != nil {
+		t.Fatalf("Error olvidando: %v", err)
+	}
+
+	// Los dueños se conservan al reabrir
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Error reabriendo: %v", err)
+	}
+	if owned := s.Owned("ana"); !reflect.DeepEqual(owned, []string{"viaje/b.jpg"}) {
+		t.Errorf("Archivos de ana incorrectos tras reabrir: %v", owned)
+	}
+	if s.Owner("viaje/c.jpg") != "luis" || s.Owner("fotos/c.jpg") != "" {
+		t.Error("El dueño debería seguir al archivo movido")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "uploads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := Account{Kind: User, Name: "ana", Limit: Usage{Bytes: 100}, Used: Usage{Bytes: 40, Files: 4}}
+	folder := Account{Kind: Folder, Name: "fotos", Limit: Usage{Files: 6}, Used: Usage{Files: 5}}
+
+	first, err := s.Reserve([]Account{user}, Usage{Bytes: 50, Files: 1})
+	if err != nil {
+		t.Fatalf("La primera subida debería caber: %v", err)
+	}
+
+	// La subida en curso cuenta para las siguientes
+	_, err = s.Reserve([]Account{user}, Usage{Bytes: 20, Files: 1})
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("Se esperaba un ExceededError, obtenido: %v", err)
+	}
+	if exceeded.Account.Name != "ana" || exceeded.Left() != (Usage{Bytes: 10}) {
+		t.Errorf("Error incorrecto: %+v, queda %+v", exceeded, exceeded.Left())
+	}
+
+	// Si no cabe en una cuenta, no se reserva en ninguna
+	if _, err := s.Reserve([]Account{folder, user}, Usage{Bytes: 20, Files: 1}); err == nil {
+		t.Fatal("La subida no debería caber en la cuota del usuario")
+	}
+	second, err := s.Reserve([]Account{folder}, Usage{Bytes: 20, Files: 1})
+	if err != nil {
+		t.Fatalf("La carpeta debería tener sitio para un archivo: %v", err)
+	}
+	if _, err := s.Reserve([]Account{folder}, Usage{Files: 1}); err == nil {
+		t.Error("La carpeta no debería tener sitio para otro archivo")
+	}
+
+	// Liberar la reserva deja sitio otra vez
+	first.Release()
+	first.Release()
+	second.Release()
+	if _, err := s.Reserve([]Account{folder, user}, Usage{Bytes: 60, Files: 1}); err != nil {
+		t.Errorf("La subida debería caber tras liberar las reservas: %v", err)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "uploads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := Account{Kind: User, Name: "ana", Limit: Usage{Bytes: 100}, Used: Usage{Bytes: 40}}
+	r, err := s.Reserve([]Account{user}, Usage{Files: 1})
+	if err != nil {
+		t.Fatalf("La subida debería empezar: %v", err)
+	}
+
+	// La reserva crece mientras se recibe la subida
+	if err := r.Grow(Usage{Bytes: 50}); err != nil {
+		t.Fatalf("Los primeros bytes deberían caber: %v", err)
+	}
+	other, err := s.Reserve([]Account{user}, Usage{Bytes: 10, Files: 1})
+	if err != nil {
+		t.Fatalf("Otra subida debería caber en lo que queda: %v", err)
+	}
+	var exceeded *ExceededError
+	if err := r.Grow(Usage{Bytes: 1}); !errors.As(err, &exceeded) {
+		t.Fatalf("Se esperaba un ExceededError, obtenido: %v", err)
+	}
+	if r.Reserved() != (Usage{Bytes: 50, Files: 1}) {
+		t.Errorf("Lo que no cabe no debería reservarse: %+v", r.Reserved())
+	}
+
+	// Al liberar se libera todo lo reservado
+	r.Release()
+	other.Release()
+	if err := r.Grow(Usage{Bytes: 1}); err == nil {
+		t.Error("Una reserva liberada no debería crecer")
+	}
+	if _, err := s.Reserve([]Account{user}, Usage{Bytes: 60, Files: 1}); err != nil {
+		t.Errorf("La subida debería caber tras liberar las reservas: %v", err)
+	}
+}
+
+func TestFolderUsage(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "uploads.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	walks := 0
+	compute := func() (Usage, error) {
+		walks++
+		return Usage{Bytes: 100, Files: 2}, nil
+	}
+
+	// El uso se calcula una vez y se actualiza con las subidas
+	for i := 0; i < 3; i++ {
+		if usage, err := s.FolderUsage("fotos", compute); err != nil || usage != (Usage{Bytes: 100, Files: 2}) {
+			t.Fatalf("FolderUsage() = %+v, %v", usage, err)
+		}
+	}
+	if walks != 1 {
+		t.Errorf("La carpeta se recorrió %d veces, se esperaba 1", walks)
+	}
+	s.Stored("fotos/nueva.jpg", Usage{Bytes: 10, Files: 1})
+	s.Stored("fotosviejas/a.jpg", Usage{Bytes: 10, Files: 1})
+	if usage, _ := s.FolderUsage("fotos", compute); usage != (Usage{Bytes: 110, Files: 3}) {
+		t.Errorf("La subida debería sumarse al uso de la carpeta: %+v", usage)
+	}
+
+	// Pasado un tiempo se vuelve a calcular
+	now = now.Add(2 * folderTTL)
+	if usage, _ := s.FolderUsage("fotos", compute); usage != (Usage{Bytes: 100, Files: 2}) || walks != 2 {
+		t.Errorf("El uso debería recalcularse: %+v, %d recorridos", usage, walks)
+	}
+}
@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+)
+
+// Error is an error response of the S3 API
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Errors returned by the server, with the codes used by Amazon S3
+var (
+	errAccessDenied          = &Error{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errBadDigest             = &Error{"BadDigest", "The Content-MD5 you specified did not match what was received", http.StatusBadRequest}
+	errContentSHA256Mismatch = &Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed", http.StatusBadRequest}
+	errExpiredToken          = &Error{"AccessDenied", "Request has expired", http.StatusForbidden}
+	errIncompleteBody        = &Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header", http.StatusBadRequest}
+	errInternal              = &Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	errInvalidAccessKeyID    = &Error{"InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records", http.StatusForbidden}
+	errInvalidArgument       = &Error{"InvalidArgument", "Invalid Argument", http.StatusBadRequest}
+	errInvalidKey            = &Error{"InvalidArgument", "The key can't be stored in the shared directory", http.StatusBadRequest}
+	errInvalidPart           = &Error{"InvalidPart", "One or more of the specified parts could not be found or its entity tag does not match", http.StatusBadRequest}
+	errInvalidPartOrder      = &Error{"InvalidPartOrder", "The list of parts was not in ascending order", http.StatusBadRequest}
+	errMalformedXML          = &Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema", http.StatusBadRequest}
+	errMethodNotAllowed      = &Error{"MethodNotAllowed", "The specified method is not allowed against this resource", http.StatusMethodNotAllowed}
+	errMissingSecurityHeader = &Error{"MissingSecurityHeader", "Your request was missing a required header", http.StatusBadRequest}
+	errNoSuchBucket          = &Error{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	errNoSuchKey             = &Error{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	errNoSuchUpload          = &Error{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	errNotImplemented        = &Error{"NotImplemented", "A header or query you provided implies functionality that is not implemented", http.StatusNotImplemented}
+	errRequestTimeTooSkewed  = &Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large", http.StatusForbidden}
+	errSignatureDoesNotMatch = &Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided", http.StatusForbidden}
+)
+
+// errorBody is the XML body of error responses
+type errorBody struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// writeError writes an error response. Unexpected errors are logged and
+// reported as internal errors.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	s3Err, ok := err.(*Error)
+	if !ok {
+		log.Printf("S3 error in %s %s: %v", r.Method, r.URL.Path, err)
+		s3Err = errInternal
+	}
+
+	// Responses to HEAD requests have no body
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.Status)
+		return
+	}
+
+	writeXML(w, s3Err.Status, errorBody{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+// writeXML writes v as the XML response body
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding S3 response: %v", err)
+	}
+}
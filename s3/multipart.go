@@ -0,0 +1,239 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrwan/shareiscare/checksum"
+)
+
+// maxParts is the maximum number of parts of a multipart upload
+const maxParts = 10000
+
+// upload describes a multipart upload in progress
+type upload struct {
+	Bucket  string    `json:"bucket"`
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+// partName returns the name of the file of a part of an upload
+func partName(number int) string {
+	return fmt.Sprintf("part-%05d", number)
+}
+
+// uploadDir returns the directory of a multipart upload of an object
+func (s *Server) uploadDir(bucket, key, id string) (string, error) {
+	// Upload IDs are generated as hex, which also keeps them within UploadsDir
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 || s.UploadsDir == "" {
+		return "", errNoSuchUpload
+	}
+
+	dir := filepath.Join(s.UploadsDir, id)
+	data, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if err != nil {
+		return "", errNoSuchUpload
+	}
+
+	var u upload
+	if err := json.Unmarshal(data, &u); err != nil || u.Bucket != bucket || u.Key != key {
+		return "", errNoSuchUpload
+	}
+	return dir, nil
+}
+
+// createMultipartUpload handles CreateMultipartUpload
+func (s *Server) createMultipartUpload(w http.ResponseWriter, bucket, key string) error {
+	type result struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}
+
+	if s.UploadsDir == "" {
+		return errNotImplemented
+	}
+	if strings.HasSuffix(key, "/") {
+		return errInvalidKey
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(b)
+
+	dir := filepath.Join(s.UploadsDir, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(upload{Bucket: bucket, Key: key, Created: s.currentTime().UTC()})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upload.json"), data, 0600); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	writeXML(w, http.StatusOK, result{Xmlns: xmlns, Bucket: bucket, Key: key, UploadID: id})
+	return nil
+}
+
+// uploadPart handles UploadPart
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, body io.Reader, bucket, key, id, partNumber string) error {
+	dir, err := s.uploadDir(bucket, key, id)
+	if err != nil {
+		return err
+	}
+
+	number, err := strconv.Atoi(partNumber)
+	if err != nil || number < 1 || number > maxParts {
+		return &Error{"InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive", http.StatusBadRequest}
+	}
+
+	md5Hash := md5.New()
+	tmp, err := writeObject(dir, body, md5Hash)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if contentMD5 := r.Header.Get("Content-Md5"); contentMD5 != "" {
+		if contentMD5 != base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)) {
+			return errBadDigest
+		}
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, partName(number))); err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", `"`+hex.EncodeToString(md5Hash.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload handles CompleteMultipartUpload. The parts are
+// joined in the file of the object, checking them against their ETags.
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, body io.Reader, bucket, key, fullPath, id string) error {
+	type request struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	type result struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}
+
+	dir, err := s.uploadDir(bucket, key, id)
+	if err != nil {
+		return err
+	}
+
+	var req request
+	if err := xml.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&req); err != nil || len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+	for i, part := range req.Parts {
+		if i > 0 && part.PartNumber <= req.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+	}
+
+	if err := prepareParent(fullPath); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	// Nothing to remove once the file has been moved to its final name
+	defer os.Remove(tmp.Name())
+
+	whole := md5.New()
+	var partSums []byte
+	for _, part := range req.Parts {
+		f, err := os.Open(filepath.Join(dir, partName(part.PartNumber)))
+		if err != nil {
+			tmp.Close()
+			return errInvalidPart
+		}
+
+		h := md5.New()
+		_, err = io.Copy(io.MultiWriter(tmp, h, whole), f)
+		f.Close()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+
+		if hex.EncodeToString(h.Sum(nil)) != strings.Trim(part.ETag, `"`) {
+			tmp.Close()
+			return errInvalidPart
+		}
+		partSums = h.Sum(partSums)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return err
+	}
+	os.RemoveAll(dir)
+
+	if s.Checksums != nil {
+		s.Checksums.Store(fullPath, checksum.MD5, hex.EncodeToString(whole.Sum(nil)))
+	}
+
+	// Multipart ETags are the MD5 of the MD5s of the parts
+	sum := md5.Sum(partSums)
+	etag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(req.Parts))
+
+	writeXML(w, http.StatusOK, result{
+		Xmlns:    xmlns,
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     etag,
+	})
+	return nil
+}
+
+// abortMultipartUpload handles AbortMultipartUpload
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, bucket, key, id string) error {
+	dir, err := s.uploadDir(bucket, key, id)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
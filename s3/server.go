@@ -0,0 +1,786 @@
+// Package s3 serves directories with a subset of the Amazon S3 API, so that
+// tools that only speak S3 can use them. Each bucket is a directory and
+// requests are authenticated with AWS Signature Version 4.
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrwan/shareiscare/checksum"
+	"github.com/rodrwan/shareiscare/safepath"
+)
+
+// xmlns is the namespace of the XML documents of the S3 API
+const xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// maxKeys is the maximum number of keys returned by a listing
+const maxKeys = 1000
+
+// Server serves the S3 API. Only path-style requests are supported, e.g.
+// http://localhost:9000/bucket/key.
+type Server struct {
+	// Region expected in the signatures
+	Region string
+	// Buckets maps the name of each bucket to its directory
+	Buckets map[string]string
+	// Keys maps the accepted access keys to their secret keys
+	Keys map[string]string
+	// UploadsDir is where the parts of multipart uploads are kept
+	UploadsDir string
+	// Hidden reports whether a key of a bucket must be hidden from clients
+	// (optional)
+	Hidden func(bucket, key string) bool
+	// Checksums caches the MD5 of the objects, used as their ETag (optional)
+	Checksums *checksum.Cache
+	// ReadOnly rejects every request that changes an object
+	ReadOnly bool
+	// DenyUploads, if not empty, rejects the requests that store objects
+	// with an AccessDenied error with this message, e.g. to explain why
+	DenyUploads string
+	// Symlinks decides which symbolic links are followed, only the ones
+	// inside the bucket if empty
+	Symlinks safepath.Policy
+
+	now func() time.Time
+}
+
+// ValidBucketName checks if name can be used as the name of a bucket
+func ValidBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '-' || c == '.':
+			if i == 0 || i == len(name)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// currentTime returns the current time
+func (s *Server) currentTime() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// hidden checks if a key is hidden
+func (s *Server) hidden(bucket, key string) bool {
+	return s.Hidden != nil && s.Hidden(bucket, key)
+}
+
+// authenticate verifies the signature of a request
+func (s *Server) authenticate(r *http.Request) (*authorization, *Error) {
+	var auth *authorization
+	var expires time.Duration
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		var ok bool
+		if auth, ok = parseAuthorization(header); !ok {
+			return nil, errInvalidArgument
+		}
+		auth.amzDate = r.Header.Get("X-Amz-Date")
+		auth.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if auth.amzDate == "" || auth.payloadHash == "" {
+			return nil, errMissingSecurityHeader
+		}
+	} else if r.URL.Query().Has("X-Amz-Signature") {
+		var ok bool
+		if auth, expires, ok = parsePresigned(r.URL.Query()); !ok || expires > maxPresignExpiry {
+			return nil, errInvalidArgument
+		}
+	} else {
+		// Anonymous requests are not allowed
+		return nil, errAccessDenied
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, auth.amzDate)
+	if err != nil || auth.date != auth.amzDate[:8] || auth.service != service || auth.region != s.Region {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	now := s.currentTime()
+	if auth.presigned {
+		if now.Before(signedAt.Add(-maxClockSkew)) || now.After(signedAt.Add(expires)) {
+			return nil, errExpiredToken
+		}
+	} else if now.Sub(signedAt) > maxClockSkew || signedAt.Sub(now) > maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	secretKey, ok := s.Keys[auth.accessKey]
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+
+	// The host must always be signed
+	if !slices.Contains(auth.signedHeaders, "host") {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	expected := computeSignature(r, auth, secretKey)
+	if !hmac.Equal([]byte(expected), []byte(auth.signature)) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	return auth, nil
+}
+
+// body returns the content of a request, decoding and verifying it as
+// required by how it was signed
+func (s *Server) body(r *http.Request, auth *authorization) io.Reader {
+	switch auth.payloadHash {
+	case UnsignedPayload:
+		return r.Body
+	case streamingPayload, streamingPayloadTrailer:
+		return newChunkedReader(r.Body, auth, s.Keys[auth.accessKey], true)
+	case streamingUnsignedTrailer:
+		return newChunkedReader(r.Body, auth, "", false)
+	default:
+		return &hashingReader{r: r.Body, hash: sha256.New(), expected: auth.payloadHash}
+	}
+}
+
+// objectPath returns the path of the file of an object, or false if the key
+// can't be mapped to a file within dir
+func objectPath(dir, key string) (string, bool) {
+	name := strings.TrimSuffix(key, "/")
+	if name == "" || path.Clean(name) != name || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)), true
+}
+
+// resolve returns the path of the file of an object like objectPath, once
+// its symbolic links are checked with the policy of the server
+func (s *Server) resolve(dir, key string) (string, error) {
+	if _, ok := objectPath(dir, key); !ok {
+		return "", errInvalidKey
+	}
+	p, err := safepath.New(dir, s.Symlinks).Resolve(strings.TrimSuffix(key, "/"))
+	if err != nil {
+		return "", errAccessDenied
+	}
+	return p, nil
+}
+
+// etag returns the ETag of the file of an object. The MD5 of the file is
+// only calculated if compute is set, otherwise an ETag based on the size and
+// modification time is used if the MD5 isn't cached.
+func (s *Server) etag(fullPath string, info fs.FileInfo, compute bool) string {
+	if s.Checksums != nil {
+		if sum, ok := s.Checksums.Lookup(fullPath, checksum.MD5); ok {
+			return `"` + sum + `"`
+		}
+		if compute {
+			if sum, err := s.Checksums.Sum(fullPath, checksum.MD5); err == nil {
+				return `"` + sum + `"`
+			}
+		}
+	}
+	// Not an MD5, so that clients don't try to verify it
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// requestID returns a random ID to identify a request in errors
+func requestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", requestID())
+	w.Header().Set("Server", "ShareIsCare")
+
+	auth, s3Err := s.authenticate(r)
+	if s3Err != nil {
+		writeError(w, r, s3Err)
+		return
+	}
+	body := s.body(r, auth)
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, errMethodNotAllowed)
+			return
+		}
+		s.listBuckets(w, r)
+		return
+	}
+
+	dir, ok := s.Buckets[bucket]
+	if !ok {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	query := r.URL.Query()
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && query.Has("location"):
+			s.location(w)
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			s.listObjects(w, r, bucket, dir)
+		case r.Method == http.MethodGet:
+			writeError(w, r, errNotImplemented)
+		default:
+			writeError(w, r, errMethodNotAllowed)
+		}
+		return
+	}
+
+	fullPath, err := s.resolve(dir, key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if s.hidden(bucket, key) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			writeError(w, r, errNoSuchKey)
+		} else {
+			writeError(w, r, errAccessDenied)
+		}
+		return
+	}
+	if s.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+	if s.DenyUploads != "" && (r.Method == http.MethodPut || r.Method == http.MethodPost) {
+		writeError(w, r, &Error{"AccessDenied", s.DenyUploads, http.StatusForbidden})
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		err = s.createMultipartUpload(w, bucket, key)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		err = s.completeMultipartUpload(w, body, bucket, key, fullPath, query.Get("uploadId"))
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		err = s.uploadPart(w, r, body, bucket, key, query.Get("uploadId"), query.Get("partNumber"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		err = s.abortMultipartUpload(w, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		err = s.getObject(w, r, key, fullPath)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		err = s.copyObject(w, r, key, fullPath)
+	case r.Method == http.MethodPut:
+		err = s.putObject(w, r, body, key, fullPath)
+	case r.Method == http.MethodDelete:
+		err = s.deleteObject(w, key, fullPath)
+	default:
+		err = errMethodNotAllowed
+	}
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// listBuckets handles ListBuckets
+func (s *Server) listBuckets(w http.ResponseWriter, r *http.Request) {
+	type bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	type result struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   struct {
+			ID          string `xml:"ID"`
+			DisplayName string `xml:"DisplayName"`
+		} `xml:"Owner"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}
+
+	res := result{Xmlns: xmlns}
+	res.Owner.ID = "shareiscare"
+	res.Owner.DisplayName = "shareiscare"
+	for name, dir := range s.Buckets {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		res.Buckets = append(res.Buckets, bucket{Name: name, CreationDate: formatTime(info.ModTime())})
+	}
+	sort.Slice(res.Buckets, func(i, j int) bool {
+		return res.Buckets[i].Name < res.Buckets[j].Name
+	})
+
+	writeXML(w, http.StatusOK, res)
+}
+
+// location handles GetBucketLocation
+func (s *Server) location(w http.ResponseWriter) {
+	type result struct {
+		XMLName xml.Name `xml:"LocationConstraint"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Region  string   `xml:",chardata"`
+	}
+
+	// The default region is reported as empty
+	region := s.Region
+	if region == "us-east-1" {
+		region = ""
+	}
+	writeXML(w, http.StatusOK, result{Xmlns: xmlns, Region: region})
+}
+
+// formatTime formats a time as in the XML documents of the S3 API
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// object is a file of a bucket
+type object struct {
+	key  string
+	path string
+	info fs.FileInfo
+}
+
+// walkObjects returns the objects of a bucket whose key starts with prefix,
+// sorted by key
+func (s *Server) walkObjects(bucket, dir, prefix string) ([]object, error) {
+	// Only walk the directory that can contain the prefix
+	base := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		base = prefix[:i]
+	}
+	root := dir
+	if base != "" {
+		var ok bool
+		if root, ok = objectPath(dir, base); !ok || s.hidden(bucket, base) {
+			return nil, nil
+		}
+		if _, err := s.resolve(dir, base); err != nil {
+			return nil, nil
+		}
+	}
+
+	var objects []object
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if s.hidden(bucket, key) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// Skip directories that can't contain the prefix
+			if !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return fs.SkipDir
+			}
+			// Empty directories are listed as folders, like the ones
+			// created by PutObject
+			if strings.HasPrefix(key+"/", prefix) && emptyDir(p) {
+				if info, err := d.Info(); err == nil {
+					objects = append(objects, object{key: key + "/", path: p, info: info})
+				}
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		objects = append(objects, object{key: key, path: p, info: info})
+		return nil
+	})
+
+	// Keys are sorted by their bytes, not by directory
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].key < objects[j].key
+	})
+	return objects, err
+}
+
+// emptyDir checks if a directory has no entries
+func emptyDir(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	return err == io.EOF
+}
+
+// listObjects handles ListObjectsV2
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket, dir string) {
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	type result struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Xmlns                 string         `xml:"xmlns,attr"`
+		Name                  string         `xml:"Name"`
+		Prefix                string         `xml:"Prefix"`
+		Delimiter             string         `xml:"Delimiter,omitempty"`
+		MaxKeys               int            `xml:"MaxKeys"`
+		KeyCount              int            `xml:"KeyCount"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+		StartAfter            string         `xml:"StartAfter,omitempty"`
+		EncodingType          string         `xml:"EncodingType,omitempty"`
+		Contents              []content      `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encoding := query.Get("encoding-type")
+	if encoding != "" && encoding != "url" {
+		writeError(w, r, errInvalidArgument)
+		return
+	}
+
+	limit := maxKeys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument)
+			return
+		}
+		limit = min(n, maxKeys)
+	}
+
+	// Keys are returned after the continuation token or StartAfter
+	marker := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			writeError(w, r, errInvalidArgument)
+			return
+		}
+		marker = string(decoded)
+	}
+
+	objects, err := s.walkObjects(bucket, dir, prefix)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	encode := func(s string) string {
+		if encoding == "url" {
+			return url.QueryEscape(s)
+		}
+		return s
+	}
+
+	res := result{
+		Xmlns:             xmlns,
+		Name:              bucket,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		MaxKeys:           limit,
+		ContinuationToken: token,
+		StartAfter:        encode(query.Get("start-after")),
+		EncodingType:      encoding,
+	}
+
+	last := ""
+	for _, obj := range objects {
+		if obj.key <= marker {
+			continue
+		}
+
+		// Keys that contain the delimiter after the prefix are grouped
+		common := ""
+		if delimiter != "" {
+			if i := strings.Index(obj.key[len(prefix):], delimiter); i >= 0 {
+				common = obj.key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if common != "" && (common == last || strings.HasPrefix(marker, common)) {
+			continue
+		}
+
+		if res.KeyCount == limit {
+			res.IsTruncated = true
+			res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			break
+		}
+
+		if common != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(common)})
+			last = common
+		} else {
+			res.Contents = append(res.Contents, content{
+				Key:          encode(obj.key),
+				LastModified: formatTime(obj.info.ModTime()),
+				ETag:         s.etag(obj.path, obj.info, false),
+				Size:         obj.info.Size(),
+				StorageClass: "STANDARD",
+			})
+			last = obj.key
+		}
+		res.KeyCount++
+	}
+
+	writeXML(w, http.StatusOK, res)
+}
+
+// getObject handles GetObject and HeadObject
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, key, fullPath string) error {
+	if strings.HasSuffix(key, "/") {
+		return errNoSuchKey
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return errNoSuchKey
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return errNoSuchKey
+	}
+	defer file.Close()
+
+	// Supports ranges and conditional requests
+	w.Header().Set("ETag", s.etag(fullPath, info, true))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	return nil
+}
+
+// writeObject writes the content of body to a temporary file in dir and
+// returns it with the hashes of its content. The caller must remove it.
+func writeObject(dir string, body io.Reader, hashes ...hash.Hash) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+
+	writers := []io.Writer{tmp}
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		var s3Err *Error
+		if errors.As(err, &s3Err) {
+			return "", s3Err
+		}
+		return "", errIncompleteBody
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// prepareParent creates the directory of the file of an object
+func prepareParent(fullPath string) error {
+	if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+		return &Error{"InvalidArgument", "The key is a directory of the shared directory", http.StatusBadRequest}
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return &Error{"InvalidArgument", "A parent of the key is a file of the shared directory", http.StatusBadRequest}
+	}
+	return nil
+}
+
+// putObject handles PutObject
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, body io.Reader, key, fullPath string) error {
+	// Keys that end with a slash are folders
+	if strings.HasSuffix(key, "/") {
+		if err := os.MkdirAll(fullPath, 0755); err != nil {
+			return errInvalidKey
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	if err := prepareParent(fullPath); err != nil {
+		return err
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	tmp, err := writeObject(filepath.Dir(fullPath), body, md5Hash, sha256Hash)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if contentMD5 := r.Header.Get("Content-Md5"); contentMD5 != "" {
+		if contentMD5 != base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)) {
+			return errBadDigest
+		}
+	}
+
+	if err := os.Rename(tmp, fullPath); err != nil {
+		return err
+	}
+
+	sum := hex.EncodeToString(md5Hash.Sum(nil))
+	if s.Checksums != nil {
+		// The checksums are already known, save them for later requests
+		s.Checksums.Store(fullPath, checksum.MD5, sum)
+		s.Checksums.Store(fullPath, checksum.SHA256, hex.EncodeToString(sha256Hash.Sum(nil)))
+	}
+
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyObject handles CopyObject. The source is given by the
+// X-Amz-Copy-Source header as /bucket/key, and can be in any bucket.
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, key, fullPath string) error {
+	type result struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}
+
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return errInvalidArgument
+	}
+	// Versions are not supported, only the current one can be copied
+	source, version, _ := strings.Cut(source, "?versionId=")
+	if version != "" && version != "null" {
+		return errNotImplemented
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	srcDir, ok := s.Buckets[srcBucket]
+	if !ok {
+		return errNoSuchBucket
+	}
+	srcPath, err := s.resolve(srcDir, srcKey)
+	if err == errAccessDenied {
+		return err
+	}
+	if err != nil || strings.HasSuffix(srcKey, "/") || s.hidden(srcBucket, srcKey) {
+		return errNoSuchKey
+	}
+	if strings.HasSuffix(key, "/") {
+		return errInvalidKey
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return errNoSuchKey
+	}
+	defer src.Close()
+	if info, err := src.Stat(); err != nil || !info.Mode().IsRegular() {
+		return errNoSuchKey
+	}
+
+	if err := prepareParent(fullPath); err != nil {
+		return err
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	tmp, err := writeObject(filepath.Dir(fullPath), src, md5Hash, sha256Hash)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Rename(tmp, fullPath); err != nil {
+		return err
+	}
+
+	sum := hex.EncodeToString(md5Hash.Sum(nil))
+	if s.Checksums != nil {
+		s.Checksums.Store(fullPath, checksum.MD5, sum)
+		s.Checksums.Store(fullPath, checksum.SHA256, hex.EncodeToString(sha256Hash.Sum(nil)))
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, result{Xmlns: xmlns, ETag: `"` + sum + `"`, LastModified: formatTime(info.ModTime())})
+	return nil
+}
+
+// deleteObject handles DeleteObject. Deleting a missing object succeeds.
+func (s *Server) deleteObject(w http.ResponseWriter, key, fullPath string) error {
+	info, err := os.Stat(fullPath)
+	switch {
+	case err != nil:
+	case strings.HasSuffix(key, "/") && info.IsDir():
+		// Folders are only deleted if they are empty
+		os.Remove(fullPath)
+	case info.Mode().IsRegular():
+		if err := os.Remove(fullPath); err != nil {
+			return err
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,441 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrwan/shareiscare/checksum"
+)
+
+const (
+	testAccessKey = "test-access-key"
+	testSecretKey = "test-secret-key"
+)
+
+// newTestServer inicia un servidor S3 con un bucket sobre un directorio temporal
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	s := &Server{
+		Region:     "us-east-1",
+		Buckets:    map[string]string{"compartido": dir},
+		Keys:       map[string]string{testAccessKey: testSecretKey},
+		UploadsDir: t.TempDir(),
+		Hidden: func(bucket, key string) bool {
+			return strings.HasPrefix(filepath.Base(key), ".")
+		},
+		Checksums: checksum.NewCache(t.TempDir()),
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server, dir
+}
+
+// s3Request ejecuta una petición firmada contra el servidor
+func s3Request(t *testing.T, server *httptest.Server, method, target string, body []byte, headers map[string]string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+target, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creando la petición: %v", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	sum := sha256.Sum256(body)
+	Sign(req, testAccessKey, testSecretKey, "us-east-1", hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error en la petición: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// checkStatus comprueba el código de estado de una respuesta
+func checkStatus(t *testing.T, resp *http.Response, status int) {
+	t.Helper()
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Código de estado incorrecto: obtenido %v, esperado %v: %s", resp.StatusCode, status, body)
+	}
+}
+
+// checkError comprueba el código de error S3 de una respuesta
+func checkError(t *testing.T, resp *http.Response, status int, code string) {
+	t.Helper()
+
+	var body errorBody
+	if err := xml.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Respuesta de error inválida: %v", err)
+	}
+	if resp.StatusCode != status || body.Code != code {
+		t.Errorf("Error incorrecto: obtenido %v %s, esperado %v %s", resp.StatusCode, body.Code, status, code)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/compartido?list-type=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusForbidden, "AccessDenied")
+
+	// Firma con otra clave secreta
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/compartido?list-type=2", nil)
+	Sign(req, testAccessKey, "otra", "us-east-1", emptySHA256, time.Now())
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusForbidden, "SignatureDoesNotMatch")
+
+	// Firma antigua
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/compartido?list-type=2", nil)
+	Sign(req, testAccessKey, testSecretKey, "us-east-1", emptySHA256, time.Now().Add(-time.Hour))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusForbidden, "RequestTimeTooSkewed")
+
+	// Clave de acceso desconocida
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/compartido?list-type=2", nil)
+	Sign(req, "desconocida", testSecretKey, "us-east-1", emptySHA256, time.Now())
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusForbidden, "InvalidAccessKeyId")
+
+	resp = s3Request(t, server, http.MethodGet, "/", nil, nil)
+	checkStatus(t, resp, http.StatusOK)
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "<Name>compartido</Name>") {
+		t.Errorf("ListBuckets debería incluir el bucket: %s", body)
+	}
+
+	checkError(t, s3Request(t, server, http.MethodGet, "/otro?list-type=2", nil, nil), http.StatusNotFound, "NoSuchBucket")
+}
+
+func TestObjects(t *testing.T) {
+	server, dir := newTestServer(t)
+	content := []byte("hola desde S3")
+
+	resp := s3Request(t, server, http.MethodPut, "/compartido/docs/nota.txt", content, nil)
+	checkStatus(t, resp, http.StatusOK)
+	sum := md5.Sum(content)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	if resp.Header.Get("ETag") != etag {
+		t.Errorf("ETag incorrecto: obtenido %s, esperado %s", resp.Header.Get("ETag"), etag)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "docs", "nota.txt"))
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("Contenido incorrecto en disco: %q (%v)", data, err)
+	}
+
+	resp = s3Request(t, server, http.MethodGet, "/compartido/docs/nota.txt", nil, nil)
+	checkStatus(t, resp, http.StatusOK)
+	got, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(got, content) || resp.Header.Get("ETag") != etag {
+		t.Errorf("GetObject incorrecto: %q con ETag %s", got, resp.Header.Get("ETag"))
+	}
+
+	resp = s3Request(t, server, http.MethodGet, "/compartido/docs/nota.txt", nil, map[string]string{"Range": "bytes=5-9"})
+	checkStatus(t, resp, http.StatusPartialContent)
+	got, _ = io.ReadAll(resp.Body)
+	if string(got) != "desde" {
+		t.Errorf("Rango incorrecto: %q", got)
+	}
+
+	resp = s3Request(t, server, http.MethodHead, "/compartido/docs/nota.txt", nil, nil)
+	checkStatus(t, resp, http.StatusOK)
+	if resp.Header.Get("Content-Length") != fmt.Sprint(len(content)) {
+		t.Errorf("Content-Length incorrecto: %s", resp.Header.Get("Content-Length"))
+	}
+
+	checkError(t, s3Request(t, server, http.MethodGet, "/compartido/docs/otra.txt", nil, nil), http.StatusNotFound, "NoSuchKey")
+	checkError(t, s3Request(t, server, http.MethodGet, "/compartido/../secreto", nil, nil), http.StatusBadRequest, "InvalidArgument")
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/.oculto", content, nil), http.StatusForbidden, "AccessDenied")
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/x.txt", content, map[string]string{"Content-MD5": "AAAAAAAAAAAAAAAAAAAAAA=="}), http.StatusBadRequest, "BadDigest")
+
+	// El cuerpo debe coincidir con el hash firmado
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/compartido/y.txt", bytes.NewReader(content))
+	Sign(req, testAccessKey, testSecretKey, "us-east-1", emptySHA256, time.Now())
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+	if _, err := os.Stat(filepath.Join(dir, "y.txt")); !os.IsNotExist(err) {
+		t.Error("No se debería guardar un objeto con un hash incorrecto")
+	}
+
+	checkStatus(t, s3Request(t, server, http.MethodDelete, "/compartido/docs/nota.txt", nil, nil), http.StatusNoContent)
+	checkStatus(t, s3Request(t, server, http.MethodDelete, "/compartido/docs/nota.txt", nil, nil), http.StatusNoContent)
+	if _, err := os.Stat(filepath.Join(dir, "docs", "nota.txt")); !os.IsNotExist(err) {
+		t.Error("El objeto debería haberse eliminado")
+	}
+}
+
+// listResult es la respuesta de ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list ejecuta ListObjectsV2 y devuelve las claves y prefijos comunes
+func list(t *testing.T, server *httptest.Server, query string) ([]string, listResult) {
+	t.Helper()
+
+	resp := s3Request(t, server, http.MethodGet, "/compartido?list-type=2&"+query, nil, nil)
+	checkStatus(t, resp, http.StatusOK)
+
+	var res listResult
+	if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+
+	var keys []string
+	for _, c := range res.Contents {
+		keys = append(keys, c.Key)
+	}
+	for _, p := range res.CommonPrefixes {
+		keys = append(keys, p.Prefix)
+	}
+	return keys, res
+}
+
+func TestListObjects(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	for _, name := range []string{"a.txt", "a/b.txt", "a/c/d.txt", "a-z.txt", "b.txt", ".oculto", "a/.oculto/x.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", "a-z.txt a.txt a/b.txt a/c/d.txt b.txt"},
+		{"delimiter=/", "a-z.txt a.txt b.txt a/"},
+		{"prefix=a/&delimiter=/", "a/b.txt a/c/"},
+		{"prefix=a/c", "a/c/d.txt"},
+		{"start-after=a/b.txt", "a/c/d.txt b.txt"},
+		{"prefix=nada/", ""},
+	}
+
+	for _, tt := range tests {
+		keys, _ := list(t, server, tt.query)
+		if got := strings.Join(keys, " "); got != tt.expected {
+			t.Errorf("ListObjectsV2(%q) = %q, esperado %q", tt.query, got, tt.expected)
+		}
+	}
+
+	// Paginación
+	var all []string
+	query := "max-keys=2&delimiter=/"
+	for i := 0; i < 5; i++ {
+		keys, res := list(t, server, query)
+		all = append(all, keys...)
+		if !res.IsTruncated {
+			break
+		}
+		query = "max-keys=2&delimiter=/&continuation-token=" + res.NextContinuationToken
+	}
+	if got := strings.Join(all, " "); got != "a-z.txt a.txt b.txt a/" {
+		t.Errorf("Paginación incorrecta: %q", got)
+	}
+
+	// Las carpetas vacías aparecen como objetos terminados en /
+	checkStatus(t, s3Request(t, server, http.MethodPut, "/compartido/a/vacia/", nil, nil), http.StatusOK)
+	keys, _ := list(t, server, "prefix=a/")
+	if got := strings.Join(keys, " "); got != "a/b.txt a/c/d.txt a/vacia/" {
+		t.Errorf("Listado de carpetas incorrecto: %q", got)
+	}
+	keys, _ = list(t, server, "prefix=a/&delimiter=/")
+	if got := strings.Join(keys, " "); got != "a/b.txt a/c/ a/vacia/" {
+		t.Errorf("Listado de carpetas incorrecto: %q", got)
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	content := []byte("hola")
+	checkStatus(t, s3Request(t, server, http.MethodPut, "/compartido/docs/nota.txt", content, nil), http.StatusOK)
+
+	resp := s3Request(t, server, http.MethodPut, "/compartido/copias/nota%20copia.txt", nil, map[string]string{"X-Amz-Copy-Source": "/compartido/docs/nota.txt"})
+	checkStatus(t, resp, http.StatusOK)
+	var result struct {
+		ETag string `xml:"ETag"`
+	}
+	sum := md5.Sum(content)
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.ETag != `"`+hex.EncodeToString(sum[:])+`"` {
+		t.Errorf("Respuesta incorrecta: %+v (%v)", result, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "copias", "nota copia.txt"))
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("Contenido incorrecto de la copia: %q (%v)", data, err)
+	}
+
+	copySource := func(source string) map[string]string {
+		return map[string]string{"X-Amz-Copy-Source": source}
+	}
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/x.txt", nil, copySource("/compartido/noexiste.txt")), http.StatusNotFound, "NoSuchKey")
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/x.txt", nil, copySource("/otro/docs/nota.txt")), http.StatusNotFound, "NoSuchBucket")
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/x.txt", nil, copySource("/compartido/../secreto")), http.StatusNotFound, "NoSuchKey")
+}
+
+func TestReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "nota.txt"), []byte("hola"), 0644)
+	server := httptest.NewServer(&Server{
+		Region:     "us-east-1",
+		Buckets:    map[string]string{"compartido": dir},
+		Keys:       map[string]string{testAccessKey: testSecretKey},
+		UploadsDir: t.TempDir(),
+		ReadOnly:   true,
+	})
+	t.Cleanup(server.Close)
+
+	checkStatus(t, s3Request(t, server, http.MethodGet, "/compartido/nota.txt", nil, nil), http.StatusOK)
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/otra.txt", []byte("x"), nil), http.StatusMethodNotAllowed, "MethodNotAllowed")
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/copia.txt", nil, map[string]string{"X-Amz-Copy-Source": "/compartido/nota.txt"}), http.StatusMethodNotAllowed, "MethodNotAllowed")
+	checkError(t, s3Request(t, server, http.MethodPost, "/compartido/grande.bin?uploads", nil, nil), http.StatusMethodNotAllowed, "MethodNotAllowed")
+	checkError(t, s3Request(t, server, http.MethodDelete, "/compartido/nota.txt", nil, nil), http.StatusMethodNotAllowed, "MethodNotAllowed")
+
+	if _, err := os.Stat(filepath.Join(dir, "nota.txt")); err != nil {
+		t.Error("El objeto no debería haberse eliminado")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "otra.txt")); err == nil {
+		t.Error("El objeto no debería haberse creado")
+	}
+}
+
+func TestDenyUploads(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "nota.txt"), []byte("hola"), 0644)
+	server := httptest.NewServer(&Server{
+		Region:      "us-east-1",
+		Buckets:     map[string]string{"compartido": dir},
+		Keys:        map[string]string{testAccessKey: testSecretKey},
+		UploadsDir:  t.TempDir(),
+		DenyUploads: "Sin subidas",
+	})
+	t.Cleanup(server.Close)
+
+	checkStatus(t, s3Request(t, server, http.MethodGet, "/compartido/nota.txt", nil, nil), http.StatusOK)
+	checkError(t, s3Request(t, server, http.MethodPut, "/compartido/otra.txt", []byte("x"), nil), http.StatusForbidden, "AccessDenied")
+	checkError(t, s3Request(t, server, http.MethodPost, "/compartido/grande.bin?uploads", nil, nil), http.StatusForbidden, "AccessDenied")
+	if _, err := os.Stat(filepath.Join(dir, "otra.txt")); err == nil {
+		t.Error("El objeto no debería haberse creado")
+	}
+
+	// Los objetos aún se pueden eliminar
+	checkStatus(t, s3Request(t, server, http.MethodDelete, "/compartido/nota.txt", nil, nil), http.StatusNoContent)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	server, dir := newTestServer(t)
+
+	resp := s3Request(t, server, http.MethodPost, "/compartido/grande.bin?uploads", nil, nil)
+	checkStatus(t, resp, http.StatusOK)
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&initiated); err != nil || initiated.UploadID == "" {
+		t.Fatalf("Respuesta inválida: %v", err)
+	}
+
+	parts := [][]byte{bytes.Repeat([]byte("a"), 1024), bytes.Repeat([]byte("b"), 100)}
+	var etags []string
+	for i, part := range parts {
+		target := fmt.Sprintf("/compartido/grande.bin?partNumber=%d&uploadId=%s", i+1, initiated.UploadID)
+		resp := s3Request(t, server, http.MethodPut, target, part, nil)
+		checkStatus(t, resp, http.StatusOK)
+		etags = append(etags, resp.Header.Get("ETag"))
+	}
+
+	// Un ETag incorrecto
+	complete := fmt.Sprintf(`<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part><Part><PartNumber>2</PartNumber><ETag>"x"</ETag></Part></CompleteMultipartUpload>`, etags[0])
+	checkError(t, s3Request(t, server, http.MethodPost, "/compartido/grande.bin?uploadId="+initiated.UploadID, []byte(complete), nil), http.StatusBadRequest, "InvalidPart")
+
+	complete = fmt.Sprintf(`<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part><Part><PartNumber>2</PartNumber><ETag>%s</ETag></Part></CompleteMultipartUpload>`, etags[0], etags[1])
+	resp = s3Request(t, server, http.MethodPost, "/compartido/grande.bin?uploadId="+initiated.UploadID, []byte(complete), nil)
+	checkStatus(t, resp, http.StatusOK)
+	var completed struct {
+		ETag string `xml:"ETag"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&completed); err != nil || !strings.HasSuffix(completed.ETag, `-2"`) {
+		t.Errorf("ETag incorrecto: %q (%v)", completed.ETag, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "grande.bin"))
+	if err != nil || !bytes.Equal(data, bytes.Join(parts, nil)) {
+		t.Errorf("Contenido incorrecto del objeto (%v)", err)
+	}
+
+	// La subida ya no existe
+	checkError(t, s3Request(t, server, http.MethodDelete, "/compartido/grande.bin?uploadId="+initiated.UploadID, nil, nil), http.StatusNotFound, "NoSuchUpload")
+	checkError(t, s3Request(t, server, http.MethodDelete, "/compartido/grande.bin?uploadId=../../etc", nil, nil), http.StatusNotFound, "NoSuchUpload")
+}
+
+func TestPresignedRequest(t *testing.T) {
+	server, dir := newTestServer(t)
+	os.WriteFile(filepath.Join(dir, "foto.jpg"), []byte("jpg"), 0644)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/compartido/foto.jpg", nil)
+	u := Presign(req, testAccessKey, testSecretKey, "us-east-1", time.Hour, time.Now())
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkStatus(t, resp, http.StatusOK)
+
+	// URL caducada
+	u = Presign(req, testAccessKey, testSecretKey, "us-east-1", time.Minute, time.Now().Add(-time.Hour))
+	resp, err = http.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	checkError(t, resp, http.StatusForbidden, "AccessDenied")
+}
@@ -0,0 +1,461 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 constants
+const (
+	algorithm     = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	service       = "s3"
+
+	// UnsignedPayload is the payload hash of requests whose body isn't signed
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	maxClockSkew     = 15 * time.Minute
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// authorization is the SigV4 signature of a request, from its Authorization
+// header or from the query of a presigned URL
+type authorization struct {
+	accessKey     string
+	date          string // Date of the credential scope (YYYYMMDD)
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+
+	amzDate     string // Time of the signature (X-Amz-Date)
+	payloadHash string
+	presigned   bool
+}
+
+// scope returns the credential scope of the signature
+func (a *authorization) scope() string {
+	return a.date + "/" + a.region + "/" + a.service + "/aws4_request"
+}
+
+// parseCredential parses a credential of the form
+// <access key>/<date>/<region>/<service>/aws4_request
+func (a *authorization) parseCredential(credential string) bool {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return false
+	}
+	a.accessKey, a.date, a.region, a.service = parts[0], parts[1], parts[2], parts[3]
+	return true
+}
+
+// parseAuthorization parses the Authorization header of a request
+func parseAuthorization(header string) (*authorization, bool) {
+	rest, ok := strings.CutPrefix(header, algorithm+" ")
+	if !ok {
+		return nil, false
+	}
+
+	auth := &authorization{}
+	var credential bool
+	for _, field := range strings.Split(rest, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Credential":
+			credential = auth.parseCredential(value)
+		case "SignedHeaders":
+			auth.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.signature = value
+		}
+	}
+
+	if !credential || len(auth.signedHeaders) == 0 || auth.signature == "" {
+		return nil, false
+	}
+	return auth, true
+}
+
+// parsePresigned parses the signature in the query of a presigned URL and
+// returns it with its expiration time
+func parsePresigned(query url.Values) (*authorization, time.Duration, bool) {
+	if query.Get("X-Amz-Algorithm") != algorithm {
+		return nil, 0, false
+	}
+
+	auth := &authorization{
+		amzDate:       query.Get("X-Amz-Date"),
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		payloadHash:   UnsignedPayload,
+		presigned:     true,
+	}
+	if hash := query.Get("X-Amz-Content-Sha256"); hash != "" {
+		auth.payloadHash = hash
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || !auth.parseCredential(query.Get("X-Amz-Credential")) || auth.signature == "" {
+		return nil, 0, false
+	}
+	return auth, time.Duration(expires) * time.Second, true
+}
+
+// uriEncode encodes s as required by SigV4. Slashes are only encoded if
+// encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// EscapePath escapes the path of a request as in its canonical form. Sending
+// requests signed with Sign with this encoding makes sure that any server
+// computes the same canonical request.
+func EscapePath(p string) string {
+	return uriEncode(p, false)
+}
+
+// canonicalQuery returns the sorted and encoded query of a request, without
+// the signature of presigned URLs
+func canonicalQuery(query url.Values) string {
+	type param struct{ key, value string }
+
+	var params []param
+	for key, values := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, param{uriEncode(key, true), uriEncode(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.key + "=" + p.value
+	}
+	return strings.Join(pairs, "&")
+}
+
+// headerValue returns the canonical value of a header of a request
+func headerValue(r *http.Request, name string) string {
+	var values []string
+	switch name {
+	case "host":
+		values = []string{r.Host}
+		if r.Host == "" {
+			values = []string{r.URL.Host}
+		}
+	case "content-length":
+		values = r.Header.Values(name)
+		if len(values) == 0 && r.ContentLength >= 0 {
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	default:
+		values = r.Header.Values(name)
+	}
+
+	for i, value := range values {
+		values[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// canonicalRequest returns the canonical form of a request that is signed
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	uri := r.URL.Path
+	if uri == "" {
+		uri = "/"
+	}
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		headers.WriteString(name + ":" + headerValue(r, name) + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(uri, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// signingKey derives the key used to sign requests of a credential scope
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// computeSignature returns the signature a request should have
+func computeSignature(r *http.Request, auth *authorization, secretKey string) string {
+	stringToSign := strings.Join([]string{
+		algorithm,
+		auth.amzDate,
+		auth.scope(),
+		sha256Hex(canonicalRequest(r, auth.signedHeaders, auth.payloadHash)),
+	}, "\n")
+
+	key := signingKey(secretKey, auth.date, auth.region, auth.service)
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaderNames returns the headers of a request that are signed by Sign
+func signedHeaderNames(r *http.Request) []string {
+	names := []string{"host"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sign signs a request with the Authorization header of SigV4. payloadHash is
+// the hex SHA-256 of the body, or UnsignedPayload.
+func Sign(r *http.Request, accessKey, secretKey, region, payloadHash string, t time.Time) {
+	auth := &authorization{
+		accessKey:   accessKey,
+		date:        t.UTC().Format("20060102"),
+		region:      region,
+		service:     service,
+		amzDate:     t.UTC().Format(amzDateFormat),
+		payloadHash: payloadHash,
+	}
+
+	r.Header.Set("X-Amz-Date", auth.amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	auth.signedHeaders = signedHeaderNames(r)
+	auth.signature = computeSignature(r, auth, secretKey)
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, accessKey, auth.scope(), strings.Join(auth.signedHeaders, ";"), auth.signature))
+}
+
+// Presign returns a URL for a request that is valid for expires without
+// any other authentication
+func Presign(r *http.Request, accessKey, secretKey, region string, expires time.Duration, t time.Time) *url.URL {
+	auth := &authorization{
+		accessKey:     accessKey,
+		date:          t.UTC().Format("20060102"),
+		region:        region,
+		service:       service,
+		amzDate:       t.UTC().Format(amzDateFormat),
+		payloadHash:   UnsignedPayload,
+		signedHeaders: []string{"host"},
+	}
+
+	u := *r.URL
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", accessKey+"/"+auth.scope())
+	query.Set("X-Amz-Date", auth.amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = query.Encode()
+
+	signed := r.Clone(r.Context())
+	signed.URL = &u
+	query.Set("X-Amz-Signature", computeSignature(signed, auth, secretKey))
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// chunkedReader decodes a body sent with aws-chunked encoding, verifying the
+// signature of each chunk when they are signed
+type chunkedReader struct {
+	r *bufio.Reader
+
+	// Signature chain, nil if the chunks are not signed
+	key     []byte
+	amzDate string
+	scope   string
+	prevSig string
+
+	remaining int64     // Bytes left in the current chunk
+	chunkSig  string    // Signature of the current chunk
+	hash      hash.Hash // Hash of the current chunk
+	inChunk   bool
+	done      bool
+}
+
+// newChunkedReader returns a reader of the decoded body of a request signed
+// with auth. The chunk signatures are verified with secretKey if signed.
+func newChunkedReader(body io.Reader, auth *authorization, secretKey string, signed bool) *chunkedReader {
+	c := &chunkedReader{r: bufio.NewReader(body), hash: sha256.New()}
+	if signed {
+		c.key = signingKey(secretKey, auth.date, auth.region, auth.service)
+		c.amzDate = auth.amzDate
+		c.scope = auth.scope()
+		c.prevSig = auth.signature
+	}
+	return c
+}
+
+// readLine reads a line terminated by CRLF
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// verifyChunk checks the signature of the chunk that has just been read
+func (c *chunkedReader) verifyChunk() error {
+	if c.key == nil {
+		return nil
+	}
+
+	stringToSign := strings.Join([]string{
+		algorithm + "-PAYLOAD",
+		c.amzDate,
+		c.scope,
+		c.prevSig,
+		emptySHA256,
+		hex.EncodeToString(c.hash.Sum(nil)),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(c.key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(c.chunkSig)) {
+		return errSignatureDoesNotMatch
+	}
+
+	c.prevSig = c.chunkSig
+	return nil
+}
+
+// nextChunk reads the header of the next chunk
+func (c *chunkedReader) nextChunk() error {
+	if c.inChunk {
+		// Each chunk ends with CRLF
+		if line, err := c.readLine(); err != nil || line != "" {
+			return errIncompleteBody
+		}
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+	}
+
+	header, err := c.readLine()
+	if err != nil {
+		return errIncompleteBody
+	}
+	sizeHex, extensions, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 {
+		return errIncompleteBody
+	}
+	c.chunkSig, _ = strings.CutPrefix(extensions, "chunk-signature=")
+	if c.key != nil && c.chunkSig == "" {
+		return errSignatureDoesNotMatch
+	}
+
+	c.hash.Reset()
+	c.remaining = size
+	c.inChunk = true
+
+	if size == 0 {
+		// The last chunk is followed by the trailers, if any, and an empty line
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+		for {
+			line, err := c.r.ReadString('\n')
+			if strings.TrimSpace(line) == "" || err != nil {
+				break
+			}
+		}
+		c.done = true
+	}
+	return nil
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	c.remaining -= int64(n)
+	if err == io.EOF {
+		return n, errIncompleteBody
+	}
+	return n, err
+}
+
+// hashingReader checks that a body matches the SHA-256 it was signed with
+type hashingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.expected {
+		return n, errContentSHA256Mismatch
+	}
+	return n, err
+}
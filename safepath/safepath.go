@@ -0,0 +1,191 @@
+// Package safepath maps the paths received from clients to paths on disk.
+// Every server of ShareIsCare resolves paths here, so that a path can't
+// leave the shared directory, neither with ".." nor through a symbolic link
+// unless the configured policy allows it.
+package safepath
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Policy decides which symbolic links are followed
+type Policy string
+
+// Symbolic link policies
+const (
+	Deny       Policy = "deny"        // No symbolic link is followed
+	WithinRoot Policy = "within_root" // Only links to files inside the root are followed
+	Follow     Policy = "follow"      // Every link is followed, even outside the root
+)
+
+// ParsePolicy parses a policy of the configuration file. Empty is
+// WithinRoot.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return WithinRoot, nil
+	case Deny, WithinRoot, Follow:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid symlink policy %q, it must be deny, within_root or follow", s)
+}
+
+var (
+	// ErrInvalidName is returned for names that can't be used as a file name
+	ErrInvalidName = fmt.Errorf("invalid name: %w", fs.ErrInvalid)
+	// ErrOutside is returned for paths that leave the root
+	ErrOutside = fmt.Errorf("path outside the shared directory: %w", fs.ErrPermission)
+	// ErrSymlink is returned for symbolic links that the policy doesn't
+	// allow
+	ErrSymlink = fmt.Errorf("symbolic link not allowed: %w", fs.ErrPermission)
+)
+
+// windows makes backslashes path separators, as they are on Windows. It's
+// a variable so that the tests can check it everywhere.
+var windows = runtime.GOOS == "windows"
+
+// reserved are the device names of Windows, which open a device instead of
+// a file whatever their extension
+var reserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true, "CONIN$": true, "CONOUT$": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true,
+	"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
+	"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// validName checks an element of a path with the rules of Windows, on
+// every platform, so that the shared files can be copied anywhere and a
+// name created on one server means the same file on all of them
+func validName(name string) bool {
+	if strings.ContainsAny(name, `<>:"|?*\`) || strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 {
+			return false
+		}
+	}
+	base, _, _ := strings.Cut(name, ".")
+	return !reserved[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// Clean validates a slash-separated path relative to the root and returns
+// it cleaned, "" for the root itself. A leading slash is ignored. Paths that
+// go above the root fail with ErrOutside, and names with NUL bytes, Windows
+// device names or reserved characters fail with ErrInvalidName, whatever
+// the platform. Backslashes are separators on Windows and invalid elsewhere.
+func Clean(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", ErrInvalidName
+	}
+	if windows {
+		name = strings.ReplaceAll(name, `\`, "/")
+	}
+
+	p := path.Clean(strings.TrimLeft(name, "/"))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrOutside
+	}
+	if p == "." {
+		return "", nil
+	}
+
+	for _, part := range strings.Split(p, "/") {
+		if !validName(part) {
+			return "", ErrInvalidName
+		}
+	}
+	return p, nil
+}
+
+// Resolver maps paths relative to a root directory to paths on disk
+type Resolver struct {
+	root   string
+	policy Policy
+}
+
+// New returns a resolver for the paths inside root
+func New(root string, policy Policy) *Resolver {
+	return &Resolver{root: root, policy: policy}
+}
+
+// Policy returns the symbolic link policy of the resolver
+func (r *Resolver) Policy() Policy {
+	return r.policy
+}
+
+// inside checks if a path is the root or inside it. Both must be absolute
+// and without symbolic links.
+func inside(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+// Resolve validates a slash-separated path relative to the root, as Clean
+// does, and returns its path on disk. The symbolic links of its parents are
+// evaluated and checked with the policy. A last element that is a link is
+// checked but kept, so that the link itself can be removed or renamed.
+// Elements that don't exist yet, e.g. a file being created, can't be links.
+func (r *Resolver) Resolve(name string) (string, error) {
+	clean, err := Clean(name)
+	if err != nil {
+		return "", &fs.PathError{Op: "resolve", Path: name, Err: err}
+	}
+	if r.policy == Follow || clean == "" {
+		return filepath.Join(r.root, filepath.FromSlash(clean)), nil
+	}
+
+	// The root itself may be a link
+	root, err := filepath.EvalSymlinks(r.root)
+	if err != nil {
+		// A missing root fails later, with the error of the operation
+		return filepath.Join(r.root, filepath.FromSlash(clean)), nil
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(clean, "/")
+	current := root
+	for i, part := range parts {
+		next := filepath.Join(current, part)
+		info, err := os.Lstat(next)
+		if err != nil {
+			// Nothing below a missing element can be a link
+			return filepath.Join(current, filepath.FromSlash(strings.Join(parts[i:], "/"))), nil
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		if r.policy == Deny {
+			return "", &fs.PathError{Op: "resolve", Path: clean, Err: ErrSymlink}
+		}
+		last := i == len(parts)-1
+		target, err := filepath.EvalSymlinks(next)
+		if err != nil {
+			// A broken link can only be removed or replaced
+			if last {
+				return next, nil
+			}
+			return "", &fs.PathError{Op: "resolve", Path: clean, Err: fs.ErrNotExist}
+		}
+		if !inside(root, target) {
+			return "", &fs.PathError{Op: "resolve", Path: clean, Err: ErrOutside}
+		}
+		if last {
+			return next, nil
+		}
+		current = target
+	}
+	return current, nil
+}
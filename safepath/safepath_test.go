@@ -0,0 +1,326 @@
+package safepath
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withWindows aplica las reglas de Windows durante una prueba
+func withWindows(t *testing.T, enabled bool) {
+	previous := windows
+	windows = enabled
+	t.Cleanup(func() { windows = previous })
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := map[string]Policy{
+		"":            WithinRoot,
+		"deny":        Deny,
+		"within_root": WithinRoot,
+		"follow":      Follow,
+	}
+	for s, expected := range tests {
+		policy, err := ParsePolicy(s)
+		if err != nil || policy != expected {
+			t.Errorf("ParsePolicy(%q) = %q, %v; se esperaba %q", s, policy, err, expected)
+		}
+	}
+	if _, err := ParsePolicy("always"); err == nil {
+		t.Error("ParsePolicy() debería rechazar políticas desconocidas")
+	}
+}
+
+func TestClean(t *testing.T) {
+	withWindows(t, false)
+
+	valid := map[string]string{
+		"":                 "",
+		"/":                "",
+		".":                "",
+		"docs/informe.pdf": "docs/informe.pdf",
+		"/docs//a/../b":    "docs/b",
+		"a/./b/":           "a/b",
+		"CONSOLA.txt":      "CONSOLA.txt",
+	}
+	for name, expected := range valid {
+		clean, err := Clean(name)
+		if err != nil || clean != expected {
+			t.Errorf("Clean(%q) = %q, %v; se esperaba %q", name, clean, err, expected)
+		}
+	}
+
+	invalid := map[string]error{
+		"..":             ErrOutside,
+		"../etc/passwd":  ErrOutside,
+		"/../etc":        ErrOutside,
+		"a/../../b":      ErrOutside,
+		"a\x00b":         ErrInvalidName,
+		"docs/\x00":      ErrInvalidName,
+		"docs/../\x00/x": ErrInvalidName,
+		// Los nombres de Windows se rechazan en todas las plataformas
+		"CON":          ErrInvalidName,
+		"docs/nul.txt": ErrInvalidName,
+		"COM1":         ErrInvalidName,
+		`a\b`:          ErrInvalidName,
+		"a:b":          ErrInvalidName,
+		"a*b":          ErrInvalidName,
+		"a?b":          ErrInvalidName,
+		`a"b`:          ErrInvalidName,
+		"a<b":          ErrInvalidName,
+		"a>b":          ErrInvalidName,
+		"a|b":          ErrInvalidName,
+	}
+	for name, expected := range invalid {
+		if _, err := Clean(name); !errors.Is(err, expected) {
+			t.Errorf("Clean(%q) devolvió %v, se esperaba %v", name, err, expected)
+		}
+	}
+}
+
+func TestCleanWindows(t *testing.T) {
+	withWindows(t, true)
+
+	valid := map[string]string{
+		`docs\informe.pdf`: "docs/informe.pdf",
+		"CONSOLA.txt":      "CONSOLA.txt",
+		"com10":            "com10",
+		"a.b.c":            "a.b.c",
+	}
+	for name, expected := range valid {
+		clean, err := Clean(name)
+		if err != nil || clean != expected {
+			t.Errorf("Clean(%q) = %q, %v; se esperaba %q", name, clean, err, expected)
+		}
+	}
+
+	invalid := map[string]error{
+		`..\windows`:  ErrOutside,
+		`a\..\..\b`:   ErrOutside,
+		"CON":         ErrInvalidName,
+		"docs/nul":    ErrInvalidName,
+		"aux.txt":     ErrInvalidName,
+		"Com1.tar.gz": ErrInvalidName,
+		"LPT9 .log":   ErrInvalidName,
+		"C:/Windows":  ErrInvalidName,
+		"a:b":         ErrInvalidName,
+		"nota.":       ErrInvalidName,
+		"nota ":       ErrInvalidName,
+		"a?b":         ErrInvalidName,
+		"a\tb":        ErrInvalidName,
+	}
+	for name, expected := range invalid {
+		if _, err := Clean(name); !errors.Is(err, expected) {
+			t.Errorf("Clean(%q) devolvió %v, se esperaba %v", name, err, expected)
+		}
+	}
+}
+
+// symlinkTree crea una raíz con enlaces dentro y fuera de ella, y devuelve
+// la raíz
+func symlinkTree(t testing.TB) string {
+	base := t.TempDir()
+	root := filepath.Join(base, "raiz")
+	outside := filepath.Join(base, "fuera")
+
+	for _, dir := range []string{
+		filepath.Join(root, "docs", "sub"),
+		outside,
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		filepath.Join(root, "docs", "informe.txt"): "informe",
+		filepath.Join(outside, "secreto.txt"):      "secreto",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"dentro":           "docs",
+		"informe.txt":      filepath.Join("docs", "informe.txt"),
+		"fuera":            outside,
+		"secreto.txt":      filepath.Join(outside, "secreto.txt"),
+		"roto":             "no-existe",
+		"docs/sub/arriba":  "../..",
+		"docs/sub/escapar": "../../..",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("No se pueden crear enlaces simbólicos: %v", err)
+		}
+	}
+	return root
+}
+
+func TestResolve(t *testing.T) {
+	root := symlinkTree(t)
+
+	// Caminos sin enlaces
+	for _, policy := range []Policy{Deny, WithinRoot, Follow} {
+		r := New(root, policy)
+		for _, name := range []string{"", "docs", "docs/informe.txt", "docs/nuevo/archivo.txt"} {
+			p, err := r.Resolve(name)
+			if err != nil {
+				t.Errorf("%s: Resolve(%q) devolvió error: %v", policy, name, err)
+				continue
+			}
+			if filepath.Base(p) != path.Base("/"+name) && name != "" {
+				t.Errorf("%s: Resolve(%q) = %s", policy, name, p)
+			}
+		}
+		if _, err := r.Resolve("../fuera/secreto.txt"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: un camino fuera de la raíz debería denegarse: %v", policy, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		err    error
+	}{
+		{"dentro/informe.txt", Deny, ErrSymlink},
+		{"informe.txt", Deny, ErrSymlink},
+		{"fuera/secreto.txt", Deny, ErrSymlink},
+
+		{"dentro/informe.txt", WithinRoot, nil},
+		{"informe.txt", WithinRoot, nil},
+		{"docs/sub/arriba/docs/informe.txt", WithinRoot, nil},
+		{"roto", WithinRoot, nil},
+		{"fuera", WithinRoot, ErrOutside},
+		{"fuera/secreto.txt", WithinRoot, ErrOutside},
+		{"secreto.txt", WithinRoot, ErrOutside},
+		{"docs/sub/escapar/fuera/secreto.txt", WithinRoot, ErrOutside},
+		{"roto/archivo", WithinRoot, fs.ErrNotExist},
+
+		{"fuera/secreto.txt", Follow, nil},
+		{"secreto.txt", Follow, nil},
+	}
+	for _, tt := range tests {
+		p, err := New(root, tt.policy).Resolve(tt.name)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s: Resolve(%q) devolvió error: %v", tt.policy, tt.name, err)
+			} else if _, err := os.Lstat(p); err != nil {
+				t.Errorf("%s: Resolve(%q) = %s, que no existe", tt.policy, tt.name, p)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Resolve(%q) devolvió %v, se esperaba %v", tt.policy, tt.name, err, tt.err)
+		}
+	}
+
+	// Un enlace al final del camino se conserva, para borrar el enlace y no
+	// su destino
+	p, err := New(root, WithinRoot).Resolve("informe.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(p); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Resolve() debería devolver el propio enlace: %s", p)
+	}
+
+	// La raíz puede ser un enlace
+	link := filepath.Join(t.TempDir(), "enlace")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(link, WithinRoot).Resolve("dentro/informe.txt"); err != nil {
+		t.Errorf("Una raíz enlazada debería admitir sus enlaces internos: %v", err)
+	}
+	if _, err := New(link, WithinRoot).Resolve("fuera/secreto.txt"); !errors.Is(err, ErrOutside) {
+		t.Errorf("Una raíz enlazada no debería seguir enlaces externos: %v", err)
+	}
+}
+
+func FuzzClean(f *testing.F) {
+	for _, seed := range []string{
+		"", "/", ".", "..", "a/../..", "docs/informe.pdf", `..\..\windows`,
+		"CON", "aux.txt", "a\x00b", "C:/x", "//servidor/recurso", "a/./b//c/",
+	} {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+
+	f.Fuzz(func(t *testing.T, name string, win bool) {
+		withWindows(t, win)
+
+		clean, err := Clean(name)
+		if err != nil {
+			if !errors.Is(err, fs.ErrInvalid) && !errors.Is(err, fs.ErrPermission) {
+				t.Fatalf("Clean(%q) devolvió un error inesperado: %v", name, err)
+			}
+			return
+		}
+		if clean == "" {
+			return
+		}
+		if strings.ContainsRune(clean, 0) {
+			t.Fatalf("Clean(%q) = %q contiene NUL", name, clean)
+		}
+		if path.Clean(clean) != clean || strings.HasPrefix(clean, "/") {
+			t.Fatalf("Clean(%q) = %q no está limpio", name, clean)
+		}
+		for _, part := range strings.Split(clean, "/") {
+			if part == ".." {
+				t.Fatalf("Clean(%q) = %q sale de la raíz", name, clean)
+			}
+			if !validName(part) {
+				t.Fatalf("Clean(%q) = %q no es válido en Windows", name, clean)
+			}
+		}
+
+		// Limpiar dos veces no cambia el resultado
+		if again, err := Clean(clean); err != nil || again != clean {
+			t.Fatalf("Clean(%q) = %q, %v; se esperaba %q", clean, again, err, clean)
+		}
+	})
+}
+
+func FuzzResolve(f *testing.F) {
+	for _, seed := range []string{
+		"", "docs/informe.txt", "dentro/informe.txt", "fuera/secreto.txt",
+		"secreto.txt", "docs/sub/arriba/fuera", "docs/sub/escapar/fuera/secreto.txt",
+		"../fuera", "roto/x", "docs/sub/arriba/docs/sub/arriba/secreto.txt",
+	} {
+		f.Add(seed)
+	}
+
+	root := symlinkTree(f)
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		for _, policy := range []Policy{Deny, WithinRoot} {
+			p, err := New(root, policy).Resolve(name)
+			if err != nil {
+				continue
+			}
+
+			// El directorio del resultado no tiene enlaces, y el resultado
+			// solo puede ser un enlace dentro de la raíz
+			if !inside(realRoot, filepath.Dir(p)) && p != root {
+				t.Fatalf("%s: Resolve(%q) = %s está fuera de la raíz", policy, name, p)
+			}
+			if target, err := filepath.EvalSymlinks(p); err == nil && !inside(realRoot, target) {
+				t.Fatalf("%s: Resolve(%q) = %s apunta a %s", policy, name, p, target)
+			}
+			if policy == Deny {
+				if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+					t.Fatalf("Deny: Resolve(%q) = %s es un enlace", name, p)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package sftpd
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rodrwan/shareiscare/safepath"
+)
+
+// handler serves the SFTP requests of a session
+type handler struct {
+	resolver *safepath.Resolver
+	perms    Permissions
+	hidden   func(name string) bool
+}
+
+// newHandlers returns the SFTP handlers of a session with the given permissions
+func newHandlers(root string, symlinks safepath.Policy, perms Permissions, hidden func(string) bool) sftp.Handlers {
+	if hidden == nil {
+		hidden = func(string) bool { return false }
+	}
+	h := &handler{resolver: safepath.New(root, symlinks), perms: perms, hidden: hidden}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+// localPath returns the local path of a request path. Paths can't go above
+// the root nor follow the symbolic links denied by the policy, and hidden
+// paths don't exist for clients.
+func (h *handler) localPath(name string) (string, error) {
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if rel != "" && h.hidden(rel) {
+		return "", os.ErrNotExist
+	}
+	return h.resolver.Resolve(rel)
+}
+
+// Fileread opens a file for downloading
+func (h *handler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	if !h.perms.Read {
+		return nil, os.ErrPermission
+	}
+	name, err := h.localPath(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, sftp.ErrSSHFxFailure
+	}
+	return f, nil
+}
+
+// Filewrite opens a file for uploading
+func (h *handler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	if !h.perms.Upload {
+		return nil, os.ErrPermission
+	}
+	name, err := h.localPath(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	pflags := r.Pflags()
+	flags := os.O_WRONLY
+	if pflags.Creat {
+		flags |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	// Appends are done by the client with the offsets of WriteAt, which
+	// doesn't work on files opened with O_APPEND
+	return os.OpenFile(name, flags, 0644)
+}
+
+// Filecmd runs the commands that modify the file system
+func (h *handler) Filecmd(r *sftp.Request) error {
+	name, err := h.localPath(r.Filepath)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "Mkdir":
+		if !h.perms.Upload {
+			return os.ErrPermission
+		}
+		return os.Mkdir(name, 0755)
+
+	case "Setstat":
+		if !h.perms.Upload {
+			return os.ErrPermission
+		}
+		return setstat(name, r)
+
+	case "Remove":
+		if !h.perms.Delete {
+			return os.ErrPermission
+		}
+		info, err := os.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return sftp.ErrSSHFxFailure
+		}
+		return os.Remove(name)
+
+	case "Rmdir":
+		if !h.perms.Delete {
+			return os.ErrPermission
+		}
+		info, err := os.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return sftp.ErrSSHFxFailure
+		}
+		return os.Remove(name)
+
+	case "Rename", "PosixRename":
+		if !h.perms.Delete {
+			return os.ErrPermission
+		}
+		target, err := h.localPath(r.Target)
+		if err != nil {
+			return err
+		}
+		// Plain SFTP renames don't replace existing files
+		if r.Method == "Rename" {
+			if _, err := os.Lstat(target); err == nil {
+				return sftp.ErrSSHFxFailure
+			}
+		}
+		return os.Rename(name, target)
+	}
+
+	// Links could point outside of the root
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// setstat changes the size, permissions or times of a file
+func setstat(name string, r *sftp.Request) error {
+	flags := r.AttrFlags()
+	attrs := r.Attributes()
+
+	if flags.Size {
+		if err := os.Truncate(name, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		if err := os.Chmod(name, os.FileMode(attrs.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		atime := time.Unix(int64(attrs.Atime), 0)
+		mtime := time.Unix(int64(attrs.Mtime), 0)
+		if err := os.Chtimes(name, atime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filelist lists directories and returns file information
+func (h *handler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if !h.perms.Read {
+		return nil, os.ErrPermission
+	}
+	name, err := h.localPath(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		rel := strings.TrimPrefix(path.Clean("/"+r.Filepath), "/")
+		var infos listerAt
+		for _, entry := range entries {
+			if h.hidden(path.Join(rel, entry.Name())) {
+				continue
+			}
+			// Links denied by the policy aren't listed either
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if _, err := h.resolver.Resolve(path.Join(rel, entry.Name())); err != nil {
+					continue
+				}
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+
+	case "Stat":
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+
+	case "Lstat":
+		info, err := os.Lstat(name)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+
+	// Links aren't followed by clients, see Filecmd
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// listerAt is a list of file information returned to clients
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(infos []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(infos, l[offset:])
+	if n < len(infos) {
+		return n, io.EOF
+	}
+	return n, nil
+}
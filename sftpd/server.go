@@ -0,0 +1,176 @@
+// Package sftpd serves a directory over SFTP. Clients are chrooted to the
+// directory and what they can do depends on the permissions given to them
+// when they authenticate.
+package sftpd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/pkg/sftp"
+	"github.com/rodrwan/shareiscare/safepath"
+	"golang.org/x/crypto/ssh"
+)
+
+// Permissions are the operations allowed to an authenticated client
+type Permissions struct {
+	Read    bool // List and download files
+	Upload  bool // Upload files and create directories
+	Delete  bool // Delete and rename files
+	Private bool // See the paths reported by Server.Private
+}
+
+// extensions stores permissions in the SSH permissions of a connection
+func (p Permissions) extensions() *ssh.Permissions {
+	return &ssh.Permissions{Extensions: map[string]string{
+		"read":    strconv.FormatBool(p.Read),
+		"upload":  strconv.FormatBool(p.Upload),
+		"delete":  strconv.FormatBool(p.Delete),
+		"private": strconv.FormatBool(p.Private),
+	}}
+}
+
+// permissionsOf returns the permissions of an SSH connection
+func permissionsOf(perms *ssh.Permissions) Permissions {
+	if perms == nil {
+		return Permissions{}
+	}
+	return Permissions{
+		Read:    perms.Extensions["read"] == "true",
+		Upload:  perms.Extensions["upload"] == "true",
+		Delete:  perms.Extensions["delete"] == "true",
+		Private: perms.Extensions["private"] == "true",
+	}
+}
+
+// Server is an SFTP server
+type Server struct {
+	// Root is the directory served to clients
+	Root string
+	// HostKey identifies the server to clients
+	HostKey ssh.Signer
+	// Password authenticates a user with a password (optional)
+	Password func(user, password string) (Permissions, bool)
+	// PublicKey authenticates a user with a public key (optional)
+	PublicKey func(user string, key ssh.PublicKey) (Permissions, bool)
+	// Hidden reports whether a slash-separated path relative to Root must be
+	// hidden from clients (optional)
+	Hidden func(name string) bool
+	// Private reports whether a slash-separated path relative to Root must be
+	// hidden from clients without the Private permission (optional)
+	Private func(name string) bool
+	// Symlinks decides which symbolic links are followed, only the ones
+	// inside Root if empty
+	Symlinks safepath.Policy
+}
+
+// hidden returns the function that reports the paths hidden from a client
+// with the given permissions
+func (s *Server) hidden(perms Permissions) func(name string) bool {
+	if s.Private == nil || perms.Private {
+		return s.Hidden
+	}
+	return func(name string) bool {
+		return s.Private(name) || (s.Hidden != nil && s.Hidden(name))
+	}
+}
+
+// sshConfig returns the configuration of the SSH connections
+func (s *Server) sshConfig() *ssh.ServerConfig {
+	config := &ssh.ServerConfig{ServerVersion: "SSH-2.0-ShareIsCare"}
+	config.AddHostKey(s.HostKey)
+
+	if s.Password != nil {
+		config.PasswordCallback = func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if perms, ok := s.Password(conn.User(), string(password)); ok {
+				return perms.extensions(), nil
+			}
+			return nil, fmt.Errorf("invalid password for %s", conn.User())
+		}
+	}
+	if s.PublicKey != nil {
+		config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if perms, ok := s.PublicKey(conn.User(), key); ok {
+				return perms.extensions(), nil
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		}
+	}
+
+	return config
+}
+
+// ListenAndServe listens on addr and serves the SFTP connections
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves the SFTP connections accepted by listener
+func (s *Server) Serve(listener net.Listener) error {
+	config := s.sshConfig()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn, config)
+	}
+}
+
+// serveConn serves an SSH connection, which can open SFTP sessions
+func (s *Server) serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		// Failed logins are normal, e.g. clients trying several keys
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	perms := permissionsOf(sshConn.Permissions)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("Error accepting SFTP session of %s: %v", sshConn.User(), err)
+			continue
+		}
+		go s.serveSession(channel, requests, perms)
+	}
+}
+
+// serveSession serves the SFTP subsystem on a session. Shells and commands
+// are not supported.
+func (s *Server) serveSession(channel ssh.Channel, requests <-chan *ssh.Request, perms Permissions) {
+	defer channel.Close()
+
+	for req := range requests {
+		// The payload of subsystem requests is the length-prefixed name
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+
+		server := sftp.NewRequestServer(channel, newHandlers(s.Root, s.Symlinks, perms, s.hidden(perms)))
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("SFTP session error: %v", err)
+		}
+		server.Close()
+		return
+	}
+}
@@ -0,0 +1,283 @@
+package sftpd
+
+import (
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// startServer inicia un servidor SFTP en un puerto libre. setup permite
+// modificar el servidor antes de iniciarlo.
+func startServer(t *testing.T, root string, setup func(*Server)) string {
+	t.Helper()
+
+	hostKey, err := LoadHostKey(filepath.Join(t.TempDir(), "host_key"))
+	if err != nil {
+		t.Fatalf("Error al generar la clave del servidor: %v", err)
+	}
+
+	server := &Server{
+		Root:    root,
+		HostKey: hostKey,
+		Password: func(user, password string) (Permissions, bool) {
+			switch {
+			case user == "admin" && password == "secreto":
+				return Permissions{Read: true, Upload: true, Delete: true, Private: true}, true
+			case user == "lector" && password == "secreto":
+				return Permissions{Read: true}, true
+			}
+			return Permissions{}, false
+		},
+		Hidden: func(name string) bool {
+			return name == "config.yaml"
+		},
+	}
+
+	if setup != nil {
+		setup(server)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error al escuchar: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+
+	return listener.Addr().String()
+}
+
+// connect abre una sesión SFTP
+func connect(t *testing.T, addr, user string, auth ssh.AuthMethod) (*sftp.Client, error) {
+	t.Helper()
+
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.Cleanup(func() {
+		client.Close()
+		conn.Close()
+	})
+	return client, nil
+}
+
+func setupFiles(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "config.yaml"), []byte("password: x"), 0644)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "docs", "nota.txt"), []byte("hola"), 0644)
+	return root
+}
+
+func TestAuthentication(t *testing.T) {
+	addr := startServer(t, setupFiles(t), nil)
+
+	if _, err := connect(t, addr, "admin", ssh.Password("incorrecta")); err == nil {
+		t.Error("Se aceptó una contraseña incorrecta")
+	}
+	if _, err := connect(t, addr, "admin", ssh.Password("secreto")); err != nil {
+		t.Errorf("No se aceptó la contraseña correcta: %v", err)
+	}
+}
+
+func TestPublicKey(t *testing.T) {
+	signer, err := LoadHostKey(filepath.Join(t.TempDir(), "id_ed25519"))
+	if err != nil {
+		t.Fatalf("Error al generar la clave: %v", err)
+	}
+	addr := startServer(t, setupFiles(t), func(server *Server) {
+		server.PublicKey = func(user string, key ssh.PublicKey) (Permissions, bool) {
+			return Permissions{Read: true}, user == "admin" && string(key.Marshal()) == string(signer.PublicKey().Marshal())
+		}
+	})
+
+	client, err := connect(t, addr, "admin", ssh.PublicKeys(signer))
+	if err != nil {
+		t.Fatalf("No se aceptó la clave autorizada: %v", err)
+	}
+	if _, err := client.Stat("/docs/nota.txt"); err != nil {
+		t.Errorf("Error al leer con la clave autorizada: %v", err)
+	}
+
+	other, _ := LoadHostKey(filepath.Join(t.TempDir(), "otra"))
+	if _, err := connect(t, addr, "admin", ssh.PublicKeys(other)); err == nil {
+		t.Error("Se aceptó una clave no autorizada")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	root := setupFiles(t)
+	addr := startServer(t, root, nil)
+
+	client, err := connect(t, addr, "admin", ssh.Password("secreto"))
+	if err != nil {
+		t.Fatalf("Error al conectar: %v", err)
+	}
+
+	// Los archivos ocultos no se listan
+	infos, err := client.ReadDir("/")
+	if err != nil {
+		t.Fatalf("Error al listar: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != "docs" {
+		t.Errorf("Listado incorrecto: %v", names)
+	}
+	if _, err := client.Open("/config.yaml"); err == nil {
+		t.Error("Se pudo abrir un archivo oculto")
+	}
+
+	// No se puede salir del directorio compartido
+	f, err := client.Open("/../../docs/nota.txt")
+	if err != nil {
+		t.Fatalf("Error al abrir: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "hola" {
+		t.Errorf("Contenido incorrecto: %q", data)
+	}
+
+	// Subir, renombrar y eliminar
+	f, err = client.Create("/docs/nuevo.txt")
+	if err != nil {
+		t.Fatalf("Error al crear: %v", err)
+	}
+	f.Write([]byte("nuevo"))
+	f.Close()
+	if data, _ := os.ReadFile(filepath.Join(root, "docs", "nuevo.txt")); string(data) != "nuevo" {
+		t.Errorf("Contenido subido incorrecto: %q", data)
+	}
+	if err := client.Mkdir("/otros"); err != nil {
+		t.Errorf("Error al crear el directorio: %v", err)
+	}
+	if err := client.Rename("/docs/nuevo.txt", "/otros/nuevo.txt"); err != nil {
+		t.Errorf("Error al renombrar: %v", err)
+	}
+	if err := client.Rename("/otros/nuevo.txt", "/config.yaml"); err == nil {
+		t.Error("Se pudo reemplazar un archivo oculto")
+	}
+	if err := client.Remove("/otros/nuevo.txt"); err != nil {
+		t.Errorf("Error al eliminar: %v", err)
+	}
+	if err := client.RemoveDirectory("/otros"); err != nil {
+		t.Errorf("Error al eliminar el directorio: %v", err)
+	}
+	if err := client.Symlink("/config.yaml", "/enlace"); err == nil {
+		t.Error("Se pudo crear un enlace")
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	root := setupFiles(t)
+	addr := startServer(t, root, nil)
+
+	client, err := connect(t, addr, "lector", ssh.Password("secreto"))
+	if err != nil {
+		t.Fatalf("Error al conectar: %v", err)
+	}
+
+	if _, err := client.Stat("/docs/nota.txt"); err != nil {
+		t.Errorf("Error al leer: %v", err)
+	}
+	if _, err := client.Create("/docs/nuevo.txt"); err == nil {
+		t.Error("Se pudo subir un archivo sin permiso")
+	}
+	if err := client.Mkdir("/otros"); err == nil {
+		t.Error("Se pudo crear un directorio sin permiso")
+	}
+	if err := client.Remove("/docs/nota.txt"); err == nil {
+		t.Error("Se pudo eliminar un archivo sin permiso")
+	}
+	if err := client.Rename("/docs/nota.txt", "/nota.txt"); err == nil {
+		t.Error("Se pudo renombrar un archivo sin permiso")
+	}
+	if _, err := os.Stat(filepath.Join(root, "docs", "nota.txt")); err != nil {
+		t.Errorf("El archivo fue modificado: %v", err)
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	root := setupFiles(t)
+	os.WriteFile(filepath.Join(root, "docs", ".privado"), []byte("secreto"), 0644)
+	addr := startServer(t, root, func(s *Server) {
+		s.Private = func(name string) bool { return strings.HasPrefix(path.Base(name), ".") }
+	})
+
+	// Sin el permiso los archivos privados no existen
+	lector, err := connect(t, addr, "lector", ssh.Password("secreto"))
+	if err != nil {
+		t.Fatalf("Error al conectar: %v", err)
+	}
+	infos, err := lector.ReadDir("/docs")
+	if err != nil {
+		t.Fatalf("Error al listar: %v", err)
+	}
+	for _, info := range infos {
+		if info.Name() == ".privado" {
+			t.Error("El listado no debería incluir archivos privados")
+		}
+	}
+	if _, err := lector.Open("/docs/.privado"); err == nil {
+		t.Error("Se pudo abrir un archivo privado sin permiso")
+	}
+
+	// Con el permiso se ven, pero los ocultos siguen sin verse
+	admin, err := connect(t, addr, "admin", ssh.Password("secreto"))
+	if err != nil {
+		t.Fatalf("Error al conectar: %v", err)
+	}
+	if _, err := admin.Stat("/docs/.privado"); err != nil {
+		t.Errorf("Error al leer un archivo privado con permiso: %v", err)
+	}
+	if _, err := admin.Stat("/config.yaml"); err == nil {
+		t.Error("Se pudo leer un archivo oculto")
+	}
+}
+
+func TestLoadHostKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sftp", "host_key")
+
+	first, err := LoadHostKey(path)
+	if err != nil {
+		t.Fatalf("Error al generar la clave: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("La clave no se guardó: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Permisos incorrectos: %v", info.Mode().Perm())
+	}
+
+	second, err := LoadHostKey(path)
+	if err != nil {
+		t.Fatalf("Error al leer la clave: %v", err)
+	}
+	if string(first.PublicKey().Marshal()) != string(second.PublicKey().Marshal()) {
+		t.Error("La clave cambió al volver a cargarla")
+	}
+}
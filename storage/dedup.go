@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BlobDir is the directory with the content of the files of a deduplicated
+// storage, at its root
+const BlobDir = ".shareiscare-blobs"
+
+// Dedup stores the files in a directory of the local disk keeping identical
+// content only once. The content of every file is a blob in BlobDir named
+// after its SHA-256 checksum, and the files are hard links to their blob.
+// The number of links of a blob is its reference count: a blob is freed when
+// the last file that references it is removed or replaced.
+type Dedup struct {
+	*Local
+
+	// mu serializes linking files to blobs and freeing blobs
+	mu sync.Mutex
+	// index finds the blob of a file from their shared inode, without
+	// hashing the file or going through the blob directory
+	index map[inode]string
+}
+
+// NewDedup returns the deduplicated storage of the files in root. The blobs
+// of files that were removed without it, e.g. from a shell, are freed.
+func NewDedup(root string) (*Dedup, error) {
+	if !hardLinks {
+		return nil, errors.New("deduplication needs hard links, which aren't supported on this platform")
+	}
+	d := &Dedup{Local: NewLocal(root), index: map[inode]string{}}
+	if err := os.MkdirAll(d.blobs(), 0755); err != nil {
+		return nil, err
+	}
+	d.Collect()
+	return d, nil
+}
+
+// blobs returns the path on disk of the blob directory
+func (d *Dedup) blobs() string {
+	return filepath.Join(d.root, BlobDir)
+}
+
+// blobPath returns the path on disk of the blob with a checksum
+func (d *Dedup) blobPath(sum string) string {
+	return filepath.Join(d.blobs(), sum[:2], sum)
+}
+
+// isBlob reports whether a name is the blob directory or inside it, which
+// can't be read or changed through the storage
+func isBlob(name string) bool {
+	first, _, _ := strings.Cut(cleanName(name), "/")
+	return first == BlobDir
+}
+
+// isLink reports whether a file may be a link to a blob
+func isLink(info fs.FileInfo) bool {
+	return info.Mode().IsRegular() && linkCount(info) > 1
+}
+
+// linked returns the files under a name, or the name itself, that may be
+// links to a blob
+func (d *Dedup) linked(name string) []fs.FileInfo {
+	p, err := d.resolve("lstat", name)
+	if err != nil {
+		return nil
+	}
+	var links []fs.FileInfo
+	filepath.WalkDir(p, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && isLink(info) {
+			links = append(links, info)
+		}
+		return nil
+	})
+	return links
+}
+
+// Collect frees the blobs that no file references anymore, going through
+// the whole blob directory. Removing files through the storage already
+// frees their blobs, this is for the files removed without it.
+func (d *Dedup) Collect() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	filepath.WalkDir(d.blobs(), func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if linkCount(info) > 1 {
+			d.index[inodeOf(info)] = p
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			log.Printf("Error freeing blob %s: %v", entry.Name(), err)
+		}
+		return nil
+	})
+}
+
+// release frees the blobs of files that have been removed or replaced if
+// they were their last references
+func (d *Dedup) release(links []fs.FileInfo) {
+	if len(links) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.free(links)
+}
+
+// free is release with mu held
+func (d *Dedup) free(links []fs.FileInfo) {
+	for _, link := range links {
+		id := inodeOf(link)
+		blob, ok := d.index[id]
+		if !ok {
+			continue
+		}
+		info, err := os.Lstat(blob)
+		if err != nil || !os.SameFile(info, link) {
+			delete(d.index, id)
+			continue
+		}
+		if linkCount(info) > 1 {
+			continue
+		}
+		if err := os.Remove(blob); err != nil {
+			log.Printf("Error freeing blob %s: %v", filepath.Base(blob), err)
+			continue
+		}
+		delete(d.index, id)
+	}
+}
+
+func (d *Dedup) Stat(name string) (fs.FileInfo, error) {
+	if isBlob(name) {
+		return nil, &fs.PathError{Op: "stat", Path: cleanName(name), Err: fs.ErrNotExist}
+	}
+	return d.Local.Stat(name)
+}
+
+func (d *Dedup) ReadDir(name string) ([]fs.FileInfo, error) {
+	if isBlob(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: cleanName(name), Err: fs.ErrNotExist}
+	}
+	infos, err := d.Local.ReadDir(name)
+	if err != nil || cleanName(name) != "" {
+		return infos, err
+	}
+	result := infos[:0]
+	for _, info := range infos {
+		if info.Name() != BlobDir {
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+func (d *Dedup) Open(name string) (File, error) {
+	if isBlob(name) {
+		return nil, &fs.PathError{Op: "open", Path: cleanName(name), Err: fs.ErrNotExist}
+	}
+	return d.Local.Open(name)
+}
+
+func (d *Dedup) Create(name string) (Writer, error) {
+	if isBlob(name) {
+		return nil, denied("create", name)
+	}
+	dst, err := d.resolve("create", name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(filepath.Dir(dst)); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: cleanName(name), Err: fs.ErrInvalid}
+	}
+
+	// The content is written to the blob directory, and moved to its blob
+	// when committed
+	tmp, err := os.CreateTemp(d.blobs(), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &dedupWriter{File: tmp, hash: sha256.New(), d: d, dst: dst}, nil
+}
+
+func (d *Dedup) Remove(name string) error {
+	if isBlob(name) || cleanName(name) == "" {
+		return denied("remove", name)
+	}
+	links := d.linked(name)
+	if err := d.Local.Remove(name); err != nil {
+		return err
+	}
+	d.release(links)
+	return nil
+}
+
+func (d *Dedup) RemoveAll(name string) error {
+	if isBlob(name) || cleanName(name) == "" {
+		return denied("removeall", name)
+	}
+	links := d.linked(name)
+	if err := d.Local.RemoveAll(name); err != nil {
+		return err
+	}
+	d.release(links)
+	return nil
+}
+
+func (d *Dedup) Rename(oldName, newName string) error {
+	if isBlob(oldName) || isBlob(newName) {
+		return denied("rename", oldName)
+	}
+	// A replaced file may have been the last reference to its blob
+	var replaced []fs.FileInfo
+	if p, err := d.resolve("lstat", newName); err == nil {
+		if info, err := os.Lstat(p); err == nil && isLink(info) {
+			replaced = append(replaced, info)
+		}
+	}
+	if err := d.Local.Rename(oldName, newName); err != nil {
+		return err
+	}
+	d.release(replaced)
+	return nil
+}
+
+func (d *Dedup) MkdirAll(name string) error {
+	if isBlob(name) {
+		return denied("mkdir", name)
+	}
+	return d.Local.MkdirAll(name)
+}
+
+// dedupWriter writes a temporary file in the blob directory and hashes it,
+// to link the file to the blob with the same content when committed
+type dedupWriter struct {
+	*os.File
+	hash      hash.Hash
+	d         *Dedup
+	dst       string
+	committed bool
+}
+
+func (w *dedupWriter) Write(p []byte) (int, error) {
+	n, err := w.File.Write(p)
+	w.hash.Write(p[:n])
+	return n, err
+}
+
+func (w *dedupWriter) Commit() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(w.hash.Sum(nil))
+	blob := w.d.blobPath(sum)
+
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+
+	// Identical content is kept only once
+	if _, err := os.Stat(blob); err == nil {
+		os.Remove(w.Name())
+	} else {
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return err
+		}
+		if err := os.Chmod(w.Name(), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(w.Name(), blob); err != nil {
+			return err
+		}
+	}
+	w.committed = true
+	if info, err := os.Lstat(blob); err == nil {
+		w.d.index[inodeOf(info)] = blob
+	}
+
+	// The link is created next to the file and moved over it, so that
+	// readers never see a missing file
+	link := filepath.Join(filepath.Dir(w.dst), filepath.Base(w.Name()))
+	if err := os.Link(blob, link); err != nil {
+		return err
+	}
+	var replaced []fs.FileInfo
+	if old, err := os.Lstat(w.dst); err == nil && isLink(old) {
+		replaced = append(replaced, old)
+	}
+	if err := os.Rename(link, w.dst); err != nil {
+		os.Remove(link)
+		return err
+	}
+	// Renaming a link over another link of the same blob does nothing
+	os.Remove(link)
+
+	w.d.free(replaced)
+	return nil
+}
+
+func (w *dedupWriter) Close() error {
+	if w.committed {
+		return nil
+	}
+	w.File.Close()
+	return os.Remove(w.Name())
+}
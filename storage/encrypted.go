@@ -0,0 +1,414 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted files start with a header with the magic string and the key of
+// the file, sealed with the master key. The content follows in chunks of
+// ChunkSize bytes sealed with AES-GCM and the key of the file. The nonce of
+// a chunk is its index with a flag for the last chunk, so chunks can't be
+// reordered, dropped or appended without being detected.
+const (
+	encryptedMagic = "SICENC01"
+	keyMagic       = "SICKEY01"
+	// KeyFile is the file with the salt of the master key, at the root of
+	// an encrypted storage
+	KeyFile = ".shareiscare-key"
+	// ChunkSize is the size of the chunks of an encrypted file
+	ChunkSize = 64 << 10
+
+	keySize    = 32
+	nonceSize  = 12
+	tagSize    = 16
+	saltSize   = 16
+	headerSize = len(encryptedMagic) + nonceSize + keySize + tagSize
+)
+
+var (
+	// ErrPassphrase is returned when the passphrase doesn't match the one
+	// the storage was encrypted with
+	ErrPassphrase = errors.New("wrong passphrase")
+	// ErrDamaged is returned when an encrypted file can't be decrypted
+	ErrDamaged = errors.New("encrypted file is damaged")
+)
+
+// encrypted stores the files of another storage encrypted
+type encrypted struct {
+	Storage
+	master    cipher.AEAD
+	chunkSize int
+}
+
+// Encrypt returns a storage that encrypts the files of s with a key derived
+// from the passphrase. The salt of the key is kept in KeyFile, which is
+// created the first time.
+func Encrypt(s Storage, passphrase string) (Storage, error) {
+	data, err := ReadFile(s, KeyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newEncrypted(s, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The key file has the magic string, the salt and a value sealed with
+	// the master key to check the passphrase
+	if len(data) < len(keyMagic)+saltSize+nonceSize+tagSize || string(data[:len(keyMagic)]) != keyMagic {
+		return nil, fmt.Errorf("%s isn't a key file", KeyFile)
+	}
+	salt := data[len(keyMagic) : len(keyMagic)+saltSize]
+	check := data[len(keyMagic)+saltSize:]
+
+	master, err := masterKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := master.Open(nil, check[:nonceSize], check[nonceSize:], []byte(keyMagic)); err != nil {
+		return nil, ErrPassphrase
+	}
+	return &encrypted{Storage: s, master: master, chunkSize: ChunkSize}, nil
+}
+
+// newEncrypted creates the key file of a storage encrypted for the first time
+func newEncrypted(s Storage, passphrase string) (Storage, error) {
+	salt := make([]byte, saltSize)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	master, err := masterKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(keyMagic), salt...)
+	data = append(data, nonce...)
+	data = master.Seal(data, nonce, nil, []byte(keyMagic))
+	if err := WriteFile(s, KeyFile, data); err != nil {
+		return nil, err
+	}
+	return &encrypted{Storage: s, master: master, chunkSize: ChunkSize}, nil
+}
+
+// masterKey derives the master key from the passphrase
+func masterKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of a chunk
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// chunks returns the number of chunks of an encrypted file
+func (e *encrypted) chunks(size int64) int64 {
+	sealed := int64(e.chunkSize + tagSize)
+	return (size - int64(headerSize) + sealed - 1) / sealed
+}
+
+// plainSize returns the size of the content of an encrypted file
+func (e *encrypted) plainSize(size int64) int64 {
+	if size < int64(headerSize+tagSize) {
+		return 0
+	}
+	return size - int64(headerSize) - e.chunks(size)*tagSize
+}
+
+// info returns the information of a file with the size of its content
+func (e *encrypted) info(info fs.FileInfo) fs.FileInfo {
+	if info.IsDir() {
+		return info
+	}
+	return encryptedInfo{FileInfo: info, size: e.plainSize(info.Size())}
+}
+
+// isKeyFile reports whether a name is the key file, which can't be read or
+// changed through the storage
+func isKeyFile(name string) bool {
+	return cleanName(name) == KeyFile
+}
+
+func (e *encrypted) Stat(name string) (fs.FileInfo, error) {
+	if isKeyFile(name) {
+		return nil, &fs.PathError{Op: "stat", Path: cleanName(name), Err: fs.ErrNotExist}
+	}
+	info, err := e.Storage.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info(info), nil
+}
+
+func (e *encrypted) ReadDir(name string) ([]fs.FileInfo, error) {
+	infos, err := e.Storage.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]fs.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if cleanName(name) == "" && info.Name() == KeyFile {
+			continue
+		}
+		result = append(result, e.info(info))
+	}
+	return result, nil
+}
+
+func (e *encrypted) Open(name string) (File, error) {
+	if isKeyFile(name) {
+		return nil, &fs.PathError{Op: "open", Path: cleanName(name), Err: fs.ErrNotExist}
+	}
+	f, err := e.Storage.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err
+	}
+
+	damaged := &fs.PathError{Op: "open", Path: cleanName(name), Err: ErrDamaged}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic || info.Size() < int64(headerSize+tagSize) {
+		f.Close()
+		return nil, damaged
+	}
+	sealed := header[len(encryptedMagic):]
+	key, err := e.master.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(encryptedMagic))
+	if err != nil {
+		f.Close()
+		return nil, damaged
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &encryptedFile{
+		f:         f,
+		aead:      aead,
+		name:      cleanName(name),
+		info:      e.info(info),
+		chunkSize: e.chunkSize,
+		chunks:    e.chunks(info.Size()),
+		size:      e.plainSize(info.Size()),
+		loaded:    -1,
+	}, nil
+}
+
+func (e *encrypted) Create(name string) (Writer, error) {
+	if isKeyFile(name) {
+		return nil, denied("create", name)
+	}
+
+	// Every file has its own key, sealed with the master key in the header
+	key := make([]byte, keySize)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(encryptedMagic), nonce...)
+	header = e.master.Seal(header, nonce, key, []byte(encryptedMagic))
+
+	w, err := e.Storage.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &encryptedWriter{
+		w:     w,
+		aead:  aead,
+		plain: make([]byte, 0, e.chunkSize),
+	}, nil
+}
+
+func (e *encrypted) Remove(name string) error {
+	if isKeyFile(name) {
+		return denied("remove", name)
+	}
+	return e.Storage.Remove(name)
+}
+
+func (e *encrypted) RemoveAll(name string) error {
+	if isKeyFile(name) {
+		return denied("removeall", name)
+	}
+	return e.Storage.RemoveAll(name)
+}
+
+func (e *encrypted) Rename(oldName, newName string) error {
+	if isKeyFile(oldName) || isKeyFile(newName) {
+		return denied("rename", oldName)
+	}
+	return e.Storage.Rename(oldName, newName)
+}
+
+// encryptedInfo is the information of an encrypted file with the size of
+// its content
+type encryptedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i encryptedInfo) Size() int64 { return i.size }
+
+// encryptedFile decrypts a file one chunk at a time, so seeking only
+// decrypts the chunks that are read
+type encryptedFile struct {
+	f         File
+	aead      cipher.AEAD
+	name      string
+	info      fs.FileInfo
+	chunkSize int
+	chunks    int64
+	size      int64
+	offset    int64
+	// Decrypted content of the chunk with index loaded
+	plain  []byte
+	loaded int64
+}
+
+// load decrypts a chunk
+func (f *encryptedFile) load(index int64) error {
+	sealed := int64(f.chunkSize + tagSize)
+	if _, err := f.f.Seek(int64(headerSize)+index*sealed, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, sealed)
+	n, err := io.ReadFull(f.f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	plain, err := f.aead.Open(f.plain[:0], chunkNonce(index, index == f.chunks-1), buf[:n], nil)
+	if err != nil {
+		f.loaded = -1
+		return &fs.PathError{Op: "read", Path: f.name, Err: ErrDamaged}
+	}
+	f.plain = plain
+	f.loaded = index
+	return nil
+}
+
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	index := f.offset / int64(f.chunkSize)
+	if index != f.loaded {
+		if err := f.load(index); err != nil {
+			return 0, err
+		}
+	}
+	start := f.offset - index*int64(f.chunkSize)
+	if start >= int64(len(f.plain)) {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrDamaged}
+	}
+	n := copy(p, f.plain[start:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *encryptedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *encryptedFile) Close() error               { return f.f.Close() }
+
+// encryptedWriter seals the content in chunks. A full chunk is kept until
+// more content arrives, because the last chunk is sealed differently.
+type encryptedWriter struct {
+	w     Writer
+	aead  cipher.AEAD
+	plain []byte
+	index int64
+}
+
+// seal writes the pending chunk
+func (w *encryptedWriter) seal(last bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.index, last), w.plain, nil)
+	if _, err := w.w.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.plain = w.plain[:0]
+	return nil
+}
+
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.plain) == cap(w.plain) {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(cap(w.plain)-len(w.plain), len(p))
+		w.plain = append(w.plain, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *encryptedWriter) Commit() error {
+	if err := w.seal(true); err != nil {
+		return err
+	}
+	return w.w.Commit()
+}
+
+func (w *encryptedWriter) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package storage
+
+import "io/fs"
+
+// hardLinks reports whether files can have several hard links
+const hardLinks = false
+
+// linkCount is not supported on this platform
+func linkCount(info fs.FileInfo) uint64 {
+	return 1
+}
+
+// inode identifies a file on disk, shared by all its hard links
+type inode struct{}
+
+// inodeOf is not supported on this platform
+func inodeOf(info fs.FileInfo) inode {
+	return inode{}
+}
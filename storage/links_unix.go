@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package storage
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hardLinks reports whether files can have several hard links
+const hardLinks = true
+
+// linkCount returns the number of hard links of a file
+func linkCount(info fs.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// inode identifies a file on disk, shared by all its hard links
+type inode struct {
+	dev, ino uint64
+}
+
+// inodeOf returns the inode of a file
+func inodeOf(info fs.FileInfo) inode {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return inode{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}
+	}
+	return inode{}
+}
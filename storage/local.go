@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rodrwan/shareiscare/safepath"
+)
+
+// Local stores the files in a directory of the local disk
+type Local struct {
+	root     string
+	resolver *safepath.Resolver
+}
+
+// NewLocal returns the storage of the files in root. Only the symbolic links
+// to files inside root are followed, see SetSymlinks.
+func NewLocal(root string) *Local {
+	return &Local{root: root, resolver: safepath.New(root, safepath.WithinRoot)}
+}
+
+// SetSymlinks sets which symbolic links are followed
+func (l *Local) SetSymlinks(policy safepath.Policy) {
+	l.resolver = safepath.New(l.root, policy)
+}
+
+// Path returns the path on disk of a file, without checking its symbolic
+// links
+func (l *Local) Path(name string) string {
+	return filepath.Join(l.root, filepath.FromSlash(cleanName(name)))
+}
+
+// resolve returns the path on disk of a file, checking its symbolic links
+// with the policy of the storage
+func (l *Local) resolve(op, name string) (string, error) {
+	p, err := l.resolver.Resolve(cleanName(name))
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Op = op
+	}
+	return p, err
+}
+
+func (l *Local) Stat(name string) (fs.FileInfo, error) {
+	p, err := l.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (l *Local) ReadDir(name string) ([]fs.FileInfo, error) {
+	dir, err := l.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Symbolic links are followed if the policy allows them, entries that
+	// can't be read are skipped
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if _, err := l.resolve("readdir", path.Join(cleanName(name), entry.Name())); err != nil {
+				continue
+			}
+		}
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (l *Local) Open(name string) (File, error) {
+	p, err := l.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (l *Local) Create(name string) (Writer, error) {
+	dst, err := l.resolve("create", name)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: tmp, dst: dst}, nil
+}
+
+func (l *Local) Remove(name string) error {
+	p, err := l.resolve("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (l *Local) RemoveAll(name string) error {
+	p, err := l.resolve("removeall", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+func (l *Local) Rename(oldName, newName string) error {
+	oldPath, err := l.resolve("rename", oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := l.resolve("rename", newName)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+func (l *Local) MkdirAll(name string) error {
+	p, err := l.resolve("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, 0755)
+}
+
+// localWriter writes a temporary file that is moved to its final name when
+// committed
+type localWriter struct {
+	*os.File
+	dst       string
+	committed bool
+}
+
+func (w *localWriter) Commit() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(w.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(w.Name(), w.dst); err != nil {
+		return err
+	}
+	w.committed = true
+	return nil
+}
+
+func (w *localWriter) Close() error {
+	if w.committed {
+		return nil
+	}
+	w.File.Close()
+	return os.Remove(w.Name())
+}
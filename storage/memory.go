@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Memory stores the files in memory. It's meant for tests.
+type Memory struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+// memNode is a file or directory of a Memory storage
+type memNode struct {
+	data    []byte
+	modTime time.Time
+	isDir   bool
+}
+
+// NewMemory returns an empty storage
+func NewMemory() *Memory {
+	return &Memory{nodes: map[string]*memNode{
+		"": {isDir: true, modTime: time.Now()},
+	}}
+}
+
+// memInfo is the fs.FileInfo of a memNode
+type memInfo struct {
+	name string
+	node memNode
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i *memInfo) ModTime() time.Time { return i.node.modTime }
+func (i *memInfo) IsDir() bool        { return i.node.isDir }
+func (i *memInfo) Sys() any           { return nil }
+
+func (i *memInfo) Mode() fs.FileMode {
+	if i.node.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// info returns the information of a node. The root is called ".".
+func (n *memNode) info(name string) fs.FileInfo {
+	base := path.Base(name)
+	if name == "" {
+		base = "."
+	}
+	return &memInfo{name: base, node: *n}
+}
+
+// parent returns the name of the directory of name
+func parent(name string) string {
+	dir := path.Dir(name)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// lookupDir checks that name is an existing directory
+func (m *Memory) lookupDir(op, name string) error {
+	node, ok := m.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !node.isDir {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+func (m *Memory) Stat(name string) (fs.FileInfo, error) {
+	name = cleanName(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return node.info(name), nil
+}
+
+func (m *Memory) ReadDir(name string) ([]fs.FileInfo, error) {
+	name = cleanName(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.lookupDir("readdir", name); err != nil {
+		return nil, err
+	}
+
+	var infos []fs.FileInfo
+	for child, node := range m.nodes {
+		if child != "" && parent(child) == name {
+			infos = append(infos, node.info(child))
+		}
+	}
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (m *Memory) Open(name string) (File, error) {
+	name = cleanName(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	// Files are replaced and never modified, so their data can be shared
+	return &memFile{Reader: bytes.NewReader(node.data), info: node.info(name)}, nil
+}
+
+func (m *Memory) Create(name string) (Writer, error) {
+	name = cleanName(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.lookupDir("create", parent(name)); err != nil {
+		return nil, err
+	}
+	if node, ok := m.nodes[name]; ok && node.isDir {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: syscall.EISDIR}
+	}
+	return &memWriter{memory: m, name: name}, nil
+}
+
+func (m *Memory) Remove(name string) error {
+	name = cleanName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[name]
+	if !ok || name == "" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if node.isDir {
+		for child := range m.nodes {
+			if child != "" && parent(child) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *Memory) RemoveAll(name string) error {
+	name = cleanName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for child := range m.nodes {
+		if child != "" && (child == name || strings.HasPrefix(child, name+"/")) {
+			delete(m.nodes, child)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Rename(oldName, newName string) error {
+	oldName, newName = cleanName(oldName), cleanName(newName)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, ok := m.nodes[oldName]
+	if !ok || oldName == "" {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	if err := m.lookupDir("rename", parent(newName)); err != nil {
+		return err
+	}
+	if node.isDir && strings.HasPrefix(newName+"/", oldName+"/") {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	}
+	if target, ok := m.nodes[newName]; ok && (target.isDir || node.isDir) {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+	}
+
+	// Move the node and everything inside it
+	for child, n := range m.nodes {
+		if child == oldName || strings.HasPrefix(child, oldName+"/") {
+			delete(m.nodes, child)
+			m.nodes[newName+strings.TrimPrefix(child, oldName)] = n
+		}
+	}
+	return nil
+}
+
+func (m *Memory) MkdirAll(name string) error {
+	name = cleanName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var dir string
+	for _, part := range strings.Split(name, "/") {
+		if part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		node, ok := m.nodes[dir]
+		if !ok {
+			m.nodes[dir] = &memNode{isDir: true, modTime: time.Now()}
+		} else if !node.isDir {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil
+}
+
+// memFile is a file of a Memory storage opened for reading
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memWriter writes a file of a Memory storage
+type memWriter struct {
+	bytes.Buffer
+	memory *Memory
+	name   string
+	closed bool
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	return w.Buffer.Write(p)
+}
+
+func (w *memWriter) Commit() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+
+	m := w.memory
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The directory could have been removed while writing
+	if err := m.lookupDir("create", parent(w.name)); err != nil {
+		return err
+	}
+	if node, ok := m.nodes[w.name]; ok && node.isDir {
+		return &fs.PathError{Op: "create", Path: w.name, Err: syscall.EISDIR}
+	}
+	m.nodes[w.name] = &memNode{data: bytes.Clone(w.Bytes()), modTime: time.Now()}
+	return nil
+}
+
+func (w *memWriter) Close() error {
+	w.closed = true
+	return nil
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"io/fs"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Mount combines several storages, each one shown as a top-level directory
+// with its name. The top-level directories can't be created, removed or
+// renamed, and files can't be moved from one storage to another.
+type Mount struct {
+	storages map[string]Storage
+	names    []string
+	modTime  time.Time
+}
+
+// NewMount returns a storage with the given storages as its directories
+func NewMount(storages map[string]Storage) *Mount {
+	names := make([]string, 0, len(storages))
+	for name := range storages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Mount{storages: storages, names: names, modTime: time.Now()}
+}
+
+// split returns the storage of a name and the name inside it. The storage
+// is nil for the root.
+func (m *Mount) split(op, name string) (Storage, string, error) {
+	name = cleanName(name)
+	if name == "" {
+		return nil, "", nil
+	}
+	_, rest, _ := strings.Cut(name, "/")
+	s, ok := m.storages[topDir(name)]
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return s, rest, nil
+}
+
+// topDir returns the top-level directory of a name
+func topDir(name string) string {
+	top, _, _ := strings.Cut(cleanName(name), "/")
+	return top
+}
+
+// mountInfo renames the information of the root of a mounted storage
+type mountInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i *mountInfo) Name() string { return i.name }
+
+// rootInfo returns the information of a top-level directory
+func (m *Mount) rootInfo(name string, s Storage) fs.FileInfo {
+	info, err := s.Stat("")
+	if err != nil {
+		// Still listed, so that the problem shows up when it's opened
+		info = &memInfo{node: memNode{isDir: true}}
+	}
+	return &mountInfo{FileInfo: info, name: name}
+}
+
+func (m *Mount) Stat(name string) (fs.FileInfo, error) {
+	s, rest, err := m.split("stat", name)
+	switch {
+	case err != nil:
+		return nil, err
+	case s == nil:
+		return &memInfo{name: ".", node: memNode{isDir: true, modTime: m.modTime}}, nil
+	case rest == "":
+		info, err := s.Stat("")
+		if err != nil {
+			return nil, err
+		}
+		return &mountInfo{FileInfo: info, name: cleanName(name)}, nil
+	}
+	return s.Stat(rest)
+}
+
+func (m *Mount) ReadDir(name string) ([]fs.FileInfo, error) {
+	s, rest, err := m.split("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		return s.ReadDir(rest)
+	}
+
+	infos := make([]fs.FileInfo, 0, len(m.names))
+	for _, name := range m.names {
+		infos = append(infos, m.rootInfo(name, m.storages[name]))
+	}
+	return infos, nil
+}
+
+func (m *Mount) Open(name string) (File, error) {
+	s, rest, err := m.split("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		return s.Open(rest)
+	}
+	info, _ := m.Stat("")
+	return &memFile{Reader: bytes.NewReader(nil), info: info}, nil
+}
+
+// inside returns the storage of a name below a top-level directory, which
+// are the only ones that can be modified
+func (m *Mount) inside(op, name string) (Storage, string, error) {
+	if !strings.Contains(cleanName(name), "/") {
+		return nil, "", &fs.PathError{Op: op, Path: cleanName(name), Err: fs.ErrPermission}
+	}
+	return m.split(op, name)
+}
+
+func (m *Mount) Create(name string) (Writer, error) {
+	s, rest, err := m.inside("create", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Create(rest)
+}
+
+func (m *Mount) Remove(name string) error {
+	s, rest, err := m.inside("remove", name)
+	if err != nil {
+		return err
+	}
+	return s.Remove(rest)
+}
+
+func (m *Mount) RemoveAll(name string) error {
+	s, rest, err := m.inside("removeall", name)
+	if err != nil {
+		return err
+	}
+	return s.RemoveAll(rest)
+}
+
+func (m *Mount) Rename(oldName, newName string) error {
+	s, oldRest, err := m.inside("rename", oldName)
+	if err != nil {
+		return err
+	}
+	_, newRest, err := m.inside("rename", newName)
+	if err != nil {
+		return err
+	}
+	if topDir(oldName) != topDir(newName) {
+		return &fs.PathError{Op: "rename", Path: cleanName(newName), Err: syscall.EXDEV}
+	}
+	return s.Rename(oldRest, newRest)
+}
+
+func (m *Mount) MkdirAll(name string) error {
+	s, rest, err := m.split("mkdir", name)
+	if err != nil || s == nil {
+		return err
+	}
+	if rest == "" {
+		_, err := s.Stat("")
+		return err
+	}
+	return s.MkdirAll(rest)
+}
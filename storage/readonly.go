@@ -0,0 +1,25 @@
+package storage
+
+import "io/fs"
+
+// readOnly is a storage whose files can't be modified
+type readOnly struct {
+	Storage
+}
+
+// ReadOnly returns a storage with the files of s that fails every change
+// with fs.ErrPermission
+func ReadOnly(s Storage) Storage {
+	return readOnly{s}
+}
+
+// denied returns the error of a change
+func denied(op, name string) error {
+	return &fs.PathError{Op: op, Path: cleanName(name), Err: fs.ErrPermission}
+}
+
+func (readOnly) Create(name string) (Writer, error) { return nil, denied("create", name) }
+func (readOnly) Remove(name string) error           { return denied("remove", name) }
+func (readOnly) RemoveAll(name string) error        { return denied("removeall", name) }
+func (readOnly) Rename(oldName, _ string) error     { return denied("rename", oldName) }
+func (readOnly) MkdirAll(name string) error         { return denied("mkdir", name) }
@@ -0,0 +1,744 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rodrwan/shareiscare/s3"
+)
+
+// defaultPartSize is the size of the parts of multipart uploads. Amazon S3
+// requires at least 5 MiB for every part but the last one.
+const defaultPartSize = 8 << 20
+
+// emptySHA256 is the SHA-256 of an empty body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options configures an S3 storage
+type S3Options struct {
+	Endpoint  string // URL of the service, e.g. "https://s3.amazonaws.com"
+	Bucket    string
+	Prefix    string // Folder of the bucket with the files (optional)
+	Region    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool  // Use endpoint/bucket/key URLs instead of bucket.endpoint/key
+	PartSize  int64 // Size of the parts of multipart uploads, 8 MiB by default
+
+	Client *http.Client // http.DefaultClient by default
+}
+
+// S3 stores the files in a bucket of Amazon S3 or of a compatible service
+// such as MinIO. Directories are emulated with the prefixes of the keys:
+// a directory exists while any key starts with its name followed by a
+// slash, and empty directories are kept with an empty object whose key
+// ends with a slash.
+type S3 struct {
+	opts     S3Options
+	endpoint *url.URL
+	prefix   string
+}
+
+// NewS3 returns the storage of the files of a bucket
+func NewS3(opts S3Options) (*S3, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint: %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, errors.New("an S3 bucket is required")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaultPartSize
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	prefix := cleanName(opts.Prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{opts: opts, endpoint: endpoint, prefix: prefix}, nil
+}
+
+// key returns the key of the object of a file
+func (s *S3) key(name string) string {
+	return s.prefix + name
+}
+
+// dirKey returns the prefix of the keys of the files in a directory
+func (s *S3) dirKey(name string) string {
+	if name == "" {
+		return s.prefix
+	}
+	return s.prefix + name + "/"
+}
+
+// url returns the URL of an object, or of the bucket if key is empty
+func (s *S3) url(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	p := "/" + key
+	if s.opts.PathStyle {
+		p = strings.TrimSuffix(u.Path, "/") + "/" + s.opts.Bucket + p
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+	}
+	u.Path = p
+	u.RawPath = s3.EscapePath(p)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	return &u
+}
+
+// s3Error is an error response of the service
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	status  int
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.status, http.StatusText(e.status))
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// Is makes missing keys match fs.ErrNotExist
+func (e *s3Error) Is(target error) bool {
+	return target == fs.ErrNotExist && e.status == http.StatusNotFound
+}
+
+// do sends a signed request. body must be nil or a *bytes.Reader, whose
+// content is signed. Error responses are returned as errors.
+func (s *S3) do(method, key string, query url.Values, body *bytes.Reader, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	payloadHash := emptySHA256
+	if body != nil {
+		h := sha256.New()
+		body.WriteTo(h)
+		body.Seek(0, io.SeekStart)
+		payloadHash = hex.EncodeToString(h.Sum(nil))
+		reader = body
+	}
+
+	req, err := http.NewRequest(method, s.url(key, query).String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s3.Sign(req, s.opts.AccessKey, s.opts.SecretKey, s.opts.Region, payloadHash, time.Now())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		e := &s3Error{status: resp.StatusCode}
+		xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(e)
+		return nil, e
+	}
+	return resp, nil
+}
+
+// pathError wraps an error of an operation on a file
+func pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// s3Info is the fs.FileInfo of an object or an emulated directory
+type s3Info struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (i *s3Info) Name() string       { return i.name }
+func (i *s3Info) Size() int64        { return i.size }
+func (i *s3Info) ModTime() time.Time { return i.modTime }
+func (i *s3Info) IsDir() bool        { return i.isDir }
+func (i *s3Info) Sys() any           { return nil }
+
+func (i *s3Info) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// baseName returns the last element of a name. The root is called ".".
+func baseName(name string) string {
+	if name == "" {
+		return "."
+	}
+	return path.Base(name)
+}
+
+// head returns the information of the object of a file
+func (s *S3) head(name string) (*s3Info, error) {
+	resp, err := s.do(http.MethodHead, s.key(name), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &s3Info{name: baseName(name), size: resp.ContentLength, modTime: modTime}, nil
+}
+
+// listResult is the response of ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		Size         int64  `xml:"Size"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list calls fn for each page of the objects whose key starts with prefix.
+// With a delimiter, the keys that contain it after the prefix are grouped
+// into common prefixes. max limits the size of the pages (0 for the default).
+func (s *S3) list(prefix, delimiter string, max int, fn func(*listResult) (bool, error)) error {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if max > 0 {
+		query.Set("max-keys", strconv.Itoa(max))
+	}
+
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		more, err := fn(&result)
+		if err != nil || !more || !result.IsTruncated {
+			return err
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// isDir checks if a directory exists, which is when any key is inside it
+func (s *S3) isDir(name string) (bool, error) {
+	if name == "" {
+		return true, nil
+	}
+	found := false
+	err := s.list(s.dirKey(name), "", 1, func(result *listResult) (bool, error) {
+		found = len(result.Contents) > 0
+		return false, nil
+	})
+	return found, err
+}
+
+// keysIn returns the keys of the objects inside a directory
+func (s *S3) keysIn(name string) ([]string, error) {
+	var keys []string
+	err := s.list(s.dirKey(name), "", 0, func(result *listResult) (bool, error) {
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		return true, nil
+	})
+	return keys, err
+}
+
+// checkDir checks that a directory exists, for the operations that need it
+func (s *S3) checkDir(op, name string) error {
+	ok, err := s.isDir(name)
+	if err != nil {
+		return pathError(op, name, err)
+	}
+	if ok {
+		return nil
+	}
+	if _, err := s.head(name); err == nil {
+		return pathError(op, name, syscall.ENOTDIR)
+	}
+	return pathError(op, name, fs.ErrNotExist)
+}
+
+func (s *S3) Stat(name string) (fs.FileInfo, error) {
+	name = cleanName(name)
+	if name == "" {
+		return &s3Info{name: ".", isDir: true}, nil
+	}
+
+	info, err := s.head(name)
+	if err == nil {
+		return info, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, pathError("stat", name, err)
+	}
+
+	ok, err := s.isDir(name)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	if !ok {
+		return nil, pathError("stat", name, fs.ErrNotExist)
+	}
+	return &s3Info{name: baseName(name), isDir: true}, nil
+}
+
+func (s *S3) ReadDir(name string) ([]fs.FileInfo, error) {
+	name = cleanName(name)
+	prefix := s.dirKey(name)
+
+	var infos []fs.FileInfo
+	err := s.list(prefix, "/", 0, func(result *listResult) (bool, error) {
+		for _, obj := range result.Contents {
+			// The object that keeps an empty directory
+			if obj.Key == prefix {
+				continue
+			}
+			modTime, _ := time.Parse(time.RFC3339, obj.LastModified)
+			infos = append(infos, &s3Info{name: obj.Key[len(prefix):], size: obj.Size, modTime: modTime})
+		}
+		for _, p := range result.CommonPrefixes {
+			infos = append(infos, &s3Info{name: strings.TrimSuffix(p.Prefix[len(prefix):], "/"), isDir: true})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	// Nothing is listed for a missing directory
+	if len(infos) == 0 && name != "" {
+		if err := s.checkDir("readdir", name); err != nil {
+			return nil, err
+		}
+	}
+
+	sortInfos(infos)
+	return infos, nil
+}
+
+func (s *S3) Open(name string) (File, error) {
+	name = cleanName(name)
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{storage: s, name: name, info: info.(*s3Info)}, nil
+}
+
+func (s *S3) Create(name string) (Writer, error) {
+	name = cleanName(name)
+	if err := s.checkDir("create", parent(name)); err != nil {
+		return nil, err
+	}
+	if ok, err := s.isDir(name); err != nil || ok {
+		if err == nil {
+			err = syscall.EISDIR
+		}
+		return nil, pathError("create", name, err)
+	}
+	return &s3Writer{storage: s, name: name}, nil
+}
+
+func (s *S3) Remove(name string) error {
+	name = cleanName(name)
+	if name == "" {
+		return pathError("remove", name, fs.ErrInvalid)
+	}
+
+	if _, err := s.head(name); err == nil {
+		return s.delete("remove", name, s.key(name))
+	}
+
+	keys, err := s.keysIn(name)
+	if err != nil {
+		return pathError("remove", name, err)
+	}
+	switch {
+	case len(keys) == 0:
+		return pathError("remove", name, fs.ErrNotExist)
+	case len(keys) > 1 || keys[0] != s.dirKey(name):
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+	return s.delete("remove", name, keys[0])
+}
+
+// delete deletes an object
+func (s *S3) delete(op, name, key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return pathError(op, name, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) RemoveAll(name string) error {
+	name = cleanName(name)
+
+	keys, err := s.keysIn(name)
+	if err != nil {
+		return pathError("removeall", name, err)
+	}
+	if name != "" {
+		keys = append(keys, s.key(name))
+	}
+	for _, key := range keys {
+		if err := s.delete("removeall", name, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rename copies the objects to their new keys and deletes the old ones, so
+// it isn't atomic. Objects larger than 5 GiB can't be copied by Amazon S3.
+func (s *S3) Rename(oldName, newName string) error {
+	oldName, newName = cleanName(oldName), cleanName(newName)
+
+	info, err := s.Stat(oldName)
+	if err != nil || oldName == "" {
+		return pathError("rename", oldName, fs.ErrNotExist)
+	}
+	if err := s.checkDir("rename", parent(newName)); err != nil {
+		return err
+	}
+	if info.IsDir() && strings.HasPrefix(newName+"/", oldName+"/") {
+		return pathError("rename", oldName, fs.ErrInvalid)
+	}
+	if target, err := s.Stat(newName); err == nil && (target.IsDir() || info.IsDir()) {
+		return pathError("rename", newName, fs.ErrExist)
+	}
+
+	if !info.IsDir() {
+		if err := s.copy(s.key(oldName), s.key(newName)); err != nil {
+			return pathError("rename", oldName, err)
+		}
+		return s.delete("rename", oldName, s.key(oldName))
+	}
+
+	keys, err := s.keysIn(oldName)
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+	oldPrefix, newPrefix := s.dirKey(oldName), s.dirKey(newName)
+	for _, key := range keys {
+		if err := s.copy(key, newPrefix+key[len(oldPrefix):]); err != nil {
+			return pathError("rename", oldName, err)
+		}
+	}
+	for _, key := range keys {
+		if err := s.delete("rename", oldName, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy copies an object. The objects that keep empty directories are
+// created again instead.
+func (s *S3) copy(from, to string) error {
+	if strings.HasSuffix(from, "/") {
+		return s.put(to, nil)
+	}
+
+	source := "/" + s.opts.Bucket + "/" + from
+	header := http.Header{"X-Amz-Copy-Source": {s3.EscapePath(source)}}
+	resp, err := s.do(http.MethodPut, to, nil, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Copies can fail after the response has started
+	var result struct {
+		XMLName xml.Name
+		s3Error
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return &result.s3Error
+	}
+	return nil
+}
+
+// put uploads an object in a single request
+func (s *S3) put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) MkdirAll(name string) error {
+	name = cleanName(name)
+	if name == "" {
+		return nil
+	}
+
+	// None of the directories can be a file
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		dir := strings.Join(parts[:i+1], "/")
+		if _, err := s.head(dir); err == nil {
+			return pathError("mkdir", dir, syscall.ENOTDIR)
+		}
+	}
+
+	if ok, err := s.isDir(name); err != nil || ok {
+		return err
+	}
+	if err := s.put(s.dirKey(name), nil); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+// s3File reads an object with ranged requests, starting a new one when
+// the file is read after seeking
+type s3File struct {
+	storage *S3
+	name    string
+	info    *s3Info
+	offset  int64
+	body    io.ReadCloser
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.info.isDir {
+		return 0, pathError("read", f.name, syscall.EISDIR)
+	}
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		header := http.Header{}
+		if f.offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", f.offset))
+		}
+		resp, err := f.storage.do(http.MethodGet, f.storage.key(f.name), nil, nil, header)
+		if err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+		f.body = resp.Body
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.info.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, pathError("seek", f.name, fs.ErrInvalid)
+	}
+
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *s3File) Close() error {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	return nil
+}
+
+// s3Writer uploads a file. Small files are uploaded in a single request
+// when committed, larger ones with a multipart upload as they are written.
+type s3Writer struct {
+	storage  *S3
+	name     string
+	buf      bytes.Buffer
+	uploadID string
+	parts    []completedPart
+	closed   bool
+}
+
+// completedPart is a part of a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+
+	size := w.storage.opts.PartSize
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), int(size)-w.buf.Len())
+		w.buf.Write(p[:n])
+		p = p[n:]
+		written += n
+
+		if int64(w.buf.Len()) == size {
+			if err := w.uploadPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// uploadPart uploads the buffered data as the next part of the upload,
+// starting it if needed
+func (w *s3Writer) uploadPart() error {
+	s := w.storage
+	key := s.key(w.name)
+
+	if w.uploadID == "" {
+		resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+		if err != nil {
+			return pathError("write", w.name, err)
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return pathError("write", w.name, err)
+		}
+		w.uploadID = result.UploadID
+	}
+
+	number := len(w.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {w.uploadID}}
+	resp, err := s.do(http.MethodPut, key, query, bytes.NewReader(w.buf.Bytes()), nil)
+	if err != nil {
+		return pathError("write", w.name, err)
+	}
+	resp.Body.Close()
+
+	w.parts = append(w.parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	w.buf.Reset()
+	return nil
+}
+
+func (w *s3Writer) Commit() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+
+	s := w.storage
+	key := s.key(w.name)
+	if w.uploadID == "" {
+		if err := s.put(key, w.buf.Bytes()); err != nil {
+			return pathError("write", w.name, err)
+		}
+		return nil
+	}
+
+	if w.buf.Len() > 0 {
+		if err := w.uploadPart(); err != nil {
+			w.abort()
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {w.uploadID}}, bytes.NewReader(body), nil)
+	if err != nil {
+		w.abort()
+		return pathError("write", w.name, err)
+	}
+	defer resp.Body.Close()
+
+	// Like copies, completing an upload can fail after the response started
+	var result struct {
+		XMLName xml.Name
+		s3Error
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return pathError("write", w.name, err)
+	}
+	if result.XMLName.Local == "Error" {
+		return pathError("write", w.name, &result.s3Error)
+	}
+	return nil
+}
+
+// abort discards the parts uploaded so far
+func (w *s3Writer) abort() {
+	if w.uploadID == "" {
+		return
+	}
+	resp, err := w.storage.do(http.MethodDelete, w.storage.key(w.name), url.Values{"uploadId": {w.uploadID}}, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+	w.uploadID = ""
+}
+
+func (w *s3Writer) Close() error {
+	if !w.closed {
+		w.closed = true
+		w.abort()
+	}
+	w.buf.Reset()
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package storage abstracts where the shared files are kept.
+//
+// Names are slash-separated paths relative to the root of the storage, with
+// "" for the root itself. Errors wrap fs.ErrNotExist, fs.ErrExist and the
+// other fs errors so that callers can check them with errors.Is.
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// File is a file opened for reading
+type File interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// Writer writes a new file. The file replaces any file with the same name
+// only when it's committed, so readers never see a partial file. Closing a
+// writer that wasn't committed discards what was written.
+type Writer interface {
+	io.Writer
+	Commit() error
+	Close() error
+}
+
+// Storage stores the shared files
+type Storage interface {
+	// Stat returns the information of a file or directory
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(name string) ([]fs.FileInfo, error)
+	// Open opens a file for reading
+	Open(name string) (File, error)
+	// Create starts writing a file in an existing directory
+	Create(name string) (Writer, error)
+	// Remove removes a file or an empty directory
+	Remove(name string) error
+	// RemoveAll removes a file or a directory with all its content
+	RemoveAll(name string) error
+	// Rename moves a file or directory, replacing any existing file
+	Rename(oldName, newName string) error
+	// MkdirAll creates a directory and any missing parents
+	MkdirAll(name string) error
+}
+
+// cleanName cleans a name. Names can't go above the root.
+func cleanName(name string) string {
+	return path.Clean("/" + name)[1:]
+}
+
+// WriteFile writes a file with the given content, creating its directory
+// if needed
+func WriteFile(s Storage, name string, data []byte) error {
+	if err := s.MkdirAll(path.Dir(cleanName(name))); err != nil {
+		return err
+	}
+	w, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// ReadFile returns the content of a file
+func ReadFile(s Storage, name string) ([]byte, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WalkFunc is called by Walk for each file and directory
+type WalkFunc func(name string, info fs.FileInfo) error
+
+// Walk calls fn for root and everything inside it, in lexical order. If fn
+// returns fs.SkipDir for a directory, its content is skipped.
+func Walk(s Storage, root string, fn WalkFunc) error {
+	info, err := s.Stat(root)
+	if err != nil {
+		return err
+	}
+	return walk(s, root, info, fn)
+}
+
+func walk(s Storage, name string, info fs.FileInfo, fn WalkFunc) error {
+	if err := fn(name, info); err != nil {
+		if err == fs.SkipDir && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := s.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := walk(s, path.Join(name, entry.Name()), entry, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortInfos sorts directory entries by name
+func sortInfos(infos []fs.FileInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.Compare(infos[i].Name(), infos[j].Name()) < 0
+	})
+}
@@ -0,0 +1,324 @@
+// Code generated by templ - DO NOT EDIT.
+
+// templ: version: v0.3.857
+package templates
+
+//lint:file-ignore SA4006 This context is only used if a nested component is present.
+
+import "github.com/a-h/templ"
+import templruntime "github.com/a-h/templ/runtime"
+
+// e2eScript implements the format of the files encrypted end to end (see the
+// e2e package) with the Web Crypto API
+func e2eScript() templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var1 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var1 == nil {
+			templ_7745c5c3_Var1 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<script nonce=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(templ.GetNonce(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 6, Col: 36}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\">\n\t\tconst e2e = {\n\t\t\tmagic: new TextEncoder().encode('SICE2E01'),\n\t\t\tchunkSize: 64 * 1024,\n\t\t\ttagSize: 16,\n\t\t\tflags: { chunk: 0, last: 1, metadata: 2 },\n\n\t\t\tnonce(index, flag) {\n\t\t\t\tconst nonce = new Uint8Array(12);\n\t\t\t\tnew DataView(nonce.buffer).setBigUint64(0, BigInt(index));\n\t\t\t\tnonce[11] = flag;\n\t\t\t\treturn nonce;\n\t\t\t},\n\t\t\tasync seal(key, index, flag, data) {\n\t\t\t\tconst params = { name: 'AES-GCM', iv: this.nonce(index, flag), additionalData: this.magic };\n\t\t\t\treturn new Uint8Array(await crypto.subtle.encrypt(params, key, data));\n\t\t\t},\n\t\t\tasync open(key, index, flag, data) {\n\t\t\t\tconst params = { name: 'AES-GCM', iv: this.nonce(index, flag), additionalData: this.magic };\n\t\t\t\ttry {\n\t\t\t\t\treturn new Uint8Array(await crypto.subtle.decrypt(params, key, data));\n\t\t\t\t} catch {\n\t\t\t\t\tthrow new Error('The file is damaged or the key is wrong');\n\t\t\t\t}\n\t\t\t},\n\t\t\tencodeKey(raw) {\n\t\t\t\treturn btoa(String.fromCharCode(...raw)).replace(/\\+/g, '-').replace(/\\//g, '_').replace(/=+$/, '');\n\t\t\t},\n\t\t\tdecodeKey(text) {\n\t\t\t\tconst raw = Uint8Array.from(atob(text.replace(/-/g, '+').replace(/_/g, '/')), c => c.charCodeAt(0));\n\t\t\t\tif (raw.length !== 32) {\n\t\t\t\t\tthrow new Error('The link has an invalid key');\n\t\t\t\t}\n\t\t\t\treturn raw;\n\t\t\t},\n\t\t\trandomName() {\n\t\t\t\tconst bytes = crypto.getRandomValues(new Uint8Array(16));\n\t\t\t\treturn Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('') + '.e2e';\n\t\t\t},\n\n\t\t\t// encrypt returns the encrypted file as a blob and its key\n\t\t\tasync encrypt(file) {\n\t\t\t\tconst raw = crypto.getRandomValues(new Uint8Array(32));\n\t\t\t\tconst key = await crypto.subtle.importKey('raw', raw, 'AES-GCM', false, ['encrypt']);\n\n\t\t\t\tconst metadata = new TextEncoder().encode(JSON.stringify({ name: file.name, type: file.type, size: file.size }));\n\t\t\t\tconst sealed = await this.seal(key, 0, this.flags.metadata, metadata);\n\t\t\t\tconst header = new Uint8Array(this.magic.length + 4);\n\t\t\t\theader.set(this.magic);\n\t\t\t\tnew DataView(header.buffer).setUint32(this.magic.length, sealed.length);\n\t\t\t\tconst parts = [header, sealed];\n\n\t\t\t\t// There is always a last chunk, even if it's empty\n\t\t\t\tconst chunks = Math.max(1, Math.ceil(file.size / this.chunkSize));\n\t\t\t\tfor (let i = 0; i < chunks; i++) {\n\t\t\t\t\tconst data = await file.slice(i * this.chunkSize, (i + 1) * this.chunkSize).arrayBuffer();\n\t\t\t\t\tparts.push(await this.seal(key, i, i === chunks - 1 ? this.flags.last : this.flags.chunk, data));\n\t\t\t\t}\n\t\t\t\treturn { blob: new Blob(parts), key: this.encodeKey(raw) };\n\t\t\t},\n\n\t\t\t// decrypt returns the metadata and the content of an encrypted file\n\t\t\tasync decrypt(buffer, text) {\n\t\t\t\tconst key = await crypto.subtle.importKey('raw', this.decodeKey(text), 'AES-GCM', false, ['decrypt']);\n\t\t\t\tconst data = new Uint8Array(buffer);\n\t\t\t\tconst view = new DataView(buffer);\n\t\t\t\tconst start = this.magic.length + 4;\n\t\t\t\tif (data.length < start || !this.magic.every((b, i) => data[i] === b)) {\n\t\t\t\t\tthrow new Error('The file is damaged or the key is wrong');\n\t\t\t\t}\n\n\t\t\t\tconst size = view.getUint32(this.magic.length);\n\t\t\t\tconst metadata = await this.open(key, 0, this.flags.metadata, data.subarray(start, start + size));\n\t\t\t\tconst meta = JSON.parse(new TextDecoder().decode(metadata));\n\n\t\t\t\tconst body = data.subarray(start + size);\n\t\t\t\tconst sealedSize = this.chunkSize + this.tagSize;\n\t\t\t\tconst chunks = Math.ceil(body.length / sealedSize);\n\t\t\t\tif (chunks === 0) {\n\t\t\t\t\tthrow new Error('The file is damaged or the key is wrong');\n\t\t\t\t}\n\t\t\t\tconst parts = [];\n\t\t\t\tfor (let i = 0; i < chunks; i++) {\n\t\t\t\t\tconst chunk = body.subarray(i * sealedSize, (i + 1) * sealedSize);\n\t\t\t\t\tparts.push(await this.open(key, i, i === chunks - 1 ? this.flags.last : this.flags.chunk, chunk));\n\t\t\t\t}\n\t\t\t\treturn { meta, blob: new Blob(parts, { type: meta.type || 'application/octet-stream' }) };\n\t\t\t},\n\t\t};\n\t</script>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// e2ePage is the layout of the pages that handle the keys of the files
+// encrypted end to end. Unlike the layout of the rest of the interface, it
+// doesn't load any third-party script or style, which could read the keys
+// and the content of the files.
+func e2ePage(title string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var3 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var3 == nil {
+			templ_7745c5c3_Var3 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><meta name=\"referrer\" content=\"no-referrer\"><title>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var4 string
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(title)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 109, Col: 16}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</title><style nonce=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var5 string
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(templ.GetNonce(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 110, Col: 36}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\">\n\t\t\tbody {\n\t\t\t\tmargin: 0;\n\t\t\t\tmin-height: 100vh;\n\t\t\t\tfont-family: system-ui, sans-serif;\n\t\t\t\tcolor: #64748b;\n\t\t\t\tbackground: linear-gradient(to bottom right, #f9fafb, #f3f4f6) fixed;\n\t\t\t}\n\t\t\tmain {\n\t\t\t\tmax-width: 32rem;\n\t\t\t\tmargin: 3rem auto;\n\t\t\t\tpadding: 1.5rem;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tbackground: #fff;\n\t\t\t\tbox-shadow: 0 1px 3px rgba(0, 0, 0, 0.1);\n\t\t\t\ttext-align: center;\n\t\t\t}\n\t\t\th1 {\n\t\t\t\tfont-size: 1.25rem;\n\t\t\t\tcolor: #111827;\n\t\t\t}\n\t\t\tp, label, ul {\n\t\t\t\tfont-size: 0.875rem;\n\t\t\t}\n\t\t\tform {\n\t\t\t\ttext-align: left;\n\t\t\t}\n\t\t\tlabel {\n\t\t\t\tdisplay: block;\n\t\t\t\tmargin-top: 1rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: #374151;\n\t\t\t}\n\t\t\tselect, input {\n\t\t\t\tbox-sizing: border-box;\n\t\t\t\twidth: 100%;\n\t\t\t\tmargin-top: 0.5rem;\n\t\t\t\tpadding: 0.375rem 0.5rem;\n\t\t\t\tborder: 1px solid #d1d5db;\n\t\t\t\tborder-radius: 0.375rem;\n\t\t\t\tfont: inherit;\n\t\t\t}\n\t\t\tul {\n\t\t\t\tpadding: 0;\n\t\t\t\tlist-style: none;\n\t\t\t\ttext-align: left;\n\t\t\t}\n\t\t\tli {\n\t\t\t\tmargin-top: 0.75rem;\n\t\t\t}\n\t\t\t.error {\n\t\t\t\tpadding: 1rem;\n\t\t\t\tborder-radius: 0.375rem;\n\t\t\t\tbackground: #fef2f2;\n\t\t\t\tcolor: #b91c1c;\n\t\t\t}\n\t\t\t.name {\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: #111827;\n\t\t\t\tword-break: break-all;\n\t\t\t}\n\t\t\t.button {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\tmargin-top: 1rem;\n\t\t\t\tpadding: 0.625rem 0.875rem;\n\t\t\t\tborder: 0;\n\t\t\t\tborder-radius: 0.375rem;\n\t\t\t\tbackground: #0284c7;\n\t\t\t\tcolor: #fff;\n\t\t\t\tfont: inherit;\n\t\t\t\tfont-weight: 600;\n\t\t\t\ttext-decoration: none;\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\t\t\t.button:hover {\n\t\t\t\tbackground: #0ea5e9;\n\t\t\t}\n\t\t\t.button:disabled {\n\t\t\t\topacity: 0.5;\n\t\t\t\tcursor: not-allowed;\n\t\t\t}\n\t\t\t@media (prefers-color-scheme: dark) {\n\t\t\t\tbody {\n\t\t\t\t\tcolor: #94a3b8;\n\t\t\t\t\tbackground: linear-gradient(to bottom right, #0f172a, #1e293b) fixed;\n\t\t\t\t}\n\t\t\t\tmain {\n\t\t\t\t\tbackground: #1e293b;\n\t\t\t\t}\n\t\t\t\th1, .name {\n\t\t\t\t\tcolor: #fff;\n\t\t\t\t}\n\t\t\t\tlabel {\n\t\t\t\t\tcolor: #d1d5db;\n\t\t\t\t}\n\t\t\t\tselect, input {\n\t\t\t\t\tborder-color: #374151;\n\t\t\t\t\tbackground: #0f172a;\n\t\t\t\t\tcolor: #fff;\n\t\t\t\t}\n\t\t\t\t.error {\n\t\t\t\t\tbackground: rgba(127, 29, 29, 0.3);\n\t\t\t\t\tcolor: #f87171;\n\t\t\t\t}\n\t\t\t}\n\t\t</style></head><body>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templ_7745c5c3_Var3.Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = e2eScript().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</body></html>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// E2E is the page that downloads and decrypts a file encrypted end to end.
+// The key is in the fragment of the link, which never reaches the server.
+func E2E(data E2EData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var6 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var6 == nil {
+			templ_7745c5c3_Var6 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var7 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<main id=\"e2e\" data-path=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var8 string
+			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(data.Path)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 228, Col: 38}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "\"><h1>Encrypted file</h1><p>This file was encrypted in the browser before it was uploaded. It's decrypted here with the key of the link, the server never sees its content.</p><p id=\"loading\">Decrypting...</p><p id=\"error\" class=\"error\" hidden></p><div id=\"ready\" hidden><p id=\"name\" class=\"name\"></p><p id=\"size\"></p><a id=\"save\" class=\"button\">Save file</a></div></main><script nonce=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var9 string
+			templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(templ.GetNonce(ctx))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 242, Col: 37}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "\">\n\t\t\tfunction formatSize(bytes) {\n\t\t\t\tconst units = ['B', 'KB', 'MB', 'GB', 'TB'];\n\t\t\t\tlet i = 0;\n\t\t\t\twhile (bytes >= 1024 && i < units.length - 1) {\n\t\t\t\t\tbytes /= 1024;\n\t\t\t\t\ti++;\n\t\t\t\t}\n\t\t\t\treturn bytes.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];\n\t\t\t}\n\n\t\t\twindow.addEventListener('DOMContentLoaded', async () => {\n\t\t\t\tconst show = id => {\n\t\t\t\t\tfor (const state of ['loading', 'error', 'ready']) {\n\t\t\t\t\t\tdocument.getElementById(state).hidden = state !== id;\n\t\t\t\t\t}\n\t\t\t\t};\n\t\t\t\ttry {\n\t\t\t\t\tconst key = window.location.hash.slice(1);\n\t\t\t\t\tif (!key) {\n\t\t\t\t\t\tthrow new Error('The link has no key, copy the whole link including the part after #');\n\t\t\t\t\t}\n\t\t\t\t\tconst path = document.getElementById('e2e').dataset.path;\n\t\t\t\t\tconst res = await fetch('/download?filename=' + encodeURIComponent(path), { referrerPolicy: 'no-referrer' });\n\t\t\t\t\tif (!res.ok) {\n\t\t\t\t\t\tthrow new Error('The file could not be downloaded (' + res.status + ')');\n\t\t\t\t\t}\n\t\t\t\t\tconst { meta, blob } = await e2e.decrypt(await res.arrayBuffer(), key);\n\t\t\t\t\tdocument.getElementById('name').textContent = meta.name;\n\t\t\t\t\tdocument.getElementById('size').textContent = formatSize(blob.size);\n\t\t\t\t\tconst save = document.getElementById('save');\n\t\t\t\t\tsave.href = URL.createObjectURL(blob);\n\t\t\t\t\tsave.download = meta.name;\n\t\t\t\t\tshow('ready');\n\t\t\t\t} catch (err) {\n\t\t\t\t\tdocument.getElementById('error').textContent = err.message;\n\t\t\t\t\tshow('error');\n\t\t\t\t}\n\t\t\t});\n\t\t</script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = e2ePage(data.Title+" - Encrypted file").Render(templ.WithChildren(ctx, templ_7745c5c3_Var7), templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// E2EUpload is the page that encrypts files end to end and uploads them.
+// The keys are made in the browser and only go in the share links.
+func E2EUpload(data UploadData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var10 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var10 == nil {
+			templ_7745c5c3_Var10 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var11 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+			if !templ_7745c5c3_IsBuffer {
+				defer func() {
+					templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err == nil {
+						templ_7745c5c3_Err = templ_7745c5c3_BufErr
+					}
+				}()
+			}
+			ctx = templ.InitializeContext(ctx)
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<main><h1>Encrypted upload</h1><p>The files are encrypted in this browser before the upload. Only people with their share link can decrypt them, not even the server.</p><form id=\"upload\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if len(data.Shares) > 0 {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<label for=\"share\">Share</label> <select id=\"share\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				for _, share := range data.Shares {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<option value=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var12 string
+					templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(share)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 299, Col: 28}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var13 string
+					templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(share)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 299, Col: 38}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "</option>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</select> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "<label for=\"expires\">Delete after</label> <select id=\"expires\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if data.FolderExpiry {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "<option value=\"\">Default of the folder</option> <option value=\"never\">Never</option> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<option value=\"\">Never</option> ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<option value=\"1h\">1 hour</option> <option value=\"24h\">1 day</option> <option value=\"168h\">1 week</option> <option value=\"720h\">30 days</option></select> <label for=\"files\">Files</label> <input id=\"files\" type=\"file\" multiple> <button id=\"submit\" type=\"submit\" class=\"button\" disabled>Encrypt and upload</button></form><p id=\"error\" class=\"error\" hidden></p><div id=\"links\" hidden><p>Share links (the key is only in the link, keep a copy):</p><ul id=\"link-list\"></ul></div><p><a href=\"/upload\">Back to the upload page</a></p></main><script nonce=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var14 string
+			templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(templ.GetNonce(ctx))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/e2e.templ`, Line: 328, Col: 37}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "\">\n\t\t\twindow.addEventListener('DOMContentLoaded', () => {\n\t\t\t\tconst form = document.getElementById('upload');\n\t\t\t\tconst files = document.getElementById('files');\n\t\t\t\tconst submit = document.getElementById('submit');\n\t\t\t\tconst error = document.getElementById('error');\n\n\t\t\t\tconst addLink = (name, url) => {\n\t\t\t\t\tconst item = document.createElement('li');\n\t\t\t\t\tconst label = document.createElement('span');\n\t\t\t\t\tlabel.className = 'name';\n\t\t\t\t\tlabel.textContent = name;\n\t\t\t\t\tconst input = document.createElement('input');\n\t\t\t\t\tinput.readOnly = true;\n\t\t\t\t\tinput.value = url;\n\t\t\t\t\tinput.addEventListener('focus', () => input.select());\n\t\t\t\t\tconst copy = document.createElement('button');\n\t\t\t\t\tcopy.type = 'button';\n\t\t\t\t\tcopy.className = 'button';\n\t\t\t\t\tcopy.textContent = 'Copy';\n\t\t\t\t\tcopy.addEventListener('click', () => navigator.clipboard.writeText(url));\n\t\t\t\t\titem.append(label, input, copy);\n\t\t\t\t\tdocument.getElementById('link-list').append(item);\n\t\t\t\t\tdocument.getElementById('links').hidden = false;\n\t\t\t\t};\n\n\t\t\t\tfiles.addEventListener('change', () => {\n\t\t\t\t\tsubmit.disabled = files.files.length === 0;\n\t\t\t\t});\n\t\t\t\tform.addEventListener('submit', async e => {\n\t\t\t\t\te.preventDefault();\n\t\t\t\t\tsubmit.disabled = true;\n\t\t\t\t\tsubmit.textContent = 'Uploading...';\n\t\t\t\t\terror.hidden = true;\n\t\t\t\t\tconst share = document.getElementById('share');\n\t\t\t\t\tconst dir = share ? share.value : '';\n\t\t\t\t\ttry {\n\t\t\t\t\t\tfor (const file of Array.from(files.files)) {\n\t\t\t\t\t\t\tconst sealed = await e2e.encrypt(file);\n\t\t\t\t\t\t\tconst name = e2e.randomName();\n\t\t\t\t\t\t\tconst body = new FormData();\n\t\t\t\t\t\t\tbody.append('files', sealed.blob, name);\n\t\t\t\t\t\t\tbody.append('expires', document.getElementById('expires').value);\n\t\t\t\t\t\t\tconst res = await fetch('/api/v1/upload?path=' + encodeURIComponent(dir), { method: 'POST', body });\n\t\t\t\t\t\t\tif (!res.ok) {\n\t\t\t\t\t\t\t\tthrow new Error((await res.json()).error);\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tconst path = dir ? dir + '/' + name : name;\n\t\t\t\t\t\t\taddLink(file.name, window.location.origin + '/e2e?filename=' + encodeURIComponent(path) + '#' + sealed.key);\n\t\t\t\t\t\t}\n\t\t\t\t\t\tfiles.value = '';\n\t\t\t\t\t} catch (err) {\n\t\t\t\t\t\terror.textContent = err.message;\n\t\t\t\t\t\terror.hidden = false;\n\t\t\t\t\t}\n\t\t\t\t\tsubmit.textContent = 'Encrypt and upload';\n\t\t\t\t\tsubmit.disabled = files.files.length === 0;\n\t\t\t\t});\n\t\t\t});\n\t\t</script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			return nil
+		})
+		templ_7745c5c3_Err = e2ePage(data.Title+" - Encrypted upload").Render(templ.WithChildren(ctx, templ_7745c5c3_Var11), templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+var _ = templruntime.GeneratedTemplate
@@ -0,0 +1,259 @@
+// Code generated by templ - DO NOT EDIT.
+
+// templ: version: v0.3.857
+package templates
+
+//lint:file-ignore SA4006 This context is only used if a nested component is present.
+
+import "github.com/a-h/templ"
+import templruntime "github.com/a-h/templ/runtime"
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// galleryLink returns the link that opens an image of the gallery
+func galleryLink(image GalleryImage) templ.SafeURL {
+	return templ.SafeURL("?" + url.Values{"image": {image.Name}}.Encode())
+}
+
+// galleryThumbnail returns the address of the thumbnail of an image
+func galleryThumbnail(image GalleryImage) string {
+	return "/thumbnail?" + url.Values{"filename": {image.Path}}.Encode()
+}
+
+// galleryItemStyle sizes a thumbnail so that rows fill the full width
+// keeping the aspect ratio of each image (justified layout)
+func galleryItemStyle(image GalleryImage) string {
+	ratio := float64(image.Width) / float64(image.Height)
+	return fmt.Sprintf("flex-grow: %.0f; width: %.0fpx", ratio*100, ratio*200)
+}
+
+// galleryItemPadding reserves the height of a thumbnail before it loads
+func galleryItemPadding(image GalleryImage) string {
+	return fmt.Sprintf("padding-bottom: %.4f%%", float64(image.Height)/float64(image.Width)*100)
+}
+
+// Gallery displays the images of a directory with a slideshow viewer
+func Gallery(data GalleryData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var1 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var1 == nil {
+			templ_7745c5c3_Var1 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div x-data=\"gallery()\" data-start=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(data.Start))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 34, Col: 37}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" x-init=\"init()\" @keydown.window=\"onKey($event)\" class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8 py-8\"><!-- Header --><div class=\"mb-8 flex items-center justify-between\"><div><h1 class=\"text-2xl font-bold text-gray-900 dark:text-white\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var3 string
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(data.Title)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 43, Col: 17}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</h1><p class=\"mt-1 text-sm text-gray-500 dark:text-gray-400\">Gallery: ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var4 string
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs("/" + data.Directory)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 46, Col: 36}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, " · ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var5 string
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(len(data.Images)))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 46, Col: 72}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, " images</p></div><div class=\"flex items-center space-x-4\"><a href=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var6 templ.SafeURL = templ.SafeURL("/browse/" + data.Directory)
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(string(templ_7745c5c3_Var6)))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "\" class=\"rounded-md bg-white dark:bg-transparent px-3 py-2 text-sm font-semibold text-gray-900 dark:text-white shadow-sm ring-1 ring-inset ring-gray-300 dark:ring-gray-700 hover:bg-gray-50 dark:hover:bg-gray-800 transition-colors\"><i class=\"fas fa-folder-open mr-1\"></i> Back to folder</a> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if len(data.Images) > 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<button type=\"button\" @click=\"open(0); play()\" class=\"inline-flex items-center rounded-md bg-primary-600 px-3 py-2 text-sm font-semibold text-white shadow-sm hover:bg-primary-500\"><i class=\"fas fa-play -ml-0.5 mr-1.5\"></i> Slideshow</button>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</div></div><!-- Justified grid --><div class=\"flex flex-wrap gap-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		for i, image := range data.Images {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<a href=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var7 templ.SafeURL = galleryLink(image)
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(string(templ_7745c5c3_Var7)))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\" data-gallery-item data-index=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var8 string
+			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(i))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 74, Col: 31}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "\" data-name=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var9 string
+			templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(image.Name)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 75, Col: 27}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "\" data-path=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var10 string
+			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(image.Path)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 76, Col: 27}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "\" @click.prevent=\"open(Number($el.dataset.index))\" style=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var11 string
+			templ_7745c5c3_Var11, templ_7745c5c3_Err = templruntime.SanitizeStyleAttributeValues(galleryItemStyle(image))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 78, Col: 36}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "\" class=\"relative block overflow-hidden rounded-md bg-gray-100 dark:bg-slate-700\"><i class=\"block\" style=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var12 string
+			templ_7745c5c3_Var12, templ_7745c5c3_Err = templruntime.SanitizeStyleAttributeValues(galleryItemPadding(image))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 81, Col: 55}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "\"></i> <img src=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var13 string
+			templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(galleryThumbnail(image))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 83, Col: 35}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "\" alt=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var14 string
+			templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(image.Name)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/gallery.templ`, Line: 84, Col: 22}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "\" loading=\"lazy\" class=\"absolute inset-0 w-full h-full object-cover hover:opacity-90 transition-opacity\"></a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<!-- Keeps the last row from stretching --><div class=\"flex-grow-[10]\"></div></div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if len(data.Images) == 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<div class=\"text-center py-12\"><div class=\"mx-auto h-12 w-12 text-gray-400\"><i class=\"fas fa-images text-3xl\"></i></div><h3 class=\"mt-2 text-sm font-semibold text-gray-900 dark:text-white\">No images</h3><p class=\"mt-1 text-sm text-gray-500 dark:text-gray-400\">This folder doesn't contain any images.</p></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "<!-- Viewer --><div x-ref=\"viewer\" x-show=\"current !== null\" class=\"fixed inset-0 z-50 flex flex-col bg-black/95\"><div class=\"flex items-center justify-between px-4 py-3 text-white\"><div class=\"truncate text-sm\"><span x-text=\"items[current]?.name\"></span> <span class=\"ml-2 text-gray-400\" x-text=\"(current + 1) + &#39; / &#39; + items.length\"></span></div><div class=\"flex items-center space-x-4\"><button type=\"button\" title=\"Play/Pause (space)\" @click=\"toggle()\"><i class=\"fas\" :class=\"playing ? &#39;fa-pause&#39; : &#39;fa-play&#39;\"></i></button> <button type=\"button\" title=\"Fullscreen (f)\" @click=\"fullscreen()\"><i class=\"fas fa-expand\"></i></button> <a :href=\"&#39;/download?filename=&#39; + encodeURIComponent(items[current]?.path ?? &#39;&#39;)\" title=\"Download\"><i class=\"fas fa-download\"></i></a> <button type=\"button\" title=\"Close (esc)\" @click=\"close()\"><i class=\"fas fa-times\"></i></button></div></div><div class=\"relative flex flex-1 items-center justify-center overflow-hidden\"><button type=\"button\" class=\"absolute left-2 z-10 p-4 text-3xl text-white/70 hover:text-white\" @click=\"prev()\"><i class=\"fas fa-chevron-left\"></i></button><template x-if=\"current !== null\"><img :src=\"&#39;/preview?filename=&#39; + encodeURIComponent(items[current].path)\" :alt=\"items[current].name\" class=\"max-h-full max-w-full object-contain\"></template><button type=\"button\" class=\"absolute right-2 z-10 p-4 text-3xl text-white/70 hover:text-white\" @click=\"next()\"><i class=\"fas fa-chevron-right\"></i></button></div></div></div><script>\n\t\tfunction gallery() {\n\t\t\treturn {\n\t\t\t\titems: [],\n\t\t\t\tcurrent: null,\n\t\t\t\tplaying: false,\n\t\t\t\ttimer: null,\n\t\t\t\tinterval: 4000,\n\t\t\t\tinit() {\n\t\t\t\t\tthis.items = Array.from(this.$el.querySelectorAll('[data-gallery-item]')).map(el => ({\n\t\t\t\t\t\tname: el.dataset.name,\n\t\t\t\t\t\tpath: el.dataset.path,\n\t\t\t\t\t}));\n\t\t\t\t\tconst start = Number(this.$el.dataset.start);\n\t\t\t\t\tif (start >= 0) {\n\t\t\t\t\t\tthis.open(start);\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\topen(index) {\n\t\t\t\t\tif (this.items.length === 0) {\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\tthis.current = (index + this.items.length) % this.items.length;\n\t\t\t\t\t// Every image has its own URL so it can be shared\n\t\t\t\t\tconst url = new URL(window.location);\n\t\t\t\t\turl.searchParams.set('image', this.items[this.current].name);\n\t\t\t\t\thistory.replaceState(null, '', url);\n\t\t\t\t},\n\t\t\t\tclose() {\n\t\t\t\t\tthis.current = null;\n\t\t\t\t\tthis.pause();\n\t\t\t\t\tconst url = new URL(window.location);\n\t\t\t\t\turl.searchParams.delete('image');\n\t\t\t\t\thistory.replaceState(null, '', url);\n\t\t\t\t\tif (document.fullscreenElement) {\n\t\t\t\t\t\tdocument.exitFullscreen();\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\tnext() {\n\t\t\t\t\tthis.open(this.current + 1);\n\t\t\t\t},\n\t\t\t\tprev() {\n\t\t\t\t\tthis.open(this.current - 1);\n\t\t\t\t},\n\t\t\t\tplay() {\n\t\t\t\t\tthis.playing = true;\n\t\t\t\t\tclearInterval(this.timer);\n\t\t\t\t\tthis.timer = setInterval(() => this.next(), this.interval);\n\t\t\t\t},\n\t\t\t\tpause() {\n\t\t\t\t\tthis.playing = false;\n\t\t\t\t\tclearInterval(this.timer);\n\t\t\t\t},\n\t\t\t\ttoggle() {\n\t\t\t\t\tthis.playing ? this.pause() : this.play();\n\t\t\t\t},\n\t\t\t\tfullscreen() {\n\t\t\t\t\tif (document.fullscreenElement) {\n\t\t\t\t\t\tdocument.exitFullscreen();\n\t\t\t\t\t} else {\n\t\t\t\t\t\tthis.$refs.viewer.requestFullscreen();\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t\tonKey(event) {\n\t\t\t\t\tif (this.current === null) {\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\tswitch (event.key) {\n\t\t\t\t\tcase 'ArrowRight':\n\t\t\t\t\t\tthis.next();\n\t\t\t\t\t\tbreak;\n\t\t\t\t\tcase 'ArrowLeft':\n\t\t\t\t\t\tthis.prev();\n\t\t\t\t\t\tbreak;\n\t\t\t\t\tcase ' ':\n\t\t\t\t\t\tevent.preventDefault();\n\t\t\t\t\t\tthis.toggle();\n\t\t\t\t\t\tbreak;\n\t\t\t\t\tcase 'f':\n\t\t\t\t\t\tthis.fullscreen();\n\t\t\t\t\t\tbreak;\n\t\t\t\t\tcase 'Escape':\n\t\t\t\t\t\tthis.close();\n\t\t\t\t\t\tbreak;\n\t\t\t\t\t}\n\t\t\t\t},\n\t\t\t};\n\t\t}\n\t</script>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+var _ = templruntime.GeneratedTemplate
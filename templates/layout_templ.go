@@ -91,17 +91,27 @@ func LayoutWithData(data LayoutData) templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</span> <a href=\"/upload\" class=\"group inline-flex items-center rounded-full bg-primary-600 px-4 py-2 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 transition-all duration-200 hover:scale-105\"><i class=\"fas fa-upload mr-2 group-hover:animate-pulse\"></i> Upload</a> <a href=\"/logout\" class=\"group inline-flex items-center rounded-md text-sm font-medium text-gray-700 dark:text-gray-300 hover:text-gray-900 dark:hover:text-white\"><i class=\"fas fa-sign-out-alt mr-1\"></i> <span class=\"hidden sm:inline\">Logout</span></a>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</span> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if !data.ReadOnly {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<a href=\"/upload\" class=\"group inline-flex items-center rounded-full bg-primary-600 px-4 py-2 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 transition-all duration-200 hover:scale-105\"><i class=\"fas fa-upload mr-2 group-hover:animate-pulse\"></i> Upload</a>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, " <a href=\"/settings/tokens\" title=\"API tokens\" class=\"group inline-flex items-center rounded-md text-sm font-medium text-gray-700 dark:text-gray-300 hover:text-gray-900 dark:hover:text-white\"><i class=\"fas fa-key mr-1\"></i> <span class=\"hidden sm:inline\">Tokens</span></a> <a href=\"/logout\" class=\"group inline-flex items-center rounded-md text-sm font-medium text-gray-700 dark:text-gray-300 hover:text-gray-900 dark:hover:text-white\"><i class=\"fas fa-sign-out-alt mr-1\"></i> <span class=\"hidden sm:inline\">Logout</span></a>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		} else {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<a href=\"/login\" class=\"group inline-flex items-center rounded-full bg-primary-600 px-4 py-2 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 transition-all duration-200 hover:scale-105\"><i class=\"fas fa-sign-in-alt mr-2 group-hover:animate-pulse\"></i> Log in</a>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<a href=\"/login\" class=\"group inline-flex items-center rounded-full bg-primary-600 px-4 py-2 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 transition-all duration-200 hover:scale-105\"><i class=\"fas fa-sign-in-alt mr-2 group-hover:animate-pulse\"></i> Log in</a>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div></div></div></header><main class=\"flex-grow\"><div class=\"mx-auto max-w-7xl py-6 sm:px-6 lg:px-8\"><div class=\"px-4 sm:px-0\"><div class=\"overflow-hidden rounded-xl bg-white shadow dark:bg-slate-800 ring-1 ring-slate-200 dark:ring-slate-800\"><div class=\"p-6\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</div></div></div></header><main class=\"flex-grow\"><div class=\"mx-auto max-w-7xl py-6 sm:px-6 lg:px-8\"><div class=\"px-4 sm:px-0\"><div class=\"overflow-hidden rounded-xl bg-white shadow dark:bg-slate-800 ring-1 ring-slate-200 dark:ring-slate-800\"><div class=\"p-6\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -109,7 +119,7 @@ func LayoutWithData(data LayoutData) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</div></div></div></div></main><footer class=\"py-4 bg-transparent\"><div class=\"mx-auto max-w-7xl px-4 sm:px-6 lg:px-8\"><p class=\"text-center text-sm text-gray-500 dark:text-slate-500\">ShareIsCare — Sharing files has never been easier</p></div></footer></div></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</div></div></div></div></main><footer class=\"py-4 bg-transparent\"><div class=\"mx-auto max-w-7xl px-4 sm:px-6 lg:px-8\"><p class=\"text-center text-sm text-gray-500 dark:text-slate-500\">ShareIsCare — Sharing files has never been easier</p></div></footer></div></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
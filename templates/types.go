@@ -13,13 +13,41 @@ const (
 // FileInfo contiene información sobre un archivo para mostrar en el listado
 type FileInfo struct {
 	Name     string
+	Title    string // Title of a share (optional)
 	Path     string
 	Size     string
+	Modified string
+	Expires  string // Remaining lifetime, e.g. "in 3 days" (empty if it doesn't expire)
 	IsDir    bool
 	IsAdmin  bool
+	ReadOnly bool // The file can't be deleted
 	FileType FileType
 }
 
+// ImageDetails contiene los metadatos de una imagen
+type ImageDetails struct {
+	Dimensions string
+	Taken      string
+	Camera     string
+	Lens       string
+	HasGPS     bool
+	Latitude   string
+	Longitude  string
+}
+
+// FileDetails estructura para pasar datos al panel de detalles de un archivo
+type FileDetails struct {
+	Name        string
+	Path        string
+	Size        string
+	Modified    string
+	Permissions string
+	SHA256      string
+	IsDir       bool
+	Image       *ImageDetails
+	CanStripGPS bool
+}
+
 // Breadcrumb estructura para representar un elemento del breadcrumb
 type Breadcrumb struct {
 	Name string
@@ -32,14 +60,97 @@ type IndexData struct {
 	Directory   string
 	Files       []FileInfo
 	Breadcrumbs []Breadcrumb
+	HasImages   bool
+	ReadOnly    bool // Nothing can be uploaded to the directory
 }
 
-// UploadData estructura para pasar datos a la plantilla de subida
-type UploadData struct {
+// GalleryImage contiene la información de una imagen de la galería
+type GalleryImage struct {
+	Name   string
+	Path   string
+	Width  int
+	Height int
+}
+
+// GalleryData estructura para pasar datos a la plantilla de galería
+type GalleryData struct {
 	Title     string
 	Directory string
-	Success   bool
-	Message   string
+	Images    []GalleryImage
+	Start     int // Índice de la imagen abierta al cargar la página (-1 si ninguna)
+}
+
+// UsageEntry representa una carpeta o archivo en la página de uso de disco
+type UsageEntry struct {
+	Path    string
+	Link    string
+	Size    string
+	Files   int
+	Percent int // Porcentaje respecto al total compartido
+}
+
+// UsageData estructura para pasar datos a la plantilla de uso de disco
+type UsageData struct {
+	Title           string
+	Scanned         bool // Indica si ya terminó el primer escaneo
+	ScannedAt       string
+	SharedSize      string
+	SharedFiles     int
+	DiskTotal       string
+	DiskFree        string
+	DiskUsedPercent int
+	Folders         []UsageEntry
+	Files           []UsageEntry
+}
+
+// TokenInfo contiene la información de un token de API para la página de ajustes
+type TokenInfo struct {
+	ID       string
+	Name     string
+	Scopes   string
+	Created  string
+	Expires  string
+	LastUsed string
+	Expired  bool
+}
+
+// TokensData estructura para pasar datos a la página de tokens de API
+type TokensData struct {
+	Title    string
+	Tokens   []TokenInfo
+	Scopes   []string
+	NewToken string // Secreto del token recién creado, solo se muestra una vez
+	NewName  string
+	Error    string
+}
+
+// UploadData estructura para pasar datos a la plantilla de subida
+type UploadData struct {
+	Title         string
+	Directory     string
+	Shares        []string     // Shares that accept uploads, empty without shares
+	Quotas        []QuotaUsage // Quotas of the user and the folders, empty without quotas
+	Success       bool
+	Message       string
+	QuotaExceeded bool // Some files didn't fit in a quota
+	FolderExpiry  bool // Some folders have a default lifetime for the uploads
+}
+
+// QuotaUsage estructura con el uso de una cuota para la página de subida
+type QuotaUsage struct {
+	Name     string // Usuario o carpeta de la cuota
+	Used     string // Tamaño usado formateado
+	Limit    string // Tamaño máximo formateado, vacío sin límite
+	Files    int    // Número de archivos
+	MaxFiles int    // Número máximo de archivos, 0 sin límite
+	Percent  int    // Porcentaje usado del límite más cercano
+}
+
+// E2EData estructura para pasar datos a la página de un archivo cifrado de
+// extremo a extremo
+type E2EData struct {
+	Title string
+	Path  string // Ruta del archivo cifrado
 }
 
 // LoginData estructura para pasar datos a la plantilla de login
@@ -54,4 +165,5 @@ type LayoutData struct {
 	Title      string
 	IsLoggedIn bool
 	Username   string
+	ReadOnly   bool // Nothing can be uploaded
 }
@@ -8,8 +8,21 @@ package templates
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
-// Upload is the page for uploading files
-func Upload(data UploadData) templ.Component {
+import "fmt"
+
+// quotaBarClass colors a quota bar by how full it is
+func quotaBarClass(percent int) string {
+	switch {
+	case percent >= 100:
+		return "h-2 rounded-full bg-red-500"
+	case percent >= 80:
+		return "h-2 rounded-full bg-amber-500"
+	}
+	return "h-2 rounded-full bg-primary-500"
+}
+
+// quotaList displays the usage of the quotas that apply to the uploads
+func quotaList(quotas []QuotaUsage) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -30,61 +43,342 @@ func Upload(data UploadData) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div><div class=\"sm:flex sm:items-center\"><div class=\"sm:flex-auto\"><h1 class=\"text-2xl font-semibold leading-6 text-gray-900 dark:text-white\">Upload files</h1><p class=\"mt-2 text-sm text-gray-700 dark:text-gray-300\">From here you can upload files to the directory: <span class=\"font-medium text-gray-900 dark:text-white\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"mt-6 grid grid-cols-1 gap-4 sm:grid-cols-2\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var2 string
-		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(data.Directory)
+		for _, q := range quotas {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<div class=\"rounded-lg bg-white dark:bg-slate-800 shadow-sm ring-1 ring-gray-200 dark:ring-gray-700 p-4\"><div class=\"flex items-center justify-between text-sm mb-2\"><span class=\"font-medium text-gray-900 dark:text-white\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var2 string
+			templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(q.Name)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 22, Col: 69}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</span> <span class=\"text-gray-500 dark:text-gray-400 whitespace-nowrap\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if q.Limit != "" {
+				var templ_7745c5c3_Var3 string
+				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(q.Used)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 25, Col: 15}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, " of ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(q.Limit)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 25, Col: 30}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, " ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				var templ_7745c5c3_Var5 string
+				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(q.Used)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 27, Col: 15}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, " ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			if q.MaxFiles > 0 {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "· ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var6 string
+				templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(q.Files))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 30, Col: 31}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, " of ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var7 string
+				templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(q.MaxFiles))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 30, Col: 61}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, " files")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "· ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var8 string
+				templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(q.Files))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 32, Col: 31}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, " files")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "</span></div><div class=\"w-full h-2 rounded-full bg-gray-100 dark:bg-gray-700\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var9 = []any{quotaBarClass(q.Percent)}
+			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var9...)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<div class=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var10 string
+			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var9).String())
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 1, Col: 0}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "\" style=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var11 string
+			templ_7745c5c3_Var11, templ_7745c5c3_Err = templruntime.SanitizeStyleAttributeValues(usageBarStyle(q.Percent))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 37, Col: 77}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "\"></div></div></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</div>")
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 10, Col: 126}
+			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		return nil
+	})
+}
+
+// Upload is the page for uploading files
+func Upload(data UploadData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var12 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var12 == nil {
+			templ_7745c5c3_Var12 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "<div><div class=\"sm:flex sm:items-center\"><div class=\"sm:flex-auto\"><h1 class=\"text-2xl font-semibold leading-6 text-gray-900 dark:text-white\">Upload files</h1>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</span></p></div></div>")
+		if len(data.Shares) > 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<p class=\"mt-2 text-sm text-gray-700 dark:text-gray-300\">From here you can upload files to one of the shares</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<p class=\"mt-2 text-sm text-gray-700 dark:text-gray-300\">From here you can upload files to the directory: <span class=\"font-medium text-gray-900 dark:text-white\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var13 string
+			templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(data.Directory)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 56, Col: 127}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "</span></p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "</div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		if data.Success {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div class=\"mt-6 rounded-md bg-green-50 dark:bg-green-900/30 p-4\"><div class=\"flex\"><div class=\"flex-shrink-0\"><i class=\"fas fa-check-circle text-green-400 dark:text-green-500 h-5 w-5\"></i></div><div class=\"ml-3\"><h3 class=\"text-sm font-medium text-green-800 dark:text-green-300\">Success</h3><div class=\"mt-2 text-sm text-green-700 dark:text-green-400\"><p>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<div class=\"mt-6 rounded-md bg-green-50 dark:bg-green-900/30 p-4\"><div class=\"flex\"><div class=\"flex-shrink-0\"><i class=\"fas fa-check-circle text-green-400 dark:text-green-500 h-5 w-5\"></i></div><div class=\"ml-3\"><h3 class=\"text-sm font-medium text-green-800 dark:text-green-300\">Success</h3><div class=\"mt-2 text-sm text-green-700 dark:text-green-400\"><p>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var3 string
-			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(data.Message)
+			var templ_7745c5c3_Var14 string
+			templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(data.Message)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 24, Col: 24}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 71, Col: 24}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</p></div></div></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</p></div></div></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		} else if data.Message != "" {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<div class=\"mt-6 rounded-md bg-red-50 dark:bg-red-900/30 p-4\"><div class=\"flex\"><div class=\"flex-shrink-0\"><i class=\"fas fa-exclamation-circle text-red-400 dark:text-red-500 h-5 w-5\"></i></div><div class=\"ml-3\"><h3 class=\"text-sm font-medium text-red-800 dark:text-red-300\">Error</h3><div class=\"mt-2 text-sm text-red-700 dark:text-red-400\"><p>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "<div class=\"mt-6 rounded-md bg-red-50 dark:bg-red-900/30 p-4\"><div class=\"flex\"><div class=\"flex-shrink-0\"><i class=\"fas fa-exclamation-circle text-red-400 dark:text-red-500 h-5 w-5\"></i></div><div class=\"ml-3\"><h3 class=\"text-sm font-medium text-red-800 dark:text-red-300\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if data.QuotaExceeded {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "Quota exceeded")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "Error")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "</h3><div class=\"mt-2 text-sm text-red-700 dark:text-red-400\"><p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var15 string
+			templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinStringErrs(data.Message)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 91, Col: 24}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "</p></div></div></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var4 string
-			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(data.Message)
+		}
+		if len(data.Quotas) > 0 {
+			templ_7745c5c3_Err = quotaList(data.Quotas).Render(ctx, templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 38, Col: 24}
+				return templ_7745c5c3_Err
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "<div x-data=\"{\n\t\t\tdragOver: false,\n\t\t\tfiles: [],\n\t\t\tuploading: false,\n\t\t\thandleDrop(e) {\n\t\t\t\te.preventDefault();\n\t\t\t\tthis.dragOver = false;\n\t\t\t\tif (e.dataTransfer.files.length &gt; 0) {\n\t\t\t\t\tthis.files = e.dataTransfer.files;\n\t\t\t\t\tdocument.getElementById(&#39;files&#39;).files = e.dataTransfer.files;\n\t\t\t\t}\n\t\t\t},\n\t\t\tremoveFile(index) {\n\t\t\t\t// We cannot modify FileList directly, this is only visual\n\t\t\t\tthis.files = Array.from(this.files).filter((_, i) =&gt; i !== index);\n\t\t\t},\n\t\t\tsubmitForm() {\n\t\t\t\tthis.uploading = true;\n\t\t\t\tdocument.getElementById(&#39;upload-form&#39;).submit();\n\t\t\t}\n\t\t}\" class=\"mt-8\"><form id=\"upload-form\" method=\"post\" action=\"/upload\" enctype=\"multipart/form-data\" @submit=\"uploading = true\" class=\"space-y-8\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if len(data.Shares) > 0 {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "<div><label for=\"share\" class=\"block text-sm font-medium text-gray-700 dark:text-gray-300\">Share</label> <select id=\"share\" name=\"share\" class=\"mt-2 block w-full rounded-md border-0 py-1.5 pl-3 pr-10 text-gray-900 dark:text-white dark:bg-slate-800 ring-1 ring-inset ring-gray-300 dark:ring-gray-700 focus:ring-2 focus:ring-primary-600 sm:text-sm\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, share := range data.Shares {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "<option value=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var16 string
+				templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(share)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 140, Col: 29}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var17 string
+				templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(share)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/upload.templ`, Line: 140, Col: 39}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "</option>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "</select></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "<div><label for=\"expires\" class=\"block text-sm font-medium text-gray-700 dark:text-gray-300\">Delete after</label> <select id=\"expires\" name=\"expires\" class=\"mt-2 block w-full rounded-md border-0 py-1.5 pl-3 pr-10 text-gray-900 dark:text-white dark:bg-slate-800 ring-1 ring-inset ring-gray-300 dark:ring-gray-700 focus:ring-2 focus:ring-primary-600 sm:text-sm\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if data.FolderExpiry {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "<option value=\"\">Default of the folder</option> <option value=\"never\">Never</option> ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</p></div></div></div></div>")
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, "<option value=\"\">Never</option> ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<div x-data=\"{\n\t\t\tdragOver: false,\n\t\t\tfiles: [],\n\t\t\tuploading: false,\n\t\t\thandleDrop(e) {\n\t\t\t\te.preventDefault();\n\t\t\t\tthis.dragOver = false;\n\t\t\t\tif (e.dataTransfer.files.length &gt; 0) {\n\t\t\t\t\tthis.files = e.dataTransfer.files;\n\t\t\t\t\tdocument.getElementById(&#39;files&#39;).files = e.dataTransfer.files;\n\t\t\t\t}\n\t\t\t},\n\t\t\tremoveFile(index) {\n\t\t\t\t// We cannot modify FileList directly, this is only visual\n\t\t\t\tthis.files = Array.from(this.files).filter((_, i) =&gt; i !== index);\n\t\t\t},\n\t\t\tsubmitForm() {\n\t\t\t\tthis.uploading = true;\n\t\t\t\tdocument.getElementById(&#39;upload-form&#39;).submit();\n\t\t\t}\n\t\t}\" class=\"mt-8\"><form id=\"upload-form\" method=\"post\" action=\"/upload\" enctype=\"multipart/form-data\" @submit=\"uploading = true\" class=\"space-y-8\"><div @dragover.prevent=\"dragOver = true\" @dragleave.prevent=\"dragOver = false\" @drop=\"handleDrop\" :class=\"{&#39;border-primary-400 bg-primary-50 dark:bg-primary-900/20&#39;: dragOver}\" class=\"mt-2 flex justify-center rounded-lg border border-dashed border-gray-300 dark:border-gray-700 px-6 py-10 transition-colors duration-200\"><div class=\"text-center\"><i class=\"fas fa-cloud-upload-alt mx-auto h-12 w-12 text-gray-400 dark:text-gray-500\"></i><div class=\"mt-4 flex text-sm leading-6 text-gray-600 dark:text-gray-400\"><label for=\"files\" class=\"relative cursor-pointer rounded-md bg-white dark:bg-slate-800 font-semibold text-primary-600 dark:text-primary-500 focus-within:outline-none focus-within:ring-2 focus-within:ring-primary-600 focus-within:ring-offset-2 hover:text-primary-500 dark:hover:text-primary-400 transition-colors\"><span>Select files</span> <input id=\"files\" name=\"files\" type=\"file\" multiple @change=\"files = $event.target.files\" class=\"sr-only\"></label><p class=\"pl-1\">or drag and drop</p></div><p class=\"text-xs leading-5 text-gray-600 dark:text-gray-400\">Files up to 32MB</p></div></div><!-- Preview of selected files --><div x-show=\"files.length &gt; 0\" class=\"mt-4\"><h3 class=\"text-sm font-medium text-gray-700 dark:text-gray-300 mb-2\">Selected files:</h3><ul class=\"divide-y divide-gray-200 dark:divide-gray-700 border border-gray-200 dark:border-gray-700 rounded-md overflow-hidden\"><template x-for=\"(file, index) in Array.from(files)\" :key=\"index\"><li class=\"px-4 py-3 flex items-center justify-between bg-white dark:bg-slate-800 hover:bg-gray-50 dark:hover:bg-slate-700/50 transition-colors\"><div class=\"flex items-center max-w-xs sm:max-w-lg\"><i class=\"fas fa-file text-primary-500 mr-3\"></i> <span class=\"text-sm text-gray-900 dark:text-white truncate\" x-text=\"file.name\"></span></div><div class=\"flex items-center\"><span class=\"text-xs text-gray-500 dark:text-gray-400 mr-3\" x-text=\"formatBytes(file.size)\"></span> <button type=\"button\" @click=\"removeFile(index)\" class=\"text-red-500 hover:text-red-700 dark:hover:text-red-300 transition-colors\"><i class=\"fas fa-times\"></i></button></div></li></template></ul></div><div class=\"flex justify-end\"><a href=\"/\" class=\"rounded-md bg-white dark:bg-transparent px-3.5 py-2.5 text-sm font-semibold text-gray-900 dark:text-white shadow-sm ring-1 ring-inset ring-gray-300 dark:ring-gray-700 hover:bg-gray-50 dark:hover:bg-gray-800 mr-3 transition-colors\">Cancel</a> <button type=\"submit\" :disabled=\"uploading || files.length === 0\" :class=\"{&#39;opacity-50 cursor-not-allowed&#39;: uploading || files.length === 0}\" class=\"rounded-md bg-primary-600 px-3.5 py-2.5 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 focus-visible:outline focus-visible:outline-2 focus-visible:outline-offset-2 focus-visible:outline-primary-600 transition-colors\"><span x-show=\"!uploading\"><i class=\"fas fa-upload mr-1\"></i> Upload</span> <span x-show=\"uploading\"><i class=\"fas fa-spinner fa-spin mr-1\"></i> Uploading...</span></button></div></form></div></div><script>\n\t\tfunction formatBytes(bytes, decimals = 2) {\n\t\t\tif (bytes === 0) return '0 Bytes';\n\n\t\t\tconst k = 1024;\n\t\t\tconst dm = decimals < 0 ? 0 : decimals;\n\t\t\tconst sizes = ['Bytes', 'KB', 'MB', 'GB', 'TB'];\n\n\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\n\t\t\treturn parseFloat((bytes / Math.pow(k, i)).toFixed(dm)) + ' ' + sizes[i];\n\t\t}\n\t</script>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 38, "<option value=\"1h\">1 hour</option> <option value=\"24h\">1 day</option> <option value=\"168h\">1 week</option> <option value=\"720h\">30 days</option></select></div><div @dragover.prevent=\"dragOver = true\" @dragleave.prevent=\"dragOver = false\" @drop=\"handleDrop\" :class=\"{&#39;border-primary-400 bg-primary-50 dark:bg-primary-900/20&#39;: dragOver}\" class=\"mt-2 flex justify-center rounded-lg border border-dashed border-gray-300 dark:border-gray-700 px-6 py-10 transition-colors duration-200\"><div class=\"text-center\"><i class=\"fas fa-cloud-upload-alt mx-auto h-12 w-12 text-gray-400 dark:text-gray-500\"></i><div class=\"mt-4 flex text-sm leading-6 text-gray-600 dark:text-gray-400\"><label for=\"files\" class=\"relative cursor-pointer rounded-md bg-white dark:bg-slate-800 font-semibold text-primary-600 dark:text-primary-500 focus-within:outline-none focus-within:ring-2 focus-within:ring-primary-600 focus-within:ring-offset-2 hover:text-primary-500 dark:hover:text-primary-400 transition-colors\"><span>Select files</span> <input id=\"files\" name=\"files\" type=\"file\" multiple @change=\"files = $event.target.files\" class=\"sr-only\"></label><p class=\"pl-1\">or drag and drop</p></div><p class=\"text-xs leading-5 text-gray-600 dark:text-gray-400\">Files up to 32MB</p></div></div><!-- Optional integrity verification --><details class=\"text-sm text-gray-700 dark:text-gray-300\"><summary class=\"cursor-pointer font-medium\">Verify integrity (optional)</summary><p class=\"mt-2 text-xs text-gray-500 dark:text-gray-400\">Paste the SHA-256 checksums of the files in <code>sha256sum</code> format. Files that don't match are not saved.</p><textarea name=\"checksums\" rows=\"3\" placeholder=\"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  file.zip\" class=\"mt-2 block w-full rounded-md border-0 py-1.5 font-mono text-xs text-gray-900 dark:text-white dark:bg-slate-900 shadow-sm ring-1 ring-inset ring-gray-300 dark:ring-gray-700 focus:ring-2 focus:ring-inset focus:ring-primary-600\"></textarea></details><!-- End-to-end encryption, on a page without third-party scripts --><div class=\"text-sm\"><a href=\"/upload/e2e\" class=\"font-medium text-primary-600 dark:text-primary-500 hover:text-primary-500\"><i class=\"fas fa-lock mr-1\"></i> Encrypt end to end</a><p class=\"text-xs text-gray-500 dark:text-gray-400\">The files are encrypted in the browser before the upload, on a separate page that doesn't load scripts from other sites. Only people with their share link can decrypt them, not even the server.</p></div><!-- Preview of selected files --><div x-show=\"files.length &gt; 0\" class=\"mt-4\"><h3 class=\"text-sm font-medium text-gray-700 dark:text-gray-300 mb-2\">Selected files:</h3><ul class=\"divide-y divide-gray-200 dark:divide-gray-700 border border-gray-200 dark:border-gray-700 rounded-md overflow-hidden\"><template x-for=\"(file, index) in Array.from(files)\" :key=\"index\"><li class=\"px-4 py-3 flex items-center justify-between bg-white dark:bg-slate-800 hover:bg-gray-50 dark:hover:bg-slate-700/50 transition-colors\"><div class=\"flex items-center max-w-xs sm:max-w-lg\"><i class=\"fas fa-file text-primary-500 mr-3\"></i> <span class=\"text-sm text-gray-900 dark:text-white truncate\" x-text=\"file.name\"></span></div><div class=\"flex items-center\"><span class=\"text-xs text-gray-500 dark:text-gray-400 mr-3\" x-text=\"formatBytes(file.size)\"></span> <button type=\"button\" @click=\"removeFile(index)\" class=\"text-red-500 hover:text-red-700 dark:hover:text-red-300 transition-colors\"><i class=\"fas fa-times\"></i></button></div></li></template></ul></div><div class=\"flex justify-end\"><a href=\"/\" class=\"rounded-md bg-white dark:bg-transparent px-3.5 py-2.5 text-sm font-semibold text-gray-900 dark:text-white shadow-sm ring-1 ring-inset ring-gray-300 dark:ring-gray-700 hover:bg-gray-50 dark:hover:bg-gray-800 mr-3 transition-colors\">Cancel</a> <button type=\"submit\" :disabled=\"uploading || files.length === 0\" :class=\"{&#39;opacity-50 cursor-not-allowed&#39;: uploading || files.length === 0}\" class=\"rounded-md bg-primary-600 px-3.5 py-2.5 text-sm font-semibold text-white shadow-sm hover:bg-primary-500 focus-visible:outline focus-visible:outline-2 focus-visible:outline-offset-2 focus-visible:outline-primary-600 transition-colors\"><span x-show=\"!uploading\"><i class=\"fas fa-upload mr-1\"></i> Upload</span> <span x-show=\"uploading\"><i class=\"fas fa-spinner fa-spin mr-1\"></i> Uploading...</span></button></div></form></div></div><script>\n\t\tfunction formatBytes(bytes, decimals = 2) {\n\t\t\tif (bytes === 0) return '0 Bytes';\n\n\t\t\tconst k = 1024;\n\t\t\tconst dm = decimals < 0 ? 0 : decimals;\n\t\t\tconst sizes = ['Bytes', 'KB', 'MB', 'GB', 'TB'];\n\n\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\n\t\t\treturn parseFloat((bytes / Math.pow(k, i)).toFixed(dm)) + ' ' + sizes[i];\n\t\t}\n\t</script>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
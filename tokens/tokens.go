@@ -0,0 +1,259 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes that can be granted to a token
+const (
+	Read   = "read"   // List, preview and download files
+	Upload = "upload" // Upload files and create directories
+	Delete = "delete" // Delete and move files
+)
+
+// Scopes lists every scope in display order
+var Scopes = []string{Read, Upload, Delete}
+
+// prefix identifies ShareIsCare tokens, e.g. in secret scanners
+const prefix = "sic_"
+
+// touchInterval limits how often the last use of a token is saved to disk
+const touchInterval = time.Minute
+
+var (
+	ErrNotFound     = errors.New("token not found")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Token is an API token. Only the SHA-256 hash of its secret is stored.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"` // User the token acts as
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // Zero if it never expires
+	LastUsed  time.Time `json:"last_used,omitzero"`  // Zero if it has never been used
+}
+
+// HasScope checks if the token has been granted a scope
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired checks if the token has expired at the given time
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+// Store keeps the tokens in a JSON file
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	tokens []*Token
+}
+
+// hashSecret returns the hash stored for a token secret
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Open loads the tokens saved in path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.tokens); err != nil {
+		return nil, fmt.Errorf("error reading tokens file: %v", err)
+	}
+	return s, nil
+}
+
+// save writes the tokens to disk. The caller must hold the lock.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("error creating tokens directory: %v", err)
+	}
+
+	// Write to a temporary file so the tokens are never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tokens-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Create creates a token and returns it with its secret, which is not
+// stored and can't be recovered later
+func (s *Store) Create(name, username string, scopes []string, expiresAt time.Time) (*Token, string, error) {
+	for _, scope := range scopes {
+		if scope != Read && scope != Upload && scope != Delete {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	random, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := prefix + random
+
+	token := &Token{
+		ID:        id,
+		Name:      name,
+		Username:  username,
+		Scopes:    scopes,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		s.tokens = s.tokens[:len(s.tokens)-1]
+		return nil, "", err
+	}
+
+	copied := *token
+	return &copied, secret, nil
+}
+
+// Authenticate returns the token of a secret if it's valid and hasn't
+// expired, and records its use
+func (s *Store) Authenticate(secret string) (*Token, bool) {
+	if !strings.HasPrefix(secret, prefix) {
+		return nil, false
+	}
+	hash := hashSecret(secret)
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Hash != hash {
+			continue
+		}
+		if token.Expired(now) {
+			return nil, false
+		}
+
+		if now.Sub(token.LastUsed) >= touchInterval {
+			token.LastUsed = now
+			// Failing to record the last use doesn't invalidate the token
+			_ = s.save()
+		}
+
+		copied := *token
+		return &copied, true
+	}
+
+	return nil, false
+}
+
+// List returns all tokens, newest first
+func (s *Store) List() []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, *token)
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// Revoke deletes a token so that it can't be used anymore
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, token := range s.tokens {
+		if token.ID != id {
+			continue
+		}
+
+		previous := s.tokens
+		s.tokens = append(append([]*Token{}, previous[:i]...), previous[i+1:]...)
+		if err := s.save(); err != nil {
+			// Keep the token so the store matches the file on disk
+			s.tokens = previous
+			return err
+		}
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// DefaultPath returns the file used to store tokens when none is configured
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "shareiscare", "tokens.json")
+}
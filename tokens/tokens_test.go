@@ -0,0 +1,111 @@
+package tokens
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() devolvió error: %v", err)
+	}
+
+	token, secret, err := store.Create("ci", "admin", []string{Read, Upload}, time.Time{})
+	if err != nil {
+		t.Fatalf("Create() devolvió error: %v", err)
+	}
+	if !strings.HasPrefix(secret, "sic_") {
+		t.Errorf("Formato de token incorrecto: %q", secret)
+	}
+
+	// El secreto no se guarda en claro
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), secret) {
+		t.Error("El archivo de tokens no debería contener el secreto")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Permisos incorrectos del archivo de tokens: %v", info.Mode().Perm())
+	}
+
+	// Los tokens se conservan al reabrir el almacén
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() devolvió error: %v", err)
+	}
+
+	got, ok := store.Authenticate(secret)
+	if !ok {
+		t.Fatal("Authenticate() debería aceptar el token")
+	}
+	if got.ID != token.ID || got.Username != "admin" || !got.HasScope(Upload) || got.HasScope(Delete) {
+		t.Errorf("Token incorrecto: %+v", got)
+	}
+	if got.LastUsed.IsZero() {
+		t.Error("Authenticate() debería registrar el último uso")
+	}
+
+	for _, invalid := range []string{"", "sic_", secret + "x", strings.TrimPrefix(secret, "sic_")} {
+		if _, ok := store.Authenticate(invalid); ok {
+			t.Errorf("Authenticate(%q) debería fallar", invalid)
+		}
+	}
+
+	// Scopes inválidos
+	if _, _, err := store.Create("malo", "admin", []string{"admin"}, time.Time{}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Create() con scope inválido debería fallar, obtenido %v", err)
+	}
+	if _, _, err := store.Create("vacío", "admin", nil, time.Time{}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Create() sin scopes debería fallar, obtenido %v", err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	store, _ := Open(filepath.Join(t.TempDir(), "tokens.json"))
+
+	_, expired, _ := store.Create("viejo", "admin", []string{Read}, time.Now().Add(-time.Hour))
+	_, valid, _ := store.Create("nuevo", "admin", []string{Read}, time.Now().Add(time.Hour))
+
+	if _, ok := store.Authenticate(expired); ok {
+		t.Error("Authenticate() no debería aceptar tokens expirados")
+	}
+	if _, ok := store.Authenticate(valid); !ok {
+		t.Error("Authenticate() debería aceptar tokens no expirados")
+	}
+}
+
+func TestListAndRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store, _ := Open(path)
+
+	first, secret, _ := store.Create("primero", "admin", []string{Read}, time.Time{})
+	time.Sleep(time.Millisecond)
+	second, _, _ := store.Create("segundo", "admin", []string{Delete}, time.Time{})
+
+	tokens := store.List()
+	if len(tokens) != 2 || tokens[0].ID != second.ID || tokens[1].ID != first.ID {
+		t.Errorf("List() debería devolver los tokens más nuevos primero: %+v", tokens)
+	}
+
+	if err := store.Revoke(first.ID); err != nil {
+		t.Fatalf("Revoke() devolvió error: %v", err)
+	}
+	if _, ok := store.Authenticate(secret); ok {
+		t.Error("Authenticate() no debería aceptar tokens revocados")
+	}
+	if err := store.Revoke(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke() de un token inexistente debería devolver ErrNotFound, obtenido %v", err)
+	}
+
+	// La revocación se guarda en disco
+	store, _ = Open(path)
+	if tokens := store.List(); len(tokens) != 1 || tokens[0].ID != second.ID {
+		t.Errorf("Tokens incorrectos tras reabrir: %+v", tokens)
+	}
+}
@@ -0,0 +1,256 @@
+package watcher
+
+import (
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay is how long a path must stay quiet before its event is sent,
+// so that a file being written produces a single event
+const settleDelay = 250 * time.Millisecond
+
+// Op is the kind of change reported by an event
+type Op string
+
+const (
+	Create Op = "create"
+	Modify Op = "modify"
+	Delete Op = "delete"
+)
+
+// Event is a change to an entry of a watched directory
+type Event struct {
+	Op    Op
+	Dir   string // Directory relative to the root, using forward slashes ("" for the root)
+	Name  string // Name of the entry within Dir
+	IsDir bool   // Whether the entry is a directory, or was one before being deleted
+}
+
+// Path returns the path of the entry relative to the root
+func (e Event) Path() string {
+	if e.Dir == "" {
+		return e.Name
+	}
+	return e.Dir + "/" + e.Name
+}
+
+// subscription receives the events of a single directory
+type subscription struct {
+	dir    string
+	events chan Event
+}
+
+// pending is a change waiting for its path to settle
+type pending struct {
+	timer   *time.Timer
+	created bool
+}
+
+// Watcher watches a directory tree and sends the changes of each directory
+// to its subscribers. inotify is used on Linux (through fsnotify), and
+// subdirectories are added as they are created.
+type Watcher struct {
+	root string
+	fs   *fsnotify.Watcher
+
+	mu      sync.Mutex
+	subs    map[*subscription]struct{}
+	pending map[string]*pending
+	dirs    map[string]bool // Watched directories, relative to the root
+	closed  bool
+}
+
+// New starts watching root and all of its subdirectories
+func New(root string) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:    root,
+		fs:      fsWatcher,
+		subs:    map[*subscription]struct{}{},
+		pending: map[string]*pending{},
+		dirs:    map[string]bool{},
+	}
+
+	if err := w.addTree(root); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// addTree adds a directory and its subdirectories to the watch list
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories that can't be read are not watched
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := w.fs.Add(p); err != nil {
+			log.Printf("Error watching %s: %v", p, err)
+		}
+		// Deleted directories can only be told apart by remembering them
+		if rel, err := filepath.Rel(w.root, p); err == nil && rel != "." {
+			w.mu.Lock()
+			w.dirs[filepath.ToSlash(rel)] = true
+			w.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+// run processes the events of the underlying watcher until it's closed
+func (w *Watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching files: %v", err)
+		}
+	}
+}
+
+// handle schedules an event for the path of a filesystem change
+func (w *Watcher) handle(event fsnotify.Event) {
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || rel == "." {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	// New directories must be watched too
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := w.addTree(event.Name); err != nil {
+				log.Printf("Error watching %s: %v", event.Name, err)
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	p, ok := w.pending[rel]
+	if !ok {
+		p = &pending{}
+		p.timer = time.AfterFunc(settleDelay, func() { w.flush(rel) })
+		w.pending[rel] = p
+	} else {
+		p.timer.Reset(settleDelay)
+	}
+	if event.Has(fsnotify.Create) {
+		p.created = true
+	}
+}
+
+// flush sends the settled change of a path to the subscribers of its directory
+func (w *Watcher) flush(rel string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, ok := w.pending[rel]
+	if !ok || w.closed {
+		return
+	}
+	delete(w.pending, rel)
+
+	// The final state of the path decides the kind of event
+	op := Modify
+	isDir := false
+	if info, err := os.Lstat(filepath.Join(w.root, filepath.FromSlash(rel))); err != nil {
+		op = Delete
+		isDir = w.dirs[rel]
+		for name := range w.dirs {
+			if name == rel || strings.HasPrefix(name, rel+"/") {
+				delete(w.dirs, name)
+			}
+		}
+	} else {
+		isDir = info.IsDir()
+		if p.created {
+			op = Create
+		}
+	}
+
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	event := Event{Op: op, Dir: dir, Name: path.Base(rel), IsDir: isDir}
+
+	for sub := range w.subs {
+		if sub.dir != dir {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// A slow subscriber misses events instead of blocking the others
+		}
+	}
+}
+
+// Subscribe returns the events of a directory relative to the root and a
+// function that must be called to stop receiving them
+func (w *Watcher) Subscribe(dir string) (<-chan Event, func()) {
+	sub := &subscription{
+		dir:    filepath.ToSlash(filepath.Clean("/" + dir))[1:],
+		events: make(chan Event, 64),
+	}
+
+	w.mu.Lock()
+	w.subs[sub] = struct{}{}
+	w.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			w.mu.Lock()
+			delete(w.subs, sub)
+			w.mu.Unlock()
+		})
+	}
+}
+
+// Close stops watching the directory tree
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	for rel, p := range w.pending {
+		p.timer.Stop()
+		delete(w.pending, rel)
+	}
+	w.mu.Unlock()
+
+	return w.fs.Close()
+}
@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent espera el siguiente evento de una suscripción
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("No se recibió ningún evento")
+		return Event{}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio: %v", err)
+	}
+
+	w, err := New(root)
+	if err != nil {
+		t.Fatalf("New() devolvió error: %v", err)
+	}
+	defer w.Close()
+
+	events, cancel := w.Subscribe("docs")
+	defer cancel()
+	rootEvents, cancelRoot := w.Subscribe("")
+	defer cancelRoot()
+
+	// Crear un archivo escrito en varias partes produce un único evento
+	path := filepath.Join(root, "docs", "nota.txt")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("No se pudo crear archivo: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		file.WriteString("hola\n")
+	}
+	file.Close()
+
+	event := nextEvent(t, events)
+	if event.Op != Create || event.Dir != "docs" || event.Name != "nota.txt" {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+	if event.Path() != "docs/nota.txt" {
+		t.Errorf("Path() incorrecto: %q", event.Path())
+	}
+
+	// Modificar
+	if err := os.WriteFile(path, []byte("adiós"), 0644); err != nil {
+		t.Fatalf("No se pudo modificar archivo: %v", err)
+	}
+	if event := nextEvent(t, events); event.Op != Modify || event.Name != "nota.txt" {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+
+	// Eliminar
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("No se pudo eliminar archivo: %v", err)
+	}
+	if event := nextEvent(t, events); event.Op != Delete || event.Name != "nota.txt" || event.IsDir {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+
+	// Los subdirectorios nuevos también se vigilan
+	if err := os.Mkdir(filepath.Join(root, "nuevo"), 0755); err != nil {
+		t.Fatalf("No se pudo crear directorio: %v", err)
+	}
+	if event := nextEvent(t, rootEvents); event.Op != Create || event.Name != "nuevo" || !event.IsDir {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+
+	newEvents, cancelNew := w.Subscribe("nuevo/")
+	defer cancelNew()
+	if err := os.WriteFile(filepath.Join(root, "nuevo", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("No se pudo crear archivo: %v", err)
+	}
+	if event := nextEvent(t, newEvents); event.Op != Create || event.Path() != "nuevo/a.txt" {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+
+	// Los eventos de otros directorios no llegan
+	select {
+	case event := <-events:
+		t.Errorf("Evento inesperado: %+v", event)
+	default:
+	}
+
+	// Un directorio eliminado se sigue reconociendo como directorio
+	if err := os.RemoveAll(filepath.Join(root, "nuevo")); err != nil {
+		t.Fatalf("No se pudo eliminar directorio: %v", err)
+	}
+	if event := nextEvent(t, rootEvents); event.Op != Delete || event.Name != "nuevo" || !event.IsDir {
+		t.Errorf("Evento incorrecto: %+v", event)
+	}
+}